@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/platform/wecom"
+	wecomproto "github.com/IMBotPlatform/bot-protocol-wecom/pkg/wecom"
+	"github.com/google/uuid"
+)
+
+// callbackClient 以企业微信服务器的身份向机器人回调地址投递加密消息。
+type callbackClient struct {
+	endpoint string
+	crypt    *wecomproto.Crypt
+	http     *http.Client
+}
+
+// newCallbackClient 创建回调客户端。
+// Parameters:
+//   - endpoint: 机器人回调地址，例如 http://127.0.0.1:8080/callback/command
+//   - token/aesKey/corpID: 与机器人一致的加解密配置
+//
+// Returns:
+//   - *callbackClient: 客户端实例
+//   - error: 加解密配置无效时返回
+func newCallbackClient(endpoint, token, aesKey, corpID string) (*callbackClient, error) {
+	crypt, err := wecom.NewCrypt(token, aesKey, corpID)
+	if err != nil {
+		return nil, fmt.Errorf("init crypt: %w", err)
+	}
+	return &callbackClient{
+		endpoint: endpoint,
+		crypt:    crypt,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// post 加密并投递一条明文回调，返回解密后的响应明文（机器人不回复时为空）。
+func (c *callbackClient) post(plain []byte) ([]byte, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := uuid.NewString()[:8]
+	// 关键步骤：EncryptResponse 的签名算法与回调一致，可直接复用生成回调报文。
+	envelope, err := c.crypt.EncryptResponse(json.RawMessage(plain), timestamp, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt callback: %w", err)
+	}
+	body, err := json.Marshal(wecomproto.EncryptedRequest{Encrypt: envelope.Encrypt})
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := url.Parse(c.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse url: %w", err)
+	}
+	query := target.Query()
+	query.Set("msg_signature", envelope.MsgSignature)
+	query.Set("timestamp", timestamp)
+	query.Set("nonce", nonce)
+	target.RawQuery = query.Encode()
+
+	resp, err := c.http.Post(target.String(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("post callback: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("callback status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	var encrypted wecomproto.EncryptedResponse
+	if err := json.Unmarshal(data, &encrypted); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return c.crypt.Decrypt(encrypted.Encrypt)
+}
+
+// follow 以刷新请求轮询流式回复，直到 finish 或超时，返回最终全文。
+func (c *callbackClient) follow(first []byte, interval, timeout time.Duration, onUpdate func(content string)) (string, error) {
+	var reply wecomproto.StreamReply
+	if err := json.Unmarshal(first, &reply); err != nil || reply.MsgType != "stream" {
+		// 非流式回复（如模板卡片）直接返回原文。
+		return string(first), nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for !reply.Stream.Finish {
+		if time.Now().After(deadline) {
+			return reply.Stream.Content, fmt.Errorf("stream %s not finished after %s", reply.Stream.ID, timeout)
+		}
+		time.Sleep(interval)
+
+		plain, err := json.Marshal(map[string]any{
+			"msgid":   uuid.NewString(),
+			"msgtype": "stream",
+			"stream":  map[string]string{"id": reply.Stream.ID},
+		})
+		if err != nil {
+			return "", err
+		}
+		data, err := c.post(plain)
+		if err != nil {
+			return reply.Stream.Content, err
+		}
+		streamID := reply.Stream.ID
+		if err := json.Unmarshal(data, &reply); err != nil {
+			return "", fmt.Errorf("decode stream reply: %w", err)
+		}
+		if reply.Stream.ID == "" {
+			reply.Stream.ID = streamID
+		}
+		if onUpdate != nil {
+			onUpdate(reply.Stream.Content)
+		}
+	}
+	return reply.Stream.Content, nil
+}
+
+// buildTextCallback 构造一条单聊文本回调明文。
+func buildTextCallback(userID, text string) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"msgid":    uuid.NewString(),
+		"chattype": "single",
+		"from":     map[string]string{"userid": userID},
+		"msgtype":  "text",
+		"text":     map[string]string{"content": text},
+	})
+}
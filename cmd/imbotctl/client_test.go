@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/IMBotPlatform/IMBotCore/pkg/platform/wecom"
+)
+
+func TestCallbackClientFollowsStream(t *testing.T) {
+	key := strings.TrimRight(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x22}, 32)), "=")
+	pipeline := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk, 2)
+		out <- botcore.StreamChunk{Content: "echo: " + ctx.Snapshot.Text}
+		out <- botcore.StreamChunk{IsFinal: true}
+		close(out)
+		return out
+	})
+	bot, err := wecom.NewBot("token", key, "corp", 0, 50*time.Millisecond, pipeline)
+	if err != nil {
+		t.Fatalf("create bot: %v", err)
+	}
+	srv := httptest.NewServer(bot)
+	defer srv.Close()
+
+	client, err := newCallbackClient(srv.URL, "token", key, "corp")
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	plain, err := buildTextCallback("u1", "ping")
+	if err != nil {
+		t.Fatalf("build callback: %v", err)
+	}
+	first, err := client.post(plain)
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	content, err := client.follow(first, 10*time.Millisecond, time.Second, nil)
+	if err != nil {
+		t.Fatalf("follow: %v", err)
+	}
+	if content != "echo: ping" {
+		t.Fatalf("unexpected content: %q", content)
+	}
+}
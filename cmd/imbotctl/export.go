@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore/handlers"
+	"github.com/spf13/cobra"
+	_ "modernc.org/sqlite"
+)
+
+// newExportCmd 将 SQLite 历史存储中的会话导出为 JSONL（格式见 handlers.ExportJSONL）。
+func newExportCmd() *cobra.Command {
+	var dbPath, table, output string
+	cmd := &cobra.Command{
+		Use:   "export [session...]",
+		Short: "Export conversation history as JSONL",
+		Long: "Export conversation history from a SQLite history store as JSONL, one message per line.\n" +
+			"All sessions are exported when none are given. The output can be loaded with handlers.ImportJSONL.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dbPath == "" {
+				return fmt.Errorf("missing --sqlite")
+			}
+			db, err := sql.Open("sqlite", dbPath)
+			if err != nil {
+				return fmt.Errorf("open history db: %w", err)
+			}
+			defer db.Close()
+			history, err := handlers.NewSQLiteHistory(db, table)
+			if err != nil {
+				return err
+			}
+
+			w := cmd.OutOrStdout()
+			if output != "" && output != "-" {
+				f, err := os.Create(output)
+				if err != nil {
+					return fmt.Errorf("create output: %w", err)
+				}
+				defer f.Close()
+				w = f
+			}
+			n, err := exportSessions(cmd.Context(), w, history, args)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "exported %d sessions\n", n)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&dbPath, "sqlite", os.Getenv("IMBOT_HISTORY_DB"), "sqlite history database path")
+	cmd.Flags().StringVar(&table, "table", "", "history table name (default chat_history)")
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "output file, - for stdout")
+	return cmd
+}
+
+// historyStore 为导出所需的历史存储能力。
+type historyStore interface {
+	handlers.History
+	handlers.HistoryAdmin
+}
+
+// exportSessions 导出指定会话；keys 为空时导出全部会话，返回导出的会话数。
+func exportSessions(ctx context.Context, w io.Writer, history historyStore, keys []string) (int, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if len(keys) == 0 {
+		sessions, err := history.ListSessions(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("list sessions: %w", err)
+		}
+		for _, s := range sessions {
+			keys = append(keys, s.Key)
+		}
+	}
+	if err := handlers.ExportJSONL(w, history, keys...); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore/handlers"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestExportSessionsDefaultsToAllSessions(t *testing.T) {
+	history := handlers.NewMemoryHistory(0)
+	history.Append("c1:u1", llms.TextParts(llms.ChatMessageTypeHuman, "hi"), llms.TextParts(llms.ChatMessageTypeAI, "hello"))
+	history.Append("c2:u2", llms.TextParts(llms.ChatMessageTypeHuman, "ping"))
+
+	var out bytes.Buffer
+	n, err := exportSessions(context.Background(), &out, history, nil)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if n != 2 || strings.Count(out.String(), "\n") != 3 {
+		t.Fatalf("expected 2 sessions and 3 lines, got %d: %q", n, out.String())
+	}
+
+	out.Reset()
+	if n, err = exportSessions(context.Background(), &out, history, []string{"c2:u2"}); err != nil || n != 1 {
+		t.Fatalf("export one session: %d %v", n, err)
+	}
+	imported, err := handlers.ImportJSONL(&out, handlers.NewMemoryHistory(0))
+	if err != nil || imported != 1 {
+		t.Fatalf("export output should round-trip through ImportJSONL: %d %v", imported, err)
+	}
+}
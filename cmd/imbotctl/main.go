@@ -0,0 +1,158 @@
+// Command imbotctl 是面向运维的命令行工具：校验配置、向运行中的机器人发送测试消息、回放录制的回调、
+// 导出会话历史，以及实时查看机器人经 Webhook 推送的事件。
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/platform/wecom"
+	"github.com/spf13/cobra"
+)
+
+// globalFlags 为所有子命令共享的机器人配置，默认读取与示例一致的环境变量。
+type globalFlags struct {
+	url    string
+	token  string
+	aesKey string
+	corpID string
+}
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// newRootCmd 构建 imbotctl 命令树。
+func newRootCmd() *cobra.Command {
+	flags := &globalFlags{}
+	root := &cobra.Command{
+		Use:           "imbotctl",
+		Short:         "IMBotCore operator toolkit",
+		SilenceUsage:  true,
+		SilenceErrors: false,
+	}
+	root.PersistentFlags().StringVar(&flags.url, "url", envOr("IMBOT_CALLBACK_URL", "http://127.0.0.1:8080/callback/command"), "bot callback url")
+	root.PersistentFlags().StringVar(&flags.token, "token", os.Getenv("WECOM_TOKEN"), "wecom callback token")
+	root.PersistentFlags().StringVar(&flags.aesKey, "aes-key", os.Getenv("WECOM_ENCODING_AES_KEY"), "wecom encoding aes key")
+	root.PersistentFlags().StringVar(&flags.corpID, "corp-id", os.Getenv("WECOM_CORP_ID"), "wecom corp id")
+
+	root.AddCommand(newValidateCmd(flags), newSendCmd(flags), newReplayCmd(flags), newExportCmd(), newTailCmd())
+	return root
+}
+
+// newValidateCmd 校验加解密配置是否完整有效。
+func newValidateCmd(flags *globalFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Validate wecom credentials",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := flags.validate(); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "config ok")
+			return nil
+		},
+	}
+}
+
+// newSendCmd 向运行中的机器人发送一条测试文本并跟随流式回复。
+func newSendCmd(flags *globalFlags) *cobra.Command {
+	var userID string
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "send <text>",
+		Short: "Send a test text message through the bot callback",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			plain, err := buildTextCallback(userID, strings.Join(args, " "))
+			if err != nil {
+				return err
+			}
+			return flags.deliver(cmd, plain, timeout)
+		},
+	}
+	cmd.Flags().StringVar(&userID, "user", "imbotctl", "sender userid")
+	cmd.Flags().DurationVar(&timeout, "timeout", 2*time.Minute, "max time to follow the stream")
+	return cmd
+}
+
+// newReplayCmd 回放录制的明文回调（JSON 文件，"-" 表示标准输入）。
+func newReplayCmd(flags *globalFlags) *cobra.Command {
+	var timeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "replay <file>",
+		Short: "Replay a recorded plaintext callback",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var plain []byte
+			var err error
+			if args[0] == "-" {
+				plain, err = io.ReadAll(cmd.InOrStdin())
+			} else {
+				plain, err = os.ReadFile(args[0])
+			}
+			if err != nil {
+				return fmt.Errorf("read callback: %w", err)
+			}
+			return flags.deliver(cmd, plain, timeout)
+		},
+	}
+	cmd.Flags().DurationVar(&timeout, "timeout", 2*time.Minute, "max time to follow the stream")
+	return cmd
+}
+
+// validate 检查必填项并尝试初始化加解密器。
+func (f *globalFlags) validate() error {
+	var missing []string
+	if f.token == "" {
+		missing = append(missing, "--token/WECOM_TOKEN")
+	}
+	if f.aesKey == "" {
+		missing = append(missing, "--aes-key/WECOM_ENCODING_AES_KEY")
+	}
+	if f.corpID == "" {
+		missing = append(missing, "--corp-id/WECOM_CORP_ID")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing config: %s", strings.Join(missing, ", "))
+	}
+	if _, err := wecom.NewCrypt(f.token, f.aesKey, f.corpID); err != nil {
+		return fmt.Errorf("invalid crypt config: %w", err)
+	}
+	return nil
+}
+
+// deliver 投递回调并打印最终回复。
+func (f *globalFlags) deliver(cmd *cobra.Command, plain []byte, timeout time.Duration) error {
+	if err := f.validate(); err != nil {
+		return err
+	}
+	client, err := newCallbackClient(f.url, f.token, f.aesKey, f.corpID)
+	if err != nil {
+		return err
+	}
+	first, err := client.post(plain)
+	if err != nil {
+		return err
+	}
+	if first == nil {
+		fmt.Fprintln(cmd.OutOrStdout(), "(no response)")
+		return nil
+	}
+	content, err := client.follow(first, 500*time.Millisecond, timeout, nil)
+	fmt.Fprintln(cmd.OutOrStdout(), content)
+	return err
+}
+
+// envOr 读取环境变量，缺失时返回默认值。
+func envOr(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
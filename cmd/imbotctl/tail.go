@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/notify"
+	"github.com/spf13/cobra"
+)
+
+// maxEventBytes 为单个事件请求体的大小上限。
+const maxEventBytes = 1 << 20
+
+// newTailCmd 启动 Webhook 接收端，实时打印机器人经 notify.Notifier 推送的事件。
+func newTailCmd() *cobra.Command {
+	var listen, secret string
+	var maxSkew time.Duration
+	var raw bool
+	cmd := &cobra.Command{
+		Use:   "tail",
+		Short: "Print live bot events pushed by the notify webhook",
+		Long: "Listen for events sent by notify.Notifier and print one line per event.\n" +
+			"Point a notify.Target at http://<listen>/ with the same secret.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			srv := &http.Server{
+				Addr:              listen,
+				Handler:           newTailHandler(cmd.OutOrStdout(), secret, maxSkew, raw),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			go func() {
+				<-ctx.Done()
+				_ = srv.Close()
+			}()
+			fmt.Fprintf(cmd.ErrOrStderr(), "listening on %s\n", listen)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&listen, "listen", "127.0.0.1:9090", "address to receive webhook events on")
+	cmd.Flags().StringVar(&secret, "secret", os.Getenv("IMBOT_WEBHOOK_SECRET"), "webhook signing secret; empty accepts unsigned events")
+	cmd.Flags().DurationVar(&maxSkew, "max-skew", 5*time.Minute, "reject signed events older than this")
+	cmd.Flags().BoolVar(&raw, "json", false, "print events as raw JSON lines")
+	return cmd
+}
+
+// newTailHandler 返回校验签名并打印事件的 Webhook 处理器。
+// Parameters:
+//   - out: 事件输出
+//   - secret: 签名密钥；为空时不校验签名
+//   - maxSkew: 签名时间戳允许的最大偏差
+//   - raw: 为 true 时原样输出 JSON
+//
+// Returns:
+//   - http.Handler: Webhook 处理器
+func newTailHandler(out io.Writer, secret string, maxSkew time.Duration, raw bool) http.Handler {
+	var mu sync.Mutex
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxEventBytes))
+		if err != nil {
+			http.Error(w, "read body", http.StatusBadRequest)
+			return
+		}
+		if secret != "" {
+			if err := verifyEvent(r.Header, body, secret, maxSkew, time.Now()); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+		var event notify.Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			http.Error(w, "invalid event", http.StatusBadRequest)
+			return
+		}
+
+		// 关键步骤：并发推送的事件逐行输出，避免交错。
+		mu.Lock()
+		if raw {
+			fmt.Fprintln(out, strings.TrimSpace(string(body)))
+		} else {
+			fmt.Fprintln(out, formatEvent(event))
+		}
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// verifyEvent 校验签名与时间戳（见 notify.SignatureHeader）。
+func verifyEvent(header http.Header, body []byte, secret string, maxSkew time.Duration, now time.Time) error {
+	timestamp := header.Get(notify.TimestampHeader)
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("missing or invalid timestamp")
+	}
+	if skew := now.Sub(time.Unix(sec, 0)); maxSkew > 0 && (skew > maxSkew || skew < -maxSkew) {
+		return fmt.Errorf("stale timestamp")
+	}
+	if !hmac.Equal([]byte(header.Get(notify.SignatureHeader)), []byte(notify.Sign(secret, timestamp, body))) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// formatEvent 将事件渲染为单行文本。
+func formatEvent(event notify.Event) string {
+	fields := []string{event.At.Local().Format(time.DateTime), string(event.Type)}
+	if event.ChatID != "" {
+		fields = append(fields, "chat="+event.ChatID)
+	}
+	if event.SenderID != "" {
+		fields = append(fields, "sender="+event.SenderID)
+	}
+	if event.Feedback != nil {
+		fields = append(fields, fmt.Sprintf("feedback=%v", event.Feedback.Kind))
+	}
+	if event.Error != "" {
+		fields = append(fields, "error="+strconv.Quote(event.Error))
+	}
+	if event.Text != "" {
+		fields = append(fields, strconv.Quote(event.Text))
+	}
+	return strings.Join(fields, " ")
+}
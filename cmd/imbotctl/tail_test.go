@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/notify"
+)
+
+// syncBuffer 是并发安全的 bytes.Buffer。
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestTailPrintsSignedEvents(t *testing.T) {
+	out := &syncBuffer{}
+	srv := httptest.NewServer(newTailHandler(out, "s3cret", time.Minute, false))
+	defer srv.Close()
+
+	var rejected []error
+	good := notify.NewNotifier([]notify.Target{{URL: srv.URL, Secret: "s3cret"}})
+	bad := notify.NewNotifier([]notify.Target{{URL: srv.URL, Secret: "wrong"}},
+		notify.WithRetry(1, 0),
+		notify.WithErrorHandler(func(target notify.Target, event notify.Event, err error) {
+			rejected = append(rejected, err)
+		}))
+	good.Publish(notify.Event{Type: notify.EventMessage, At: time.Now(), ChatID: "c1", SenderID: "u1", Text: "hello"})
+	bad.Publish(notify.Event{Type: notify.EventMessage, At: time.Now(), Text: "forged"})
+	if err := good.Close(context.Background()); err != nil {
+		t.Fatalf("close notifier: %v", err)
+	}
+	_ = bad.Close(context.Background())
+
+	got := out.String()
+	if !strings.Contains(got, `message.received chat=c1 sender=u1 "hello"`) {
+		t.Fatalf("event not printed: %q", got)
+	}
+	if strings.Contains(got, "forged") || len(rejected) != 1 {
+		t.Fatalf("event with a bad signature must be rejected: %q %v", got, rejected)
+	}
+}
+
+func TestTailRejectsStaleTimestamp(t *testing.T) {
+	body := []byte(`{"type":"error"}`)
+	ts := "1000"
+	header := http.Header{}
+	header.Set(notify.TimestampHeader, ts)
+	header.Set(notify.SignatureHeader, notify.Sign("k", ts, body))
+	if err := verifyEvent(header, body, "k", time.Minute, time.Unix(1030, 0)); err != nil {
+		t.Fatalf("fresh event rejected: %v", err)
+	}
+	if err := verifyEvent(header, body, "k", time.Minute, time.Unix(2000, 0)); err == nil {
+		t.Fatalf("stale event accepted")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore/handlers"
 	"github.com/IMBotPlatform/IMBotCore/pkg/command"
 	"github.com/IMBotPlatform/IMBotCore/pkg/platform/wecom"
 	"github.com/spf13/cobra"
@@ -87,41 +88,6 @@ func newRootCmd(llm llms.Model) *cobra.Command {
 	return root
 }
 
-// newAIHandler 构建默认 AI 路由（非命令消息）。
-// 参数：llm 为 langchaingo 模型实例。
-// 返回：botcore.PipelineInvoker。
-func newAIHandler(llm llms.Model) botcore.PipelineInvoker {
-	return botcore.PipelineFunc(func(pipelineCtx botcore.PipelineContext) <-chan botcore.StreamChunk {
-		out := make(chan botcore.StreamChunk, 1)
-		go func() {
-			defer close(out)
-
-			prompt := strings.TrimSpace(pipelineCtx.Snapshot.Text)
-			if prompt == "" {
-				out <- botcore.StreamChunk{Content: "empty input", IsFinal: true}
-				return
-			}
-			if llm == nil {
-				out <- botcore.StreamChunk{Content: "llm not initialized", IsFinal: true}
-				return
-			}
-
-			// 调用 LLM 并将流式输出转换为 StreamChunk。
-			ch, err := streamPrompt(context.Background(), llm, prompt)
-			if err != nil {
-				out <- botcore.StreamChunk{Content: fmt.Sprintf("ai error: %v", err), IsFinal: true}
-				return
-			}
-
-			for part := range ch {
-				out <- botcore.StreamChunk{Content: part}
-			}
-			out <- botcore.StreamChunk{Content: "", IsFinal: true}
-		}()
-		return out
-	})
-}
-
 // loadEnvConfig 统一读取并校验示例所需环境变量。
 // 返回：envConfig；缺失必需变量时直接退出。
 func loadEnvConfig() envConfig {
@@ -168,10 +134,20 @@ func main() {
 	}
 
 	// 3) 构建路由链（默认 AI 路由）。
-	chain := botcore.NewChain(newAIHandler(llm))
-
-	// 4) 初始化企业微信 Bot（内部创建加解密上下文）。
-	bot, err := wecom.NewBot(cfg.wecomToken, cfg.wecomAESKey, cfg.wecomCorpID, time.Minute, 2*time.Second, chain)
+	// 每位用户每分钟最多 10 次模型调用，防止刷屏消耗额度。
+	llmHandler := botcore.Wrap(
+		handlers.NewLLMHandler(llm, handlers.WithHistory(handlers.NewMemoryHistory(20))),
+		botcore.RateLimit(10, time.Minute, botcore.SenderKey),
+	)
+	chain := botcore.NewChain(llmHandler)
+
+	// 4) 初始化企业微信 Bot（内部创建加解密上下文）；/stop 在路由前拦截，用于中止进行中的回答。
+	bot, err := wecom.NewBot(cfg.wecomToken, cfg.wecomAESKey, cfg.wecomCorpID, time.Minute, 2*time.Second,
+		botcore.Wrap(chain, botcore.Recover(func(_ botcore.RequestSnapshot, err *botcore.PanicError) {
+			log.Printf("%v\n%s", err, err.Stack)
+		}), botcore.StopCommand()),
+		wecom.WithAdapterOptions(wecom.WithSessionLifetime(6*time.Minute)),
+	)
 	if err != nil {
 		log.Fatalf("init wecom bot: %v", err)
 	}
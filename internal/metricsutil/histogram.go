@@ -0,0 +1,87 @@
+// Package metricsutil 提供命令统计（command.Metrics）与企业微信适配层指标（wecom.Metrics）共用的
+// Prometheus 文本格式直方图。
+package metricsutil
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Buckets 返回升序排列的分桶副本；buckets 为空时使用 fallback。
+func Buckets(buckets, fallback []float64) []float64 {
+	if len(buckets) == 0 {
+		buckets = fallback
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// Histogram 是线程安全的累积分桶直方图。
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64 // 与 buckets 对应的非累积计数
+	count   uint64
+	sum     float64
+}
+
+// NewHistogram 创建直方图。
+// Parameters:
+//   - buckets: 升序分桶上界（可由 Buckets 生成），创建后不应再修改
+//
+// Returns:
+//   - *Histogram: 直方图
+func NewHistogram(buckets []float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+// Observe 记录一个观测值。
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.count++
+	h.sum += v
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+			return
+		}
+	}
+}
+
+// WriteHeader 输出直方图指标的 HELP 与 TYPE 行，同名的多组样本只需输出一次。
+func WriteHeader(w io.Writer, name, help string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	return err
+}
+
+// WriteSamples 以 Prometheus 文本格式输出直方图样本（不含 HELP/TYPE 行）。
+// Parameters:
+//   - w: 输出
+//   - name: 指标名
+//   - labels: 附加标签（如 `command="deploy"`），为空表示无标签
+//
+// Returns:
+//   - error: 写入失败时返回
+func (h *Histogram) WriteSamples(w io.Writer, name, labels string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	prefix, series := "", ""
+	if labels != "" {
+		prefix, series = labels+",", "{"+labels+"}"
+	}
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i]
+		if _, err := fmt.Fprintf(w, "%s_bucket{%sle=\"%s\"} %d\n", name, prefix, strconv.FormatFloat(bound, 'g', -1, 64), cumulative); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n%s_sum%s %s\n%s_count%s %d\n",
+		name, prefix, h.count, name, series, strconv.FormatFloat(h.sum, 'g', -1, 64), name, series, h.count)
+	return err
+}
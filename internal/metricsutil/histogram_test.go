@@ -0,0 +1,28 @@
+package metricsutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHistogramWritesCumulativeSamples(t *testing.T) {
+	h := NewHistogram(Buckets(nil, []float64{1, 0.5}))
+	for _, v := range []float64{0.2, 0.7, 3} {
+		h.Observe(v)
+	}
+
+	var plain, labeled strings.Builder
+	if err := h.WriteSamples(&plain, "m", ""); err != nil {
+		t.Fatalf("WriteSamples: %v", err)
+	}
+	if err := h.WriteSamples(&labeled, "m", `command="x"`); err != nil {
+		t.Fatalf("WriteSamples: %v", err)
+	}
+	wantPlain := "m_bucket{le=\"0.5\"} 1\nm_bucket{le=\"1\"} 2\nm_bucket{le=\"+Inf\"} 3\nm_sum 3.9\nm_count 3\n"
+	if plain.String() != wantPlain {
+		t.Fatalf("unexpected samples:\n%s", plain.String())
+	}
+	if !strings.Contains(labeled.String(), "m_bucket{command=\"x\",le=\"1\"} 2\n") || !strings.Contains(labeled.String(), "m_count{command=\"x\"} 3\n") {
+		t.Fatalf("unexpected labeled samples:\n%s", labeled.String())
+	}
+}
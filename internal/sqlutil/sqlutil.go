@@ -0,0 +1,151 @@
+// Package sqlutil 提供各 SQL 存储（归档、审计、反馈、多轮流程、对话历史）共用的表名校验、
+// 数据库方言与版本化迁移。
+package sqlutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tableNamePattern 限制表名字符，避免拼接 SQL 时注入。
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// TableName 返回表名：为空时使用 fallback，非法时返回错误。
+// Parameters:
+//   - table: 调用方传入的表名
+//   - fallback: 默认表名
+//
+// Returns:
+//   - string: 最终表名
+//   - error: 表名包含字母、数字、下划线以外的字符时返回
+func TableName(table, fallback string) (string, error) {
+	if table == "" {
+		table = fallback
+	}
+	if !tableNamePattern.MatchString(table) {
+		return "", fmt.Errorf("invalid table name: %q", table)
+	}
+	return table, nil
+}
+
+// TimeLayout 为 SQLite 中时间列的存储格式（固定宽度的 UTC 时间，按字符串比较与排序即按时间先后）。
+const TimeLayout = "2006-01-02T15:04:05.000000Z"
+
+// Dialect 描述不同数据库的建表语句、占位符与迁移锁差异。
+type Dialect struct {
+	// IDType 为自增主键列的类型声明。
+	IDType string
+	// TimeType 为时间列的类型。
+	TimeType string
+	// TimeValue 将时间转换为写入数据库的值。
+	TimeValue func(t time.Time) any
+	// Placeholder 返回第 i 个（从 1 开始）参数占位符。
+	Placeholder func(i int) string
+	// lock 在迁移事务开始时串行化多个副本的迁移，参数为迁移表名。
+	lock func(ctx context.Context, tx *sql.Tx, versions string) error
+}
+
+var (
+	// SQLite 使用 ? 占位符与文本时间列（也适用于其他使用 ? 占位符的驱动）。
+	SQLite = Dialect{
+		IDType:      "INTEGER PRIMARY KEY AUTOINCREMENT",
+		TimeType:    "TEXT",
+		TimeValue:   func(t time.Time) any { return t.UTC().Format(TimeLayout) },
+		Placeholder: func(int) string { return "?" },
+		lock: func(ctx context.Context, tx *sql.Tx, versions string) error {
+			// 关键步骤：以一条不修改数据的写语句提前取得写锁，其它连接的迁移在此等待或返回 busy。
+			_, err := tx.ExecContext(ctx, `UPDATE `+versions+` SET version = version WHERE 1 = 0`)
+			return err
+		},
+	}
+	// Postgres 使用 $n 占位符与 TIMESTAMPTZ 时间列，迁移以事务级 advisory lock 串行化。
+	Postgres = Dialect{
+		IDType:      "BIGSERIAL PRIMARY KEY",
+		TimeType:    "TIMESTAMPTZ",
+		TimeValue:   func(t time.Time) any { return t.UTC() },
+		Placeholder: func(i int) string { return "$" + strconv.Itoa(i) },
+		lock: func(ctx context.Context, tx *sql.Tx, versions string) error {
+			_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, versions)
+			return err
+		},
+	}
+)
+
+// Placeholders 返回从第 from 个开始的 n 个占位符，以 ", " 连接。
+func (d Dialect) Placeholders(from, n int) string {
+	list := make([]string, n)
+	for i := range list {
+		list[i] = d.Placeholder(from + i)
+	}
+	return strings.Join(list, ", ")
+}
+
+// Migrate 在一个事务内依次执行尚未应用的迁移，已执行的版本记录在 <table>_migrations 表中。
+// 语句中的 {table}、{id}、{time} 分别替换为表名、IDType 与 TimeType；语句应可重复执行（IF NOT EXISTS），
+// 新增字段或索引时只追加新版本，不修改已发布的语句。多个副本同时启动时由方言的锁串行化，后到者看到已应用的版本后直接返回。
+// Parameters:
+//   - ctx: 执行上下文
+//   - db: 数据库连接
+//   - d: 方言
+//   - table: 已校验的表名
+//   - migrations: 按版本排列的迁移语句
+//
+// Returns:
+//   - error: 任一步骤失败时返回（事务回滚）
+func Migrate(ctx context.Context, db *sql.DB, d Dialect, table string, migrations []string) error {
+	versions := table + "_migrations"
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+versions+` (version INTEGER NOT NULL PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("create migrations table: %w", err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migrate %s: %w", table, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if d.lock != nil {
+		if err := d.lock(ctx, tx, versions); err != nil {
+			return fmt.Errorf("lock migrations of %s: %w", table, err)
+		}
+	}
+	var applied int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM `+versions).Scan(&applied); err != nil {
+		return fmt.Errorf("read migration version: %w", err)
+	}
+	replacer := strings.NewReplacer("{table}", table, "{id}", d.IDType, "{time}", d.TimeType)
+	for i := applied; i < len(migrations); i++ {
+		if _, err := tx.ExecContext(ctx, replacer.Replace(migrations[i])); err != nil {
+			return fmt.Errorf("migrate %s to version %d: %w", table, i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO `+versions+` (version) VALUES (`+d.Placeholder(1)+`)`, i+1); err != nil {
+			return fmt.Errorf("migrate %s to version %d: %w", table, i+1, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migrate %s: %w", table, err)
+	}
+	return nil
+}
+
+// ScanTime 将数据库返回的时间列（TIMESTAMPTZ 或 TimeLayout / RFC3339 文本）转换为 time.Time。
+func ScanTime(v any) (time.Time, error) {
+	var text string
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		text = t
+	case []byte:
+		text = string(t)
+	default:
+		return time.Time{}, fmt.Errorf("unsupported time value %T", v)
+	}
+	if t, err := time.Parse(TimeLayout, text); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339Nano, text)
+}
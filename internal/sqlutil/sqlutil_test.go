@@ -0,0 +1,72 @@
+package sqlutil
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestMigrateIsIdempotentAndSerialized(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+t.TempDir()+"/m.db?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	migrations := []string{
+		`CREATE TABLE IF NOT EXISTS {table} (id {id}, at {time} NOT NULL)`,
+		`CREATE INDEX IF NOT EXISTS {table}_at ON {table} (at)`,
+	}
+
+	// 模拟多个副本同时启动。
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- Migrate(context.Background(), db, SQLite, "items", migrations)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Migrate: %v", err)
+		}
+	}
+	var versions int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM items_migrations`).Scan(&versions); err != nil || versions != 2 {
+		t.Fatalf("expected 2 recorded versions, got %d, %v", versions, err)
+	}
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 6000, time.UTC)
+	if _, err := db.Exec(`INSERT INTO items (at) VALUES (?)`, SQLite.TimeValue(now)); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	var raw any
+	if err := db.QueryRow(`SELECT at FROM items`).Scan(&raw); err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if got, err := ScanTime(raw); err != nil || !got.Equal(now) {
+		t.Fatalf("ScanTime = %v, %v", got, err)
+	}
+	if got, err := ScanTime("2026-01-02T03:04:05Z"); err != nil || got.Second() != 5 {
+		t.Fatalf("ScanTime RFC3339 = %v, %v", got, err)
+	}
+}
+
+func TestTableName(t *testing.T) {
+	if name, err := TableName("", "fallback"); err != nil || name != "fallback" {
+		t.Fatalf("TableName default = %q, %v", name, err)
+	}
+	if _, err := TableName("bad;name", "x"); err == nil {
+		t.Fatal("expected invalid table name error")
+	}
+	if got := Postgres.Placeholders(3, 2); got != "$3, $4" {
+		t.Fatalf("Placeholders = %q", got)
+	}
+}
@@ -0,0 +1,95 @@
+package botcore
+
+import (
+	"strings"
+	"sync"
+)
+
+// Accumulator 以线程安全方式按流 ID 累积流式文本，并支持全文与增量两种读取方式。
+// 需要增量（Telegram 编辑、SSE）或全文（企业微信）的发送端可共享同一份累积状态。
+type Accumulator struct {
+	mu      sync.RWMutex
+	streams map[string]*accumulatedStream
+}
+
+type accumulatedStream struct {
+	content strings.Builder
+	final   bool
+}
+
+// NewAccumulator 创建空的累积器。
+func NewAccumulator() *Accumulator {
+	return &Accumulator{streams: make(map[string]*accumulatedStream)}
+}
+
+// Append 追加增量文本并返回追加后的全文长度（字节偏移）。
+// Parameters:
+//   - streamID: 流 ID
+//   - delta: 增量文本
+//   - final: 是否为最终片段
+//
+// Returns:
+//   - int: 当前全文字节长度，可作为下一次 Delta 的起点
+func (a *Accumulator) Append(streamID, delta string, final bool) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stream, ok := a.streams[streamID]
+	if !ok {
+		stream = &accumulatedStream{}
+		a.streams[streamID] = stream
+	}
+	stream.content.WriteString(delta)
+	if final {
+		stream.final = true
+	}
+	return stream.content.Len()
+}
+
+// Snapshot 返回指定流的累积全文。
+// Returns:
+//   - string: 当前全文
+//   - bool: 是否已收到最终片段
+//   - bool: 流是否存在
+func (a *Accumulator) Snapshot(streamID string) (string, bool, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	stream, ok := a.streams[streamID]
+	if !ok {
+		return "", false, false
+	}
+	return stream.content.String(), stream.final, true
+}
+
+// Delta 返回自 since 偏移之后新增的文本。
+// Parameters:
+//   - streamID: 流 ID
+//   - since: 上一次读取返回的偏移（首次为 0）
+//
+// Returns:
+//   - string: 新增文本（since 越界时返回空串）
+//   - int: 新的偏移
+//   - bool: 是否已收到最终片段
+//   - bool: 流是否存在
+func (a *Accumulator) Delta(streamID string, since int) (string, int, bool, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	stream, ok := a.streams[streamID]
+	if !ok {
+		return "", since, false, false
+	}
+	content := stream.content.String()
+	if since < 0 || since >= len(content) {
+		return "", len(content), stream.final, true
+	}
+	return content[since:], len(content), stream.final, true
+}
+
+// Remove 删除指定流的累积状态。
+func (a *Accumulator) Remove(streamID string) {
+	a.mu.Lock()
+	delete(a.streams, streamID)
+	a.mu.Unlock()
+}
@@ -0,0 +1,27 @@
+package botcore
+
+import "testing"
+
+func TestAccumulatorSnapshotAndDelta(t *testing.T) {
+	acc := NewAccumulator()
+	offset := acc.Append("s1", "你好", false)
+	acc.Append("s1", "，世界", true)
+
+	content, final, ok := acc.Snapshot("s1")
+	if !ok || !final || content != "你好，世界" {
+		t.Fatalf("unexpected snapshot: %q final=%v ok=%v", content, final, ok)
+	}
+
+	delta, next, _, _ := acc.Delta("s1", offset)
+	if delta != "，世界" || next != len(content) {
+		t.Fatalf("unexpected delta: %q next=%d", delta, next)
+	}
+	if delta, _, _, _ := acc.Delta("s1", next); delta != "" {
+		t.Fatalf("expected empty delta at end, got %q", delta)
+	}
+
+	acc.Remove("s1")
+	if _, _, ok := acc.Snapshot("s1"); ok {
+		t.Fatal("stream should be removed")
+	}
+}
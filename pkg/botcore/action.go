@@ -0,0 +1,23 @@
+package botcore
+
+import "strings"
+
+// Action 描述交互式应用按钮的回调（如企业微信智能应用附件 actions）。
+type Action struct {
+	CallbackID string // 回调 ID，对应发出按钮时设置的 callback_id
+	Name       string // 动作名称
+	Value      string // 动作值；以 "/" 开头时平台适配层会将其作为指令文本进入命令路由
+	Type       string // 动作类型（如 button）
+}
+
+// IsCommand 报告动作值是否为指令（以 "/" 开头）。
+func (a Action) IsCommand() bool {
+	return strings.HasPrefix(strings.TrimSpace(a.Value), "/")
+}
+
+// MatchAction 返回匹配交互回调的 Matcher；callbackID 为空时匹配任意交互回调。
+func MatchAction(callbackID string) Matcher {
+	return func(u RequestSnapshot) bool {
+		return u.Action != nil && (callbackID == "" || u.Action.CallbackID == callbackID)
+	}
+}
@@ -0,0 +1,140 @@
+// Package adaptertest 提供平台适配层的一致性测试套件。
+// 每个平台适配层都应在自己的测试中调用 Run，保证跨平台行为一致：
+// 提及剥离、事件标准化、回复编码长度限制等。
+package adaptertest
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+)
+
+// Adapter 是被测适配层需要实现的能力，为 botcore.Bot 的编解码子集。
+type Adapter interface {
+	// BuildFirstSnapshot 将平台原始消息标准化为快照。
+	BuildFirstSnapshot(raw any) (botcore.RequestSnapshot, error)
+	// BuildReply 将单个输出片段编码为平台回复。
+	BuildReply(first botcore.RequestSnapshot, chunk botcore.StreamChunk) (any, error)
+}
+
+// Message 描述一条入站消息夹具及其期望的标准化结果。
+// Want 中的空字段不做比较。
+type Message struct {
+	Name    string                  // 子测试名称
+	Raw     any                     // 平台原始消息
+	Want    botcore.RequestSnapshot // 期望快照（比较 SenderID/ChatID/ChatType/Text/Metadata）
+	WantErr bool                    // 是否期望标准化失败
+}
+
+// Fixtures 为一致性测试的输入。
+type Fixtures struct {
+	// Messages 为入站消息夹具，应至少覆盖单聊、群聊 @ 提及与事件消息。
+	Messages []Message
+	// MaxContentBytes 为单个回复中文本内容的平台上限（<=0 表示不检查）。
+	MaxContentBytes int
+	// ContentOf 从 BuildReply 的结果中取出文本内容，MaxContentBytes>0 时必填。
+	ContentOf func(reply any) string
+}
+
+// Run 执行一致性测试套件。
+// Parameters:
+//   - t: 测试上下文
+//   - adapter: 被测适配层
+//   - fixtures: 平台夹具
+func Run(t *testing.T, adapter Adapter, fixtures Fixtures) {
+	t.Helper()
+
+	t.Run("normalize", func(t *testing.T) {
+		for _, fixture := range fixtures.Messages {
+			fixture := fixture
+			t.Run(fixture.Name, func(t *testing.T) {
+				checkSnapshot(t, adapter, fixture)
+			})
+		}
+	})
+
+	t.Run("encode", func(t *testing.T) {
+		checkEncoding(t, adapter, fixtures)
+	})
+}
+
+// checkSnapshot 校验单条消息的标准化结果。
+func checkSnapshot(t *testing.T, adapter Adapter, fixture Message) {
+	got, err := adapter.BuildFirstSnapshot(fixture.Raw)
+	if fixture.WantErr {
+		if err == nil {
+			t.Fatalf("expected error, got snapshot %+v", got)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("BuildFirstSnapshot: %v", err)
+	}
+
+	want := fixture.Want
+	if want.SenderID != "" && got.SenderID != want.SenderID {
+		t.Errorf("SenderID = %q, want %q", got.SenderID, want.SenderID)
+	}
+	if want.ChatID != "" && got.ChatID != want.ChatID {
+		t.Errorf("ChatID = %q, want %q", got.ChatID, want.ChatID)
+	}
+	if want.ChatType != "" && got.ChatType != want.ChatType {
+		t.Errorf("ChatType = %q, want %q", got.ChatType, want.ChatType)
+	}
+	if want.Text != "" && got.Text != want.Text {
+		t.Errorf("Text = %q, want %q", got.Text, want.Text)
+	}
+	for key, value := range want.Metadata {
+		if got.Metadata[key] != value {
+			t.Errorf("Metadata[%q] = %q, want %q", key, got.Metadata[key], value)
+		}
+	}
+
+	// 通用约束：所有平台都必须满足。
+	if got.Text != strings.TrimSpace(got.Text) {
+		t.Errorf("Text should be trimmed: %q", got.Text)
+	}
+	if got.ChatType != "" && got.ChatType != botcore.ChatTypeSingle && got.ChatType != botcore.ChatTypeChatroom {
+		t.Errorf("ChatType %q is not a botcore.ChatType", got.ChatType)
+	}
+	if got.Metadata["platform"] == "" {
+		t.Errorf("Metadata[platform] must be set")
+	}
+}
+
+// checkEncoding 校验回复编码：常规片段、静默信号与超长文本。
+func checkEncoding(t *testing.T, adapter Adapter, fixtures Fixtures) {
+	first := botcore.RequestSnapshot{ID: "adaptertest-stream", ChatID: "adaptertest-chat", ChatType: botcore.ChatTypeSingle}
+
+	for _, chunk := range []botcore.StreamChunk{
+		{Content: "hello"},
+		{Content: "world", IsFinal: true},
+		{Payload: botcore.NoResponse, IsFinal: true},
+	} {
+		if _, err := adapter.BuildReply(first, chunk); err != nil {
+			t.Errorf("BuildReply(%+v): %v", chunk, err)
+		}
+	}
+
+	if fixtures.MaxContentBytes <= 0 {
+		return
+	}
+	if fixtures.ContentOf == nil {
+		t.Fatalf("Fixtures.ContentOf is required when MaxContentBytes > 0")
+	}
+	// 关键步骤：超长文本（含多字节字符）必须被截断到平台上限内，且不能截断在字符中间。
+	long := strings.Repeat("长文本", fixtures.MaxContentBytes)
+	reply, err := adapter.BuildReply(first, botcore.StreamChunk{Content: long, IsFinal: true})
+	if err != nil {
+		t.Fatalf("BuildReply(long): %v", err)
+	}
+	content := fixtures.ContentOf(reply)
+	if len(content) > fixtures.MaxContentBytes {
+		t.Errorf("content is %d bytes, exceeds limit %d", len(content), fixtures.MaxContentBytes)
+	}
+	if !strings.HasPrefix(long, content) || !utf8.ValidString(content) {
+		t.Errorf("content truncated inside a character")
+	}
+}
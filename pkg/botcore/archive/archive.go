@@ -0,0 +1,260 @@
+// Package archive 归档所有入站消息与最终回复（含时间戳与耗时），满足企业部署的合规留存要求。
+// 以 Middleware 包在 pipeline 外层，记录写入 MessageArchiver（JSON Lines 文件、SQLite、Postgres）。
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/internal/sqlutil"
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+)
+
+// Record 为一次消息处理的归档记录：入站消息与最终回复。
+type Record struct {
+	MessageID  string           `json:"message_id,omitempty"` // 消息键（botcore.MessageKey）
+	ChatID     string           `json:"chat_id"`
+	ChatType   botcore.ChatType `json:"chat_type,omitempty"`
+	SenderID   string           `json:"sender_id"`
+	Text       string           `json:"text"`               // 入站文本
+	Feedback   string           `json:"feedback,omitempty"` // 反馈事件的评价类型
+	Reply      string           `json:"reply,omitempty"`    // 最终回复全文（增量片段累积结果）
+	Error      string           `json:"error,omitempty"`    // 处理出错时的错误信息
+	ReceivedAt time.Time        `json:"received_at"`
+	RepliedAt  time.Time        `json:"replied_at"`
+	Latency    time.Duration    `json:"latency"` // 从接收到回复结束的耗时
+}
+
+// MessageArchiver 持久化归档记录。
+type MessageArchiver interface {
+	// Archive 写入一条归档记录。
+	Archive(ctx context.Context, record Record) error
+}
+
+// Option 自定义 Middleware 行为。
+type Option func(*config)
+
+type config struct {
+	onError func(err error)
+}
+
+// WithErrorHandler 设置写入失败时的回调（默认忽略）。
+func WithErrorHandler(fn func(err error)) Option {
+	return func(c *config) {
+		c.onError = fn
+	}
+}
+
+// Middleware 返回归档中间件：每条入站消息（含反馈事件）在下游输出结束后写入一条记录，
+// 包含入站内容、最终回复全文、接收与回复时间及耗时。写入在输出结束后进行，不影响回复延迟；
+// 下游无输出时 Reply 为空。应放在 pipeline 外层，以记录实际发出的回复。要求增量语义（ContentDelta）。
+// Parameters:
+//   - archiver: 归档存储
+//   - opts: 可选配置
+//
+// Returns:
+//   - botcore.Middleware: 归档中间件
+func Middleware(archiver MessageArchiver, opts ...Option) botcore.Middleware {
+	cfg := config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(next botcore.PipelineInvoker) botcore.PipelineInvoker {
+		return botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+			record := newRecord(ctx.Snapshot)
+			in := next.Trigger(ctx)
+			if archiver == nil {
+				return in
+			}
+			if in == nil {
+				archive(ctx.Context(), archiver, cfg, record)
+				return nil
+			}
+
+			out := make(chan botcore.StreamChunk)
+			go func() {
+				defer close(out)
+				var reply strings.Builder
+				for chunk := range in {
+					if chunk.Type == botcore.ChunkContent && chunk.Payload == nil {
+						reply.WriteString(chunk.Content)
+					}
+					if chunk.Err != nil {
+						record.Error = chunk.Err.Error()
+					}
+					out <- chunk
+				}
+				record.Reply = reply.String()
+				archive(ctx.Context(), archiver, cfg, record)
+			}()
+			return out
+		})
+	}
+}
+
+// newRecord 从快照构造归档记录。
+func newRecord(snapshot botcore.RequestSnapshot) Record {
+	record := Record{
+		MessageID:  botcore.MessageKey(snapshot),
+		ChatID:     snapshot.ChatID,
+		ChatType:   snapshot.ChatType,
+		SenderID:   snapshot.SenderID,
+		Text:       snapshot.Text,
+		ReceivedAt: time.Now(),
+	}
+	if snapshot.Feedback != nil {
+		record.Feedback = string(snapshot.Feedback.Kind)
+	}
+	return record
+}
+
+// archive 补齐回复时间后写入记录；请求 context 取消后仍然写入。
+func archive(ctx context.Context, archiver MessageArchiver, cfg config, record Record) {
+	record.RepliedAt = time.Now()
+	record.Latency = record.RepliedAt.Sub(record.ReceivedAt)
+	if err := archiver.Archive(context.WithoutCancel(ctx), record); err != nil && cfg.onError != nil {
+		cfg.onError(err)
+	}
+}
+
+// FileArchiver 以 JSON Lines 格式追加写入归档记录。
+type FileArchiver struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileArchiver 创建文件归档（文件不存在时自动创建）。
+func NewFileArchiver(path string) *FileArchiver {
+	return &FileArchiver{path: path}
+}
+
+// Archive 实现 MessageArchiver 接口。
+func (a *FileArchiver) Archive(ctx context.Context, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal archive record: %w", err)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open archive file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write archive record: %w", err)
+	}
+	return nil
+}
+
+// archiveMigrations 为归档表的版本化迁移（见 sqlutil.Migrate）。
+var archiveMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS {table} (
+		message_id TEXT,
+		chat_id TEXT,
+		chat_type TEXT,
+		sender_id TEXT,
+		text TEXT,
+		feedback TEXT,
+		reply TEXT,
+		error TEXT,
+		received_at {time} NOT NULL,
+		replied_at {time} NOT NULL,
+		latency_ms BIGINT NOT NULL
+	)`,
+}
+
+// archiveColumns 为归档表的列，顺序与 SQLArchiver.Archive 的参数一致。
+var archiveColumns = []string{
+	"message_id", "chat_id", "chat_type", "sender_id", "text", "feedback",
+	"reply", "error", "received_at", "replied_at", "latency_ms",
+}
+
+// SQLArchiver 将归档记录写入 database/sql 数据库（需调用方导入驱动）。
+type SQLArchiver struct {
+	db      *sql.DB
+	dialect sqlutil.Dialect
+	insert  string
+}
+
+// NewSQLiteArchiver 创建 SQLite 归档并自动建表（也适用于其他使用 ? 占位符的驱动，如 mysql）。
+// Parameters:
+//   - db: 已打开的数据库连接
+//   - table: 表名；为空时使用 "message_archive"
+//
+// Returns:
+//   - *SQLArchiver: 归档存储
+//   - error: 表名非法或建表失败时返回
+func NewSQLiteArchiver(db *sql.DB, table string) (*SQLArchiver, error) {
+	return newSQLArchiver(db, table, sqlutil.SQLite)
+}
+
+// NewPostgresArchiver 创建 Postgres 归档并自动建表（使用 $n 占位符，时间列为 TIMESTAMPTZ）。
+// Parameters:
+//   - db: 已打开的数据库连接（如 pgx/stdlib、lib/pq）
+//   - table: 表名；为空时使用 "message_archive"
+//
+// Returns:
+//   - *SQLArchiver: 归档存储
+//   - error: 表名非法或建表失败时返回
+func NewPostgresArchiver(db *sql.DB, table string) (*SQLArchiver, error) {
+	return newSQLArchiver(db, table, sqlutil.Postgres)
+}
+
+// newSQLArchiver 按方言建表并预生成插入语句。
+func newSQLArchiver(db *sql.DB, table string, d sqlutil.Dialect) (*SQLArchiver, error) {
+	table, err := sqlutil.TableName(table, "message_archive")
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlutil.Migrate(context.Background(), db, d, table, archiveMigrations); err != nil {
+		return nil, fmt.Errorf("create archive table: %w", err)
+	}
+	insert := `INSERT INTO ` + table + ` (` + strings.Join(archiveColumns, ", ") + `) VALUES (` + d.Placeholders(1, len(archiveColumns)) + `)`
+	return &SQLArchiver{db: db, dialect: d, insert: insert}, nil
+}
+
+// Archive 实现 MessageArchiver 接口。
+func (a *SQLArchiver) Archive(ctx context.Context, record Record) error {
+	_, err := a.db.ExecContext(ctx, a.insert,
+		record.MessageID, record.ChatID, string(record.ChatType), record.SenderID, record.Text, record.Feedback,
+		record.Reply, record.Error, a.dialect.TimeValue(record.ReceivedAt), a.dialect.TimeValue(record.RepliedAt), record.Latency.Milliseconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("insert archive record: %w", err)
+	}
+	return nil
+}
+
+// MemoryArchiver 是进程内 MessageArchiver 实现，适用于测试。
+type MemoryArchiver struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+// NewMemoryArchiver 创建进程内归档。
+func NewMemoryArchiver() *MemoryArchiver {
+	return &MemoryArchiver{}
+}
+
+// Archive 实现 MessageArchiver 接口。
+func (a *MemoryArchiver) Archive(ctx context.Context, record Record) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.records = append(a.records, record)
+	return nil
+}
+
+// Records 返回已归档记录的副本。
+func (a *MemoryArchiver) Records() []Record {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Record(nil), a.records...)
+}
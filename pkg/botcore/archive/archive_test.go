@@ -0,0 +1,110 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	_ "modernc.org/sqlite"
+)
+
+// drain 触发 pipeline 并读完输出，返回回复全文。
+func drain(p botcore.PipelineInvoker, snapshot botcore.RequestSnapshot) string {
+	var reply string
+	for chunk := range p.Trigger(botcore.PipelineContext{Snapshot: snapshot}) {
+		reply += chunk.Content
+	}
+	return reply
+}
+
+func TestMiddlewareRecordsInboundAndReply(t *testing.T) {
+	archiver := NewMemoryArchiver()
+	handler := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk, 2)
+		out <- botcore.StreamChunk{Content: "hello "}
+		out <- botcore.StreamChunk{Content: ctx.Snapshot.Text, IsFinal: true}
+		close(out)
+		return out
+	})
+	p := botcore.Wrap(handler, Middleware(archiver))
+
+	if got := drain(p, botcore.RequestSnapshot{ID: "m1", ChatID: "c1", SenderID: "u1", Text: "bob"}); got != "hello bob" {
+		t.Fatalf("unexpected reply: %q", got)
+	}
+	records := archiver.Records()
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	r := records[0]
+	if r.ChatID != "c1" || r.SenderID != "u1" || r.Text != "bob" || r.Reply != "hello bob" || r.MessageID == "" {
+		t.Fatalf("unexpected record: %+v", r)
+	}
+	if r.ReceivedAt.IsZero() || r.RepliedAt.Before(r.ReceivedAt) || r.Latency < 0 {
+		t.Fatalf("unexpected timestamps: %+v", r)
+	}
+}
+
+func TestMiddlewareRecordsSilentHandler(t *testing.T) {
+	archiver := NewMemoryArchiver()
+	p := botcore.Wrap(botcore.PipelineFunc(func(botcore.PipelineContext) <-chan botcore.StreamChunk { return nil }), Middleware(archiver))
+	if out := p.Trigger(botcore.PipelineContext{Snapshot: botcore.RequestSnapshot{ChatID: "c1", Text: "x"}}); out != nil {
+		t.Fatalf("expected nil output")
+	}
+	if records := archiver.Records(); len(records) != 1 || records[0].Reply != "" {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+}
+
+func sampleRecord() Record {
+	return Record{MessageID: "m1", ChatID: "c1", SenderID: "u1", Text: "q", Reply: "a"}
+}
+
+func TestFileArchiverAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	archiver := NewFileArchiver(path)
+	for i := 0; i < 2; i++ {
+		if err := archiver.Archive(context.Background(), sampleRecord()); err != nil {
+			t.Fatalf("archive: %v", err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var got Record
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[0]), &got) != nil || got.Reply != "a" {
+		t.Fatalf("unexpected file content: %q", data)
+	}
+}
+
+func TestSQLiteArchiverInsertsRows(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "archive.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	archiver, err := NewSQLiteArchiver(db, "")
+	if err != nil {
+		t.Fatalf("new archiver: %v", err)
+	}
+	if err := archiver.Archive(context.Background(), sampleRecord()); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	var text, reply string
+	if err := db.QueryRow(`SELECT text, reply FROM message_archive`).Scan(&text, &reply); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if text != "q" || reply != "a" {
+		t.Fatalf("unexpected row: %q %q", text, reply)
+	}
+	if _, err := NewPostgresArchiver(db, "bad name"); err == nil {
+		t.Fatalf("expected invalid table name error")
+	}
+}
@@ -0,0 +1,145 @@
+package botcore
+
+import (
+	"maps"
+	"time"
+)
+
+// OverflowPolicy 描述输出队列已满时的处理策略。
+type OverflowPolicy struct {
+	kind    overflowKind
+	timeout time.Duration
+}
+
+type overflowKind int
+
+const (
+	overflowCoalesce overflowKind = iota
+	overflowDropOldest
+	overflowBlock
+)
+
+var (
+	// OverflowCoalesce 将新的纯文本片段合并进队尾片段（类型与 Metadata 须一致），不丢失任何文本（适用于增量语义）。
+	OverflowCoalesce = OverflowPolicy{kind: overflowCoalesce}
+	// OverflowDropOldest 丢弃最早的非最终片段（仅适用于全文语义或可丢弃的状态更新）。
+	OverflowDropOldest = OverflowPolicy{kind: overflowDropOldest}
+)
+
+// OverflowBlock 返回阻塞等待策略：队列满时最多阻塞生产者 timeout，
+// 超时后退化为 OverflowCoalesce，保证生产者不会被慢消费者永久卡住。
+func OverflowBlock(timeout time.Duration) OverflowPolicy {
+	return OverflowPolicy{kind: overflowBlock, timeout: timeout}
+}
+
+// Buffer 返回有界输出队列中间件，将下游生产者与上层消费者解耦。
+// Parameters:
+//   - capacity: 队列容量（<=0 时为透传）
+//   - policy: 队列满时的处理策略
+//
+// Returns:
+//   - Middleware: 有界队列中间件
+func Buffer(capacity int, policy OverflowPolicy) Middleware {
+	return func(next PipelineInvoker) PipelineInvoker {
+		if capacity <= 0 {
+			return next
+		}
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			in := next.Trigger(ctx)
+			if in == nil {
+				return nil
+			}
+			out := make(chan StreamChunk)
+			go runBuffer(in, out, capacity, policy)
+			return out
+		})
+	}
+}
+
+// runBuffer 在单个 goroutine 内同时收发，队列满时按策略处理。
+func runBuffer(in <-chan StreamChunk, out chan<- StreamChunk, capacity int, policy OverflowPolicy) {
+	defer close(out)
+
+	var queue []StreamChunk
+	var blockTimer *time.Timer
+	var blockC <-chan time.Time
+	degraded := false // OverflowBlock 超时后退化为合并
+	defer func() {
+		if blockTimer != nil {
+			blockTimer.Stop()
+		}
+	}()
+
+	for in != nil || len(queue) > 0 {
+		full := len(queue) >= capacity
+		blocking := full && policy.kind == overflowBlock && !degraded
+
+		// 关键步骤：阻塞策略下队列满时暂停接收，并启动超时计时。
+		recv := in
+		if blocking {
+			recv = nil
+			if blockC == nil {
+				blockTimer = time.NewTimer(policy.timeout)
+				blockC = blockTimer.C
+			}
+		}
+
+		var send chan<- StreamChunk
+		var head StreamChunk
+		if len(queue) > 0 {
+			send = out
+			head = queue[0]
+		}
+
+		select {
+		case chunk, ok := <-recv:
+			if !ok {
+				in = nil
+				continue
+			}
+			queue = enqueueChunk(queue, chunk, capacity, policy.kind, degraded)
+		case send <- head:
+			queue = queue[1:]
+			if blockC != nil && len(queue) < capacity {
+				blockTimer.Stop()
+				blockC = nil
+			}
+		case <-blockC:
+			blockC = nil
+			degraded = true
+		}
+	}
+}
+
+// enqueueChunk 将片段加入队列；队列满时按策略合并或丢弃。
+func enqueueChunk(queue []StreamChunk, chunk StreamChunk, capacity int, kind overflowKind, degraded bool) []StreamChunk {
+	if len(queue) < capacity {
+		return append(queue, chunk)
+	}
+
+	switch {
+	case kind == overflowDropOldest:
+		for i, queued := range queue {
+			if !queued.IsFinal {
+				queue = append(queue[:i], queue[i+1:]...)
+				break
+			}
+		}
+	case kind == overflowCoalesce || degraded:
+		last := &queue[len(queue)-1]
+		if isPlainText(*last) && !last.IsFinal && isPlainText(chunk) && last.Type == chunk.Type && maps.Equal(last.Metadata, chunk.Metadata) {
+			last.Content += chunk.Content
+			last.IsFinal = chunk.IsFinal
+			return queue
+		}
+	}
+	// 无法合并（如 Payload 片段）时仍然入队，宁可超出容量也不丢失结构化输出。
+	return append(queue, chunk)
+}
+
+// isPlainText 判断片段是否仅包含文本（不含负载、附件与错误）。
+// 携带 Err 的片段（如 ErrorChunk）不可合并，否则错误会在合并中丢失。
+// 元数据（如 ReplyInThread 写入的 MetaThreadID）相同的片段仍可合并，由调用方比较。
+func isPlainText(chunk StreamChunk) bool {
+	return chunk.Payload == nil && len(chunk.Attachments) == 0 && chunk.Err == nil
+}
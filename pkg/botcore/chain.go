@@ -1,48 +1,188 @@
 package botcore
 
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
 // Matcher 定义路由匹配逻辑。
 // 返回 true 表示该路由应该处理此首包快照。
 type Matcher func(update RequestSnapshot) bool
 
 // Route 定义单条路由规则。
 type Route struct {
-	Name    string
-	Matcher Matcher
-	Handler PipelineInvoker
+	Name     string
+	Matcher  Matcher
+	Handler  PipelineInvoker
+	Priority int // 优先级，数值大的先匹配；相同优先级按添加顺序
 }
 
 // Chain 实现了一个基于责任链/路由表的 PipelineInvoker。
-// 它按顺序检查路由，一旦匹配成功，就移交给对应的 PipelineInvoker，并停止后续匹配。
+// 它按优先级与添加顺序检查路由，一旦匹配成功，就移交给对应的 PipelineInvoker，并停止后续匹配。
 // 如果所有路由都不匹配，且设置了 defaultHandler，则调用 defaultHandler。
+// 路由表可在运行时并发增删替换（如管理员命令开关功能），正在执行的请求不受影响。
 type Chain struct {
-	routes         []Route
+	mu             sync.RWMutex
+	routes         []Route // 已按优先级排序；修改时整体替换，读取方持有的切片不会被改写
 	defaultHandler PipelineInvoker
+	tracer         Tracer
+}
+
+// ChainOption 自定义 Chain 行为。
+type ChainOption func(*Chain)
+
+// WithChainTracer 为路由分发创建 span（名称 "route"，属性 imbot.route 为命中的路由名，
+// 默认处理器为 "default"），span 覆盖被选中处理器的整个输出过程。
+func WithChainTracer(tracer Tracer) ChainOption {
+	return func(c *Chain) {
+		c.tracer = tracer
+	}
 }
 
 // NewChain 创建一个新的责任链路由器。
 // Parameters:
 //   - defaultHandler: 默认处理器；为 nil 表示无默认处理
+//   - opts: 可选配置（链路追踪）
 //
 // Returns:
 //   - *Chain: 初始化后的责任链路由器
-func NewChain(defaultHandler PipelineInvoker) *Chain {
-	return &Chain{
+func NewChain(defaultHandler PipelineInvoker, opts ...ChainOption) *Chain {
+	c := &Chain{
 		routes:         make([]Route, 0),
 		defaultHandler: defaultHandler,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// RouteOption 自定义单条路由的行为。
+type RouteOption func(*routeConfig)
+
+type routeConfig struct {
+	middlewares []Middleware
+	priority    *int
+}
+
+// WithRoutePriority 设置路由优先级（默认 0），数值大的先匹配。
+func WithRoutePriority(priority int) RouteOption {
+	return func(c *routeConfig) {
+		c.priority = &priority
+	}
+}
+
+// WithRouteMiddleware 为路由附加专属中间件，仅在该路由命中时生效。
+func WithRouteMiddleware(mws ...Middleware) RouteOption {
+	return func(c *routeConfig) {
+		c.middlewares = append(c.middlewares, mws...)
+	}
+}
+
+// WithRouteTransform 为路由附加输出转换，使输出格式与处理器类型匹配
+// （如命令路由输出等宽代码块，AI 路由输出 Markdown）。
+func WithRouteTransform(transform ChunkTransform) RouteOption {
+	return WithRouteMiddleware(MapChunks(transform))
 }
 
 // AddRoute 添加一条路由规则。
 // Parameters:
-//   - name: 路由名称（便于调试与日志）
+//   - name: 路由名称（便于调试与日志，RemoveRoute/ReplaceRoute 按名称定位）
 //   - matcher: 匹配规则
 //   - handler: 命中后执行的 PipelineInvoker
-func (c *Chain) AddRoute(name string, matcher Matcher, handler PipelineInvoker) {
-	c.routes = append(c.routes, Route{
+//   - opts: 路由级可选配置（中间件、输出转换、优先级）
+func (c *Chain) AddRoute(name string, matcher Matcher, handler PipelineInvoker, opts ...RouteOption) {
+	route, _ := buildRoute(name, matcher, handler, opts)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	routes := append(append(make([]Route, 0, len(c.routes)+1), c.routes...), route)
+	c.routes = sortRoutes(routes)
+}
+
+// RemoveRoute 移除指定名称的全部路由。
+// Parameters:
+//   - name: 路由名称
+//
+// Returns:
+//   - bool: 是否移除了路由
+func (c *Chain) RemoveRoute(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	routes := make([]Route, 0, len(c.routes))
+	for _, route := range c.routes {
+		if route.Name != name {
+			routes = append(routes, route)
+		}
+	}
+	if len(routes) == len(c.routes) {
+		return false
+	}
+	c.routes = routes
+	return true
+}
+
+// ReplaceRoute 替换指定名称的第一条路由，保留其在同优先级中的顺序；
+// 未通过 WithRoutePriority 指定优先级时沿用原优先级。
+// Parameters:
+//   - name: 路由名称
+//   - matcher: 新的匹配规则
+//   - handler: 新的处理器
+//   - opts: 路由级可选配置
+//
+// Returns:
+//   - bool: 是否找到并替换了路由（未找到时不会新增）
+func (c *Chain) ReplaceRoute(name string, matcher Matcher, handler PipelineInvoker, opts ...RouteOption) bool {
+	route, hasPriority := buildRoute(name, matcher, handler, opts)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, old := range c.routes {
+		if old.Name != name {
+			continue
+		}
+		if !hasPriority {
+			route.Priority = old.Priority
+		}
+		routes := append(make([]Route, 0, len(c.routes)), c.routes...)
+		routes[i] = route
+		c.routes = sortRoutes(routes)
+		return true
+	}
+	return false
+}
+
+// Routes 返回当前路由表（按匹配顺序）的副本。
+func (c *Chain) Routes() []Route {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]Route(nil), c.routes...)
+}
+
+// buildRoute 应用路由选项构造 Route，并返回是否显式指定了优先级。
+func buildRoute(name string, matcher Matcher, handler PipelineInvoker, opts []RouteOption) (Route, bool) {
+	cfg := routeConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	route := Route{
 		Name:    name,
 		Matcher: matcher,
-		Handler: handler,
+		Handler: Wrap(handler, cfg.middlewares...),
+	}
+	if cfg.priority != nil {
+		route.Priority = *cfg.priority
+	}
+	return route, cfg.priority != nil
+}
+
+// sortRoutes 按优先级降序稳定排序（同优先级保持添加顺序）。
+func sortRoutes(routes []Route) []Route {
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Priority > routes[j].Priority
 	})
+	return routes
 }
 
 // Trigger 实现 PipelineInvoker 接口。
@@ -53,35 +193,67 @@ func (c *Chain) AddRoute(name string, matcher Matcher, handler PipelineInvoker)
 //   - <-chan StreamChunk: 流式输出片段通道（无匹配时可能返回 nil）
 func (c *Chain) Trigger(ctx PipelineContext) <-chan StreamChunk {
 	update := ctx.Snapshot
+	c.mu.RLock()
+	routes := c.routes
+	c.mu.RUnlock()
+
 	// 1. 遍历路由表
-	for _, route := range c.routes {
+	for _, route := range routes {
 		if route.Matcher(update) {
 			// 匹配成功，移交控制权
-			return route.Handler.Trigger(ctx)
+			return c.dispatch(route.Name, route.Handler, ctx)
 		}
 	}
 
 	// 2. 没有任何匹配，使用默认处理器
 	if c.defaultHandler != nil {
-		return c.defaultHandler.Trigger(ctx)
+		return c.dispatch("default", c.defaultHandler, ctx)
 	}
 
 	// 3. 既无匹配也无默认处理器，返回空流 (静默)
 	return nil
 }
 
+// dispatch 执行选中的处理器；配置了 Tracer 时包裹路由 span。
+func (c *Chain) dispatch(name string, handler PipelineInvoker, ctx PipelineContext) <-chan StreamChunk {
+	if c.tracer == nil {
+		return handler.Trigger(ctx)
+	}
+	attrs := SnapshotAttributes(ctx.Snapshot)
+	attrs["imbot.route"] = name
+	return triggerTraced(c.tracer, "route", attrs, handler, ctx)
+}
+
 // ContextMatcher 辅助函数：创建一个基于上下文的 Matcher (预留接口，目前快照中主要是 Text)
 // 这里提供一些常用的 Matcher 构造器
 
-// MatchPrefix 返回一个匹配文本前缀的 Matcher。
+// MatchPrefix 返回一个匹配文本前缀的 Matcher，文本以任一前缀开头即匹配。
 // Parameters:
-//   - prefix: 需要匹配的文本前缀
+//   - prefixes: 需要匹配的文本前缀（如 "/"、"!"、"#"）
 //
 // Returns:
 //   - Matcher: 当前前缀匹配器
-func MatchPrefix(prefix string) Matcher {
+func MatchPrefix(prefixes ...string) Matcher {
+	return func(u RequestSnapshot) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(u.Text, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchEvent 返回匹配平台事件类型（Metadata["event_type"]，如企业微信 enter_chat）的 Matcher。
+// 用于将欢迎语等事件流程注册为独立路由，而非依赖魔法文本。
+// Parameters:
+//   - eventType: 事件类型
+//
+// Returns:
+//   - Matcher: 事件类型匹配器
+func MatchEvent(eventType string) Matcher {
 	return func(u RequestSnapshot) bool {
-		return len(u.Text) >= len(prefix) && u.Text[0:len(prefix)] == prefix
+		return eventType != "" && u.Metadata["event_type"] == eventType
 	}
 }
 
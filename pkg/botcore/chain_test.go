@@ -0,0 +1,143 @@
+package botcore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChainRouteTransformAppliesOnlyToRoute(t *testing.T) {
+	chain := NewChain(staticPipeline(StreamChunk{Content: "default", IsFinal: true}))
+	chain.AddRoute("command", MatchPrefix("/"),
+		staticPipeline(StreamChunk{Content: "pong", IsFinal: true}),
+		WithRouteTransform(func(chunk StreamChunk) StreamChunk {
+			chunk.Content = strings.ToUpper(chunk.Content)
+			return chunk
+		}),
+	)
+
+	routed := collectChunks(chain.Trigger(PipelineContext{Snapshot: RequestSnapshot{Text: "/ping"}}))
+	if routed[0].Content != "PONG" {
+		t.Fatalf("route transform not applied: %+v", routed)
+	}
+	fallback := collectChunks(chain.Trigger(PipelineContext{Snapshot: RequestSnapshot{Text: "hi"}}))
+	if fallback[0].Content != "default" {
+		t.Fatalf("default handler should be untouched: %+v", fallback)
+	}
+}
+
+func TestMatchEvent(t *testing.T) {
+	match := MatchEvent("enter_chat")
+	if !match(RequestSnapshot{Metadata: map[string]string{"event_type": "enter_chat"}}) {
+		t.Fatalf("expected enter_chat to match")
+	}
+	if match(RequestSnapshot{Text: "enter_chat"}) || MatchEvent("")(RequestSnapshot{}) {
+		t.Fatalf("unexpected match")
+	}
+}
+
+func TestTextMatchers(t *testing.T) {
+	snap := func(text string) RequestSnapshot { return RequestSnapshot{Text: text} }
+	cases := []struct {
+		name    string
+		matcher Matcher
+		text    string
+		want    bool
+	}{
+		{"exact", MatchExact("help", "帮助"), "  帮助 ", true},
+		{"exact case sensitive", MatchExact("help"), "HELP", false},
+		{"keyword", MatchKeyword("deploy", ""), "please DEPLOY v2", true},
+		{"keyword miss", MatchKeyword("deploy"), "hello", false},
+		{"regexp", MatchRegexp(`^#\d+$`), "#123", true},
+		{"regexp miss", MatchRegexp(`^#\d+$`), "#12a", false},
+		{"text func", MatchText(func(text string) bool { return len(text) > 3 }), " ab ", false},
+	}
+	for _, tc := range cases {
+		if got := tc.matcher(snap(tc.text)); got != tc.want {
+			t.Fatalf("%s: got %v want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCompositeMatchers(t *testing.T) {
+	yes, no := MatchAny(), Not(MatchAny())
+	u := RequestSnapshot{}
+	if !And()(u) || Or()(u) || !Not(nil)(u) {
+		t.Fatalf("unexpected empty combinator results")
+	}
+	if And(yes, no)(u) || !And(yes, nil, yes)(u) || !Or(no, yes)(u) || Or(no, nil)(u) {
+		t.Fatalf("unexpected combinator results")
+	}
+
+	var evaluated bool
+	spy := func(RequestSnapshot) bool { evaluated = true; return true }
+	And(no, spy)(u)
+	Or(yes, spy)(u)
+	if evaluated {
+		t.Fatalf("combinators should short-circuit")
+	}
+}
+
+func TestSnapshotFieldMatchers(t *testing.T) {
+	u := RequestSnapshot{SenderID: "admin", ChatID: "g1", ChatType: ChatTypeChatroom, Metadata: map[string]string{"platform": "wecom"}}
+	route := And(MatchPrefix("/"), MatchChatType(ChatTypeChatroom), MatchSender("admin", "ops"))
+	u.Text = "/deploy"
+	if !route(u) {
+		t.Fatalf("expected admin group command to match")
+	}
+	if !MatchPrefix("!", "/")(u) || MatchPrefix("!", "#")(u) {
+		t.Fatalf("unexpected multi-prefix match")
+	}
+	if MatchSender("ops")(u) || MatchSender()(RequestSnapshot{}) {
+		t.Fatalf("unexpected sender match")
+	}
+	if !MatchChat("g1")(u) || MatchChat("g2")(u) {
+		t.Fatalf("unexpected chat match")
+	}
+	if MatchChatType(ChatTypeSingle)(u) {
+		t.Fatalf("group should not match single chat type")
+	}
+	if !MatchMetadata("platform", "wecom")(u) || MatchMetadata("lang", "")(u) {
+		t.Fatalf("unexpected metadata match")
+	}
+	if MatchMentioned()(u) {
+		t.Fatalf("message without bot mention should not match")
+	}
+	u.MentionedBot, u.Mentions = true, []string{"alice"}
+	if !MatchMentioned()(u) || !MatchMentioned("alice", "bob")(u) || MatchMentioned("bob")(u) {
+		t.Fatalf("unexpected mention match")
+	}
+}
+
+func TestChainRoutePriorityAndMutation(t *testing.T) {
+	reply := func(content string) PipelineInvoker {
+		return staticPipeline(StreamChunk{Content: content, IsFinal: true})
+	}
+	route := func(chain *Chain, text string) string {
+		chunks := collectChunks(chain.Trigger(PipelineContext{Snapshot: RequestSnapshot{Text: text}}))
+		return chunks[0].Content
+	}
+
+	chain := NewChain(reply("default"))
+	chain.AddRoute("command", MatchPrefix("/"), reply("command"))
+	chain.AddRoute("admin", MatchPrefix("/admin"), reply("admin"), WithRoutePriority(10))
+	if got := route(chain, "/admin on"); got != "admin" {
+		t.Fatalf("higher priority route should match first, got %q", got)
+	}
+
+	if !chain.ReplaceRoute("command", MatchPrefix("/"), reply("command v2")) {
+		t.Fatal("expected command route to be replaced")
+	}
+	if got := route(chain, "/help"); got != "command v2" {
+		t.Fatalf("replaced handler not used, got %q", got)
+	}
+
+	if !chain.RemoveRoute("admin") || chain.RemoveRoute("admin") {
+		t.Fatal("admin route should be removed exactly once")
+	}
+	if got := route(chain, "/admin on"); got != "command v2" {
+		t.Fatalf("removed route still matched, got %q", got)
+	}
+	if routes := chain.Routes(); len(routes) != 1 || routes[0].Name != "command" {
+		t.Fatalf("unexpected routes: %+v", routes)
+	}
+}
@@ -0,0 +1,123 @@
+package botcore
+
+import "strings"
+
+// ChunkType 描述 StreamChunk 的内容类别，使发送端可区别展示推理过程、工具调用与正文。
+type ChunkType string
+
+const (
+	// ChunkContent 为面向用户的正文（零值，兼容未设置 Type 的片段）。
+	ChunkContent ChunkType = ""
+	// ChunkReasoning 为模型的推理/思考过程，发送端可折叠、弱化展示或丢弃。
+	ChunkReasoning ChunkType = "reasoning"
+	// ChunkToolCall 为工具调用（Content 通常为调用参数），工具名称见 Metadata[MetaToolName]。
+	ChunkToolCall ChunkType = "tool-call"
+	// ChunkToolResult 为工具返回结果。
+	ChunkToolResult ChunkType = "tool-result"
+	// ChunkError 为失败片段，由 StreamChunk.Kind 根据 Err 推导，无需显式设置。
+	ChunkError ChunkType = "error"
+)
+
+// 常用的片段元数据键。
+const (
+	MetaToolName   = "tool_name"    // 工具名称
+	MetaToolCallID = "tool_call_id" // 工具调用 ID，关联调用与结果
+)
+
+// Kind 返回片段的有效类别：Err 非空时为 ChunkError，否则为 Type。
+func (c StreamChunk) Kind() ChunkType {
+	if c.Err != nil {
+		return ChunkError
+	}
+	return c.Type
+}
+
+// ReasoningChunk 构造推理过程的增量片段。
+func ReasoningChunk(text string) StreamChunk {
+	return StreamChunk{Type: ChunkReasoning, Content: text}
+}
+
+// ToolCallChunk 构造工具调用片段。
+// Parameters:
+//   - id: 调用 ID（可为空）
+//   - name: 工具名称
+//   - args: 调用参数（通常为 JSON）
+//
+// Returns:
+//   - StreamChunk: Type 为 ChunkToolCall 的片段
+func ToolCallChunk(id, name, args string) StreamChunk {
+	return StreamChunk{
+		Type:     ChunkToolCall,
+		Content:  args,
+		Metadata: map[string]string{MetaToolCallID: id, MetaToolName: name},
+	}
+}
+
+// ToolResultChunk 构造工具返回结果片段，id 与 name 应与对应的 ToolCallChunk 一致。
+func ToolResultChunk(id, name, result string) StreamChunk {
+	return StreamChunk{
+		Type:     ChunkToolResult,
+		Content:  result,
+		Metadata: map[string]string{MetaToolCallID: id, MetaToolName: name},
+	}
+}
+
+// DropChunkTypes 返回丢弃指定类别片段的中间件，例如不向用户展示推理过程。
+// 被丢弃的片段若为最终片段，会以空的正文最终片段代替，保证流正常结束。
+func DropChunkTypes(types ...ChunkType) Middleware {
+	drop := make(map[ChunkType]bool, len(types))
+	for _, t := range types {
+		drop[t] = true
+	}
+	return func(next PipelineInvoker) PipelineInvoker {
+		if len(drop) == 0 {
+			return next
+		}
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			in := next.Trigger(ctx)
+			if in == nil {
+				return nil
+			}
+			out := make(chan StreamChunk)
+			go func() {
+				defer close(out)
+				for chunk := range in {
+					if !drop[chunk.Kind()] {
+						out <- chunk
+					} else if chunk.IsFinal {
+						out <- StreamChunk{IsFinal: true}
+					}
+				}
+			}()
+			return out
+		})
+	}
+}
+
+// QuoteReasoning 返回将推理过程渲染为 Markdown 引用块（灰色折叠效果）的中间件，
+// 供不支持原生思考展示的平台使用；推理片段转换为正文，其余片段原样输出。仅适用于增量语义。
+func QuoteReasoning() Middleware {
+	return MapChunksWith(func(PipelineContext) ChunkTransform {
+		inReasoning := false
+		return func(chunk StreamChunk) StreamChunk {
+			switch {
+			case chunk.Type == ChunkReasoning:
+				text := strings.ReplaceAll(chunk.Content, "\n", "\n> ")
+				if !inReasoning && text != "" {
+					text = "> " + text
+					inReasoning = true
+				}
+				if chunk.IsFinal && inReasoning {
+					text += "\n\n"
+				}
+				chunk.Type = ChunkContent
+				chunk.Content = text
+			case chunk.Type == ChunkContent && inReasoning && (chunk.Content != "" || chunk.IsFinal):
+				// 关键步骤：空行结束引用块，后续正文才不会被并入引用。
+				chunk.Content = "\n\n" + chunk.Content
+				inReasoning = false
+			}
+			return chunk
+		}
+	})
+}
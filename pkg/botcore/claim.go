@@ -0,0 +1,131 @@
+package botcore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClaimStore 提供跨副本的一次性认领能力。
+// 多副本部署时应使用共享存储实现（如 RedisClaimStore），保证同一消息只由一个副本执行。
+type ClaimStore interface {
+	// Claim 尝试在 ttl 内独占 key。
+	// Returns:
+	//   - bool: true 表示当前调用方获得执行权
+	//   - error: 存储不可用时返回
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// KeyFunc 从首包快照中提取中间件使用的键；返回空字符串表示跳过该请求。
+type KeyFunc func(snapshot RequestSnapshot) string
+
+// MessageKey 返回平台消息 ID（Metadata["msgid"]），缺失时回退到 RequestSnapshot.ID。
+// 企业微信的 RequestSnapshot.ID 为副本本地生成的 streamID，跨副本去重必须使用 msgid。
+func MessageKey(snapshot RequestSnapshot) string {
+	if msgID := strings.TrimSpace(snapshot.Metadata["msgid"]); msgID != "" {
+		return msgID
+	}
+	return snapshot.ID
+}
+
+// Exclusive 返回认领中间件：只有成功认领消息键的副本才会执行下游 handler，
+// 其余副本直接返回 NoResponse。存储出错时放行，避免因存储故障导致消息丢失。
+// Parameters:
+//   - store: 认领存储
+//   - ttl: 认领有效期（应覆盖平台的重试窗口）
+//   - key: 键提取函数；为 nil 时使用 MessageKey
+//
+// Returns:
+//   - Middleware: 认领中间件
+func Exclusive(store ClaimStore, ttl time.Duration, key KeyFunc) Middleware {
+	if key == nil {
+		key = MessageKey
+	}
+	return func(next PipelineInvoker) PipelineInvoker {
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			k := key(ctx.Snapshot)
+			if store == nil || k == "" {
+				return next.Trigger(ctx)
+			}
+
+			claimed, err := store.Claim(ctx.Context(), "claim:"+k, ttl)
+			if err == nil && !claimed {
+				return singleChunk(StreamChunk{Payload: NoResponse, IsFinal: true})
+			}
+			return next.Trigger(ctx)
+		})
+	}
+}
+
+// singleChunk 返回只包含一个片段的已关闭通道。
+func singleChunk(chunk StreamChunk) <-chan StreamChunk {
+	out := make(chan StreamChunk, 1)
+	out <- chunk
+	close(out)
+	return out
+}
+
+// MemoryClaimStore 是进程内 ClaimStore 实现，适用于单副本或测试。
+type MemoryClaimStore struct {
+	mu     sync.Mutex
+	claims map[string]time.Time
+}
+
+// NewMemoryClaimStore 创建进程内认领存储。
+func NewMemoryClaimStore() *MemoryClaimStore {
+	return &MemoryClaimStore{claims: make(map[string]time.Time)}
+}
+
+// Claim 实现 ClaimStore 接口。
+func (s *MemoryClaimStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 关键步骤：顺带清理过期键，避免长期运行时无界增长。
+	for k, expireAt := range s.claims {
+		if now.After(expireAt) {
+			delete(s.claims, k)
+		}
+	}
+	if _, ok := s.claims[key]; ok {
+		return false, nil
+	}
+	s.claims[key] = now.Add(ttl)
+	return true, nil
+}
+
+// redisClaimScript 以 SET NX PX 认领键，返回 1 表示认领成功。
+const redisClaimScript = `return redis.call('SET', KEYS[1], '1', 'NX', 'PX', ARGV[1]) and 1 or 0`
+
+// RedisClaimStore 是基于 Redis 的 ClaimStore 实现（SET NX PX），认领状态在所有副本间共享。
+type RedisClaimStore struct {
+	eval   RedisEval
+	prefix string
+}
+
+// NewRedisClaimStore 创建 Redis 认领存储。
+// Parameters:
+//   - eval: Redis EVAL 执行函数
+//   - prefix: 键前缀（可为空），用于与其他业务隔离
+//
+// Returns:
+//   - *RedisClaimStore: 认领存储
+func NewRedisClaimStore(eval RedisEval, prefix string) *RedisClaimStore {
+	return &RedisClaimStore{eval: eval, prefix: prefix}
+}
+
+// Claim 实现 ClaimStore 接口。
+func (s *RedisClaimStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	res, err := s.eval(ctx, redisClaimScript, []string{s.prefix + key}, redisMillis(ttl))
+	if err != nil {
+		return false, fmt.Errorf("redis claim: %w", err)
+	}
+	claimed, ok := res.(int64)
+	if !ok {
+		return false, fmt.Errorf("redis claim: unexpected result %v", res)
+	}
+	return claimed == 1, nil
+}
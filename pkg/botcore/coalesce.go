@@ -0,0 +1,93 @@
+package botcore
+
+import (
+	"maps"
+	"strings"
+	"time"
+)
+
+// Coalesce 返回合并细碎增量片段的中间件：纯文本片段先缓冲，
+// 缓冲达到 maxBytes 或距首个缓冲片段超过 minInterval 时合并为一个片段输出。
+// 最终片段、附件片段会携带尚未输出的缓冲文本；Payload 片段前会先输出缓冲文本。
+// 不同 Type 或 Metadata 的片段（如推理过程与正文、不同线程的回复）分别合并，切换时先输出已缓冲的文本；
+// 合并后的片段保留缓冲片段的 Metadata（如 ReplyInThread 写入的 MetaThreadID）。
+// 仅适用于增量语义（ContentDelta）的输出。
+// Parameters:
+//   - minInterval: 最长缓冲时长（<=0 表示不按时间合并）
+//   - maxBytes: 最大缓冲字节数（<=0 表示不按大小合并）
+//
+// Returns:
+//   - Middleware: 片段合并中间件；两个参数均 <=0 时为透传
+func Coalesce(minInterval time.Duration, maxBytes int) Middleware {
+	return func(next PipelineInvoker) PipelineInvoker {
+		if minInterval <= 0 && maxBytes <= 0 {
+			return next
+		}
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			in := next.Trigger(ctx)
+			if in == nil {
+				return nil
+			}
+			out := make(chan StreamChunk)
+			go func() {
+				defer close(out)
+
+				var buf strings.Builder
+				var bufType ChunkType
+				var bufMeta map[string]string
+				var timer *time.Timer
+				var timerC <-chan time.Time
+				stopTimer := func() {
+					if timer != nil {
+						timer.Stop()
+					}
+					timerC = nil
+				}
+				defer stopTimer()
+				flush := func() {
+					stopTimer()
+					if buf.Len() > 0 {
+						out <- StreamChunk{Type: bufType, Content: buf.String(), Metadata: bufMeta}
+						buf.Reset()
+					}
+				}
+
+				for {
+					select {
+					case chunk, ok := <-in:
+						if !ok {
+							flush()
+							return
+						}
+						if chunk.Type != bufType || !maps.Equal(chunk.Metadata, bufMeta) {
+							flush()
+							bufType, bufMeta = chunk.Type, chunk.Metadata
+						}
+						switch {
+						case chunk.Payload != nil:
+							flush()
+							out <- chunk
+						case chunk.IsFinal || len(chunk.Attachments) > 0:
+							// 关键步骤：缓冲文本并入当前片段，避免多出一个单独的刷新条目。
+							stopTimer()
+							chunk.Content = buf.String() + chunk.Content
+							buf.Reset()
+							out <- chunk
+						default:
+							buf.WriteString(chunk.Content)
+							if maxBytes > 0 && buf.Len() >= maxBytes {
+								flush()
+							} else if timerC == nil && minInterval > 0 {
+								timer = time.NewTimer(minInterval)
+								timerC = timer.C
+							}
+						}
+					case <-timerC:
+						flush()
+					}
+				}
+			}()
+			return out
+		})
+	}
+}
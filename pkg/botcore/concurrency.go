@@ -0,0 +1,171 @@
+package botcore
+
+import (
+	"sync"
+	"time"
+)
+
+// ConcurrencyOption 自定义 Concurrency 行为。
+type ConcurrencyOption func(*concurrencyConfig)
+
+type concurrencyConfig struct {
+	wait  time.Duration
+	reply *string // 为 nil 时按快照语言回复 MsgConcurrencyBusy
+}
+
+// WithConcurrencyWait 设置最长排队时间：0 表示不排队、超出并发数立即拒绝；
+// 默认（<0）一直排队，直到轮到执行或请求 context 被取消。
+func WithConcurrencyWait(d time.Duration) ConcurrencyOption {
+	return func(c *concurrencyConfig) {
+		c.wait = d
+	}
+}
+
+// WithConcurrencyReply 自定义被拒绝时的回复文本（默认为本地化的 MsgConcurrencyBusy）；空字符串表示静默丢弃。
+func WithConcurrencyReply(reply string) ConcurrencyOption {
+	return func(c *concurrencyConfig) {
+		c.reply = &reply
+	}
+}
+
+// Concurrency 返回按键限制并发执行数的中间件：同一键（如同一用户）最多 limit 个下游执行同时进行，
+// 其余请求排队等待，排队超时（见 WithConcurrencyWait）时回复提示。执行从下游被调用持续到其输出通道关闭。
+// limit 为 1 时即串行化，避免同一用户的连续消息交错写入会话历史。仅在进程内生效。
+// Parameters:
+//   - limit: 每个键的最大并发数（<=0 表示不限制）
+//   - key: 键提取函数；为 nil 时使用 SenderKey，返回空字符串时不限制
+//   - opts: 可选配置（排队时间、拒绝文本）
+//
+// Returns:
+//   - Middleware: 并发限制中间件
+func Concurrency(limit int, key KeyFunc, opts ...ConcurrencyOption) Middleware {
+	if key == nil {
+		key = SenderKey
+	}
+	cfg := concurrencyConfig{wait: -1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	slots := &keyedSemaphore{slots: make(map[string]*semaphore)}
+
+	return func(next PipelineInvoker) PipelineInvoker {
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			k := key(ctx.Snapshot)
+			if limit <= 0 || k == "" {
+				return next.Trigger(ctx)
+			}
+			reply := Localize(ctx.Snapshot, MsgConcurrencyBusy)
+			if cfg.reply != nil {
+				reply = *cfg.reply
+			}
+
+			sem := slots.acquire(k, limit)
+			// 关键步骤：有空位时同步执行，保持下游返回 nil 等语义不变。
+			select {
+			case sem.ch <- struct{}{}:
+				return releaseOnClose(next.Trigger(ctx), func() { slots.release(k, sem) })
+			default:
+			}
+			if cfg.wait == 0 {
+				slots.drop(k, sem)
+				return concurrencyReject(reply)
+			}
+
+			out := make(chan StreamChunk)
+			go func() {
+				defer close(out)
+				var timeout <-chan time.Time
+				if cfg.wait > 0 {
+					timer := time.NewTimer(cfg.wait)
+					defer timer.Stop()
+					timeout = timer.C
+				}
+				select {
+				case sem.ch <- struct{}{}:
+				case <-timeout:
+					slots.drop(k, sem)
+					out <- <-concurrencyReject(reply)
+					return
+				case <-ctx.Context().Done():
+					slots.drop(k, sem)
+					return
+				}
+				defer slots.release(k, sem)
+				in := next.Trigger(ctx)
+				if in == nil {
+					return
+				}
+				for chunk := range in {
+					out <- chunk
+				}
+			}()
+			return out
+		})
+	}
+}
+
+// concurrencyReject 返回拒绝回复；reply 为空时静默。
+func concurrencyReject(reply string) <-chan StreamChunk {
+	if reply == "" {
+		return singleChunk(StreamChunk{Payload: NoResponse, IsFinal: true})
+	}
+	return singleChunk(StreamChunk{Content: reply, IsFinal: true})
+}
+
+// releaseOnClose 在 in 关闭后调用 release；in 为 nil 时立即释放。
+func releaseOnClose(in <-chan StreamChunk, release func()) <-chan StreamChunk {
+	if in == nil {
+		release()
+		return nil
+	}
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		defer release()
+		for chunk := range in {
+			out <- chunk
+		}
+	}()
+	return out
+}
+
+// semaphore 为单个键的计数信号量，refs 记录持有或等待的请求数，用于回收空闲键。
+type semaphore struct {
+	ch   chan struct{}
+	refs int
+}
+
+// keyedSemaphore 按键管理信号量。
+type keyedSemaphore struct {
+	mu    sync.Mutex
+	slots map[string]*semaphore
+}
+
+// acquire 返回 key 的信号量并登记一次引用（尚未占用空位）。
+func (k *keyedSemaphore) acquire(key string, limit int) *semaphore {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	sem, ok := k.slots[key]
+	if !ok {
+		sem = &semaphore{ch: make(chan struct{}, limit)}
+		k.slots[key] = sem
+	}
+	sem.refs++
+	return sem
+}
+
+// release 归还空位并撤销引用。
+func (k *keyedSemaphore) release(key string, sem *semaphore) {
+	<-sem.ch
+	k.drop(key, sem)
+}
+
+// drop 撤销引用；无人持有或等待时删除该键。
+func (k *keyedSemaphore) drop(key string, sem *semaphore) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	sem.refs--
+	if sem.refs == 0 {
+		delete(k.slots, key)
+	}
+}
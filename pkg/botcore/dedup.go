@@ -0,0 +1,216 @@
+package botcore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DedupReply 为首次处理某条消息时产出的完整回复，用于回放重复投递。
+type DedupReply struct {
+	Content string // 完整文本（增量片段累积结果）
+	Payload any    // 最终片段的非流式负载；跨进程存储实现可只保留 Content
+}
+
+// DedupStore 记录已处理的消息并缓存其回复，用于抵御平台重试或多次投递导致的重复执行。
+// 多副本部署时应使用共享存储实现（如 RedisDedupStore）。
+type DedupStore interface {
+	ClaimStore
+	// SaveReply 缓存 key 的完整回复，ttl 后过期。
+	SaveReply(ctx context.Context, key string, reply DedupReply, ttl time.Duration) error
+	// LoadReply 读取 key 的完整回复；首次处理尚未完成时返回 false。
+	LoadReply(ctx context.Context, key string) (DedupReply, bool, error)
+}
+
+// DedupOption 自定义 Dedup 行为。
+type DedupOption func(*dedupConfig)
+
+type dedupConfig struct {
+	key    KeyFunc
+	replay bool
+}
+
+// WithDedupKey 设置去重键提取函数（默认 MessageKey）；返回空字符串时不去重。
+func WithDedupKey(key KeyFunc) DedupOption {
+	return func(c *dedupConfig) {
+		if key != nil {
+			c.key = key
+		}
+	}
+}
+
+// WithDedupReplay 让重复消息回放首次处理的完整回复；首次处理仍在进行中时仍然静默。
+func WithDedupReplay() DedupOption {
+	return func(c *dedupConfig) {
+		c.replay = true
+	}
+}
+
+// Dedup 返回幂等中间件：ttl 内重复出现的消息键不会再次执行下游 handler，
+// 默认直接返回 NoResponse，启用 WithDedupReplay 时回放首次的回复。存储出错时放行。
+// 与 Exclusive 的区别在于可回放回复，适用于所有平台适配层；回放要求增量语义（ContentDelta）。
+// Parameters:
+//   - store: 去重存储
+//   - ttl: 去重窗口（应覆盖平台的重试窗口）
+//   - opts: 可选配置（键、回放）
+//
+// Returns:
+//   - Middleware: 幂等中间件
+func Dedup(store DedupStore, ttl time.Duration, opts ...DedupOption) Middleware {
+	cfg := dedupConfig{key: MessageKey}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return func(next PipelineInvoker) PipelineInvoker {
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			k := cfg.key(ctx.Snapshot)
+			if store == nil || k == "" {
+				return next.Trigger(ctx)
+			}
+			k = "dedup:" + k
+
+			claimed, err := store.Claim(ctx.Context(), k, ttl)
+			if err != nil || claimed {
+				in := next.Trigger(ctx)
+				if in == nil || !cfg.replay || err != nil {
+					return in
+				}
+				return recordReply(ctx.Context(), store, k, ttl, in)
+			}
+			if cfg.replay {
+				if reply, ok, err := store.LoadReply(ctx.Context(), k); err == nil && ok {
+					return singleChunk(StreamChunk{Content: reply.Content, Payload: reply.Payload, IsFinal: true})
+				}
+			}
+			return singleChunk(StreamChunk{Payload: NoResponse, IsFinal: true})
+		})
+	}
+}
+
+// recordReply 转发下游输出，并在最终片段时缓存累积的完整回复。
+func recordReply(ctx context.Context, store DedupStore, key string, ttl time.Duration, in <-chan StreamChunk) <-chan StreamChunk {
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		var content strings.Builder
+		for chunk := range in {
+			if chunk.Type == ChunkContent && chunk.Payload == nil {
+				content.WriteString(chunk.Content)
+			}
+			if chunk.IsFinal && chunk.Err == nil && chunk.Payload != NoResponse {
+				_ = store.SaveReply(context.WithoutCancel(ctx), key, DedupReply{Content: content.String(), Payload: chunk.Payload}, ttl)
+			}
+			out <- chunk
+		}
+	}()
+	return out
+}
+
+// MemoryDedupStore 是进程内 DedupStore 实现，适用于单副本或测试。
+type MemoryDedupStore struct {
+	claims  *MemoryClaimStore
+	mu      sync.Mutex
+	replies map[string]memoryDedupReply
+}
+
+type memoryDedupReply struct {
+	reply    DedupReply
+	expireAt time.Time
+}
+
+// NewMemoryDedupStore 创建进程内去重存储。
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{claims: NewMemoryClaimStore(), replies: make(map[string]memoryDedupReply)}
+}
+
+// Claim 实现 ClaimStore 接口。
+func (s *MemoryDedupStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.claims.Claim(ctx, key, ttl)
+}
+
+// SaveReply 实现 DedupStore 接口。
+func (s *MemoryDedupStore) SaveReply(ctx context.Context, key string, reply DedupReply, ttl time.Duration) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, entry := range s.replies {
+		if now.After(entry.expireAt) {
+			delete(s.replies, k)
+		}
+	}
+	s.replies[key] = memoryDedupReply{reply: reply, expireAt: now.Add(ttl)}
+	return nil
+}
+
+// LoadReply 实现 DedupStore 接口。
+func (s *MemoryDedupStore) LoadReply(ctx context.Context, key string) (DedupReply, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.replies[key]
+	if !ok || time.Now().After(entry.expireAt) {
+		return DedupReply{}, false, nil
+	}
+	return entry.reply, true, nil
+}
+
+const (
+	redisSetScript = `redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2]) return 1`
+	// redisGetScript 以 {是否存在, 值} 返回，避免客户端把 nil 回复转换为错误（如 go-redis 的 redis.Nil）。
+	redisGetScript = `local v = redis.call('GET', KEYS[1]) if v then return {1, v} end return {0, ''}`
+)
+
+// RedisDedupStore 是基于 Redis 的 DedupStore 实现，去重状态在所有副本间共享。
+// 只缓存回复文本，Payload 不会跨进程保存。
+type RedisDedupStore struct {
+	*RedisClaimStore
+	eval   RedisEval
+	prefix string
+}
+
+// NewRedisDedupStore 创建 Redis 去重存储。
+// Parameters:
+//   - eval: Redis EVAL 执行函数
+//   - prefix: 键前缀（可为空），用于与其他业务隔离
+//
+// Returns:
+//   - *RedisDedupStore: 去重存储
+func NewRedisDedupStore(eval RedisEval, prefix string) *RedisDedupStore {
+	return &RedisDedupStore{RedisClaimStore: NewRedisClaimStore(eval, prefix), eval: eval, prefix: prefix}
+}
+
+// SaveReply 实现 DedupStore 接口。
+func (s *RedisDedupStore) SaveReply(ctx context.Context, key string, reply DedupReply, ttl time.Duration) error {
+	if _, err := s.eval(ctx, redisSetScript, []string{s.prefix + key + ":reply"}, reply.Content, redisMillis(ttl)); err != nil {
+		return fmt.Errorf("redis dedup: %w", err)
+	}
+	return nil
+}
+
+// LoadReply 实现 DedupStore 接口。
+func (s *RedisDedupStore) LoadReply(ctx context.Context, key string) (DedupReply, bool, error) {
+	res, err := s.eval(ctx, redisGetScript, []string{s.prefix + key + ":reply"})
+	if err != nil {
+		return DedupReply{}, false, fmt.Errorf("redis dedup: %w", err)
+	}
+	values, ok := res.([]any)
+	if !ok || len(values) != 2 {
+		return DedupReply{}, false, fmt.Errorf("redis dedup: unexpected result %v", res)
+	}
+	found, _ := values[0].(int64)
+	content, _ := values[1].(string)
+	if found != 1 {
+		return DedupReply{}, false, nil
+	}
+	return DedupReply{Content: content}, true, nil
+}
+
+// redisMillis 将时长转换为 Redis PX 参数（至少 1 毫秒）。
+func redisMillis(d time.Duration) int64 {
+	if ms := d.Milliseconds(); ms > 0 {
+		return ms
+	}
+	return 1
+}
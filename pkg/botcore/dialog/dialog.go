@@ -0,0 +1,420 @@
+// Package dialog 提供多轮对话流程（状态机）：按会话成员保存当前状态与已收集的数据，
+// 每条消息交给当前状态的处理函数并决定下一状态，适用于“输入姓名 → 输入日期 → 确认”一类的向导。
+package dialog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+)
+
+// State 为流程状态名。
+type State string
+
+// End 表示流程结束：会话被删除，后续消息不再进入该流程。
+const End State = ""
+
+// Session 为流程的持久化状态。
+type Session struct {
+	State     State             `json:"state"`
+	Data      map[string]string `json:"data,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// Store 持久化流程会话。多副本部署时应使用共享存储实现（如 RedisStore、SQLStore）。
+type Store interface {
+	// Load 读取会话；不存在时返回 false。
+	Load(ctx context.Context, key string) (Session, bool, error)
+	// Save 保存会话。
+	Save(ctx context.Context, key string, session Session) error
+	// Delete 删除会话。
+	Delete(ctx context.Context, key string) error
+}
+
+// StateHandler 处理当前状态下收到的一条消息，返回下一状态。
+// 返回当前状态表示停留（如输入校验失败），返回 End 表示结束流程。
+type StateHandler func(turn *Turn) State
+
+// Turn 描述流程中的一轮交互。
+type Turn struct {
+	botcore.PipelineContext
+	session *Session
+	replies []string
+}
+
+// Input 返回去除首尾空白后的用户输入。
+func (t *Turn) Input() string {
+	return strings.TrimSpace(t.Snapshot.Text)
+}
+
+// State 返回当前状态。
+func (t *Turn) State() State {
+	return t.session.State
+}
+
+// Get 读取已收集的数据。
+func (t *Turn) Get(key string) string {
+	return t.session.Data[key]
+}
+
+// Set 保存数据，随会话持久化。
+func (t *Turn) Set(key, value string) {
+	if t.session.Data == nil {
+		t.session.Data = make(map[string]string)
+	}
+	t.session.Data[key] = value
+}
+
+// Reply 追加一段回复文本；多段回复以换行拼接为一条消息。
+func (t *Turn) Reply(text string) {
+	t.replies = append(t.replies, text)
+}
+
+// step 为单个状态的定义。
+type step struct {
+	prompt  string
+	handler StateHandler
+}
+
+// Option 自定义 Flow 行为。
+type Option func(*Flow)
+
+// WithStore 设置会话存储（默认进程内 MemoryStore）。
+func WithStore(store Store) Option {
+	return func(f *Flow) {
+		if store != nil {
+			f.store = store
+		}
+	}
+}
+
+// WithTTL 设置会话空闲超时，超时后视为流程未开始（默认 10 分钟，<=0 表示不过期）。
+func WithTTL(ttl time.Duration) Option {
+	return func(f *Flow) {
+		f.ttl = ttl
+	}
+}
+
+// WithKey 自定义会话范围（默认按 ChatID + SenderID）。
+func WithKey(key botcore.KeyFunc) Option {
+	return func(f *Flow) {
+		if key != nil {
+			f.key = key
+		}
+	}
+}
+
+// WithCancel 设置中途退出流程的指令与回复（默认 "/cancel"、"已取消。"；指令为空表示禁用）。
+func WithCancel(command, reply string) Option {
+	return func(f *Flow) {
+		f.cancelCommand = strings.TrimSpace(command)
+		f.cancelReply = reply
+	}
+}
+
+// Flow 是一个多轮对话流程，实现 botcore.PipelineInvoker。
+// 未处于流程中的用户触发时进入起始状态并发送其提示；处于流程中时将消息交给当前状态处理。
+// 通常以 Or(入口匹配, flow.Active()) 注册为高优先级路由，使流程中的后续消息不被其他路由截走。
+type Flow struct {
+	name          string
+	start         State
+	steps         map[State]step
+	store         Store
+	ttl           time.Duration
+	key           botcore.KeyFunc
+	cancelCommand string
+	cancelReply   string
+	now           func() time.Time
+}
+
+// New 创建对话流程。
+// Parameters:
+//   - name: 流程名称（用于隔离不同流程的会话）
+//   - start: 起始状态（需通过 Flow.State 定义）
+//   - opts: 可选配置（存储、超时、会话范围、取消指令）
+//
+// Returns:
+//   - *Flow: 对话流程
+func New(name string, start State, opts ...Option) *Flow {
+	f := &Flow{
+		name:          name,
+		start:         start,
+		steps:         make(map[State]step),
+		store:         NewMemoryStore(),
+		ttl:           10 * time.Minute,
+		key:           func(s botcore.RequestSnapshot) string { return s.ChatID + ":" + s.SenderID },
+		cancelCommand: "/cancel",
+		cancelReply:   "已取消。",
+		now:           time.Now,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// State 定义一个状态。
+// Parameters:
+//   - state: 状态名
+//   - prompt: 进入该状态时发送的提示（可为空）
+//   - handler: 该状态下的消息处理函数
+//
+// Returns:
+//   - *Flow: 便于链式定义
+func (f *Flow) State(state State, prompt string, handler StateHandler) *Flow {
+	f.steps[state] = step{prompt: prompt, handler: handler}
+	return f
+}
+
+// Active 返回匹配“当前处于该流程中”的 Matcher。存储出错时视为不在流程中。
+func (f *Flow) Active() botcore.Matcher {
+	return func(snapshot botcore.RequestSnapshot) bool {
+		_, ok, err := f.load(context.Background(), f.sessionKey(snapshot))
+		return err == nil && ok
+	}
+}
+
+// Trigger 实现 botcore.PipelineInvoker 接口。
+func (f *Flow) Trigger(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+	out := make(chan botcore.StreamChunk, 1)
+	defer close(out)
+
+	reply, err := f.handle(ctx)
+	if err != nil {
+		out <- botcore.ErrorChunk(err)
+		return out
+	}
+	if reply == "" {
+		out <- botcore.StreamChunk{Payload: botcore.NoResponse, IsFinal: true}
+		return out
+	}
+	out <- botcore.StreamChunk{Content: reply, IsFinal: true}
+	return out
+}
+
+// handle 推进一轮流程并返回回复文本。
+func (f *Flow) handle(ctx botcore.PipelineContext) (string, error) {
+	c := ctx.Context()
+	key := f.sessionKey(ctx.Snapshot)
+	session, ok, err := f.load(c, key)
+	if err != nil {
+		return "", err
+	}
+
+	// 1. 未在流程中：进入起始状态
+	if !ok {
+		session = Session{State: f.start}
+		if err := f.save(c, key, session); err != nil {
+			return "", err
+		}
+		return f.steps[f.start].prompt, nil
+	}
+
+	// 2. 取消指令
+	if f.cancelCommand != "" && strings.EqualFold(strings.TrimSpace(ctx.Snapshot.Text), f.cancelCommand) {
+		return f.cancelReply, f.store.Delete(c, key)
+	}
+
+	// 3. 交给当前状态处理
+	turn := &Turn{PipelineContext: ctx, session: &session}
+	current := session.State
+	next := End
+	if s, defined := f.steps[current]; defined && s.handler != nil {
+		next = s.handler(turn)
+	}
+	if next == End {
+		return strings.Join(turn.replies, "\n"), f.store.Delete(c, key)
+	}
+
+	// 关键步骤：状态发生变化时追加新状态的提示。
+	if next != current {
+		if prompt := f.steps[next].prompt; prompt != "" {
+			turn.Reply(prompt)
+		}
+	}
+	session.State = next
+	if err := f.save(c, key, session); err != nil {
+		return "", err
+	}
+	return strings.Join(turn.replies, "\n"), nil
+}
+
+// sessionKey 返回会话存储键。
+func (f *Flow) sessionKey(snapshot botcore.RequestSnapshot) string {
+	return "dialog:" + f.name + ":" + f.key(snapshot)
+}
+
+// load 读取会话并处理过期。
+func (f *Flow) load(ctx context.Context, key string) (Session, bool, error) {
+	session, ok, err := f.store.Load(ctx, key)
+	if err != nil || !ok {
+		return Session{}, false, err
+	}
+	if f.ttl > 0 && f.now().Sub(session.UpdatedAt) > f.ttl {
+		return Session{}, false, nil
+	}
+	return session, true, nil
+}
+
+// save 刷新更新时间并保存会话；存储支持按键过期时一并设置 TTL。
+func (f *Flow) save(ctx context.Context, key string, session Session) error {
+	session.UpdatedAt = f.now()
+	if expiring, ok := f.store.(ExpiringStore); ok && f.ttl > 0 {
+		return expiring.SaveTTL(ctx, key, session, f.ttl)
+	}
+	return f.store.Save(ctx, key, session)
+}
+
+// ErrSessionTooLarge 表示会话超出存储允许的大小上限。
+var ErrSessionTooLarge = errors.New("dialog: session too large")
+
+// MemoryStoreOption 自定义 MemoryStore 行为。
+type MemoryStoreOption func(*MemoryStore)
+
+// WithMaxSessionSize 限制单个会话的大小（状态名与数据键值的字节数之和，<=0 表示不限制），
+// 超出时 Save 返回 ErrSessionTooLarge，避免长期运行的机器人因持续累积数据而无界增长。
+func WithMaxSessionSize(bytes int) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		s.maxSize = bytes
+	}
+}
+
+// WithSweepInterval 设置清理过期会话的最小间隔（默认 1 分钟）。
+func WithSweepInterval(d time.Duration) MemoryStoreOption {
+	return func(s *MemoryStore) {
+		if d > 0 {
+			s.sweepInterval = d
+		}
+	}
+}
+
+// memoryEntry 为 MemoryStore 中的一条会话。
+type memoryEntry struct {
+	session  Session
+	expireAt time.Time // 零值表示不过期
+}
+
+// MemoryStore 是进程内 ExpiringStore 实现，适用于单副本或测试。
+// 过期会话在读取时视为不存在，并在后续访问时按清理间隔批量删除。
+type MemoryStore struct {
+	mu            sync.Mutex
+	sessions      map[string]memoryEntry
+	maxSize       int
+	sweepInterval time.Duration
+	lastSweep     time.Time
+	now           func() time.Time
+}
+
+// NewMemoryStore 创建进程内会话存储。
+// Parameters:
+//   - opts: 可选配置（会话大小上限、清理间隔）
+//
+// Returns:
+//   - *MemoryStore: 会话存储
+func NewMemoryStore(opts ...MemoryStoreOption) *MemoryStore {
+	s := &MemoryStore{
+		sessions:      make(map[string]memoryEntry),
+		sweepInterval: time.Minute,
+		now:           time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Load 实现 Store 接口。
+func (s *MemoryStore) Load(ctx context.Context, key string) (Session, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	entry, ok := s.sessions[key]
+	if !ok || entry.expired(now) {
+		return Session{}, false, nil
+	}
+	session := entry.session
+	session.Data = cloneData(session.Data)
+	return session, true, nil
+}
+
+// Save 实现 Store 接口，会话不过期。
+func (s *MemoryStore) Save(ctx context.Context, key string, session Session) error {
+	return s.SaveTTL(ctx, key, session, 0)
+}
+
+// SaveTTL 实现 ExpiringStore 接口。
+func (s *MemoryStore) SaveTTL(ctx context.Context, key string, session Session, ttl time.Duration) error {
+	if s.maxSize > 0 && sessionSize(session) > s.maxSize {
+		return ErrSessionTooLarge
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	entry := memoryEntry{session: session}
+	entry.session.Data = cloneData(session.Data)
+	if ttl > 0 {
+		entry.expireAt = now.Add(ttl)
+	}
+	s.sessions[key] = entry
+	return nil
+}
+
+// Delete 实现 Store 接口。
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, key)
+	return nil
+}
+
+// Len 返回当前保存的会话数（含尚未清理的过期会话）。
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// sweep 按清理间隔删除过期会话，调用方需持有锁。
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.sweepInterval {
+		return
+	}
+	for k, entry := range s.sessions {
+		if entry.expired(now) {
+			delete(s.sessions, k)
+		}
+	}
+	s.lastSweep = now
+}
+
+// expired 判断会话是否已过期。
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+// sessionSize 估算会话大小：状态名与数据键值的字节数之和。
+func sessionSize(session Session) int {
+	size := len(session.State)
+	for k, v := range session.Data {
+		size += len(k) + len(v)
+	}
+	return size
+}
+
+// cloneData 复制数据，避免调用方修改存储内的状态。
+func cloneData(data map[string]string) map[string]string {
+	if data == nil {
+		return nil
+	}
+	cloned := make(map[string]string, len(data))
+	for k, v := range data {
+		cloned[k] = v
+	}
+	return cloned
+}
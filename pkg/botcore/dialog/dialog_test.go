@@ -0,0 +1,82 @@
+package dialog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+)
+
+// say 以指定文本触发流程并返回回复文本。
+func say(flow *Flow, text string) string {
+	ctx := botcore.PipelineContext{Snapshot: botcore.RequestSnapshot{ChatID: "c1", SenderID: "u1", Text: text}}
+	var reply string
+	for chunk := range flow.Trigger(ctx) {
+		reply += chunk.Content
+	}
+	return reply
+}
+
+func newSignupFlow(opts ...Option) *Flow {
+	return New("signup", "name", opts...).
+		State("name", "请输入姓名", func(t *Turn) State {
+			t.Set("name", t.Input())
+			return "date"
+		}).
+		State("date", "请输入日期（YYYY-MM-DD）", func(t *Turn) State {
+			if _, err := time.Parse("2006-01-02", t.Input()); err != nil {
+				t.Reply("日期格式不正确")
+				return t.State()
+			}
+			t.Set("date", t.Input())
+			return "confirm"
+		}).
+		State("confirm", "确认提交吗？(y/n)", func(t *Turn) State {
+			if t.Input() == "y" {
+				t.Reply("已提交：" + t.Get("name") + " " + t.Get("date"))
+			}
+			return End
+		})
+}
+
+func TestFlowWalksThroughStates(t *testing.T) {
+	flow := newSignupFlow()
+	active := flow.Active()
+	snapshot := botcore.RequestSnapshot{ChatID: "c1", SenderID: "u1"}
+
+	steps := []struct{ input, want string }{
+		{"/signup", "请输入姓名"},
+		{"Alice", "请输入日期（YYYY-MM-DD）"},
+		{"tomorrow", "日期格式不正确"},
+		{"2024-05-01", "确认提交吗？(y/n)"},
+		{"y", "已提交：Alice 2024-05-01"},
+	}
+	for _, step := range steps {
+		if got := say(flow, step.input); got != step.want {
+			t.Fatalf("input %q: got %q, want %q", step.input, got, step.want)
+		}
+		if step.input == "Alice" && !active(snapshot) {
+			t.Fatal("expected flow to be active mid-way")
+		}
+	}
+	if active(snapshot) {
+		t.Fatal("flow should end after confirmation")
+	}
+}
+
+func TestFlowCancelAndExpiry(t *testing.T) {
+	now := time.Unix(0, 0)
+	flow := newSignupFlow(WithTTL(time.Minute))
+	flow.now = func() time.Time { return now }
+
+	say(flow, "/signup")
+	if got := say(flow, "/cancel"); got != "已取消。" {
+		t.Fatalf("unexpected cancel reply: %q", got)
+	}
+
+	say(flow, "/signup")
+	now = now.Add(2 * time.Minute)
+	if got := say(flow, "Bob"); got != "请输入姓名" {
+		t.Fatalf("expired session should restart the flow, got %q", got)
+	}
+}
@@ -0,0 +1,242 @@
+package dialog
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/internal/sqlutil"
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+)
+
+// ExpiringStore 为支持按键过期的 Store。Flow 配置了 TTL 时通过 SaveTTL 保存会话，
+// 由存储自行清理空闲会话，避免共享存储中堆积已放弃的流程。
+type ExpiringStore interface {
+	Store
+	// SaveTTL 保存会话并设置过期时间（ttl<=0 表示不过期）。
+	SaveTTL(ctx context.Context, key string, session Session, ttl time.Duration) error
+}
+
+const (
+	// redisSaveScript 保存会话，ARGV[2] 为过期毫秒数（0 表示不过期）。
+	redisSaveScript = `if tonumber(ARGV[2]) > 0 then redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2]) else redis.call('SET', KEYS[1], ARGV[1]) end return 1`
+	// redisLoadScript 以 {是否存在, 值} 返回，避免客户端把 nil 回复转换为错误（如 go-redis 的 redis.Nil）。
+	redisLoadScript   = `local v = redis.call('GET', KEYS[1]) if v then return {1, v} end return {0, ''}`
+	redisDeleteScript = `return redis.call('DEL', KEYS[1])`
+)
+
+// RedisStore 是基于 Redis 的 ExpiringStore 实现，会话以 JSON 保存，在所有副本间共享且进程重启后保留。
+type RedisStore struct {
+	eval   botcore.RedisEval
+	prefix string
+}
+
+// NewRedisStore 创建 Redis 会话存储。
+// Parameters:
+//   - eval: Redis EVAL 执行函数（见 botcore.RedisEval）
+//   - prefix: 键前缀（可为空），用于与其他业务隔离
+//
+// Returns:
+//   - *RedisStore: 会话存储
+func NewRedisStore(eval botcore.RedisEval, prefix string) *RedisStore {
+	return &RedisStore{eval: eval, prefix: prefix}
+}
+
+// Load 实现 Store 接口。
+func (s *RedisStore) Load(ctx context.Context, key string) (Session, bool, error) {
+	res, err := s.eval(ctx, redisLoadScript, []string{s.prefix + key})
+	if err != nil {
+		return Session{}, false, fmt.Errorf("redis dialog store: %w", err)
+	}
+	values, ok := res.([]any)
+	if !ok || len(values) != 2 {
+		return Session{}, false, fmt.Errorf("redis dialog store: unexpected result %v", res)
+	}
+	if found, _ := values[0].(int64); found != 1 {
+		return Session{}, false, nil
+	}
+	encoded, _ := values[1].(string)
+	var session Session
+	if err := json.Unmarshal([]byte(encoded), &session); err != nil {
+		return Session{}, false, fmt.Errorf("redis dialog store: decode session: %w", err)
+	}
+	return session, true, nil
+}
+
+// Save 实现 Store 接口，会话不过期。
+func (s *RedisStore) Save(ctx context.Context, key string, session Session) error {
+	return s.SaveTTL(ctx, key, session, 0)
+}
+
+// SaveTTL 实现 ExpiringStore 接口。
+func (s *RedisStore) SaveTTL(ctx context.Context, key string, session Session, ttl time.Duration) error {
+	encoded, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("redis dialog store: encode session: %w", err)
+	}
+	var ttlMS int64
+	if ttl > 0 {
+		ttlMS = max(ttl.Milliseconds(), 1)
+	}
+	if _, err := s.eval(ctx, redisSaveScript, []string{s.prefix + key}, string(encoded), ttlMS); err != nil {
+		return fmt.Errorf("redis dialog store: %w", err)
+	}
+	return nil
+}
+
+// Delete 实现 Store 接口。
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	if _, err := s.eval(ctx, redisDeleteScript, []string{s.prefix + key}); err != nil {
+		return fmt.Errorf("redis dialog store: %w", err)
+	}
+	return nil
+}
+
+// sqlMigrations 为会话表的版本化迁移（见 sqlutil.Migrate）。
+var sqlMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS {table} (
+		session_key VARCHAR(255) NOT NULL PRIMARY KEY,
+		state TEXT NOT NULL,
+		data TEXT,
+		updated_at BIGINT NOT NULL,
+		expires_at BIGINT NOT NULL DEFAULT 0
+	)`,
+	`CREATE INDEX IF NOT EXISTS {table}_expires_at ON {table} (expires_at)`,
+}
+
+// SQLStore 是基于 database/sql 的 ExpiringStore 实现（需调用方导入驱动）。
+// 过期会话在读取时视为不存在，可定期调用 PurgeExpired 清理。
+type SQLStore struct {
+	db      *sql.DB
+	table   string
+	dialect sqlutil.Dialect
+	now     func() time.Time
+}
+
+// NewSQLStore 创建 SQL 会话存储并执行尚未应用的迁移。
+// Parameters:
+//   - db: 已打开的 SQLite 数据库连接（迁移使用 CREATE INDEX IF NOT EXISTS，不适用于 MySQL）
+//   - table: 表名；为空时使用 "dialog_sessions"
+//
+// Returns:
+//   - *SQLStore: 会话存储
+//   - error: 表名非法或迁移失败时返回
+func NewSQLStore(db *sql.DB, table string) (*SQLStore, error) {
+	return newSQLStore(db, table, sqlutil.SQLite)
+}
+
+// NewPostgresStore 创建 Postgres 会话存储并执行尚未应用的迁移（使用 $n 占位符，迁移以 advisory lock 串行化）。
+// Parameters:
+//   - db: 已打开的数据库连接（如 pgx/stdlib、lib/pq）
+//   - table: 表名；为空时使用 "dialog_sessions"
+//
+// Returns:
+//   - *SQLStore: 会话存储
+//   - error: 表名非法或迁移失败时返回
+func NewPostgresStore(db *sql.DB, table string) (*SQLStore, error) {
+	return newSQLStore(db, table, sqlutil.Postgres)
+}
+
+// newSQLStore 校验表名并按方言执行迁移。
+func newSQLStore(db *sql.DB, table string, d sqlutil.Dialect) (*SQLStore, error) {
+	table, err := sqlutil.TableName(table, "dialog_sessions")
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlutil.Migrate(context.Background(), db, d, table, sqlMigrations); err != nil {
+		return nil, err
+	}
+	return &SQLStore{db: db, table: table, dialect: d, now: time.Now}, nil
+}
+
+// ph 返回第 i 个占位符。
+func (s *SQLStore) ph(i int) string {
+	return s.dialect.Placeholder(i)
+}
+
+// Load 实现 Store 接口。
+func (s *SQLStore) Load(ctx context.Context, key string) (Session, bool, error) {
+	var (
+		session   Session
+		data      sql.NullString
+		updatedAt int64
+	)
+	err := s.db.QueryRowContext(ctx,
+		`SELECT state, data, updated_at FROM `+s.table+` WHERE session_key = `+s.ph(1)+` AND (expires_at = 0 OR expires_at > `+s.ph(2)+`)`,
+		key, s.now().UnixMilli(),
+	).Scan(&session.State, &data, &updatedAt)
+	if err == sql.ErrNoRows {
+		return Session{}, false, nil
+	}
+	if err != nil {
+		return Session{}, false, fmt.Errorf("load dialog session: %w", err)
+	}
+	if data.Valid && data.String != "" {
+		if err := json.Unmarshal([]byte(data.String), &session.Data); err != nil {
+			return Session{}, false, fmt.Errorf("decode dialog session: %w", err)
+		}
+	}
+	session.UpdatedAt = time.UnixMilli(updatedAt)
+	return session, true, nil
+}
+
+// Save 实现 Store 接口，会话不过期。
+func (s *SQLStore) Save(ctx context.Context, key string, session Session) error {
+	return s.SaveTTL(ctx, key, session, 0)
+}
+
+// SaveTTL 实现 ExpiringStore 接口。
+func (s *SQLStore) SaveTTL(ctx context.Context, key string, session Session, ttl time.Duration) error {
+	data, err := json.Marshal(session.Data)
+	if err != nil {
+		return fmt.Errorf("encode dialog session: %w", err)
+	}
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = s.now().Add(ttl).UnixMilli()
+	}
+
+	// 关键步骤：以“删除 + 插入”的事务实现 upsert，兼容不同数据库的方言。
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("save dialog session: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM `+s.table+` WHERE session_key = `+s.ph(1), key); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("save dialog session: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO `+s.table+` (session_key, state, data, updated_at, expires_at) VALUES (`+s.dialect.Placeholders(1, 5)+`)`,
+		key, string(session.State), string(data), session.UpdatedAt.UnixMilli(), expiresAt,
+	); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("save dialog session: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("save dialog session: %w", err)
+	}
+	return nil
+}
+
+// Delete 实现 Store 接口。
+func (s *SQLStore) Delete(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM `+s.table+` WHERE session_key = `+s.ph(1), key); err != nil {
+		return fmt.Errorf("delete dialog session: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpired 删除已过期的会话。
+// Returns:
+//   - int64: 删除的会话数
+//   - error: 执行失败时返回
+func (s *SQLStore) PurgeExpired(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM `+s.table+` WHERE expires_at <> 0 AND expires_at <= `+s.ph(1), s.now().UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("purge dialog sessions: %w", err)
+	}
+	return res.RowsAffected()
+}
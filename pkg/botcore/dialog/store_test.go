@@ -0,0 +1,186 @@
+package dialog
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/internal/sqlutil"
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLStoreRoundTripAndTTL(t *testing.T) {
+	db, err := sql.Open("sqlite", t.TempDir()+"/dialog.db")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	store, err := NewSQLStore(db, "")
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+	// 重复创建不应重复执行迁移。
+	if store, err = NewSQLStore(db, ""); err != nil {
+		t.Fatalf("NewSQLStore again: %v", err)
+	}
+	now := time.UnixMilli(1_700_000_000_000)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	session := Session{State: "date", Data: map[string]string{"name": "Alice"}, UpdatedAt: now}
+	if err := store.SaveTTL(ctx, "k1", session, time.Minute); err != nil {
+		t.Fatalf("SaveTTL: %v", err)
+	}
+	if err := store.Save(ctx, "k2", Session{State: "name", UpdatedAt: now}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, ok, err := store.Load(ctx, "k1")
+	if err != nil || !ok || got.State != "date" || got.Data["name"] != "Alice" || !got.UpdatedAt.Equal(now) {
+		t.Fatalf("unexpected load: %+v %v %v", got, ok, err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok, _ := store.Load(ctx, "k1"); ok {
+		t.Fatal("expected k1 to expire")
+	}
+	if _, ok, _ := store.Load(ctx, "k2"); !ok {
+		t.Fatal("expected k2 without ttl to remain")
+	}
+	if n, err := store.PurgeExpired(ctx); err != nil || n != 1 {
+		t.Fatalf("PurgeExpired = %d, %v", n, err)
+	}
+	if err := store.Delete(ctx, "k2"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok, _ := store.Load(ctx, "k2"); ok {
+		t.Fatal("expected k2 to be deleted")
+	}
+
+	if _, err := NewSQLStore(db, "bad;name"); err == nil {
+		t.Fatal("expected invalid table name error")
+	}
+}
+
+func TestSQLStorePostgresPlaceholders(t *testing.T) {
+	db, err := sql.Open("sqlite", t.TempDir()+"/dialog.db")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	store, err := NewSQLStore(db, "")
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+	// SQLite 同样接受 $n 形式的参数，借此验证 Postgres 方言生成的语句。
+	store.dialect = sqlutil.Postgres
+	ctx := context.Background()
+	now := time.UnixMilli(1_700_000_000_000)
+	if err := store.SaveTTL(ctx, "k", Session{State: "name", UpdatedAt: now}, time.Minute); err != nil {
+		t.Fatalf("SaveTTL: %v", err)
+	}
+	if got, ok, err := store.Load(ctx, "k"); err != nil || !ok || got.State != "name" {
+		t.Fatalf("unexpected load: %+v %v %v", got, ok, err)
+	}
+	if _, err := store.PurgeExpired(ctx); err != nil {
+		t.Fatalf("PurgeExpired: %v", err)
+	}
+	if err := store.Delete(ctx, "k"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if _, err := NewPostgresStore(db, "bad name"); err == nil {
+		t.Fatal("expected invalid table name error")
+	}
+}
+
+func TestFlowUsesStoreTTL(t *testing.T) {
+	values := map[string]string{}
+	var ttls []int64
+	store := NewRedisStore(func(_ context.Context, script string, keys []string, args ...any) (any, error) {
+		switch script {
+		case redisSaveScript:
+			values[keys[0]] = args[0].(string)
+			ttls = append(ttls, args[1].(int64))
+			return int64(1), nil
+		case redisLoadScript:
+			if v, ok := values[keys[0]]; ok {
+				return []any{int64(1), v}, nil
+			}
+			return []any{int64(0), ""}, nil
+		default:
+			delete(values, keys[0])
+			return int64(1), nil
+		}
+	}, "bot:")
+
+	flow := newSignupFlow(WithStore(store), WithTTL(5*time.Minute))
+	if got := say(flow, "/signup"); got != "请输入姓名" {
+		t.Fatalf("unexpected prompt: %q", got)
+	}
+	if got := say(flow, "Alice"); got != "请输入日期（YYYY-MM-DD）" {
+		t.Fatalf("unexpected prompt: %q", got)
+	}
+	if len(ttls) != 2 || ttls[0] != (5*time.Minute).Milliseconds() {
+		t.Fatalf("expected sessions saved with ttl, got %v", ttls)
+	}
+	session, ok, err := store.Load(context.Background(), "dialog:signup:c1:u1")
+	if err != nil || !ok || session.State != "date" || session.Data["name"] != "Alice" {
+		t.Fatalf("unexpected session: %+v %v %v", session, ok, err)
+	}
+	say(flow, "/cancel")
+	if len(values) != 0 {
+		t.Fatalf("expected session to be deleted, got %v", values)
+	}
+}
+
+func TestMemoryStoreTTLAndEviction(t *testing.T) {
+	store := NewMemoryStore(WithSweepInterval(time.Minute))
+	now := time.Unix(1_700_000_000, 0)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_ = store.SaveTTL(ctx, "short", Session{State: "a"}, 30*time.Second)
+	_ = store.SaveTTL(ctx, "long", Session{State: "b"}, time.Hour)
+	_ = store.Save(ctx, "forever", Session{State: "c"})
+
+	now = now.Add(45 * time.Second)
+	if _, ok, _ := store.Load(ctx, "short"); ok {
+		t.Fatal("expected short session to expire")
+	}
+	if store.Len() != 3 {
+		t.Fatalf("expected eviction to wait for sweep interval, got %d entries", store.Len())
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok, _ := store.Load(ctx, "long"); !ok {
+		t.Fatal("expected long session to remain")
+	}
+	if store.Len() != 2 {
+		t.Fatalf("expected expired session to be evicted, got %d entries", store.Len())
+	}
+}
+
+func TestMemoryStoreMaxSessionSize(t *testing.T) {
+	store := NewMemoryStore(WithMaxSessionSize(16))
+	flow := New("note", "text", WithStore(store)).
+		State("text", "请输入内容", func(t *Turn) State {
+			t.Set("text", t.Input())
+			return "confirm"
+		}).
+		State("confirm", "确认？", func(t *Turn) State { return End })
+
+	say(flow, "/note")
+	ctx := botcore.PipelineContext{Snapshot: botcore.RequestSnapshot{ChatID: "c1", SenderID: "u1", Text: "这是一段超过大小上限的内容"}}
+	chunk := <-flow.Trigger(ctx)
+	if !errors.Is(chunk.Err, ErrSessionTooLarge) {
+		t.Fatalf("expected size limit error, got %+v", chunk)
+	}
+	if err := store.Save(context.Background(), "k", Session{State: "ok", Data: map[string]string{"a": "b"}}); err != nil {
+		t.Fatalf("small session rejected: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package botcore
+
+import (
+	"sync"
+	"time"
+)
+
+// DisclaimerScope 描述免责声明的展示频率。
+type DisclaimerScope int
+
+const (
+	// DisclaimerOncePerConversation 每个会话只展示一次（进程生命周期内）。
+	DisclaimerOncePerConversation DisclaimerScope = iota
+	// DisclaimerOncePerDay 每个会话每个自然日展示一次。
+	DisclaimerOncePerDay
+	// DisclaimerEveryAnswer 每个最终回答都展示。
+	DisclaimerEveryAnswer
+)
+
+// DisclaimerOption 自定义 Disclaimer 行为。
+type DisclaimerOption func(*disclaimerConfig)
+
+type disclaimerConfig struct {
+	scope DisclaimerScope
+	key   KeyFunc
+	text  func(snapshot RequestSnapshot) string
+	now   func() time.Time
+}
+
+// WithDisclaimerScope 设置展示频率（默认 DisclaimerOncePerConversation）。
+func WithDisclaimerScope(scope DisclaimerScope) DisclaimerOption {
+	return func(c *disclaimerConfig) {
+		c.scope = scope
+	}
+}
+
+// WithDisclaimerKey 自定义“会话”的划分方式（默认按 ChatID）。
+func WithDisclaimerKey(key KeyFunc) DisclaimerOption {
+	return func(c *disclaimerConfig) {
+		if key != nil {
+			c.key = key
+		}
+	}
+}
+
+// WithDisclaimerText 按请求动态决定声明文本（如按租户或地区），返回空字符串表示不追加。
+func WithDisclaimerText(text func(snapshot RequestSnapshot) string) DisclaimerOption {
+	return func(c *disclaimerConfig) {
+		if text != nil {
+			c.text = text
+		}
+	}
+}
+
+// WithDisclaimerClock 替换时钟（测试用）。
+func WithDisclaimerClock(now func() time.Time) DisclaimerOption {
+	return func(c *disclaimerConfig) {
+		if now != nil {
+			c.now = now
+		}
+	}
+}
+
+// Disclaimer 返回在最终回答末尾追加 AI 生成内容声明的中间件。
+// 展示记录保存在进程内存中，多副本部署时各副本独立计数。
+// Parameters:
+//   - text: 声明文本（可被 WithDisclaimerText 覆盖）
+//   - opts: 可选配置
+//
+// Returns:
+//   - Middleware: 声明注入中间件
+func Disclaimer(text string, opts ...DisclaimerOption) Middleware {
+	cfg := disclaimerConfig{
+		scope: DisclaimerOncePerConversation,
+		key:   func(snapshot RequestSnapshot) string { return snapshot.ChatID },
+		text:  func(RequestSnapshot) string { return text },
+		now:   time.Now,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var mu sync.Mutex
+	shown := make(map[string]string) // 会话键 -> 最近展示的周期标识
+	shouldShow := func(snapshot RequestSnapshot) bool {
+		if cfg.scope == DisclaimerEveryAnswer {
+			return true
+		}
+		period := "conversation"
+		if cfg.scope == DisclaimerOncePerDay {
+			period = cfg.now().Format("2006-01-02")
+		}
+		key := cfg.key(snapshot)
+		mu.Lock()
+		defer mu.Unlock()
+		if last, ok := shown[key]; ok && last == period {
+			return false
+		}
+		shown[key] = period
+		return true
+	}
+
+	return MapChunksWith(func(ctx PipelineContext) ChunkTransform {
+		return func(chunk StreamChunk) StreamChunk {
+			// 关键步骤：只修改纯文本最终片段，Payload（卡片、静默等）保持原样。
+			if !chunk.IsFinal || chunk.Payload != nil {
+				return chunk
+			}
+			notice := cfg.text(ctx.Snapshot)
+			if notice == "" || !shouldShow(ctx.Snapshot) {
+				return chunk
+			}
+			chunk.Content += "\n\n" + notice
+			return chunk
+		}
+	})
+}
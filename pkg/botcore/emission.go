@@ -0,0 +1,69 @@
+package botcore
+
+import "strings"
+
+// ContentMode 描述 StreamChunk.Content 的语义。
+type ContentMode int
+
+const (
+	// ContentDelta 表示每个片段只包含新增文本（框架默认约定，如 StreamWriter）。
+	ContentDelta ContentMode = iota
+	// ContentFull 表示每个片段包含截至目前的完整文本（如企业微信 stream.content、消息编辑类平台）。
+	ContentFull
+)
+
+// ContentConverter 在增量与全文两种片段语义之间做有状态转换。
+// 每个流应使用独立的转换器实例。
+type ContentConverter struct {
+	from, to ContentMode
+	full     string
+}
+
+// NewContentConverter 创建从 from 语义转换到 to 语义的转换器。
+func NewContentConverter(from, to ContentMode) *ContentConverter {
+	return &ContentConverter{from: from, to: to}
+}
+
+// Convert 转换单个片段；携带 Payload 的片段与非正文片段（Type 非 ChunkContent）原样返回。
+// 全文转增量时，若新全文不是旧全文的延续（内容被改写），无法用增量表达，返回完整新全文。
+func (c *ContentConverter) Convert(chunk StreamChunk) StreamChunk {
+	if chunk.Payload != nil || chunk.Type != ChunkContent || c.from == c.to {
+		return chunk
+	}
+
+	switch c.from {
+	case ContentDelta:
+		c.full += chunk.Content
+		chunk.Content = c.full
+	case ContentFull:
+		prev := c.full
+		c.full = chunk.Content
+		if strings.HasPrefix(chunk.Content, prev) {
+			chunk.Content = chunk.Content[len(prev):]
+		}
+	}
+	return chunk
+}
+
+// ConvertContent 返回在两种片段语义之间转换输出的中间件，
+// 使同一 pipeline 可服务于需要增量或全文的不同平台。
+func ConvertContent(from, to ContentMode) Middleware {
+	return func(next PipelineInvoker) PipelineInvoker {
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			in := next.Trigger(ctx)
+			if in == nil || from == to {
+				return in
+			}
+
+			out := make(chan StreamChunk)
+			go func() {
+				defer close(out)
+				converter := NewContentConverter(from, to)
+				for chunk := range in {
+					out <- converter.Convert(chunk)
+				}
+			}()
+			return out
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package botcore
+
+import "testing"
+
+func TestContentConverterRoundTrip(t *testing.T) {
+	toFull := NewContentConverter(ContentDelta, ContentFull)
+	toDelta := NewContentConverter(ContentFull, ContentDelta)
+
+	var fulls, deltas []string
+	for _, delta := range []string{"Hel", "lo", " world"} {
+		full := toFull.Convert(StreamChunk{Content: delta}).Content
+		fulls = append(fulls, full)
+		deltas = append(deltas, toDelta.Convert(StreamChunk{Content: full}).Content)
+	}
+
+	if fulls[2] != "Hello world" {
+		t.Fatalf("unexpected full content: %v", fulls)
+	}
+	if deltas[0] != "Hel" || deltas[1] != "lo" || deltas[2] != " world" {
+		t.Fatalf("unexpected deltas: %v", deltas)
+	}
+}
@@ -0,0 +1,200 @@
+package botcore
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// 内置 Enricher 写入的元数据键。
+const (
+	MetaLanguage      = "lang"     // 消息语言：zh/ja/ko/en/und
+	MetaPII           = "pii"      // 检出的敏感信息类别，逗号分隔，如 "email,phone"
+	MetaProfilePrefix = "profile." // 用户资料字段前缀，如 "profile.department"
+)
+
+// Enricher 在路由前补充快照信息，例如语言检测、用户资料查询、敏感信息标记。
+// 经 ChainEnrichers 或 Enrich 调用时，传入的是快照副本且 Metadata 非 nil；返回错误时该 Enricher 的修改会被丢弃。
+type Enricher interface {
+	Enrich(ctx context.Context, snapshot *RequestSnapshot) error
+}
+
+// EnricherFunc 便于直接以函数充当 Enricher。
+type EnricherFunc func(ctx context.Context, snapshot *RequestSnapshot) error
+
+// Enrich 实现 Enricher 接口。
+func (f EnricherFunc) Enrich(ctx context.Context, snapshot *RequestSnapshot) error {
+	if f == nil {
+		return nil
+	}
+	return f(ctx, snapshot)
+}
+
+// ChainEnrichers 将多个 Enricher 串联为一个，按顺序执行，后者可读取前者写入的字段。
+// 单个 Enricher 失败时跳过其修改并继续执行其余 Enricher，最终返回合并后的错误。
+func ChainEnrichers(enrichers ...Enricher) Enricher {
+	return EnricherFunc(func(ctx context.Context, snapshot *RequestSnapshot) error {
+		var errs []error
+		for _, enricher := range enrichers {
+			if enricher == nil {
+				continue
+			}
+			// 关键步骤：在副本上执行，失败时不留下半成品字段。
+			candidate := *snapshot
+			candidate.Metadata = cloneMetadata(snapshot.Metadata)
+			if err := enricher.Enrich(ctx, &candidate); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			*snapshot = candidate
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// Enrich 返回在路由前依次执行 Enricher 的中间件，应放在路由（Chain）之外。
+// 失败不会中断请求：已成功的补充照常生效，并对错误调用 onError（可为 nil）。
+func Enrich(onError func(snapshot RequestSnapshot, err error), enrichers ...Enricher) Middleware {
+	chain := ChainEnrichers(enrichers...)
+	return func(next PipelineInvoker) PipelineInvoker {
+		if len(enrichers) == 0 {
+			return next
+		}
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			if err := chain.Enrich(ctx.Context(), &ctx.Snapshot); err != nil && onError != nil {
+				onError(ctx.Snapshot, err)
+			}
+			return next.Trigger(ctx)
+		})
+	}
+}
+
+// DetectLanguage 返回按文字系统粗略判断消息语言的 Enricher，结果写入 Metadata[MetaLanguage]。
+// 含假名判为 ja、含谚文判为 ko、含汉字判为 zh、以拉丁字母为主判为 en，否则为 und。
+// 已存在语言标记（如平台提供）时不覆盖。
+func DetectLanguage() Enricher {
+	return EnricherFunc(func(ctx context.Context, snapshot *RequestSnapshot) error {
+		if snapshot.Metadata[MetaLanguage] != "" || strings.TrimSpace(snapshot.Text) == "" {
+			return nil
+		}
+		snapshot.Metadata[MetaLanguage] = detectLanguage(snapshot.Text)
+		return nil
+	})
+}
+
+// detectLanguage 统计各文字系统的字符数并给出语言代码。
+func detectLanguage(text string) string {
+	var han, kana, hangul, latin int
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+	switch {
+	case kana > 0:
+		return "ja"
+	case hangul > 0:
+		return "ko"
+	case han > 0:
+		return "zh"
+	case latin > 0:
+		return "en"
+	default:
+		return "und"
+	}
+}
+
+// piiPatterns 为内置的敏感信息识别规则，按类别名排序以保证输出稳定。
+// valid 非空时对每个匹配再做校验：18 位身份证号同样是 16~19 位数字，银行卡号因此需通过 Luhn 校验且不是合法的身份证号。
+var piiPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+}{
+	{"bank_card", regexp.MustCompile(`\b\d{16,19}\b`), func(s string) bool { return luhnValid(s) && !idCardValid(s) }},
+	{"email", regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), nil},
+	{"id_card", regexp.MustCompile(`\b\d{17}[\dXx]\b`), idCardValid},
+	{"phone", regexp.MustCompile(`(^|\D)1[3-9]\d{9}($|\D)`), nil},
+}
+
+// luhnValid 按 Luhn 算法校验银行卡号。
+func luhnValid(digits string) bool {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// idCardWeights 为 18 位居民身份证号前 17 位的加权因子（GB 11643）。
+var idCardWeights = [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+
+// idCardValid 校验 18 位居民身份证号的校验码。
+func idCardValid(id string) bool {
+	if len(id) != 18 {
+		return false
+	}
+	sum := 0
+	for i, w := range idCardWeights {
+		sum += int(id[i]-'0') * w
+	}
+	check := "10X98765432"[sum%11]
+	return id[17] == check || (check == 'X' && id[17] == 'x')
+}
+
+// TagPII 返回识别消息中敏感信息的 Enricher：检出的类别（email/phone/id_card/bank_card）
+// 以逗号分隔写入 Metadata[MetaPII]，便于下游在记录日志或调用外部模型前脱敏。仅做标记，不改写 Text。
+func TagPII() Enricher {
+	return EnricherFunc(func(ctx context.Context, snapshot *RequestSnapshot) error {
+		var found []string
+		for _, p := range piiPatterns {
+			for _, match := range p.pattern.FindAllString(snapshot.Text, -1) {
+				if p.valid == nil || p.valid(match) {
+					found = append(found, p.name)
+					break
+				}
+			}
+		}
+		if len(found) > 0 {
+			snapshot.Metadata[MetaPII] = strings.Join(found, ",")
+		}
+		return nil
+	})
+}
+
+// ProfileLookup 按用户 ID 查询资料（部门、职级、语言偏好等）。
+// 用户不存在时应返回空 map 与 nil。
+type ProfileLookup func(ctx context.Context, senderID string) (map[string]string, error)
+
+// LookupProfile 返回查询发送者资料的 Enricher，每个字段以 MetaProfilePrefix 为前缀写入 Metadata。
+// 查询失败时返回错误，由 Enrich 的 onError 处理，请求照常路由。
+func LookupProfile(lookup ProfileLookup) Enricher {
+	return EnricherFunc(func(ctx context.Context, snapshot *RequestSnapshot) error {
+		if lookup == nil || snapshot.SenderID == "" {
+			return nil
+		}
+		profile, err := lookup(ctx, snapshot.SenderID)
+		if err != nil {
+			return err
+		}
+		for k, v := range profile {
+			snapshot.Metadata[MetaProfilePrefix+k] = v
+		}
+		return nil
+	})
+}
@@ -0,0 +1,50 @@
+package botcore
+
+// ErrorChunk 构造表示处理失败的最终片段。
+// Parameters:
+//   - err: 失败原因
+//
+// Returns:
+//   - StreamChunk: 携带 Err 的最终片段
+func ErrorChunk(err error) StreamChunk {
+	return StreamChunk{Err: err, IsFinal: true}
+}
+
+// ErrorRenderer 将失败片段渲染为用户可见的回复（文本或卡片 Payload），可按快照做本地化。
+// 返回值的 Err 字段会被忽略，IsFinal 沿用原片段。
+type ErrorRenderer func(snapshot RequestSnapshot, chunk StreamChunk) StreamChunk
+
+// DefaultErrorRenderer 保留已有的兜底文本；无文本时输出按快照语言本地化的通用错误提示（MsgProcessingError）。
+func DefaultErrorRenderer(snapshot RequestSnapshot, chunk StreamChunk) StreamChunk {
+	if chunk.Content != "" || chunk.Payload != nil {
+		return chunk
+	}
+	return StreamChunk{Content: Localize(snapshot, MsgProcessingError, chunk.Err)}
+}
+
+// RenderError 使用 renderer（为 nil 时使用 DefaultErrorRenderer）渲染失败片段，非失败片段原样返回。
+func RenderError(renderer ErrorRenderer, snapshot RequestSnapshot, chunk StreamChunk) StreamChunk {
+	if chunk.Err == nil {
+		return chunk
+	}
+	if renderer == nil {
+		renderer = DefaultErrorRenderer
+	}
+	rendered := renderer(snapshot, chunk)
+	rendered.Err = nil
+	rendered.IsFinal = chunk.IsFinal
+	return rendered
+}
+
+// RenderErrors 返回在 pipeline 内渲染失败片段的中间件，并对每个失败调用 onError（可为 nil）。
+// 平台适配层已内置渲染时（如 wecom.WithErrorRenderer）无需再使用本中间件。
+func RenderErrors(renderer ErrorRenderer, onError func(snapshot RequestSnapshot, err error)) Middleware {
+	return MapChunksWith(func(ctx PipelineContext) ChunkTransform {
+		return func(chunk StreamChunk) StreamChunk {
+			if chunk.Err != nil && onError != nil {
+				onError(ctx.Snapshot, chunk.Err)
+			}
+			return RenderError(renderer, ctx.Snapshot, chunk)
+		}
+	})
+}
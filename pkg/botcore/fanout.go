@@ -0,0 +1,110 @@
+package botcore
+
+// fanOutBufferSize 为每个分支缓冲的片段数上限。
+const fanOutBufferSize = 64
+
+// FanOutMode 描述 FanOut 合并多个分支输出的方式。
+type FanOutMode int
+
+const (
+	// FanOutFirst 按分支顺序选取第一个有输出的分支，其余分支照常执行但输出被丢弃。
+	FanOutFirst FanOutMode = iota
+	// FanOutConcat 按分支顺序拼接所有有输出的分支，分支之间以空行分隔。
+	FanOutConcat
+	// FanOutSections 与 FanOutConcat 相同，但每个分支输出前加上以 Label 为标题的小节。
+	FanOutSections
+)
+
+// FanOutBranch 描述 FanOut 的一个分支。
+type FanOutBranch struct {
+	Label   string // 分支标签，FanOutSections 模式下作为小节标题
+	Handler PipelineInvoker
+}
+
+// FanOut 返回并发触发多个分支并合并输出的执行器。
+// 所有分支以同一 PipelineContext 同时启动，输出按分支声明顺序转发：排在前面的分支以流式转发，
+// 后续分支的输出在此期间被缓冲：每个分支最多缓冲 64 个片段，超出后纯文本片段合并进队尾片段（OverflowCoalesce），
+// 既不丢弃文本也不阻塞分支；无法合并的结构化片段（卡片、附件）仍会入队。只输出 NoResponse 或空文本的分支视为无输出（如统计、日志分支）。
+// 分支的结束标记被合并为一个最终片段；所有分支均无输出时返回 NoResponse。
+// 拼接模式按增量语义处理文本片段。
+// Parameters:
+//   - mode: 合并方式
+//   - branches: 分支列表
+//
+// Returns:
+//   - PipelineInvoker: 扇出执行器
+func FanOut(mode FanOutMode, branches ...FanOutBranch) PipelineInvoker {
+	return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		// 关键步骤：先启动全部分支，并以合并文本的有界队列缓冲，避免未轮到的分支阻塞。
+		inputs := make([]<-chan StreamChunk, len(branches))
+		for i, branch := range branches {
+			if branch.Handler == nil {
+				continue
+			}
+			in := branch.Handler.Trigger(ctx)
+			if in == nil {
+				continue
+			}
+			buffered := make(chan StreamChunk)
+			go runBuffer(in, buffered, fanOutBufferSize, OverflowCoalesce)
+			inputs[i] = buffered
+		}
+
+		out := make(chan StreamChunk)
+		go func() {
+			defer close(out)
+			emitted := false
+			for i, in := range inputs {
+				if in == nil {
+					continue
+				}
+				if mode == FanOutFirst && emitted {
+					for range in {
+					}
+					continue
+				}
+				started := false
+				for chunk := range in {
+					if !hasOutput(chunk) {
+						continue
+					}
+					if !started {
+						started = true
+						if prefix := fanOutPrefix(mode, branches[i].Label, emitted); prefix != "" {
+							out <- StreamChunk{Content: prefix}
+						}
+						emitted = true
+					}
+					chunk.IsFinal = false
+					out <- chunk
+				}
+			}
+			if !emitted {
+				out <- StreamChunk{Payload: NoResponse, IsFinal: true}
+				return
+			}
+			out <- StreamChunk{IsFinal: true}
+		}()
+		return out
+	})
+}
+
+// hasOutput 判断片段是否包含用户可见的输出。
+func hasOutput(chunk StreamChunk) bool {
+	if chunk.Payload == NoResponse {
+		return false
+	}
+	return chunk.Content != "" || chunk.Payload != nil || len(chunk.Attachments) > 0 || chunk.Err != nil
+}
+
+// fanOutPrefix 返回分支输出前的分隔文本与小节标题。
+func fanOutPrefix(mode FanOutMode, label string, emitted bool) string {
+	var prefix string
+	if emitted && mode != FanOutFirst {
+		prefix = "\n\n"
+	}
+	if mode == FanOutSections && label != "" {
+		prefix += "**" + label + "**\n\n"
+	}
+	return prefix
+}
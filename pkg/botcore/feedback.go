@@ -0,0 +1,191 @@
+package botcore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FeedbackKind 描述用户对回答的评价。
+type FeedbackKind string
+
+const (
+	// FeedbackPositive 表示用户认为回答准确（点赞）。
+	FeedbackPositive FeedbackKind = "positive"
+	// FeedbackNegative 表示用户认为回答不准确（点踩）。
+	FeedbackNegative FeedbackKind = "negative"
+	// FeedbackCanceled 表示用户取消了之前的评价。
+	FeedbackCanceled FeedbackKind = "canceled"
+)
+
+// Feedback 描述用户对机器人回答的反馈事件。
+type Feedback struct {
+	ID      string       `json:"id"`                // 反馈 ID，与回答发出时携带的反馈 ID 一致（企业微信为源消息 msgid）
+	Kind    FeedbackKind `json:"kind"`              // 评价类型
+	Comment string       `json:"comment,omitempty"` // 用户填写的反馈内容
+	Reasons []string     `json:"reasons,omitempty"` // 负反馈原因（平台原因编码）
+}
+
+// FeedbackRecord 是写入 FeedbackSink 的反馈记录，已关联原始提问与回答。
+type FeedbackRecord struct {
+	Feedback
+	ChatID   string    `json:"chat_id"`
+	SenderID string    `json:"sender_id"`
+	Question string    `json:"question,omitempty"` // 原始提问（关联不到时为空）
+	Answer   string    `json:"answer,omitempty"`   // 原始回答（关联不到时为空）
+	At       time.Time `json:"at"`
+}
+
+// FeedbackSink 持久化反馈记录。
+type FeedbackSink interface {
+	// Record 写入一条反馈记录。
+	Record(ctx context.Context, record FeedbackRecord) error
+}
+
+// MatchFeedback 返回匹配反馈事件的 Matcher。
+func MatchFeedback() Matcher {
+	return func(u RequestSnapshot) bool {
+		return u.Feedback != nil
+	}
+}
+
+// FeedbackOption 自定义 CollectFeedback 行为。
+type FeedbackOption func(*feedbackConfig)
+
+type feedbackConfig struct {
+	maxAnswers int
+	onError    func(err error)
+}
+
+// WithFeedbackAnswers 设置用于关联反馈的最近回答缓存条数（默认 1000，<=0 表示不关联）。
+func WithFeedbackAnswers(n int) FeedbackOption {
+	return func(c *feedbackConfig) {
+		c.maxAnswers = n
+	}
+}
+
+// WithFeedbackErrorHandler 设置写入失败时的回调（默认忽略）。
+func WithFeedbackErrorHandler(fn func(err error)) FeedbackOption {
+	return func(c *feedbackConfig) {
+		c.onError = fn
+	}
+}
+
+// CollectFeedback 返回收集反馈的中间件。
+// 普通消息原样交给下游，同时按 MessageKey 缓存提问与最终回答；
+// 反馈事件按 Feedback.ID 关联缓存后写入 sink，不再回复。
+// 缓存保存在进程内存中，多副本部署时关联不到的记录 Question/Answer 为空。
+// Parameters:
+//   - sink: 反馈存储
+//   - opts: 可选配置
+//
+// Returns:
+//   - Middleware: 反馈收集中间件
+func CollectFeedback(sink FeedbackSink, opts ...FeedbackOption) Middleware {
+	cfg := feedbackConfig{maxAnswers: 1000}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	answers := newAnswerCache(cfg.maxAnswers)
+
+	return func(next PipelineInvoker) PipelineInvoker {
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			snapshot := ctx.Snapshot
+			if fb := snapshot.Feedback; fb != nil {
+				record := FeedbackRecord{
+					Feedback: *fb,
+					ChatID:   snapshot.ChatID,
+					SenderID: snapshot.SenderID,
+					At:       time.Now(),
+				}
+				record.Question, record.Answer = answers.get(fb.ID)
+				if sink != nil {
+					if err := sink.Record(ctx.Context(), record); err != nil && cfg.onError != nil {
+						cfg.onError(err)
+					}
+				}
+				return singleChunk(StreamChunk{Payload: NoResponse, IsFinal: true})
+			}
+
+			in := next.Trigger(ctx)
+			key := MessageKey(snapshot)
+			if in == nil || key == "" || cfg.maxAnswers <= 0 {
+				return in
+			}
+			out := make(chan StreamChunk)
+			go func() {
+				defer close(out)
+				var answer []byte
+				for chunk := range in {
+					if chunk.Payload == nil {
+						answer = append(answer, chunk.Content...)
+					}
+					out <- chunk
+				}
+				answers.put(key, snapshot.Text, string(answer))
+			}()
+			return out
+		})
+	}
+}
+
+// answerCache 按插入顺序保留最近的提问与回答。
+type answerCache struct {
+	mu    sync.Mutex
+	max   int
+	order []string
+	items map[string][2]string
+}
+
+// newAnswerCache 创建回答缓存。
+func newAnswerCache(max int) *answerCache {
+	return &answerCache{max: max, items: make(map[string][2]string)}
+}
+
+// put 写入一条记录，超出容量时淘汰最早的记录。
+func (c *answerCache) put(key, question, answer string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.items[key] = [2]string{question, answer}
+	for len(c.order) > c.max {
+		delete(c.items, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// get 读取提问与回答。
+func (c *answerCache) get(key string) (question, answer string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item := c.items[key]
+	return item[0], item[1]
+}
+
+// MemoryFeedbackSink 是进程内 FeedbackSink 实现，适用于测试或临时统计。
+type MemoryFeedbackSink struct {
+	mu      sync.Mutex
+	records []FeedbackRecord
+}
+
+// NewMemoryFeedbackSink 创建进程内反馈存储。
+func NewMemoryFeedbackSink() *MemoryFeedbackSink {
+	return &MemoryFeedbackSink{}
+}
+
+// Record 实现 FeedbackSink 接口。
+func (s *MemoryFeedbackSink) Record(ctx context.Context, record FeedbackRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	return nil
+}
+
+// Records 返回已记录的反馈副本。
+func (s *MemoryFeedbackSink) Records() []FeedbackRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]FeedbackRecord(nil), s.records...)
+}
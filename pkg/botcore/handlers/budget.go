@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// 结束包 Metadata 中的上下文用量键，便于调用方展示“上下文已用 80%”之类的提示。
+const (
+	MetaContextTokens  = "context_tokens"  // 本次请求估算的上下文 token 数
+	MetaContextLimit   = "context_limit"   // 上下文预算（TokenBudget.MaxTokens）
+	MetaTrimmedHistory = "trimmed_history" // 因超出预算未发送给模型的历史消息数
+)
+
+// messageOverhead 为每条消息在角色、分隔符上的额外 token 估算。
+const messageOverhead = 4
+
+// TokenCounter 返回文本的 token 数。
+type TokenCounter func(text string) int
+
+// ApproxTokens 粗略估算 token 数：ASCII 字符按 4 个计 1 个 token，其余字符（如中文）各计 1 个，无需下载词表。
+func ApproxTokens(text string) int {
+	var ascii, other int
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return other + (ascii+3)/4
+}
+
+// TiktokenCounter 返回基于 tiktoken 词表计数的 TokenCounter（见 llms.CountTokens），
+// 首次使用时可能需要联网下载词表，无法下载时退化为粗略估算。
+func TiktokenCounter(model string) TokenCounter {
+	return func(text string) int {
+		return llms.CountTokens(model, text)
+	}
+}
+
+// TokenBudget 限制每次请求发送给模型的上下文大小：超出时从最早的历史消息开始丢弃。
+type TokenBudget struct {
+	// MaxTokens 为上下文预算（含系统提示词、历史与本轮输入），应按模型的上下文窗口并预留回答空间设置。
+	MaxTokens int
+	// Counter 为 token 计数函数，默认 ApproxTokens。
+	Counter TokenCounter
+}
+
+// WithTokenBudget 为多轮对话设置上下文预算：历史超出预算时丢弃最早的消息，
+// 并在结束包 Metadata 中写入 MetaContextTokens / MetaContextLimit / MetaTrimmedHistory。
+func WithTokenBudget(budget TokenBudget) LLMOption {
+	return func(c *llmConfig) {
+		if budget.Counter == nil {
+			budget.Counter = ApproxTokens
+		}
+		c.budget = &budget
+	}
+}
+
+// CountMessageTokens 估算消息列表的 token 数（仅统计文本部分）。
+func CountMessageTokens(counter TokenCounter, messages ...llms.MessageContent) int {
+	total := 0
+	for _, msg := range messages {
+		total += messageOverhead
+		for _, part := range msg.Parts {
+			if text, ok := part.(llms.TextContent); ok {
+				total += counter(text.Text)
+			}
+		}
+	}
+	return total
+}
+
+// trimHistory 在 fixed（系统提示词与本轮输入）之外，从最早的消息开始丢弃历史直到总量不超过预算。
+// 保留的历史不以模型回答开头，避免出现缺少提问的孤立回答。
+// Returns:
+//   - kept: 保留的历史
+//   - dropped: 丢弃的历史（按时间顺序）
+//   - used: 保留部分与 fixed 的 token 总数
+func (b TokenBudget) trimHistory(history []llms.MessageContent, fixed int) (kept, dropped []llms.MessageContent, used int) {
+	used = fixed + CountMessageTokens(b.Counter, history...)
+	start := 0
+	for start < len(history) && used > b.MaxTokens {
+		used -= CountMessageTokens(b.Counter, history[start])
+		start++
+	}
+	for start > 0 && start < len(history) && history[start].Role == llms.ChatMessageTypeAI {
+		used -= CountMessageTokens(b.Counter, history[start])
+		start++
+	}
+	return history[start:], history[:start], used
+}
+
+// usageMetadata 返回写入结束包的上下文用量。
+func (b TokenBudget) usageMetadata(used, trimmed int) map[string]string {
+	return map[string]string{
+		MetaContextTokens:  strconv.Itoa(used),
+		MetaContextLimit:   strconv.Itoa(b.MaxTokens),
+		MetaTrimmedHistory: strconv.Itoa(trimmed),
+	}
+}
+
+// ContextUsage 从结束包中读取上下文用量占预算的比例（0~1+），未启用 WithTokenBudget 时返回 false。
+func ContextUsage(chunk botcore.StreamChunk) (float64, bool) {
+	used, err1 := strconv.Atoi(chunk.Metadata[MetaContextTokens])
+	limit, err2 := strconv.Atoi(chunk.Metadata[MetaContextLimit])
+	if err1 != nil || err2 != nil || limit <= 0 {
+		return 0, false
+	}
+	return float64(used) / float64(limit), true
+}
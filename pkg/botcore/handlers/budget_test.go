@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestApproxTokens(t *testing.T) {
+	if got := ApproxTokens("hello world!"); got != 3 {
+		t.Fatalf("ascii tokens = %d", got)
+	}
+	if got := ApproxTokens("你好ab"); got != 3 {
+		t.Fatalf("mixed tokens = %d", got)
+	}
+}
+
+func TestLLMHandlerTrimsHistoryToBudget(t *testing.T) {
+	model := &fakeModel{parts: []string{strings.Repeat("答", 10)}}
+	// 每轮：提问 10 + 回答 10 个 token，外加每条消息 4 个开销。
+	handler := NewLLMHandler(model,
+		WithHistory(NewMemoryHistory(0)),
+		WithTokenBudget(TokenBudget{MaxTokens: 60}),
+	)
+
+	var final botcore.StreamChunk
+	for i := 0; i < 3; i++ {
+		snapshot := botcore.RequestSnapshot{ChatID: "c1", SenderID: "u1", Text: strings.Repeat("问", 10)}
+		for chunk := range handler.Trigger(botcore.PipelineContext{Snapshot: snapshot}) {
+			if chunk.IsFinal {
+				final = chunk
+			}
+		}
+	}
+
+	// 第三轮：本轮 14 + 两轮历史 56 > 60，丢弃最早一轮后为 42。
+	last := model.received[2]
+	if len(last) != 3 || last[0].Role != llms.ChatMessageTypeHuman {
+		t.Fatalf("expected oldest turn to be trimmed, got %d messages", len(last))
+	}
+	if final.Metadata[MetaContextTokens] != "42" || final.Metadata[MetaTrimmedHistory] != "2" {
+		t.Fatalf("unexpected usage metadata: %v", final.Metadata)
+	}
+	if usage, ok := ContextUsage(final); !ok || usage != 0.7 {
+		t.Fatalf("ContextUsage = %v, %v", usage, ok)
+	}
+}
+
+func TestLLMHandlerSummarizesTrimmedHistory(t *testing.T) {
+	model := &fakeModel{parts: []string{strings.Repeat("答", 10)}}
+	summarizer := &scriptedModel{answer: "用户名叫小明"}
+	history := NewMemoryHistory(0)
+	handler := NewLLMHandler(model,
+		WithHistory(history),
+		// 第三轮：本轮 14 + 两轮历史 56 > 65，压缩最早一轮后为 14 + 摘要 19 + 一轮历史 28 = 61。
+		WithTokenBudget(TokenBudget{MaxTokens: 65}),
+		WithSummarizer(Summarizer{Model: summarizer}),
+	)
+	for i := 0; i < 3; i++ {
+		runHandler(handler, strings.Repeat("问", 10))
+	}
+
+	if summarizer.calls != 1 {
+		t.Fatalf("expected one summarization, got %d", summarizer.calls)
+	}
+	last := model.received[2]
+	if len(last) != 4 || last[0].Role != llms.ChatMessageTypeSystem {
+		t.Fatalf("expected summary to be pinned before the kept turn, got %d messages", len(last))
+	}
+	if text := last[0].Parts[0].(llms.TextContent).Text; text != summaryPrefix+"用户名叫小明" {
+		t.Fatalf("unexpected summary: %q", text)
+	}
+	// 历史中被压缩的一轮替换为摘要：摘要 + 第二轮 + 第三轮。
+	stored := history.Load("c1:u1")
+	if len(stored) != 5 || stored[0].Role != llms.ChatMessageTypeSystem {
+		t.Fatalf("unexpected stored history: %d messages", len(stored))
+	}
+}
+
+func TestLLMHandlerRetrimsAfterSummary(t *testing.T) {
+	model := &fakeModel{parts: []string{strings.Repeat("答", 10)}}
+	history := NewMemoryHistory(0)
+	handler := NewLLMHandler(model,
+		WithHistory(history),
+		// 第三轮：压缩后 14 + 摘要 19 + 一轮历史 28 = 61 > 60，保留的一轮也需裁剪。
+		WithTokenBudget(TokenBudget{MaxTokens: 60}),
+		WithSummarizer(Summarizer{Model: &scriptedModel{answer: "用户名叫小明"}}),
+	)
+	var final botcore.StreamChunk
+	for i := 0; i < 3; i++ {
+		snapshot := botcore.RequestSnapshot{ChatID: "c1", SenderID: "u1", Text: strings.Repeat("问", 10)}
+		for chunk := range handler.Trigger(botcore.PipelineContext{Snapshot: snapshot}) {
+			if chunk.IsFinal {
+				final = chunk
+			}
+		}
+	}
+
+	last := model.received[2]
+	if len(last) != 2 || last[0].Role != llms.ChatMessageTypeSystem {
+		t.Fatalf("expected only the summary and the question, got %d messages", len(last))
+	}
+	if final.Metadata[MetaContextTokens] != "33" || final.Metadata[MetaTrimmedHistory] != "4" {
+		t.Fatalf("unexpected usage metadata: %v", final.Metadata)
+	}
+}
+
+func TestMemoryHistoryRejectsStaleCompaction(t *testing.T) {
+	history := NewMemoryHistory(0)
+	msgs := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, "q1"),
+		llms.TextParts(llms.ChatMessageTypeAI, "a1"),
+		llms.TextParts(llms.ChatMessageTypeHuman, "q2"),
+	}
+	history.Append("k", msgs...)
+	loaded := history.Load("k")
+
+	if !history.Compact("k", loaded[:2], llms.TextParts(llms.ChatMessageTypeSystem, "summary")) {
+		t.Fatal("expected compaction of the loaded prefix to succeed")
+	}
+	// 关键步骤：同一批消息已被压缩，基于旧快照的第二次压缩不得再删除消息。
+	if history.Compact("k", loaded[:2], llms.TextParts(llms.ChatMessageTypeSystem, "stale")) {
+		t.Fatal("expected compaction with a stale prefix to be rejected")
+	}
+	stored := history.Load("k")
+	if len(stored) != 2 || stored[0].Parts[0].(llms.TextContent).Text != "summary" {
+		t.Fatalf("unexpected history after stale compaction: %v", stored)
+	}
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// RetryPolicy 描述单个模型的重试策略。
+type RetryPolicy struct {
+	// Attempts 为每个模型的最多尝试次数（<=0 时为 1，即不重试）。
+	Attempts int
+	// Backoff 为重试等待的基数：第 i 次重试前等待 Backoff * 2^(i-1)。
+	Backoff time.Duration
+	// RetryOn 判断错误是否值得重试，默认为 RetryableError（429、5xx 与网络错误）。
+	RetryOn func(err error) bool
+	// FallbackOn 判断不可重试的错误是否仍切换到下一个模型（如仅主模型不支持某参数导致的 400）；
+	// 默认为空，即 400/401 等请求或凭证错误直接返回，不再降级。
+	FallbackOn func(err error) bool
+}
+
+// FallbackModel 按顺序尝试多个模型（如 gpt-4o → gpt-4o-mini → 本地模型），每个模型按 RetryPolicy 重试，
+// 使单个服务的 429/5xx 等瞬时故障降级处理，而不是直接把错误返回给用户；其它错误直接返回（见 RetryPolicy.FallbackOn）。
+// 流式输出已开始后的失败不再重试或切换模型，避免重复输出。
+type FallbackModel struct {
+	models []llms.Model
+	policy RetryPolicy
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+var _ llms.Model = (*FallbackModel)(nil)
+
+// NewFallbackModel 创建带重试与降级的模型。
+// Parameters:
+//   - policy: 每个模型的重试策略
+//   - models: 按优先级排列的模型
+//
+// Returns:
+//   - *FallbackModel: 实现 llms.Model，可直接交给 NewLLMHandler、command.WithLLM 等使用
+func NewFallbackModel(policy RetryPolicy, models ...llms.Model) *FallbackModel {
+	if policy.Attempts <= 0 {
+		policy.Attempts = 1
+	}
+	if policy.RetryOn == nil {
+		policy.RetryOn = RetryableError
+	}
+	return &FallbackModel{models: models, policy: policy, sleep: sleepContext}
+}
+
+// GenerateContent 实现 llms.Model 接口。
+func (m *FallbackModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	if len(m.models) == 0 {
+		return nil, errors.New("fallback model: no models configured")
+	}
+
+	// 关键步骤：包装流式回调以记录是否已输出，已输出后失败不可重试。
+	var opts llms.CallOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
+	streamed := false
+	if opts.StreamingFunc != nil {
+		stream := opts.StreamingFunc
+		options = append(options, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+			streamed = true
+			return stream(ctx, chunk)
+		}))
+	}
+
+	var lastErr error
+	for _, model := range m.models {
+		for attempt := 0; attempt < m.policy.Attempts; attempt++ {
+			if attempt > 0 {
+				if err := m.sleep(ctx, m.policy.Backoff<<(attempt-1)); err != nil {
+					return nil, err
+				}
+			}
+			resp, err := model.GenerateContent(ctx, messages, options...)
+			if err == nil {
+				return resp, nil
+			}
+			lastErr = err
+			if streamed || ctx.Err() != nil {
+				return nil, err
+			}
+			if !m.policy.RetryOn(err) {
+				// 关键步骤：请求本身有误或凭证失效时换模型通常同样失败，除非显式声明，否则直接返回。
+				if m.policy.FallbackOn == nil || !m.policy.FallbackOn(err) {
+					return nil, err
+				}
+				break
+			}
+		}
+	}
+	return nil, lastErr
+}
+
+// Call 实现 llms.Model 接口。
+func (m *FallbackModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// statusPattern 从错误信息中提取 HTTP 状态码（如 "API returned unexpected status code: 429"）。
+var statusPattern = regexp.MustCompile(`status(?: code)?:? (\d{3})\b`)
+
+// StatusCode 返回错误携带的 HTTP 状态码：优先取实现 StatusCode() int 的错误，否则从错误信息中解析；无法确定时返回 0。
+func StatusCode(err error) int {
+	var coded interface{ StatusCode() int }
+	if errors.As(err, &coded) {
+		return coded.StatusCode()
+	}
+	if err == nil {
+		return 0
+	}
+	if match := statusPattern.FindStringSubmatch(err.Error()); match != nil {
+		code, _ := strconv.Atoi(match[1])
+		return code
+	}
+	return 0
+}
+
+// RetryableError 为默认的重试判断：429、5xx 与网络错误视为瞬时故障；context 取消或超时不重试。
+func RetryableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if code := StatusCode(err); code != 0 {
+		return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// sleepContext 等待 d，context 结束时提前返回其错误。
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// scriptedModel 依次返回 errs 中的错误，耗尽后流式输出 answer。
+type scriptedModel struct {
+	errs   []error
+	answer string
+	calls  int
+}
+
+func (m *scriptedModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.calls++
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return nil, err
+	}
+	opts := llms.CallOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	if opts.StreamingFunc != nil {
+		if err := opts.StreamingFunc(ctx, []byte(m.answer)); err != nil {
+			return nil, err
+		}
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: m.answer}}}, nil
+}
+
+func (m *scriptedModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+func TestFallbackModelRetriesAndFallsBack(t *testing.T) {
+	rateLimited := errors.New("API returned unexpected status code: 429")
+	primary := &scriptedModel{errs: []error{rateLimited, rateLimited, rateLimited}}
+	secondary := &scriptedModel{errs: []error{errors.New("API returned unexpected status code: 503")}, answer: "ok"}
+	model := NewFallbackModel(RetryPolicy{Attempts: 3, Backoff: time.Second}, primary, secondary)
+	var waits []time.Duration
+	model.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	got, err := model.Call(context.Background(), "hi")
+	if err != nil || got != "ok" {
+		t.Fatalf("Call = %q, %v", got, err)
+	}
+	if primary.calls != 3 || secondary.calls != 2 {
+		t.Fatalf("unexpected calls: primary=%d secondary=%d", primary.calls, secondary.calls)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, time.Second}
+	if len(waits) != len(want) || waits[0] != want[0] || waits[1] != want[1] || waits[2] != want[2] {
+		t.Fatalf("unexpected backoff: %v", waits)
+	}
+}
+
+func TestFallbackModelSkipsRetryForClientErrors(t *testing.T) {
+	badRequest := errors.New("API returned unexpected status code: 400")
+	primary := &scriptedModel{errs: []error{badRequest}}
+	secondary := &scriptedModel{answer: "fallback"}
+	model := NewFallbackModel(RetryPolicy{Attempts: 3}, primary, secondary)
+
+	if _, err := model.Call(context.Background(), "hi"); !errors.Is(err, badRequest) {
+		t.Fatalf("expected the client error to be returned, got %v", err)
+	}
+	if primary.calls != 1 || secondary.calls != 0 {
+		t.Fatalf("expected no retry or fallback for 400: primary=%d secondary=%d", primary.calls, secondary.calls)
+	}
+
+	// 显式声明 FallbackOn 时，不可重试的错误仍切换到备用模型。
+	primary = &scriptedModel{errs: []error{badRequest}}
+	model = NewFallbackModel(RetryPolicy{
+		Attempts:   3,
+		FallbackOn: func(err error) bool { return StatusCode(err) == 400 },
+	}, primary, &scriptedModel{answer: "fallback"})
+	handler := NewLLMHandler(model)
+	if got := runHandler(handler, "hi"); got != "fallback" {
+		t.Fatalf("unexpected reply: %q", got)
+	}
+	if primary.calls != 1 {
+		t.Fatalf("expected no retry for 400, got %d calls", primary.calls)
+	}
+}
+
+func TestRetryableError(t *testing.T) {
+	cases := map[string]bool{
+		"API returned unexpected status code: 429": true,
+		"status 502 bad gateway":                   true,
+		"API returned unexpected status code: 401": false,
+		"invalid prompt":                           false,
+	}
+	for msg, want := range cases {
+		if got := RetryableError(errors.New(msg)); got != want {
+			t.Errorf("RetryableError(%q) = %v, want %v", msg, got, want)
+		}
+	}
+	if RetryableError(context.DeadlineExceeded) {
+		t.Error("deadline exceeded should not be retried")
+	}
+}
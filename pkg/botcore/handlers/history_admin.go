@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/internal/sqlutil"
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/IMBotPlatform/IMBotCore/pkg/command"
+	"github.com/spf13/cobra"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// SessionInfo 描述一个会话的历史概况。
+type SessionInfo struct {
+	Key          string    `json:"key"`
+	Messages     int       `json:"messages"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// HistoryAdmin 为支持管理操作的 History（MemoryHistory、SQLHistory、RedisHistory）实现，供运维迁移存储或清理会话。
+type HistoryAdmin interface {
+	// ListSessions 返回所有会话，按最后活动时间倒序。
+	ListSessions(ctx context.Context) ([]SessionInfo, error)
+	// SessionInfo 返回单个会话的概况，会话不存在时返回 false。
+	SessionInfo(ctx context.Context, key string) (SessionInfo, bool, error)
+	// Delete 删除会话的全部历史（含摘要）。
+	Delete(ctx context.Context, key string) error
+}
+
+var (
+	_ HistoryAdmin = (*MemoryHistory)(nil)
+	_ HistoryAdmin = (*SQLHistory)(nil)
+	_ HistoryAdmin = (*RedisHistory)(nil)
+)
+
+// exportLine 为 JSONL 导出格式中的一行。
+type exportLine struct {
+	Session string              `json:"session"`
+	Message llms.MessageContent `json:"message"`
+}
+
+// ExportJSONL 将会话历史以 JSONL 写入 w，每行一条消息 {"session": ..., "message": ...}。
+// Parameters:
+//   - w: 输出
+//   - history: 历史存储
+//   - keys: 要导出的会话
+//
+// Returns:
+//   - error: 编码或写入失败时返回
+func ExportJSONL(w io.Writer, history History, keys ...string) error {
+	enc := json.NewEncoder(w)
+	for _, key := range keys {
+		for _, msg := range history.Load(key) {
+			if err := enc.Encode(exportLine{Session: key, Message: msg}); err != nil {
+				return fmt.Errorf("export history: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// ImportJSONL 读取 ExportJSONL 的输出并追加到 history，可用于在不同存储间迁移。
+// 会话开头的系统消息视为摘要，目标实现 Compactor 时经 Compact 写入。
+// Parameters:
+//   - r: 输入
+//   - history: 目标历史存储
+//
+// Returns:
+//   - int: 导入的消息数
+//   - error: 解析失败时返回（已导入的消息不回滚）
+func ImportJSONL(r io.Reader, history History) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	compactor, canCompact := history.(Compactor)
+	seen := make(map[string]bool)
+	n := 0
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var item exportLine
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			return n, fmt.Errorf("import history line %d: %w", line, err)
+		}
+		first := !seen[item.Session]
+		seen[item.Session] = true
+		if first && canCompact && item.Message.Role == llms.ChatMessageTypeSystem {
+			compactor.Compact(item.Session, nil, item.Message)
+		} else {
+			history.Append(item.Session, item.Message)
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, fmt.Errorf("import history: %w", err)
+	}
+	return n, nil
+}
+
+// ListSessions 实现 HistoryAdmin 接口。
+func (h *MemoryHistory) ListSessions(ctx context.Context) ([]SessionInfo, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	infos := make([]SessionInfo, 0, len(h.sessions))
+	for key, msgs := range h.sessions {
+		infos = append(infos, SessionInfo{Key: key, Messages: len(msgs), LastActivity: h.updated[key]})
+	}
+	sortSessions(infos)
+	return infos, nil
+}
+
+// SessionInfo 实现 HistoryAdmin 接口。
+func (h *MemoryHistory) SessionInfo(ctx context.Context, key string) (SessionInfo, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	msgs, ok := h.sessions[key]
+	if !ok {
+		return SessionInfo{}, false, nil
+	}
+	return SessionInfo{Key: key, Messages: len(msgs), LastActivity: h.updated[key]}, true, nil
+}
+
+// Delete 实现 HistoryAdmin 接口。
+func (h *MemoryHistory) Delete(ctx context.Context, key string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sessions, key)
+	delete(h.updated, key)
+	return nil
+}
+
+// ListSessions 实现 HistoryAdmin 接口。
+func (h *SQLHistory) ListSessions(ctx context.Context) ([]SessionInfo, error) {
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT session_id, COUNT(*), MAX(created_at) FROM `+h.table+` GROUP BY session_id`)
+	if err != nil {
+		return nil, fmt.Errorf("list chat sessions: %w", err)
+	}
+	defer rows.Close()
+	var infos []SessionInfo
+	for rows.Next() {
+		info, err := scanSessionInfo(rows)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list chat sessions: %w", err)
+	}
+	sortSessions(infos)
+	return infos, nil
+}
+
+// SessionInfo 实现 HistoryAdmin 接口。
+func (h *SQLHistory) SessionInfo(ctx context.Context, key string) (SessionInfo, bool, error) {
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT session_id, COUNT(*), MAX(created_at) FROM `+h.table+` WHERE session_id = `+h.ph(1)+` GROUP BY session_id`, key)
+	if err != nil {
+		return SessionInfo{}, false, fmt.Errorf("read chat session: %w", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return SessionInfo{}, false, rows.Err()
+	}
+	info, err := scanSessionInfo(rows)
+	return info, err == nil, err
+}
+
+// Delete 实现 HistoryAdmin 接口。
+func (h *SQLHistory) Delete(ctx context.Context, key string) error {
+	if _, err := h.db.ExecContext(ctx, `DELETE FROM `+h.table+` WHERE session_id = `+h.ph(1), key); err != nil {
+		return fmt.Errorf("delete chat session: %w", err)
+	}
+	return nil
+}
+
+// scanSessionInfo 读取一行 (session_id, count, max(created_at))。
+func scanSessionInfo(rows *sql.Rows) (SessionInfo, error) {
+	var (
+		info   SessionInfo
+		latest any
+	)
+	if err := rows.Scan(&info.Key, &info.Messages, &latest); err != nil {
+		return SessionInfo{}, fmt.Errorf("scan chat session: %w", err)
+	}
+	t, err := sqlutil.ScanTime(latest)
+	if err != nil {
+		return SessionInfo{}, fmt.Errorf("scan chat session: %w", err)
+	}
+	info.LastActivity = t
+	return info, nil
+}
+
+// sortSessions 按最后活动时间倒序排列，时间相同时按键。
+func sortSessions(infos []SessionInfo) {
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].LastActivity.Equal(infos[j].LastActivity) {
+			return infos[i].LastActivity.After(infos[j].LastActivity)
+		}
+		return infos[i].Key < infos[j].Key
+	})
+}
+
+// /history 使用的消息键。
+const (
+	MsgHistoryEmpty     = "history.empty"     // 没有对话历史
+	MsgHistoryTruncated = "history.truncated" // 导出超出长度上限，参数：导出条数、总条数
+	MsgHistoryCleared   = "history.cleared"   // 对话历史已清空
+	MsgHistoryNoSession = "history.nosession" // 没有会话
+	MsgHistorySessions  = "history.sessions"  // 会话列表标题
+	MsgHistorySession   = "history.session"   // 会话列表项，参数：会话键、消息数、最后活动时间
+
+	MsgHistoryClearAction = "history.clear.action" // 演练模式下描述清空操作，参数：会话键
+)
+
+func init() {
+	botcore.Messages.Add("zh", map[string]string{
+		MsgHistoryEmpty:     "没有对话历史。",
+		MsgHistoryTruncated: "超出长度上限，仅导出最近 %d 条（共 %d 条）。",
+		MsgHistoryCleared:   "对话历史已清空。",
+		MsgHistoryNoSession: "没有会话。",
+		MsgHistorySessions:  "**会话列表**",
+		MsgHistorySession:   "- `%s` %d 条，最后活动 %s",
+
+		MsgHistoryClearAction: "清空会话 `%s` 的对话历史",
+	})
+	botcore.Messages.Add("en", map[string]string{
+		MsgHistoryEmpty:     "No chat history.",
+		MsgHistoryTruncated: "Too long to export in full; showing the latest %d of %d messages.",
+		MsgHistoryCleared:   "Chat history cleared.",
+		MsgHistoryNoSession: "No sessions.",
+		MsgHistorySessions:  "**Sessions**",
+		MsgHistorySession:   "- `%s` %d messages, last active %s",
+
+		MsgHistoryClearAction: "clear the history of session `%s`",
+	})
+}
+
+// historyExportLimit 为 /history export 回复中 JSONL 的字节上限（低于企业微信 Markdown 的 20480 字节限制），
+// 超出时只导出最近的消息。
+const historyExportLimit = 16 << 10
+
+// exportRecent 以 JSONL 导出会话中不超过 limit 字节的最近消息。
+// Returns:
+//   - string: JSONL 文本
+//   - int: 导出的消息数
+//   - int: 会话的消息总数
+//   - error: 编码失败时返回
+func exportRecent(history History, key string, limit int) (string, int, int, error) {
+	msgs := history.Load(key)
+	lines := make([]string, 0, len(msgs))
+	size := 0
+	// 关键步骤：从最新的消息向前累加，超出上限即停止。
+	for i := len(msgs) - 1; i >= 0; i-- {
+		encoded, err := json.Marshal(exportLine{Session: key, Message: msgs[i]})
+		if err != nil {
+			return "", 0, 0, fmt.Errorf("export history: %w", err)
+		}
+		if size+len(encoded)+1 > limit {
+			break
+		}
+		size += len(encoded) + 1
+		lines = append(lines, string(encoded))
+	}
+	var b strings.Builder
+	for i := len(lines) - 1; i >= 0; i-- {
+		b.WriteString(lines[i])
+		b.WriteByte('\n')
+	}
+	return b.String(), len(lines), len(msgs), nil
+}
+
+// HistoryCommand 返回管理对话历史的 /history 命令：
+//
+//	/history export    以 JSONL 导出自己的对话历史（超出 16KB 时只导出最近的消息）
+//	/history clear     清空自己的对话历史（需要 HistoryAdmin，演练模式下只提示）
+//	/history sessions  列出所有会话（需要 HistoryAdmin，仅 adminRoles 可用，默认 "admin"）
+//
+// Parameters:
+//   - history: 历史存储（与 NewLLMHandler 的 WithHistory 相同）
+//   - key: 会话键策略（与 NewLLMHandler 的 WithSessionKey 相同，nil 时为 ChatSenderKey）
+//   - adminRoles: 允许列出所有会话的角色
+//
+// Returns:
+//   - *cobra.Command: 可挂载到命令树的 /history 命令
+func HistoryCommand(history History, key botcore.KeyFunc, adminRoles ...string) *cobra.Command {
+	if key == nil {
+		key = ChatSenderKey
+	}
+	if len(adminRoles) == 0 {
+		adminRoles = []string{"admin"}
+	}
+	admin, _ := history.(HistoryAdmin)
+	sessionKey := func(cmd *cobra.Command) string {
+		return key(command.FromContext(cmd.Context()).RequestSnapshot)
+	}
+	localize := func(cmd *cobra.Command, msgKey string, args ...any) string {
+		return botcore.Localize(command.FromContext(cmd.Context()).RequestSnapshot, msgKey, args...)
+	}
+
+	root := &cobra.Command{Use: "history", Short: "管理对话历史"}
+	root.AddCommand(&cobra.Command{
+		Use:   "export",
+		Short: "以 JSONL 导出自己的对话历史",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jsonl, exported, total, err := exportRecent(history, sessionKey(cmd), historyExportLimit)
+			if err != nil {
+				return err
+			}
+			if total == 0 {
+				cmd.Print(localize(cmd, MsgHistoryEmpty))
+				return nil
+			}
+			cmd.Print("```jsonl\n" + jsonl + "```")
+			if exported < total {
+				cmd.Print("\n" + localize(cmd, MsgHistoryTruncated, exported, total))
+			}
+			return nil
+		},
+	})
+	if admin == nil {
+		return root
+	}
+	root.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "清空自己的对话历史",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if execCtx := command.FromContext(cmd.Context()); execCtx.DryRun() {
+				cmd.Print(execCtx.DryRunMessage(localize(cmd, MsgHistoryClearAction, sessionKey(cmd))))
+				return nil
+			}
+			if err := admin.Delete(cmd.Context(), sessionKey(cmd)); err != nil {
+				return err
+			}
+			cmd.Print(localize(cmd, MsgHistoryCleared))
+			return nil
+		},
+	})
+	root.AddCommand(command.RequireRoles(&cobra.Command{
+		Use:   "sessions",
+		Short: "列出所有会话",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			infos, err := admin.ListSessions(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if len(infos) == 0 {
+				cmd.Print(localize(cmd, MsgHistoryNoSession))
+				return nil
+			}
+			var b strings.Builder
+			b.WriteString(localize(cmd, MsgHistorySessions))
+			for _, info := range infos {
+				b.WriteString("\n" + localize(cmd, MsgHistorySession, info.Key, info.Messages, info.LastActivity.Local().Format("01-02 15:04:05")))
+			}
+			cmd.Print(b.String())
+			return nil
+		},
+	}, adminRoles...))
+	return root
+}
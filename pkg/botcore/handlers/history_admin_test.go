@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/IMBotPlatform/IMBotCore/pkg/command"
+	"github.com/spf13/cobra"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestExportImportJSONL(t *testing.T) {
+	source := NewMemoryHistory(0)
+	source.Append("c1:u1", llms.TextParts(llms.ChatMessageTypeHuman, "q1"), llms.TextParts(llms.ChatMessageTypeAI, "a1"))
+	source.Compact("c1:u1", nil, llms.TextParts(llms.ChatMessageTypeSystem, "summary"))
+	source.Append("c2:u2", llms.TextParts(llms.ChatMessageTypeHuman, "hello"))
+
+	var b strings.Builder
+	if err := ExportJSONL(&b, source, "c1:u1", "c2:u2"); err != nil {
+		t.Fatalf("ExportJSONL: %v", err)
+	}
+	if lines := strings.Count(b.String(), "\n"); lines != 4 {
+		t.Fatalf("expected 4 lines, got %d:\n%s", lines, b.String())
+	}
+
+	db, err := sql.Open("sqlite", t.TempDir()+"/history.db")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	target, err := NewSQLiteHistory(db, "")
+	if err != nil {
+		t.Fatalf("NewSQLiteHistory: %v", err)
+	}
+	if n, err := ImportJSONL(strings.NewReader(b.String()), target); err != nil || n != 4 {
+		t.Fatalf("ImportJSONL = %d, %v", n, err)
+	}
+	msgs := target.Load("c1:u1")
+	if len(msgs) != 3 || msgs[0].Role != llms.ChatMessageTypeSystem || msgs[2].Parts[0].(llms.TextContent).Text != "a1" {
+		t.Fatalf("unexpected imported history: %+v", msgs)
+	}
+
+	infos, err := target.ListSessions(context.Background())
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("ListSessions = %+v, %v", infos, err)
+	}
+	if info, ok, err := target.SessionInfo(context.Background(), "c2:u2"); err != nil || !ok || info.Messages != 1 || info.LastActivity.IsZero() {
+		t.Fatalf("SessionInfo = %+v, %v, %v", info, ok, err)
+	}
+	if _, err := ImportJSONL(strings.NewReader("not json\n"), target); err == nil {
+		t.Fatal("expected parse error")
+	}
+}
+
+func TestHistoryCommand(t *testing.T) {
+	history := NewMemoryHistory(0)
+	history.Append("c1:alice", llms.TextParts(llms.ChatMessageTypeHuman, "hi"))
+	history.Append("c1:bob", llms.TextParts(llms.ChatMessageTypeHuman, "yo"))
+	mgr := command.NewManager(func() *cobra.Command {
+		root := &cobra.Command{Use: "bot"}
+		root.AddCommand(HistoryCommand(history, nil))
+		return root
+	}, command.WithRoleProvider(command.StaticRoles{"bob": {"admin"}}))
+	run := func(sender, text string) string {
+		var out strings.Builder
+		snapshot := botcore.RequestSnapshot{ChatID: "c1", SenderID: sender, Text: text}
+		for chunk := range mgr.Trigger(botcore.PipelineContext{Snapshot: snapshot}) {
+			out.WriteString(chunk.Content)
+		}
+		return out.String()
+	}
+
+	if got := run("alice", "/history export"); !strings.Contains(got, `"session":"c1:alice"`) || strings.Contains(got, "c1:bob") {
+		t.Fatalf("unexpected export: %q", got)
+	}
+	if got := run("alice", "/history sessions"); !strings.Contains(got, "权限不足") {
+		t.Fatalf("expected sessions to require admin, got %q", got)
+	}
+	dryRun := command.NewManager(func() *cobra.Command {
+		root := &cobra.Command{Use: "bot"}
+		root.AddCommand(HistoryCommand(history, nil))
+		return root
+	}, command.WithDryRunFlag())
+	var out strings.Builder
+	for chunk := range dryRun.Trigger(botcore.PipelineContext{Snapshot: botcore.RequestSnapshot{ChatID: "c1", SenderID: "alice", Text: "/history clear --dry-run"}}) {
+		out.WriteString(chunk.Content)
+	}
+	if got := out.String(); got != "🧪 [演练] 将会：清空会话 `c1:alice` 的对话历史" || len(history.Load("c1:alice")) != 1 {
+		t.Fatalf("dry run should not clear history: %q", got)
+	}
+	if got := run("alice", "/history clear"); got != "对话历史已清空。" {
+		t.Fatalf("unexpected clear reply: %q", got)
+	}
+	if got := run("bob", "/history sessions"); !strings.Contains(got, "`c1:bob` 1 条") || strings.Contains(got, "alice") {
+		t.Fatalf("unexpected sessions: %q", got)
+	}
+	var en strings.Builder
+	snapshot := botcore.RequestSnapshot{ChatID: "c1", SenderID: "alice", Text: "/history export", Metadata: map[string]string{"lang": "en"}}
+	for chunk := range mgr.Trigger(botcore.PipelineContext{Snapshot: snapshot}) {
+		en.WriteString(chunk.Content)
+	}
+	if got := en.String(); got != "No chat history." {
+		t.Fatalf("expected localized reply, got %q", got)
+	}
+}
+
+func TestExportRecentCapsOutput(t *testing.T) {
+	history := NewMemoryHistory(0)
+	for i := 0; i < 50; i++ {
+		history.Append("c1:u1", llms.TextParts(llms.ChatMessageTypeHuman, strings.Repeat("x", 100)))
+	}
+	history.Append("c1:u1", llms.TextParts(llms.ChatMessageTypeHuman, "latest"))
+
+	jsonl, exported, total, err := exportRecent(history, "c1:u1", 1024)
+	if err != nil {
+		t.Fatalf("exportRecent: %v", err)
+	}
+	if len(jsonl) > 1024 || total != 51 || exported == 0 || exported >= total {
+		t.Fatalf("unexpected export: %d bytes, %d of %d", len(jsonl), exported, total)
+	}
+	if lines := strings.Split(strings.TrimSuffix(jsonl, "\n"), "\n"); len(lines) != exported || !strings.Contains(lines[len(lines)-1], "latest") {
+		t.Fatalf("expected the latest messages in order: %q", jsonl)
+	}
+}
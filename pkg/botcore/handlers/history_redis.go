@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// HistoryOption 自定义共享存储（RedisHistory、SQLHistory）的行为。
+type HistoryOption func(*historyConfig)
+
+type historyConfig struct {
+	maxMessages int
+	ttl         time.Duration
+	onError     func(err error)
+}
+
+// WithMaxMessages 设置每个会话保留的最大消息数（<=0 表示不限制），摘要消息不计入。
+func WithMaxMessages(n int) HistoryOption {
+	return func(c *historyConfig) {
+		c.maxMessages = n
+	}
+}
+
+// WithHistoryTTL 设置会话空闲过期时间（<=0 表示不过期），每次写入时刷新。
+func WithHistoryTTL(ttl time.Duration) HistoryOption {
+	return func(c *historyConfig) {
+		c.ttl = ttl
+	}
+}
+
+// WithHistoryErrorHandler 设置读写失败时的回调（默认忽略）。History 接口不返回错误，读取失败按空历史处理。
+func WithHistoryErrorHandler(fn func(err error)) HistoryOption {
+	return func(c *historyConfig) {
+		c.onError = fn
+	}
+}
+
+// newHistoryConfig 应用选项。
+func newHistoryConfig(opts []HistoryOption) historyConfig {
+	var cfg historyConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// report 报告读写错误。
+func (c historyConfig) report(err error) {
+	if err != nil && c.onError != nil {
+		c.onError(err)
+	}
+}
+
+const (
+	// redisHistoryLoadScript 以 {摘要, 消息列表} 返回会话历史，摘要不存在时为空字符串。
+	redisHistoryLoadScript = `local s = redis.call('GET', KEYS[2]) return {s or '', redis.call('LRANGE', KEYS[1], 0, -1)}`
+	// redisHistoryAppendScript 追加消息：ARGV[1] 为最大消息数，ARGV[2] 为过期毫秒数（0 表示不限制），
+	// ARGV[3] 为当前毫秒时间戳（记为最后活动时间），其余为消息。
+	redisHistoryAppendScript = `for i = 4, #ARGV do redis.call('RPUSH', KEYS[1], ARGV[i]) end
+if tonumber(ARGV[1]) > 0 then redis.call('LTRIM', KEYS[1], -tonumber(ARGV[1]), -1) end
+redis.call('SET', KEYS[3], ARGV[3])
+if tonumber(ARGV[2]) > 0 then for i = 1, 3 do redis.call('PEXPIRE', KEYS[i], ARGV[2]) end end
+return 1`
+	// redisHistoryCompactScript 以新摘要 ARGV[2] 替换已有摘要 ARGV[3]（空字符串表示没有摘要）与列表开头的 ARGV[4..]，
+	// ARGV[1] 为过期毫秒数；会话开头与预期不一致（已被并发修改）时不做修改并返回 0。
+	redisHistoryCompactScript = `if (redis.call('GET', KEYS[2]) or '') ~= ARGV[3] then return 0 end
+local n = #ARGV - 3
+if n > 0 then
+  local items = redis.call('LRANGE', KEYS[1], 0, n - 1)
+  if #items ~= n then return 0 end
+  for i = 1, n do if items[i] ~= ARGV[i + 3] then return 0 end end
+  redis.call('LTRIM', KEYS[1], n, -1)
+end
+if tonumber(ARGV[1]) > 0 then redis.call('SET', KEYS[2], ARGV[2], 'PX', ARGV[1]) redis.call('PEXPIRE', KEYS[1], ARGV[1]) else redis.call('SET', KEYS[2], ARGV[2]) end
+return 1`
+	// redisHistoryInfoScript 以 {消息数（含摘要）, 最后活动毫秒时间戳} 返回会话概况。
+	redisHistoryInfoScript = `return {redis.call('LLEN', KEYS[1]) + redis.call('EXISTS', KEYS[2]), redis.call('GET', KEYS[3]) or '0'}`
+	// redisHistoryDeleteScript 删除会话的消息列表、摘要与活动时间。
+	redisHistoryDeleteScript = `return redis.call('DEL', KEYS[1], KEYS[2], KEYS[3])`
+	// redisHistoryScanScript 执行一轮 SCAN（ARGV[1] 游标、ARGV[2] 匹配模式、ARGV[3] 批量），
+	// 只保留列表类型的会话键，以 {下一游标, {键, 消息数, 最后活动毫秒时间戳, ...}} 返回。
+	redisHistoryScanScript = `local r = redis.call('SCAN', ARGV[1], 'MATCH', ARGV[2], 'COUNT', ARGV[3])
+local out = {}
+for _, k in ipairs(r[2]) do
+  if redis.call('TYPE', k).ok == 'list' then
+    table.insert(out, k)
+    table.insert(out, redis.call('LLEN', k) + redis.call('EXISTS', k .. ':summary'))
+    table.insert(out, redis.call('GET', k .. ':updated') or '0')
+  end
+end
+return {r[1], out}`
+)
+
+// redisScanCount 为 ListSessions 每轮 SCAN 的建议批量。
+const redisScanCount = 500
+
+// RedisHistory 是基于 Redis 的 History 实现，每个会话为一个列表（摘要另存一个键），在所有副本间共享，适合多实例部署。
+type RedisHistory struct {
+	eval   botcore.RedisEval
+	prefix string
+	cfg    historyConfig
+}
+
+var (
+	_ History   = (*RedisHistory)(nil)
+	_ Compactor = (*RedisHistory)(nil)
+)
+
+// NewRedisHistory 创建 Redis 历史存储。
+// Parameters:
+//   - eval: Redis EVAL 执行函数（见 botcore.RedisEval）
+//   - prefix: 键前缀（可为空），用于与其他业务隔离
+//   - opts: 可选配置（最大消息数、过期时间、错误回调）
+//
+// Returns:
+//   - *RedisHistory: 历史存储
+func NewRedisHistory(eval botcore.RedisEval, prefix string, opts ...HistoryOption) *RedisHistory {
+	return &RedisHistory{eval: eval, prefix: prefix, cfg: newHistoryConfig(opts)}
+}
+
+// keys 返回会话的消息列表键、摘要键与最后活动时间键。
+func (h *RedisHistory) keys(key string) []string {
+	return []string{h.prefix + key, h.prefix + key + ":summary", h.prefix + key + ":updated"}
+}
+
+// Load 实现 History 接口。
+func (h *RedisHistory) Load(key string) []llms.MessageContent {
+	msgs, err := h.load(context.Background(), key)
+	h.cfg.report(err)
+	return msgs
+}
+
+// load 读取并解码会话历史。
+func (h *RedisHistory) load(ctx context.Context, key string) ([]llms.MessageContent, error) {
+	res, err := h.eval(ctx, redisHistoryLoadScript, h.keys(key))
+	if err != nil {
+		return nil, fmt.Errorf("redis history: %w", err)
+	}
+	values, ok := res.([]any)
+	if !ok || len(values) != 2 {
+		return nil, fmt.Errorf("redis history: unexpected result %v", res)
+	}
+	items, _ := values[1].([]any)
+	msgs := make([]llms.MessageContent, 0, len(items)+1)
+	if summary, _ := values[0].(string); summary != "" {
+		items = append([]any{summary}, items...)
+	}
+	for _, item := range items {
+		encoded, _ := item.(string)
+		var msg llms.MessageContent
+		if err := json.Unmarshal([]byte(encoded), &msg); err != nil {
+			return nil, fmt.Errorf("redis history: decode message: %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// Append 实现 History 接口。
+func (h *RedisHistory) Append(key string, messages ...llms.MessageContent) {
+	args := []any{int64(h.cfg.maxMessages), redisTTL(h.cfg.ttl), time.Now().UnixMilli()}
+	for _, msg := range messages {
+		encoded, err := json.Marshal(msg)
+		if err != nil {
+			h.cfg.report(fmt.Errorf("redis history: encode message: %w", err))
+			return
+		}
+		args = append(args, string(encoded))
+	}
+	if _, err := h.eval(context.Background(), redisHistoryAppendScript, h.keys(key), args...); err != nil {
+		h.cfg.report(fmt.Errorf("redis history: %w", err))
+	}
+}
+
+// Compact 实现 Compactor 接口：在脚本内原子地比较会话开头与 replaced（按 JSON 编码），一致时才替换。
+func (h *RedisHistory) Compact(key string, replaced []llms.MessageContent, summary llms.MessageContent) bool {
+	encoded, err := json.Marshal(summary)
+	if err != nil {
+		h.cfg.report(fmt.Errorf("redis history: encode summary: %w", err))
+		return false
+	}
+	args := []any{redisTTL(h.cfg.ttl), string(encoded), ""}
+	// 关键步骤：load 把摘要放在最前，开头的系统消息对应摘要键，其余对应列表开头。
+	for i, msg := range replaced {
+		item, err := json.Marshal(msg)
+		if err != nil {
+			h.cfg.report(fmt.Errorf("redis history: encode message: %w", err))
+			return false
+		}
+		if i == 0 && msg.Role == llms.ChatMessageTypeSystem {
+			args[2] = string(item)
+			continue
+		}
+		args = append(args, string(item))
+	}
+	res, err := h.eval(context.Background(), redisHistoryCompactScript, h.keys(key), args...)
+	if err != nil {
+		h.cfg.report(fmt.Errorf("redis history: %w", err))
+		return false
+	}
+	compacted, _ := res.(int64)
+	return compacted == 1
+}
+
+// ListSessions 实现 HistoryAdmin 接口：以 SCAN 分批遍历前缀下的会话列表键，不会长时间阻塞 Redis。
+// 最后活动时间由 Append 记录，早于该记录写入的会话为零值。
+func (h *RedisHistory) ListSessions(ctx context.Context) ([]SessionInfo, error) {
+	pattern := redisGlobEscape(h.prefix) + "*"
+	var infos []SessionInfo
+	cursor := "0"
+	for {
+		res, err := h.eval(ctx, redisHistoryScanScript, nil, cursor, pattern, int64(redisScanCount))
+		if err != nil {
+			return nil, fmt.Errorf("redis history: list sessions: %w", err)
+		}
+		values, ok := res.([]any)
+		if !ok || len(values) != 2 {
+			return nil, fmt.Errorf("redis history: unexpected result %v", res)
+		}
+		cursor, _ = values[0].(string)
+		items, _ := values[1].([]any)
+		for i := 0; i+2 < len(items); i += 3 {
+			key, _ := items[i].(string)
+			info, err := redisSessionInfo(strings.TrimPrefix(key, h.prefix), items[i+1], items[i+2])
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+		}
+		if cursor == "0" || cursor == "" {
+			break
+		}
+	}
+	sortSessions(infos)
+	return infos, nil
+}
+
+// SessionInfo 实现 HistoryAdmin 接口。
+func (h *RedisHistory) SessionInfo(ctx context.Context, key string) (SessionInfo, bool, error) {
+	res, err := h.eval(ctx, redisHistoryInfoScript, h.keys(key))
+	if err != nil {
+		return SessionInfo{}, false, fmt.Errorf("redis history: %w", err)
+	}
+	values, ok := res.([]any)
+	if !ok || len(values) != 2 {
+		return SessionInfo{}, false, fmt.Errorf("redis history: unexpected result %v", res)
+	}
+	info, err := redisSessionInfo(key, values[0], values[1])
+	if err != nil || info.Messages == 0 {
+		return SessionInfo{}, false, err
+	}
+	return info, true, nil
+}
+
+// Delete 实现 HistoryAdmin 接口。
+func (h *RedisHistory) Delete(ctx context.Context, key string) error {
+	if _, err := h.eval(ctx, redisHistoryDeleteScript, h.keys(key)); err != nil {
+		return fmt.Errorf("redis history: %w", err)
+	}
+	return nil
+}
+
+// redisSessionInfo 解码脚本返回的消息数与最后活动毫秒时间戳。
+func redisSessionInfo(key string, count, updated any) (SessionInfo, error) {
+	n, ok := count.(int64)
+	if !ok {
+		return SessionInfo{}, fmt.Errorf("redis history: unexpected count %v", count)
+	}
+	info := SessionInfo{Key: key, Messages: int(n)}
+	encoded, _ := updated.(string)
+	ms, err := strconv.ParseInt(encoded, 10, 64)
+	if err != nil {
+		return SessionInfo{}, fmt.Errorf("redis history: decode activity: %w", err)
+	}
+	if ms > 0 {
+		info.LastActivity = time.UnixMilli(ms)
+	}
+	return info, nil
+}
+
+// redisGlobEscape 转义 SCAN MATCH 模式中的通配符。
+func redisGlobEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// redisTTL 将过期时间转换为毫秒（0 表示不过期）。
+func redisTTL(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	return max(ttl.Milliseconds(), 1)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// fakeRedis 以内存模拟 RedisHistory 使用的脚本。
+type fakeRedis struct {
+	lists   map[string][]string
+	strings map[string]string
+	ttls    map[string]int64
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{lists: map[string][]string{}, strings: map[string]string{}, ttls: map[string]int64{}}
+}
+
+func (r *fakeRedis) eval(_ context.Context, script string, keys []string, args ...any) (any, error) {
+	if script == redisHistoryScanScript {
+		// 单轮返回全部匹配键，模式仅支持 "<前缀>*"。
+		prefix := strings.TrimSuffix(args[1].(string), "*")
+		var out []any
+		for key, items := range r.lists {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			n := int64(len(items))
+			if _, ok := r.strings[key+":summary"]; ok {
+				n++
+			}
+			out = append(out, key, n, r.strings[key+":updated"])
+		}
+		return []any{"0", out}, nil
+	}
+	list, summary := keys[0], keys[1]
+	switch script {
+	case redisHistoryLoadScript:
+		items := make([]any, 0, len(r.lists[list]))
+		for _, item := range r.lists[list] {
+			items = append(items, item)
+		}
+		return []any{r.strings[summary], items}, nil
+	case redisHistoryAppendScript:
+		for _, arg := range args[3:] {
+			r.lists[list] = append(r.lists[list], arg.(string))
+		}
+		r.strings[keys[2]] = strconv.FormatInt(args[2].(int64), 10)
+		if n := int(args[0].(int64)); n > 0 && len(r.lists[list]) > n {
+			r.lists[list] = r.lists[list][len(r.lists[list])-n:]
+		}
+		r.ttls[list] = args[1].(int64)
+	case redisHistoryCompactScript:
+		if r.strings[summary] != args[2].(string) {
+			return int64(0), nil
+		}
+		expected := args[3:]
+		if len(r.lists[list]) < len(expected) {
+			return int64(0), nil
+		}
+		for i, item := range expected {
+			if r.lists[list][i] != item.(string) {
+				return int64(0), nil
+			}
+		}
+		r.lists[list] = r.lists[list][len(expected):]
+		r.strings[summary] = args[1].(string)
+	case redisHistoryInfoScript:
+		n := int64(len(r.lists[list]))
+		if _, ok := r.strings[summary]; ok {
+			n++
+		}
+		updated, ok := r.strings[keys[2]]
+		if !ok {
+			updated = "0"
+		}
+		return []any{n, updated}, nil
+	case redisHistoryDeleteScript:
+		delete(r.lists, list)
+		delete(r.strings, summary)
+		delete(r.strings, keys[2])
+	}
+	return int64(1), nil
+}
+
+func TestRedisHistory(t *testing.T) {
+	redis := newFakeRedis()
+	history := NewRedisHistory(redis.eval, "bot:", WithMaxMessages(4), WithHistoryTTL(time.Hour))
+
+	for _, text := range []string{"q1", "a1", "q2", "a2", "q3"} {
+		role := llms.ChatMessageTypeHuman
+		if text[0] == 'a' {
+			role = llms.ChatMessageTypeAI
+		}
+		history.Append("c1:u1", llms.TextParts(role, text))
+	}
+	msgs := history.Load("c1:u1")
+	if len(msgs) != 4 || msgs[0].Parts[0].(llms.TextContent).Text != "a1" || msgs[3].Role != llms.ChatMessageTypeHuman {
+		t.Fatalf("unexpected history: %+v", msgs)
+	}
+	if redis.ttls["bot:c1:u1"] != time.Hour.Milliseconds() {
+		t.Fatalf("expected ttl to be refreshed, got %v", redis.ttls)
+	}
+
+	if !history.Compact("c1:u1", msgs[:2], llms.TextParts(llms.ChatMessageTypeSystem, "summary")) {
+		t.Fatal("expected compaction to apply")
+	}
+	if history.Compact("c1:u1", msgs[:2], llms.TextParts(llms.ChatMessageTypeSystem, "stale")) {
+		t.Fatal("expected a stale compaction to be rejected")
+	}
+	msgs = history.Load("c1:u1")
+	if len(msgs) != 3 || msgs[0].Role != llms.ChatMessageTypeSystem || msgs[1].Parts[0].(llms.TextContent).Text != "a2" {
+		t.Fatalf("unexpected compacted history: %+v", msgs)
+	}
+	// 再次压缩时 replaced 包含已有摘要。
+	if !history.Compact("c1:u1", msgs[:2], llms.TextParts(llms.ChatMessageTypeSystem, "summary2")) {
+		t.Fatal("expected recompaction to apply")
+	}
+	msgs = history.Load("c1:u1")
+	if len(msgs) != 2 || msgs[0].Parts[0].(llms.TextContent).Text != "summary2" || msgs[1].Parts[0].(llms.TextContent).Text != "q3" {
+		t.Fatalf("unexpected recompacted history: %+v", msgs)
+	}
+}
+
+func TestRedisHistoryAdmin(t *testing.T) {
+	redis := newFakeRedis()
+	history := NewRedisHistory(redis.eval, "bot:")
+	history.Append("c1:u1", llms.TextParts(llms.ChatMessageTypeHuman, "q1"), llms.TextParts(llms.ChatMessageTypeAI, "a1"))
+	history.Append("c2:u2", llms.TextParts(llms.ChatMessageTypeHuman, "hello"))
+	redis.lists["other:c3"] = []string{"{}"}
+
+	infos, err := history.ListSessions(context.Background())
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("ListSessions = %+v, %v", infos, err)
+	}
+	for _, info := range infos {
+		if info.LastActivity.IsZero() || (info.Key != "c1:u1" && info.Key != "c2:u2") {
+			t.Fatalf("unexpected session: %+v", info)
+		}
+	}
+	info, ok, err := history.SessionInfo(context.Background(), "c1:u1")
+	if err != nil || !ok || info.Messages != 2 {
+		t.Fatalf("SessionInfo = %+v, %v, %v", info, ok, err)
+	}
+	if err := history.Delete(context.Background(), "c1:u1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok, err := history.SessionInfo(context.Background(), "c1:u1"); err != nil || ok {
+		t.Fatalf("expected deleted session to be gone: %v %v", ok, err)
+	}
+	if got := redisGlobEscape("a*b?[c]"); got != `a\*b\?\[c\]` {
+		t.Fatalf("unexpected escape: %q", got)
+	}
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/internal/sqlutil"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// roleSummary 为摘要消息（见 Compactor）在 role 列中的取值，读取时排在最前。
+const roleSummary = "summary"
+
+// sqlHistoryMigrations 为历史表的版本化迁移（见 sqlutil.Migrate）。
+var sqlHistoryMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS {table} (
+		id {id},
+		session_id VARCHAR(255) NOT NULL,
+		role VARCHAR(32) NOT NULL,
+		content TEXT NOT NULL,
+		created_at {time} NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS {table}_session_id ON {table} (session_id, id)`,
+	`CREATE INDEX IF NOT EXISTS {table}_created_at ON {table} (created_at)`,
+}
+
+// SQLHistory 是基于 database/sql 的 History 实现（需调用方导入驱动）：每条消息一行，
+// 带会话 ID、角色与时间列，可直接查询统计，进程重启后保留。
+type SQLHistory struct {
+	db      *sql.DB
+	table   string
+	dialect sqlutil.Dialect
+	cfg     historyConfig
+	now     func() time.Time
+}
+
+var (
+	_ History   = (*SQLHistory)(nil)
+	_ Compactor = (*SQLHistory)(nil)
+)
+
+// NewSQLiteHistory 创建 SQLite 历史存储并执行尚未应用的迁移。
+// Parameters:
+//   - db: 已打开的数据库连接
+//   - table: 表名；为空时使用 "chat_history"
+//   - opts: 可选配置（最大消息数、空闲过期时间、错误回调）
+//
+// Returns:
+//   - *SQLHistory: 历史存储
+//   - error: 表名非法或迁移失败时返回
+func NewSQLiteHistory(db *sql.DB, table string, opts ...HistoryOption) (*SQLHistory, error) {
+	return newSQLHistory(db, table, sqlutil.SQLite, opts)
+}
+
+// NewPostgresHistory 创建 Postgres 历史存储并执行尚未应用的迁移（使用 $n 占位符，时间列为 TIMESTAMPTZ）。
+// Parameters:
+//   - db: 已打开的数据库连接（如 pgx/stdlib、lib/pq）
+//   - table: 表名；为空时使用 "chat_history"
+//   - opts: 可选配置（最大消息数、空闲过期时间、错误回调）
+//
+// Returns:
+//   - *SQLHistory: 历史存储
+//   - error: 表名非法或迁移失败时返回
+func NewPostgresHistory(db *sql.DB, table string, opts ...HistoryOption) (*SQLHistory, error) {
+	return newSQLHistory(db, table, sqlutil.Postgres, opts)
+}
+
+// newSQLHistory 校验表名并执行迁移。
+func newSQLHistory(db *sql.DB, table string, d sqlutil.Dialect, opts []HistoryOption) (*SQLHistory, error) {
+	table, err := sqlutil.TableName(table, "chat_history")
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlutil.Migrate(context.Background(), db, d, table, sqlHistoryMigrations); err != nil {
+		return nil, err
+	}
+	return &SQLHistory{db: db, table: table, dialect: d, cfg: newHistoryConfig(opts), now: time.Now}, nil
+}
+
+// ph 返回第 i 个占位符。
+func (h *SQLHistory) ph(i int) string {
+	return h.dialect.Placeholder(i)
+}
+
+// Load 实现 History 接口；配置了 WithHistoryTTL 且会话最后一条消息已超过空闲时间时删除该会话并返回空历史。
+func (h *SQLHistory) Load(key string) []llms.MessageContent {
+	msgs, err := h.load(context.Background(), key)
+	h.cfg.report(err)
+	return msgs
+}
+
+// load 读取会话历史：摘要在前，其余按写入顺序。
+func (h *SQLHistory) load(ctx context.Context, key string) ([]llms.MessageContent, error) {
+	rows, err := h.db.QueryContext(ctx,
+		`SELECT content, created_at FROM `+h.table+` WHERE session_id = `+h.ph(1)+`
+		ORDER BY CASE WHEN role = '`+roleSummary+`' THEN 0 ELSE 1 END, id`, key)
+	if err != nil {
+		return nil, fmt.Errorf("load chat history: %w", err)
+	}
+	defer rows.Close()
+	var (
+		msgs   []llms.MessageContent
+		latest time.Time
+	)
+	for rows.Next() {
+		var (
+			content   string
+			createdAt any
+		)
+		if err := rows.Scan(&content, &createdAt); err != nil {
+			return nil, fmt.Errorf("load chat history: %w", err)
+		}
+		var msg llms.MessageContent
+		if err := json.Unmarshal([]byte(content), &msg); err != nil {
+			return nil, fmt.Errorf("decode chat history: %w", err)
+		}
+		msgs = append(msgs, msg)
+		if t, err := sqlutil.ScanTime(createdAt); err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("load chat history: %w", err)
+	}
+	if h.cfg.ttl > 0 && len(msgs) > 0 && h.now().Sub(latest) > h.cfg.ttl {
+		// 关键步骤：过期会话立即删除，否则下一次 Append 写入新消息后旧消息会重新可见。
+		if _, err := h.expire(ctx, h.db, key); err != nil {
+			return nil, fmt.Errorf("expire chat history: %w", err)
+		}
+		return nil, nil
+	}
+	return msgs, nil
+}
+
+// execer 为 *sql.DB 与 *sql.Tx 的公共方法。
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// expire 在会话空闲超过 WithHistoryTTL 时删除其全部消息（含摘要），未配置过期时间时不做任何事。
+func (h *SQLHistory) expire(ctx context.Context, db execer, key string) (int64, error) {
+	if h.cfg.ttl <= 0 {
+		return 0, nil
+	}
+	res, err := db.ExecContext(ctx, `DELETE FROM `+h.table+` WHERE session_id = `+h.ph(1)+` AND (
+		SELECT MAX(created_at) FROM `+h.table+` WHERE session_id = `+h.ph(2)+`) < `+h.ph(3),
+		key, key, h.dialect.TimeValue(h.now().Add(-h.cfg.ttl)))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Append 实现 History 接口：先删除已空闲过期的会话，配置了 WithMaxMessages 时再删除超出上限的最早消息（摘要除外）。
+func (h *SQLHistory) Append(key string, messages ...llms.MessageContent) {
+	ctx := context.Background()
+	h.cfg.report(h.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := h.expire(ctx, tx, key); err != nil {
+			return err
+		}
+		now := h.dialect.TimeValue(h.now())
+		for _, msg := range messages {
+			if err := h.insert(tx, key, string(msg.Role), msg, now); err != nil {
+				return err
+			}
+		}
+		if h.cfg.maxMessages <= 0 {
+			return nil
+		}
+		_, err := tx.Exec(`DELETE FROM `+h.table+` WHERE session_id = `+h.ph(1)+` AND role <> '`+roleSummary+`' AND id NOT IN (
+			SELECT id FROM `+h.table+` WHERE session_id = `+h.ph(2)+` AND role <> '`+roleSummary+`' ORDER BY id DESC LIMIT `+h.ph(3)+`)`,
+			key, key, h.cfg.maxMessages)
+		return err
+	}))
+}
+
+// errStaleCompaction 表示会话开头在读取后已被并发修改，放弃本次压缩。
+var errStaleCompaction = errors.New("chat history changed since it was loaded")
+
+// Compact 实现 Compactor 接口：按读取时校验过的 id 删除被替换的消息，其中任一行已被并发删除时回滚。
+func (h *SQLHistory) Compact(key string, replaced []llms.MessageContent, summary llms.MessageContent) bool {
+	ctx := context.Background()
+	err := h.inTx(ctx, func(tx *sql.Tx) error {
+		ids, err := h.prefixIDs(ctx, tx, key, replaced)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			res, err := tx.ExecContext(ctx, `DELETE FROM `+h.table+` WHERE id = `+h.ph(1), id)
+			if err != nil {
+				return err
+			}
+			if n, err := res.RowsAffected(); err == nil && n != 1 {
+				return errStaleCompaction
+			}
+		}
+		return h.insert(tx, key, roleSummary, summary, h.dialect.TimeValue(h.now()))
+	})
+	if errors.Is(err, errStaleCompaction) {
+		return false
+	}
+	h.cfg.report(err)
+	return err == nil
+}
+
+// prefixIDs 返回会话开头（摘要在前）与 replaced 逐条相同的消息 id，不一致时返回 errStaleCompaction。
+func (h *SQLHistory) prefixIDs(ctx context.Context, tx *sql.Tx, key string, replaced []llms.MessageContent) ([]int64, error) {
+	if len(replaced) == 0 {
+		return nil, nil
+	}
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, content FROM `+h.table+` WHERE session_id = `+h.ph(1)+`
+		ORDER BY CASE WHEN role = '`+roleSummary+`' THEN 0 ELSE 1 END, id LIMIT `+h.ph(2), key, len(replaced))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make([]int64, 0, len(replaced))
+	for rows.Next() {
+		var (
+			id      int64
+			content string
+			msg     llms.MessageContent
+		)
+		if err := rows.Scan(&id, &content); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(content), &msg); err != nil {
+			return nil, fmt.Errorf("decode chat history: %w", err)
+		}
+		if !sameMessage(msg, replaced[len(ids)]) {
+			return nil, errStaleCompaction
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) != len(replaced) {
+		return nil, errStaleCompaction
+	}
+	return ids, nil
+}
+
+// PurgeExpired 删除空闲超过 WithHistoryTTL 的会话，未配置过期时间时不做任何事。
+// Returns:
+//   - int64: 删除的消息数
+//   - error: 执行失败时返回
+func (h *SQLHistory) PurgeExpired(ctx context.Context) (int64, error) {
+	if h.cfg.ttl <= 0 {
+		return 0, nil
+	}
+	res, err := h.db.ExecContext(ctx, `DELETE FROM `+h.table+` WHERE session_id IN (
+		SELECT session_id FROM `+h.table+` GROUP BY session_id HAVING MAX(created_at) < `+h.ph(1)+`)`,
+		h.dialect.TimeValue(h.now().Add(-h.cfg.ttl)))
+	if err != nil {
+		return 0, fmt.Errorf("purge chat history: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// insert 写入一条消息。
+func (h *SQLHistory) insert(tx *sql.Tx, key, role string, msg llms.MessageContent, createdAt any) error {
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("encode chat message: %w", err)
+	}
+	_, err = tx.Exec(`INSERT INTO `+h.table+` (session_id, role, content, created_at) VALUES (`+
+		h.ph(1)+`, `+h.ph(2)+`, `+h.ph(3)+`, `+h.ph(4)+`)`, key, role, string(encoded), createdAt)
+	return err
+}
+
+// inTx 在事务中执行 fn。
+func (h *SQLHistory) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("save chat history: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("save chat history: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("save chat history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLHistory(t *testing.T) {
+	db, err := sql.Open("sqlite", t.TempDir()+"/history.db")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := NewSQLiteHistory(db, "", WithMaxMessages(3)); err != nil {
+		t.Fatalf("NewSQLiteHistory: %v", err)
+	}
+	// 重复创建不应重复执行迁移。
+	history, err := NewSQLiteHistory(db, "", WithMaxMessages(3), WithHistoryTTL(time.Hour),
+		WithHistoryErrorHandler(func(err error) { t.Errorf("history error: %v", err) }))
+	if err != nil {
+		t.Fatalf("NewSQLiteHistory again: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	history.now = func() time.Time { return now }
+
+	history.Append("s1", llms.TextParts(llms.ChatMessageTypeHuman, "q1"), llms.TextParts(llms.ChatMessageTypeAI, "a1"))
+	history.Append("s1", llms.TextParts(llms.ChatMessageTypeHuman, "q2"), llms.TextParts(llms.ChatMessageTypeAI, "a2"))
+	history.Append("s2", llms.TextParts(llms.ChatMessageTypeHuman, "other"))
+
+	msgs := history.Load("s1")
+	if len(msgs) != 3 || msgs[0].Parts[0].(llms.TextContent).Text != "a1" {
+		t.Fatalf("expected oldest message to be capped, got %+v", msgs)
+	}
+	var roles int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM chat_history WHERE session_id = 's1' AND role = 'ai'`).Scan(&roles); err != nil || roles != 2 {
+		t.Fatalf("expected role column to be queryable, got %d, %v", roles, err)
+	}
+
+	// 读取后被 WithMaxMessages 裁剪过的前缀不再匹配，压缩放弃。
+	if history.Compact("s1", history.Load("s2"), llms.TextParts(llms.ChatMessageTypeSystem, "stale")) {
+		t.Fatal("expected compaction with a mismatched prefix to be rejected")
+	}
+	if !history.Compact("s1", msgs[:2], llms.TextParts(llms.ChatMessageTypeSystem, "summary")) {
+		t.Fatal("expected compaction to apply")
+	}
+	if history.Compact("s1", msgs[:2], llms.TextParts(llms.ChatMessageTypeSystem, "again")) {
+		t.Fatal("expected a second compaction of the same prefix to be rejected")
+	}
+	history.Append("s1", llms.TextParts(llms.ChatMessageTypeHuman, "q3"), llms.TextParts(llms.ChatMessageTypeAI, "a3"))
+	msgs = history.Load("s1")
+	if len(msgs) != 4 || msgs[0].Role != llms.ChatMessageTypeSystem || msgs[1].Parts[0].(llms.TextContent).Text != "a2" {
+		t.Fatalf("expected summary to stay pinned, got %+v", msgs)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if msgs := history.Load("s1"); len(msgs) != 0 {
+		t.Fatalf("expected idle session to expire, got %d messages", len(msgs))
+	}
+	// 过期会话在读取时删除，之后追加的消息不会让旧消息重新可见。
+	history.Append("s1", llms.TextParts(llms.ChatMessageTypeHuman, "fresh"))
+	if msgs := history.Load("s1"); len(msgs) != 1 || msgs[0].Parts[0].(llms.TextContent).Text != "fresh" {
+		t.Fatalf("expected only the new message after expiry, got %+v", msgs)
+	}
+	// 未经读取的过期会话在追加时同样先被清空。
+	history.Append("s2", llms.TextParts(llms.ChatMessageTypeHuman, "again"))
+	if msgs := history.Load("s2"); len(msgs) != 1 || msgs[0].Parts[0].(llms.TextContent).Text != "again" {
+		t.Fatalf("expected append to drop the expired session, got %+v", msgs)
+	}
+	history.Append("s3", llms.TextParts(llms.ChatMessageTypeHuman, "idle"))
+	now = now.Add(2 * time.Hour)
+	if n, err := history.PurgeExpired(context.Background()); err != nil || n != 3 {
+		t.Fatalf("PurgeExpired = %d, %v", n, err)
+	}
+
+	if _, err := NewSQLiteHistory(db, "bad;name"); err == nil {
+		t.Fatal("expected invalid table name error")
+	}
+}
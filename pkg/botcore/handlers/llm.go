@@ -0,0 +1,307 @@
+// Package handlers 提供开箱即用的 botcore.PipelineInvoker 实现。
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// History 按会话键保存多轮对话历史。
+type History interface {
+	// Load 读取会话历史（按时间顺序）。
+	Load(key string) []llms.MessageContent
+	// Append 追加一轮或多轮消息。
+	Append(key string, messages ...llms.MessageContent)
+}
+
+// Moderator 在调用 LLM 前审核用户输入；返回错误表示拒绝处理。
+type Moderator func(ctx context.Context, text string) error
+
+// LLMOption 自定义 NewLLMHandler 行为。
+type LLMOption func(*llmConfig)
+
+type llmConfig struct {
+	systemPrompt string
+	history      History
+	sessionKey   botcore.KeyFunc
+	moderator    Moderator
+	errorMessage func(err error) string
+	callOptions  []llms.CallOption
+	tracer       botcore.Tracer
+	budget       *TokenBudget
+	summarizer   *Summarizer
+	images       bool
+	maxImages    int
+}
+
+// WithSystemPrompt 设置系统提示词。
+func WithSystemPrompt(prompt string) LLMOption {
+	return func(c *llmConfig) {
+		c.systemPrompt = prompt
+	}
+}
+
+// WithHistory 启用多轮对话历史；为 nil 时每条消息独立对话。
+func WithHistory(history History) LLMOption {
+	return func(c *llmConfig) {
+		c.history = history
+	}
+}
+
+// WithSessionKey 自定义会话键策略（默认 ChatSenderKey，即 chatID:senderID）。
+func WithSessionKey(key botcore.KeyFunc) LLMOption {
+	return func(c *llmConfig) {
+		if key != nil {
+			c.sessionKey = key
+		}
+	}
+}
+
+// WithModerator 设置输入审核器。
+func WithModerator(m Moderator) LLMOption {
+	return func(c *llmConfig) {
+		c.moderator = m
+	}
+}
+
+// WithErrorMessage 自定义错误到用户提示的映射。
+// 默认只回复本地化的通用提示（MsgLLMUnavailable），不向用户暴露上游错误；错误本身经结束包的 Err
+// 交给平台适配层记录日志并触发 OnError 钩子。
+func WithErrorMessage(fn func(err error) string) LLMOption {
+	return func(c *llmConfig) {
+		if fn != nil {
+			c.errorMessage = fn
+		}
+	}
+}
+
+// WithCallOptions 追加每次调用模型时使用的 llms.CallOption。
+func WithCallOptions(opts ...llms.CallOption) LLMOption {
+	return func(c *llmConfig) {
+		c.callOptions = append(c.callOptions, opts...)
+	}
+}
+
+// WithTracer 为每次模型调用创建 "llm.generate" span（父级为 PipelineContext.Context()）。
+func WithTracer(tracer botcore.Tracer) LLMOption {
+	return func(c *llmConfig) {
+		c.tracer = tracer
+	}
+}
+
+// ChatSenderKey 以 chatID:senderID 作为会话键。
+func ChatSenderKey(snapshot botcore.RequestSnapshot) string {
+	return snapshot.ChatID + ":" + snapshot.SenderID
+}
+
+// MsgLLMUnavailable 为模型调用失败时的默认提示，不含错误详情。
+const MsgLLMUnavailable = "llm.unavailable"
+
+func init() {
+	botcore.Messages.Add("zh", map[string]string{
+		MsgLLMUnavailable: "❌ AI 服务暂时不可用，请稍后再试。",
+	})
+	botcore.Messages.Add("en", map[string]string{
+		MsgLLMUnavailable: "❌ The AI service is temporarily unavailable. Please try again later.",
+	})
+}
+
+// errorReply 返回错误对应的用户提示：配置了 WithErrorMessage 时使用自定义映射，否则为本地化的通用提示。
+func (c llmConfig) errorReply(snapshot botcore.RequestSnapshot, err error) string {
+	if c.errorMessage != nil {
+		return c.errorMessage(err)
+	}
+	return botcore.Localize(snapshot, MsgLLMUnavailable)
+}
+
+// NewLLMHandler 创建将消息文本交给 LLM 并流式返回的默认 AI 路由。
+// Parameters:
+//   - model: langchaingo 模型实例
+//   - opts: 可选配置（系统提示词、历史、会话键、审核、错误映射、上下文预算、图片输入）
+//
+// Returns:
+//   - botcore.PipelineInvoker: AI 路由处理器
+func NewLLMHandler(model llms.Model, opts ...LLMOption) botcore.PipelineInvoker {
+	cfg := llmConfig{
+		sessionKey: ChatSenderKey,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return botcore.PipelineFunc(func(pipelineCtx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk, 1)
+		go func() {
+			defer close(out)
+			defer botcore.RecoverInto(pipelineCtx, out)
+			ctx := pipelineCtx.Context()
+
+			prompt := strings.TrimSpace(pipelineCtx.Snapshot.Text)
+			var images []botcore.Attachment
+			if cfg.images {
+				images = ImageAttachments(pipelineCtx.Snapshot)
+			}
+			if prompt == "" && len(images) > 0 {
+				prompt = defaultImagePrompt
+			}
+			if prompt == "" {
+				out <- botcore.StreamChunk{Content: "empty input", IsFinal: true}
+				return
+			}
+			if model == nil {
+				err := fmt.Errorf("llm not initialized")
+				out <- botcore.StreamChunk{Content: cfg.errorReply(pipelineCtx.Snapshot, err), IsFinal: true, Err: err}
+				return
+			}
+			if cfg.moderator != nil {
+				if err := cfg.moderator(ctx, prompt); err != nil {
+					out <- botcore.StreamChunk{Content: cfg.errorReply(pipelineCtx.Snapshot, err), IsFinal: true, Err: err}
+					return
+				}
+			}
+			imageParts, err := ImageParts(ctx, images, cfg.maxImages)
+			if err != nil {
+				out <- botcore.StreamChunk{Content: cfg.errorReply(pipelineCtx.Snapshot, err), IsFinal: true, Err: err}
+				return
+			}
+
+			// 1. 组装消息：系统提示词 + 历史 + 本轮输入。
+			key := cfg.sessionKey(pipelineCtx.Snapshot)
+			messages := make([]llms.MessageContent, 0)
+			if cfg.systemPrompt != "" {
+				messages = append(messages, llms.TextParts(llms.ChatMessageTypeSystem, cfg.systemPrompt))
+			}
+			var history []llms.MessageContent
+			if cfg.history != nil {
+				history = cfg.history.Load(key)
+			}
+			userMsg := llms.TextParts(llms.ChatMessageTypeHuman, prompt)
+			var final botcore.StreamChunk
+			if cfg.budget != nil {
+				fixed := CountMessageTokens(cfg.budget.Counter, messages...) + CountMessageTokens(cfg.budget.Counter, userMsg)
+				history, final.Metadata = cfg.fitHistory(ctx, model, key, history, fixed)
+			}
+			messages = append(messages, history...)
+			// 关键步骤：图片只随本轮提问发送，写入历史的 userMsg 仅含文本。
+			messages = append(messages, llms.MessageContent{
+				Role:  llms.ChatMessageTypeHuman,
+				Parts: append(imageParts, userMsg.Parts...),
+			})
+
+			// 2. 流式调用模型，逐片段转发。
+			var answer strings.Builder
+			callOpts := append([]llms.CallOption{
+				llms.WithStreamingFunc(func(streamCtx context.Context, chunk []byte) error {
+					answer.Write(chunk)
+					select {
+					case out <- botcore.StreamChunk{Content: string(chunk)}:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}),
+			}, cfg.callOptions...)
+			if err := cfg.generate(ctx, pipelineCtx.Snapshot, model, messages, callOpts); err != nil {
+				// 关键步骤：会话已结束（context 取消）时无人接收输出，直接退出且不写入历史。
+				if ctx.Err() != nil {
+					return
+				}
+				out <- botcore.StreamChunk{Content: "\n" + cfg.errorReply(pipelineCtx.Snapshot, err), IsFinal: true, Err: err}
+				return
+			}
+
+			// 3. 仅在成功时写入历史，避免半截回答污染上下文。
+			if cfg.history != nil {
+				cfg.history.Append(key, userMsg, llms.TextParts(llms.ChatMessageTypeAI, answer.String()))
+			}
+			final.IsFinal = true
+			out <- final
+		}()
+		return out
+	})
+}
+
+// generate 调用模型；配置了 Tracer 时包裹 span。
+func (c llmConfig) generate(ctx context.Context, snapshot botcore.RequestSnapshot, model llms.Model, messages []llms.MessageContent, opts []llms.CallOption) error {
+	attrs := botcore.SnapshotAttributes(snapshot)
+	attrs["llm.messages"] = strconv.Itoa(len(messages))
+	spanCtx, span := botcore.StartSpan(c.tracer, ctx, "llm.generate", attrs)
+	_, err := model.GenerateContent(spanCtx, messages, opts...)
+	span.End(err)
+	return err
+}
+
+// MemoryHistory 是进程内 History 实现，保留每个会话最近 maxMessages 条消息。
+type MemoryHistory struct {
+	mu          sync.Mutex
+	maxMessages int
+	sessions    map[string][]llms.MessageContent
+	updated     map[string]time.Time
+	now         func() time.Time
+}
+
+// NewMemoryHistory 创建进程内历史存储。
+// Parameters:
+//   - maxMessages: 每个会话保留的最大消息数（<=0 表示不限制）
+func NewMemoryHistory(maxMessages int) *MemoryHistory {
+	return &MemoryHistory{
+		maxMessages: maxMessages,
+		sessions:    make(map[string][]llms.MessageContent),
+		updated:     make(map[string]time.Time),
+		now:         time.Now,
+	}
+}
+
+// Load 实现 History 接口。
+func (h *MemoryHistory) Load(key string) []llms.MessageContent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	msgs := h.sessions[key]
+	cloned := make([]llms.MessageContent, len(msgs))
+	copy(cloned, msgs)
+	return cloned
+}
+
+// Append 实现 History 接口。
+func (h *MemoryHistory) Append(key string, messages ...llms.MessageContent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	msgs := append(h.sessions[key], messages...)
+	if h.maxMessages > 0 && len(msgs) > h.maxMessages {
+		// 开头的系统消息为摘要（见 Compactor），超出上限时保留。
+		if msgs[0].Role == llms.ChatMessageTypeSystem && h.maxMessages > 1 {
+			msgs = append(msgs[:1:1], msgs[len(msgs)-h.maxMessages+1:]...)
+		} else {
+			msgs = msgs[len(msgs)-h.maxMessages:]
+		}
+	}
+	h.sessions[key] = msgs
+	h.updated[key] = h.now()
+}
+
+// Compact 实现 Compactor 接口。
+func (h *MemoryHistory) Compact(key string, replaced []llms.MessageContent, summary llms.MessageContent) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	msgs := h.sessions[key]
+	if len(msgs) < len(replaced) || !slices.EqualFunc(msgs[:len(replaced)], replaced, sameMessage) {
+		return false
+	}
+	h.sessions[key] = append([]llms.MessageContent{summary}, msgs[len(replaced):]...)
+	h.updated[key] = h.now()
+	return true
+}
+
+// sameMessage 判断两条消息是否相同（Compact 校验会话开头未被并发修改）。
+func sameMessage(a, b llms.MessageContent) bool {
+	return reflect.DeepEqual(a, b)
+}
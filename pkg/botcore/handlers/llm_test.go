@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// fakeModel 按片段流式返回固定回答，并记录收到的消息。
+type fakeModel struct {
+	parts    []string
+	err      error
+	received [][]llms.MessageContent
+}
+
+func (m *fakeModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	m.received = append(m.received, messages)
+	opts := llms.CallOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	for _, part := range m.parts {
+		if err := opts.StreamingFunc(ctx, []byte(part)); err != nil {
+			return nil, err
+		}
+	}
+	return &llms.ContentResponse{}, nil
+}
+
+func (m *fakeModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+func runHandler(handler botcore.PipelineInvoker, text string) string {
+	var content string
+	snapshot := botcore.RequestSnapshot{ChatID: "c1", SenderID: "u1", Text: text}
+	for chunk := range handler.Trigger(botcore.PipelineContext{Snapshot: snapshot}) {
+		content += chunk.Content
+	}
+	return content
+}
+
+func TestLLMHandlerStreamsAndKeepsHistory(t *testing.T) {
+	model := &fakeModel{parts: []string{"hel", "lo"}}
+	handler := NewLLMHandler(model, WithSystemPrompt("be nice"), WithHistory(NewMemoryHistory(10)))
+
+	if got := runHandler(handler, "hi"); got != "hello" {
+		t.Fatalf("unexpected reply: %q", got)
+	}
+	runHandler(handler, "again")
+
+	// system + (human, ai) + human
+	if n := len(model.received[1]); n != 4 {
+		t.Fatalf("expected history in second call, got %d messages", n)
+	}
+}
+
+func TestLLMHandlerModerationAndErrors(t *testing.T) {
+	model := &fakeModel{parts: []string{"x"}}
+	blocked := NewLLMHandler(model,
+		WithModerator(func(ctx context.Context, text string) error { return errors.New("blocked") }),
+		WithErrorMessage(func(err error) string { return "sorry: " + err.Error() }),
+	)
+	if got := runHandler(blocked, "bad"); got != "sorry: blocked" {
+		t.Fatalf("unexpected moderation reply: %q", got)
+	}
+	if len(model.received) != 0 {
+		t.Fatalf("moderated input should not reach the model")
+	}
+
+	failing := NewLLMHandler(&fakeModel{err: errors.New("boom")},
+		WithErrorMessage(func(err error) string { return "sorry: " + err.Error() }),
+	)
+	if got := runHandler(failing, "hi"); got != "\nsorry: boom" {
+		t.Fatalf("unexpected error reply: %q", got)
+	}
+
+	// 默认提示不含上游错误详情，错误经结束包的 Err 传出。
+	upstream := errors.New("API returned unexpected status code: 401: invalid api key sk-xxx")
+	var final botcore.StreamChunk
+	snapshot := botcore.RequestSnapshot{ChatID: "c1", SenderID: "u1", Text: "hi", Metadata: map[string]string{botcore.MetaLanguage: "en"}}
+	for chunk := range NewLLMHandler(&fakeModel{err: upstream}).Trigger(botcore.PipelineContext{Snapshot: snapshot}) {
+		final = chunk
+	}
+	if strings.Contains(final.Content, "sk-xxx") || !strings.Contains(final.Content, "temporarily unavailable") {
+		t.Fatalf("unexpected default error reply: %q", final.Content)
+	}
+	if !errors.Is(final.Err, upstream) {
+		t.Fatalf("expected upstream error on the final chunk, got %v", final.Err)
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// defaultSummaryPrompt 为默认的摘要提示词，%s 为待压缩的对话记录。
+const defaultSummaryPrompt = `请将以下对话压缩为简洁的摘要，保留用户的身份信息、偏好、已确认的事实与未完成的事项，省略寒暄与重复内容，直接输出摘要正文：
+
+%s`
+
+// summaryPrefix 为摘要消息的前缀，帮助模型区分摘要与系统提示词。
+const summaryPrefix = "此前对话的摘要：\n"
+
+// Compactor 为支持压缩的 History：以一条摘要消息替换会话最早的若干条消息。
+type Compactor interface {
+	// Compact 以 summary（系统消息）替换会话开头的 replaced（含已有摘要）。
+	// 读取历史后会话可能已被并发请求压缩或经 WithMaxMessages 裁剪，此时开头已不是 replaced，
+	// 实现应放弃本次压缩并返回 false，避免删除未被摘要覆盖的消息。
+	Compact(key string, replaced []llms.MessageContent, summary llms.MessageContent) bool
+}
+
+// Summarizer 配置历史压缩：裁剪历史时先用模型把将被丢弃的消息（连同已有摘要）压缩为一条固定在开头的系统消息，
+// 使超长对话仍能保留早期的关键信息。
+type Summarizer struct {
+	// Model 为生成摘要的模型；为空时使用对话模型，可改用更便宜的模型。
+	Model llms.Model
+	// Prompt 为摘要提示词，需包含一个 %s 占位符（对话记录）；为空时使用默认中文提示词。
+	Prompt string
+	// MinMessages 为触发摘要的最少丢弃消息数（默认 2，即至少一轮），丢弃更少时直接裁剪。
+	MinMessages int
+	// OnError 为摘要失败时的回调（默认忽略）；失败时退化为直接裁剪。
+	OnError func(err error)
+}
+
+// WithSummarizer 在 WithTokenBudget 裁剪历史时启用摘要压缩。
+// 需要 History 实现 Compactor（MemoryHistory、RedisHistory 与 SQL 历史均已实现），否则仍按预算直接裁剪。
+func WithSummarizer(s Summarizer) LLMOption {
+	return func(c *llmConfig) {
+		if s.Prompt == "" {
+			s.Prompt = defaultSummaryPrompt
+		}
+		if s.MinMessages <= 0 {
+			s.MinMessages = 2
+		}
+		c.summarizer = &s
+	}
+}
+
+// fitHistory 按预算裁剪历史；配置了摘要时把被裁剪的消息压缩进固定在开头的摘要消息，并写回 History。
+// Returns:
+//   - []llms.MessageContent: 本次发送给模型的历史
+//   - map[string]string: 写入结束包的上下文用量
+func (c llmConfig) fitHistory(ctx context.Context, model llms.Model, key string, history []llms.MessageContent, fixed int) ([]llms.MessageContent, map[string]string) {
+	// 关键步骤：开头的系统消息为已有摘要，固定保留，不参与裁剪。
+	var pinned []llms.MessageContent
+	if len(history) > 0 && history[0].Role == llms.ChatMessageTypeSystem {
+		pinned, history = history[:1], history[1:]
+	}
+	pinnedTokens := CountMessageTokens(c.budget.Counter, pinned...)
+	kept, dropped, used := c.budget.trimHistory(history, fixed+pinnedTokens)
+
+	trimmed := len(dropped)
+
+	compactor, ok := c.history.(Compactor)
+	if c.summarizer != nil && ok && len(dropped) >= c.summarizer.MinMessages {
+		replaced := slices.Concat(pinned, dropped)
+		summary, err := c.summarizer.summarize(ctx, model, replaced)
+		if err == nil {
+			// 压缩失败（历史已被并发修改）时仍以摘要回答本次请求，由下一次请求重新压缩。
+			compactor.Compact(key, replaced, summary)
+			pinned = []llms.MessageContent{summary}
+			// 关键步骤：摘要可能比原摘要更长，按新摘要重新裁剪，保证不超出预算。
+			var more []llms.MessageContent
+			kept, more, used = c.budget.trimHistory(kept, fixed+CountMessageTokens(c.budget.Counter, summary))
+			trimmed += len(more)
+		} else if c.summarizer.OnError != nil {
+			c.summarizer.OnError(err)
+		}
+	}
+	return slices.Concat(pinned, kept), c.budget.usageMetadata(used, trimmed)
+}
+
+// summarize 将消息压缩为一条摘要系统消息。
+func (s Summarizer) summarize(ctx context.Context, fallback llms.Model, messages []llms.MessageContent) (llms.MessageContent, error) {
+	model := s.Model
+	if model == nil {
+		model = fallback
+	}
+	var transcript strings.Builder
+	for _, msg := range messages {
+		role := "用户"
+		switch msg.Role {
+		case llms.ChatMessageTypeAI:
+			role = "助手"
+		case llms.ChatMessageTypeSystem:
+			role = "摘要"
+		}
+		for _, part := range msg.Parts {
+			if text, ok := part.(llms.TextContent); ok {
+				fmt.Fprintf(&transcript, "%s：%s\n", role, strings.TrimPrefix(text.Text, summaryPrefix))
+			}
+		}
+	}
+	summary, err := llms.GenerateFromSinglePrompt(ctx, model, fmt.Sprintf(s.Prompt, transcript.String()))
+	if err != nil {
+		return llms.MessageContent{}, fmt.Errorf("summarize history: %w", err)
+	}
+	return llms.TextParts(llms.ChatMessageTypeSystem, summaryPrefix+strings.TrimSpace(summary)), nil
+}
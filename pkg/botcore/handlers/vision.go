@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// defaultImagePrompt 为只发送图片、不带文字时使用的提问。
+const defaultImagePrompt = "请描述这张图片。"
+
+// WithImageInputs 把消息（及引用消息）中的图片附件随本轮提问一并发送给模型，需使用视觉模型（如 GPT-4o、Gemini）。
+// maxImages 限制每轮发送的图片数（<=0 表示不限制）。图片只用于本轮，历史中仅保存文本。
+func WithImageInputs(maxImages int) LLMOption {
+	return func(c *llmConfig) {
+		c.images = true
+		c.maxImages = maxImages
+	}
+}
+
+// ImageAttachments 返回快照及其引用消息中的图片附件（按出现顺序，本条消息在前）。
+func ImageAttachments(snapshot botcore.RequestSnapshot) []botcore.Attachment {
+	attachments := snapshot.Attachments
+	if snapshot.Reference != nil {
+		attachments = append(attachments[:len(attachments):len(attachments)], snapshot.Reference.Attachments...)
+	}
+	var images []botcore.Attachment
+	for _, att := range attachments {
+		if att.Type == botcore.AttachmentTypeImage {
+			images = append(images, att)
+		}
+	}
+	return images
+}
+
+// ImageParts 加载图片附件并转换为 llms.BinaryContent，供命令或自定义处理器组装多模态消息。
+// Parameters:
+//   - ctx: 下载上下文
+//   - attachments: 图片附件（非图片附件会被忽略）
+//   - maxImages: 最多转换的图片数（<=0 表示不限制）
+//
+// Returns:
+//   - []llms.ContentPart: 图片内容片段
+//   - error: 下载、解密失败或格式无法识别时返回
+func ImageParts(ctx context.Context, attachments []botcore.Attachment, maxImages int) ([]llms.ContentPart, error) {
+	var parts []llms.ContentPart
+	for _, att := range attachments {
+		if att.Type != botcore.AttachmentTypeImage {
+			continue
+		}
+		if maxImages > 0 && len(parts) >= maxImages {
+			break
+		}
+		data, err := att.Load(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("load image: %w", err)
+		}
+		// 关键步骤：企业微信等平台的图片 URL 不带扩展名，按内容识别 MIME 类型。
+		mimeType := http.DetectContentType(data)
+		if !strings.HasPrefix(mimeType, "image/") {
+			return nil, fmt.Errorf("load image: unsupported content type %q", mimeType)
+		}
+		parts = append(parts, llms.BinaryPart(mimeType, data))
+	}
+	return parts, nil
+}
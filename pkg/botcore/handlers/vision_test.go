@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/tmc/langchaingo/llms"
+)
+
+var pngHeader = []byte{0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0, 0, 0, 0}
+
+func TestLLMHandlerSendsImages(t *testing.T) {
+	// 引用消息中的图片经 URL 下载并解密（此处以反转字节模拟）。
+	encrypted := make([]byte, len(pngHeader))
+	for i, b := range pngHeader {
+		encrypted[len(pngHeader)-1-i] = b
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(encrypted)
+	}))
+	defer srv.Close()
+	reverse := func(data []byte) ([]byte, error) {
+		out := make([]byte, len(data))
+		for i, b := range data {
+			out[len(data)-1-i] = b
+		}
+		return out, nil
+	}
+
+	model := &fakeModel{parts: []string{"a cat"}}
+	history := NewMemoryHistory(0)
+	handler := NewLLMHandler(model, WithHistory(history), WithImageInputs(1))
+	snapshot := botcore.RequestSnapshot{
+		ChatID: "c1", SenderID: "u1",
+		Attachments: []botcore.Attachment{{Type: botcore.AttachmentTypeFile, Data: []byte("x")}},
+		Reference: &botcore.Reference{Attachments: []botcore.Attachment{
+			{Type: botcore.AttachmentTypeImage, URL: srv.URL, DownloadTransform: reverse},
+			{Type: botcore.AttachmentTypeImage, Data: pngHeader},
+		}},
+	}
+	for range handler.Trigger(botcore.PipelineContext{Snapshot: snapshot}) {
+	}
+
+	parts := model.received[0][0].Parts
+	if len(parts) != 2 {
+		t.Fatalf("expected one image and the prompt, got %+v", parts)
+	}
+	if img, ok := parts[0].(llms.BinaryContent); !ok || img.MIMEType != "image/png" || string(img.Data) != string(pngHeader) {
+		t.Fatalf("unexpected image part: %+v", parts[0])
+	}
+	if text, ok := parts[1].(llms.TextContent); !ok || text.Text != defaultImagePrompt {
+		t.Fatalf("unexpected prompt part: %+v", parts[1])
+	}
+	stored := history.Load("c1:u1")
+	if len(stored) != 2 || len(stored[0].Parts) != 1 {
+		t.Fatalf("expected text-only history, got %+v", stored)
+	}
+}
+
+func TestImagePartsRejectsNonImage(t *testing.T) {
+	attachments := []botcore.Attachment{{Type: botcore.AttachmentTypeImage, Data: []byte("plain text")}}
+	if _, err := ImageParts(t.Context(), attachments, 0); err == nil {
+		t.Fatal("expected unsupported content type error")
+	}
+}
@@ -0,0 +1,78 @@
+package botcore
+
+// Hooks 是消息处理生命周期回调，常用于审计日志与告警。
+// 所有回调均可为 nil；回调在处理 goroutine 内同步执行，应避免阻塞。
+type Hooks struct {
+	// OnUpdate 在标准化后的入站消息进入 pipeline 前调用。
+	OnUpdate func(snapshot RequestSnapshot)
+	// OnReply 在每个出站片段交给平台前调用，包括适配层在回复超时、停机中断时合成的最终片段。
+	OnReply func(snapshot RequestSnapshot, chunk StreamChunk)
+	// OnError 在处理出错时调用（如回复超时、主动发送失败），由平台适配层触发。
+	OnError func(snapshot RequestSnapshot, err error)
+}
+
+// Update 触发 OnUpdate（nil 安全）。
+func (h Hooks) Update(snapshot RequestSnapshot) {
+	if h.OnUpdate != nil {
+		h.OnUpdate(snapshot)
+	}
+}
+
+// Reply 触发 OnReply（nil 安全）。
+func (h Hooks) Reply(snapshot RequestSnapshot, chunk StreamChunk) {
+	if h.OnReply != nil {
+		h.OnReply(snapshot, chunk)
+	}
+}
+
+// Error 触发 OnError（nil 安全，err 为 nil 时忽略）。
+func (h Hooks) Error(snapshot RequestSnapshot, err error) {
+	if h.OnError != nil && err != nil {
+		h.OnError(snapshot, err)
+	}
+}
+
+// Observe 返回在入站消息与出站片段上触发 Hooks 的中间件。
+// OnError 不由本中间件触发，需平台适配层在出错位置调用 Hooks.Error。
+func Observe(h Hooks) Middleware {
+	return func(next PipelineInvoker) PipelineInvoker {
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			h.Update(ctx.Snapshot)
+			in := next.Trigger(ctx)
+			if in == nil || h.OnReply == nil {
+				return in
+			}
+			out := make(chan StreamChunk)
+			go func() {
+				defer close(out)
+				for chunk := range in {
+					h.Reply(ctx.Snapshot, chunk)
+					out <- chunk
+				}
+			}()
+			return out
+		})
+	}
+}
+
+// CombineHooks 将多组 Hooks 合并为一组，回调按参数顺序依次触发，nil 回调被跳过。
+// 适用于同时接入审计日志、外部通知等多个订阅方。
+func CombineHooks(hooks ...Hooks) Hooks {
+	return Hooks{
+		OnUpdate: func(snapshot RequestSnapshot) {
+			for _, h := range hooks {
+				h.Update(snapshot)
+			}
+		},
+		OnReply: func(snapshot RequestSnapshot, chunk StreamChunk) {
+			for _, h := range hooks {
+				h.Reply(snapshot, chunk)
+			}
+		},
+		OnError: func(snapshot RequestSnapshot, err error) {
+			for _, h := range hooks {
+				h.Error(snapshot, err)
+			}
+		},
+	}
+}
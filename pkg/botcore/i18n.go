@@ -0,0 +1,157 @@
+package botcore
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// 内置消息键，对应的中英文文案随框架提供，可通过 Messages.Add 覆盖或补充其他语言。
+const (
+	MsgProcessingError = "error.processing"     // 通用处理失败，参数：错误
+	MsgRateLimited     = "ratelimit.exceeded"   // 请求过于频繁，参数：等待秒数
+	MsgConcurrencyBusy = "concurrency.busy"     // 上一条消息仍在处理
+	MsgStopped         = "stop.stopped"         // 被停止的回答末尾追加的提示（含前导空行）
+	MsgStopNone        = "stop.none"            // 停止指令的回复：没有进行中的回答
+	MsgStopDone        = "stop.done"            // 停止指令的回复：已停止进行中的回答
+	MsgTruncated       = "timeout.truncated"    // 超时被截断的回答末尾追加的提示（含前导空行）
+	MsgReplyContinued  = "reply.continued"      // 超长回答续见下条消息的提示（含前导空行）
+	MsgReplyOversize   = "reply.oversize"       // 超长回答被截断的提示（含前导空行）
+	MsgPageFooter      = "reply.page"           // 分页页脚（含前导空行），参数：页码、总页数
+	MsgGreeting1       = "smalltalk.greeting.1" // 寒暄：问候的回复
+	MsgGreeting2       = "smalltalk.greeting.2" // 寒暄：问候的备选回复
+	MsgThanks1         = "smalltalk.thanks.1"   // 寒暄：致谢的回复
+	MsgThanks2         = "smalltalk.thanks.2"   // 寒暄：致谢的备选回复
+)
+
+// Catalog 是按语言组织的消息目录，并发安全。
+// 查找顺序：完整语言标签（如 "en-US"）→ 基础语言（"en"）→ 回退语言 → 消息键本身。
+type Catalog struct {
+	mu       sync.RWMutex
+	fallback string
+	bundles  map[string]map[string]string
+}
+
+// NewCatalog 创建空的消息目录。
+// Parameters:
+//   - fallback: 回退语言，找不到请求语言的文案时使用
+//
+// Returns:
+//   - *Catalog: 消息目录
+func NewCatalog(fallback string) *Catalog {
+	return &Catalog{fallback: normalizeLocale(fallback), bundles: make(map[string]map[string]string)}
+}
+
+// Add 为 locale 添加或覆盖文案（键 → fmt 格式串），可多次调用以合并。
+func (c *Catalog) Add(locale string, messages map[string]string) {
+	locale = normalizeLocale(locale)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	bundle, ok := c.bundles[locale]
+	if !ok {
+		bundle = make(map[string]string, len(messages))
+		c.bundles[locale] = bundle
+	}
+	for key, msg := range messages {
+		bundle[key] = msg
+	}
+}
+
+// SetFallback 设置回退语言。
+func (c *Catalog) SetFallback(locale string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fallback = normalizeLocale(locale)
+}
+
+// Lookup 返回 locale 下 key 的文案；均未找到时返回 key 本身，便于发现遗漏。
+func (c *Catalog) Lookup(locale, key string) string {
+	locale = normalizeLocale(locale)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	candidates := []string{locale}
+	if idx := strings.IndexByte(locale, '-'); idx > 0 {
+		candidates = append(candidates, locale[:idx])
+	}
+	candidates = append(candidates, c.fallback)
+	for _, candidate := range candidates {
+		if msg, ok := c.bundles[candidate][key]; ok {
+			return msg
+		}
+	}
+	return key
+}
+
+// Sprintf 以 locale 下 key 的文案为格式串格式化参数。
+func (c *Catalog) Sprintf(locale, key string, args ...any) string {
+	format := c.Lookup(locale, key)
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// normalizeLocale 统一语言标签格式：小写并以 "-" 分隔（"zh_CN" → "zh-cn"）。
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// Messages 是框架内置文案使用的默认消息目录，回退语言为中文。
+// 业务可调用 Messages.Add 覆盖内置文案或注册新语言。
+var Messages = NewCatalog("zh")
+
+func init() {
+	Messages.Add("zh", map[string]string{
+		MsgProcessingError: "❌ 处理出错: %v",
+		MsgRateLimited:     "请求过于频繁，请 %d 秒后再试。",
+		MsgConcurrencyBusy: "上一条消息还在处理中，请稍后再试。",
+		MsgStopped:         "\n\n> 已停止生成。",
+		MsgStopNone:        "当前没有进行中的回答。",
+		MsgStopDone:        "已停止进行中的回答。",
+		MsgTruncated:       "\n\n> 处理超时，回答已截断。",
+		MsgReplyContinued:  "\n\n（内容较长，续见下条消息）",
+		MsgReplyOversize:   "\n\n…（内容过长，已截断）",
+		MsgPageFooter:      "\n\n（%d/%d）",
+		MsgGreeting1:       "你好！有什么可以帮你的吗？",
+		MsgGreeting2:       "在的，请问有什么需要？",
+		MsgThanks1:         "不客气！",
+		MsgThanks2:         "很高兴能帮到你 😊",
+	})
+	Messages.Add("en", map[string]string{
+		MsgProcessingError: "❌ Something went wrong: %v",
+		MsgRateLimited:     "Too many requests, please try again in %d seconds.",
+		MsgConcurrencyBusy: "Your previous message is still being processed, please try again later.",
+		MsgStopped:         "\n\n> Generation stopped.",
+		MsgStopNone:        "There is no answer in progress.",
+		MsgStopDone:        "Stopped the answer in progress.",
+		MsgTruncated:       "\n\n> Timed out, the answer was cut short.",
+		MsgReplyContinued:  "\n\n(Continued in the next message)",
+		MsgReplyOversize:   "\n\n… (Too long, truncated)",
+		MsgPageFooter:      "\n\n(%d/%d)",
+		MsgGreeting1:       "Hi! How can I help you?",
+		MsgGreeting2:       "I'm here. What do you need?",
+		MsgThanks1:         "You're welcome!",
+		MsgThanks2:         "Glad I could help 😊",
+	})
+}
+
+// Locale 返回快照的语言：取 Metadata[MetaLanguage]（平台提供或由 DetectLanguage 写入），
+// 未知时返回空字符串，由消息目录使用回退语言。
+func Locale(snapshot RequestSnapshot) string {
+	if lang := snapshot.Metadata[MetaLanguage]; lang != "und" {
+		return lang
+	}
+	return ""
+}
+
+// Localize 按快照语言从 Messages 取出 key 的文案并格式化。
+// Parameters:
+//   - snapshot: 请求快照（决定语言）
+//   - key: 消息键
+//   - args: 格式化参数
+//
+// Returns:
+//   - string: 本地化文案
+func Localize(snapshot RequestSnapshot, key string, args ...any) string {
+	return Messages.Sprintf(Locale(snapshot), key, args...)
+}
@@ -0,0 +1,102 @@
+package botcore
+
+import (
+	"fmt"
+	"time"
+)
+
+// Latency 记录单条消息从接收到最终回复的关键时间点。
+type Latency struct {
+	ReceivedAt   time.Time // 进入 Pipeline 的时间
+	FirstChunkAt time.Time // 首个有效片段（非空内容或 Payload）产出时间；无输出时为零值
+	FinalAt      time.Time // 最终片段产出或输出通道关闭的时间
+}
+
+// FirstChunk 返回首个有效片段的等待时长（无输出时返回 0）。
+func (l Latency) FirstChunk() time.Duration {
+	if l.FirstChunkAt.IsZero() {
+		return 0
+	}
+	return l.FirstChunkAt.Sub(l.ReceivedAt)
+}
+
+// Total 返回从接收到结束的总耗时。
+func (l Latency) Total() time.Duration {
+	if l.FinalAt.IsZero() {
+		return 0
+	}
+	return l.FinalAt.Sub(l.ReceivedAt)
+}
+
+// LatencyReporter 在单条消息处理结束后接收耗时明细，常用于上报指标。
+type LatencyReporter func(snapshot RequestSnapshot, latency Latency)
+
+// LatencyOption 自定义 TrackLatency 行为。
+type LatencyOption func(*latencyConfig)
+
+type latencyConfig struct {
+	suffixFormat string
+}
+
+// WithLatencySuffix 在最终片段末尾追加耗时提示（调试用）。
+// format 需包含一个 %.1f 占位符，单位为秒，例如 "\n\n> answered in %.1fs"。
+// 携带 Payload 的最终片段不会被修改。
+func WithLatencySuffix(format string) LatencyOption {
+	return func(c *latencyConfig) {
+		c.suffixFormat = format
+	}
+}
+
+// TrackLatency 返回记录端到端耗时的中间件。
+// Parameters:
+//   - report: 处理结束后的耗时回调，可为 nil（仅使用调试后缀时）
+//   - opts: 可选配置
+//
+// Returns:
+//   - Middleware: 耗时记录中间件
+func TrackLatency(report LatencyReporter, opts ...LatencyOption) Middleware {
+	cfg := latencyConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(next PipelineInvoker) PipelineInvoker {
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			latency := Latency{ReceivedAt: time.Now()}
+			in := next.Trigger(ctx)
+			if in == nil {
+				if report != nil {
+					latency.FinalAt = time.Now()
+					report(ctx.Snapshot, latency)
+				}
+				return nil
+			}
+
+			out := make(chan StreamChunk)
+			go func() {
+				defer close(out)
+				for chunk := range in {
+					now := time.Now()
+					if latency.FirstChunkAt.IsZero() && (chunk.Content != "" || chunk.Payload != nil) {
+						latency.FirstChunkAt = now
+					}
+					if chunk.IsFinal && latency.FinalAt.IsZero() {
+						latency.FinalAt = now
+						// 关键步骤：仅对纯文本最终片段追加后缀，避免破坏平台负载结构。
+						if cfg.suffixFormat != "" && chunk.Payload == nil {
+							chunk.Content += fmt.Sprintf(cfg.suffixFormat, latency.Total().Seconds())
+						}
+					}
+					out <- chunk
+				}
+				if latency.FinalAt.IsZero() {
+					latency.FinalAt = time.Now()
+				}
+				if report != nil {
+					report(ctx.Snapshot, latency)
+				}
+			}()
+			return out
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package botcore
+
+import "unicode/utf8"
+
+// LimitBytes 返回限制输出文本总字节数的中间件，避免超出平台回复上限后被静默截断。
+// 正文与推理片段的累计字节数超过 maxBytes 时，在 UTF-8 边界截断并追加 notice，之后的文本被丢弃，
+// 附件、Payload 与最终片段标记照常转发。仅适用于增量语义（ContentDelta）。
+// Parameters:
+//   - maxBytes: 文本字节上限（含 notice；<=0 表示不限制）
+//   - notice: 截断提示文本或消息键（按快照语言本地化），如 MsgReplyOversize
+//
+// Returns:
+//   - Middleware: 长度限制中间件
+func LimitBytes(maxBytes int, notice string) Middleware {
+	return MapChunksWith(func(ctx PipelineContext) ChunkTransform {
+		if maxBytes <= 0 {
+			return nil
+		}
+		notice := Localize(ctx.Snapshot, notice)
+		budget := maxBytes - len(notice)
+		if budget < 0 {
+			budget, notice = maxBytes, ""
+		}
+		total := 0
+		truncated := false
+		return func(chunk StreamChunk) StreamChunk {
+			if chunk.Type != ChunkContent && chunk.Type != ChunkReasoning {
+				return chunk
+			}
+			if truncated {
+				chunk.Content = ""
+				return chunk
+			}
+			if total+len(chunk.Content) <= budget {
+				total += len(chunk.Content)
+				return chunk
+			}
+			truncated = true
+			chunk.Content = TruncateUTF8(chunk.Content, budget-total) + notice
+			total = maxBytes
+			return chunk
+		}
+	})
+}
+
+// TruncateUTF8 将字符串截断到 max 字节以内，且不截断在多字节字符中间。
+func TruncateUTF8(s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	if len(s) <= max {
+		return s
+	}
+	s = s[:max]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
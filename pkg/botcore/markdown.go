@@ -0,0 +1,183 @@
+package botcore
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MarkdownDialect 描述平台 Markdown 方言支持的语法，NormalizeMarkdown 据此把通用 Markdown 改写为平台可展示的形式。
+type MarkdownDialect struct {
+	Strikethrough bool // 支持 ~~删除线~~；否则去除标记保留文字
+	TaskList      bool // 支持 "- [ ]" 任务列表；否则改写为 ☐ / ☑
+	HTML          bool // 支持内联 HTML；否则把常见标签（<b>、<i>、<code>、<br> 等）改写为 Markdown 或去除
+}
+
+// MarkdownDialectWeCom 为企业微信 markdown_v2（流式回复 content）的方言：不支持删除线、任务列表与 HTML。
+var MarkdownDialectWeCom = MarkdownDialect{}
+
+var (
+	strikethroughPattern = regexp.MustCompile(`~~(.+?)~~`)
+	taskListPattern      = regexp.MustCompile(`^(\s*[-*+]\s+)\[([ xX])\](\s)`)
+	// taskListPrefixPattern 匹配可能尚未写完的任务列表开头，流式输出时需等待其完整。
+	taskListPrefixPattern = regexp.MustCompile(`^\s*([-*+](\s+(\[[ xX]?\]?)?)?)?$`)
+	// htmlTagPattern 只匹配常见的格式化标签，保留 <think>、<@userid> 等平台语法。
+	htmlTagPattern = regexp.MustCompile(`(?i)</?(b|strong|i|em|code|br|p|div|span|u|s|del|strike)(\s[^>]*)?/?>`)
+)
+
+// markdownRiskyChars 为可能开始一个需改写的行内结构的字符；流式输出时从这些字符起暂缓输出直到行尾。
+const markdownRiskyChars = "~<`"
+
+// NormalizeMarkdown 返回按方言改写通用 Markdown 的中间件，使 handler 无需了解平台方言。
+// 代码块与行内代码保持原样。改写按行进行：可能被改写的行尾片段会暂缓到换行或最终片段才输出，
+// 其余文本照常流式输出。仅处理正文片段，且要求增量语义（ContentDelta）。
+// Parameters:
+//   - dialect: 目标平台方言
+//
+// Returns:
+//   - Middleware: Markdown 改写中间件；方言支持全部语法时为透传
+func NormalizeMarkdown(dialect MarkdownDialect) Middleware {
+	return func(next PipelineInvoker) PipelineInvoker {
+		if dialect.Strikethrough && dialect.TaskList && dialect.HTML {
+			return next
+		}
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			in := next.Trigger(ctx)
+			if in == nil {
+				return nil
+			}
+			out := make(chan StreamChunk)
+			go func() {
+				defer close(out)
+				n := &markdownNormalizer{dialect: dialect, atLineStart: true}
+				for chunk := range in {
+					if chunk.Type != ChunkContent || chunk.Payload != nil {
+						// 关键步骤：非正文片段前先输出暂缓的文本，保持先后顺序。
+						if rest := n.flush(); rest != "" {
+							out <- StreamChunk{Content: rest}
+						}
+						out <- chunk
+						continue
+					}
+					chunk.Content = n.write(chunk.Content, chunk.IsFinal)
+					out <- chunk
+				}
+				if rest := n.flush(); rest != "" {
+					out <- StreamChunk{Content: rest}
+				}
+			}()
+			return out
+		})
+	}
+}
+
+// markdownNormalizer 为单个流的增量改写状态。
+type markdownNormalizer struct {
+	dialect     MarkdownDialect
+	pending     string // 暂缓输出的行尾片段
+	atLineStart bool   // pending 是否从行首开始
+	inFence     bool   // 是否处于代码块内
+}
+
+// write 追加增量文本，返回可立即输出的改写结果。
+func (n *markdownNormalizer) write(delta string, final bool) string {
+	text := n.pending + delta
+	n.pending = ""
+	var sb strings.Builder
+	for {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			break
+		}
+		sb.WriteString(n.segment(text[:i+1], n.atLineStart))
+		n.atLineStart = true
+		text = text[i+1:]
+	}
+	if final {
+		sb.WriteString(n.segment(text, n.atLineStart))
+		n.atLineStart = true
+		return sb.String()
+	}
+
+	cut := strings.IndexAny(text, markdownRiskyChars)
+	if cut < 0 {
+		cut = len(text)
+	}
+	if n.atLineStart && (taskListPrefixPattern.MatchString(text) || strings.TrimLeft(text[:cut], " \t") == "") {
+		// 关键步骤：行首结构（任务列表、代码块围栏）尚未写完，整行暂缓。
+		n.pending = text
+		return sb.String()
+	}
+	if cut > 0 {
+		sb.WriteString(n.segment(text[:cut], n.atLineStart))
+		n.atLineStart = false
+	}
+	n.pending = text[cut:]
+	return sb.String()
+}
+
+// flush 输出全部暂缓文本。
+func (n *markdownNormalizer) flush() string {
+	if n.pending == "" {
+		return ""
+	}
+	return n.write("", true)
+}
+
+// segment 改写一段位于同一行内的文本；lineStart 表示该段从行首开始。
+func (n *markdownNormalizer) segment(text string, lineStart bool) string {
+	if lineStart {
+		trimmed := strings.TrimLeft(text, " \t")
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			n.inFence = !n.inFence
+			return text
+		}
+	}
+	if n.inFence {
+		return text
+	}
+	if lineStart && !n.dialect.TaskList {
+		text = taskListPattern.ReplaceAllStringFunc(text, func(m string) string {
+			parts := taskListPattern.FindStringSubmatch(m)
+			box := "☐"
+			if parts[2] != " " {
+				box = "☑"
+			}
+			return parts[1] + box + parts[3]
+		})
+	}
+
+	// 关键步骤：按反引号切分，只改写行内代码之外的部分。
+	parts := strings.Split(text, "`")
+	for i := 0; i < len(parts); i += 2 {
+		parts[i] = n.inline(parts[i])
+	}
+	return strings.Join(parts, "`")
+}
+
+// inline 改写行内代码之外的文本。
+func (n *markdownNormalizer) inline(text string) string {
+	if !n.dialect.HTML {
+		text = htmlTagPattern.ReplaceAllStringFunc(text, func(tag string) string {
+			name := strings.ToLower(htmlTagPattern.FindStringSubmatch(tag)[1])
+			switch name {
+			case "b", "strong":
+				return "**"
+			case "i", "em":
+				return "*"
+			case "code":
+				return "`"
+			case "br":
+				return "\n"
+			case "s", "del", "strike":
+				if n.dialect.Strikethrough {
+					return "~~"
+				}
+			}
+			return ""
+		})
+	}
+	if !n.dialect.Strikethrough {
+		text = strikethroughPattern.ReplaceAllString(text, "$1")
+	}
+	return text
+}
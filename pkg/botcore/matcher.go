@@ -0,0 +1,212 @@
+package botcore
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MatchText 将针对文本的判断函数包装为 Matcher（文本为快照 Text 去除首尾空白后的结果）。
+// Parameters:
+//   - fn: 文本判断函数
+//
+// Returns:
+//   - Matcher: 文本匹配器
+func MatchText(fn func(text string) bool) Matcher {
+	return func(u RequestSnapshot) bool {
+		return fn != nil && fn(strings.TrimSpace(u.Text))
+	}
+}
+
+// MatchExact 返回文本（去除首尾空白）与任一候选完全相等时匹配的 Matcher，区分大小写。
+// Parameters:
+//   - texts: 候选文本
+//
+// Returns:
+//   - Matcher: 精确匹配器
+func MatchExact(texts ...string) Matcher {
+	return MatchText(func(text string) bool {
+		for _, t := range texts {
+			if text == t {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// MatchKeyword 返回文本包含任一关键词时匹配的 Matcher，不区分大小写。
+// 例如 MatchKeyword("deploy", "发布") 可将运维相关消息路由到专门的处理器。
+// Parameters:
+//   - words: 关键词（空字符串会被忽略）
+//
+// Returns:
+//   - Matcher: 关键词匹配器
+func MatchKeyword(words ...string) Matcher {
+	lowered := make([]string, 0, len(words))
+	for _, w := range words {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			lowered = append(lowered, w)
+		}
+	}
+	return MatchText(func(text string) bool {
+		text = strings.ToLower(text)
+		for _, w := range lowered {
+			if strings.Contains(text, w) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// MatchRegexp 返回文本匹配正则表达式时匹配的 Matcher。
+// 与 regexp.MustCompile 一致，pattern 非法时会 panic，适合在启动阶段注册路由。
+// Parameters:
+//   - pattern: 正则表达式（RE2 语法）
+//
+// Returns:
+//   - Matcher: 正则匹配器
+func MatchRegexp(pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+	return MatchText(re.MatchString)
+}
+
+// And 返回全部 Matcher 均匹配时才匹配的组合 Matcher（按顺序短路求值；为空时总是匹配，nil 项被忽略）。
+// Parameters:
+//   - matchers: 子匹配器
+//
+// Returns:
+//   - Matcher: 组合匹配器
+func And(matchers ...Matcher) Matcher {
+	return func(u RequestSnapshot) bool {
+		for _, m := range matchers {
+			if m != nil && !m(u) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or 返回任一 Matcher 匹配即匹配的组合 Matcher（按顺序短路求值；为空时从不匹配，nil 项被忽略）。
+// Parameters:
+//   - matchers: 子匹配器
+//
+// Returns:
+//   - Matcher: 组合匹配器
+func Or(matchers ...Matcher) Matcher {
+	return func(u RequestSnapshot) bool {
+		for _, m := range matchers {
+			if m != nil && m(u) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not 返回对 Matcher 取反的匹配器（m 为 nil 时总是匹配）。
+// Parameters:
+//   - m: 子匹配器
+//
+// Returns:
+//   - Matcher: 取反匹配器
+func Not(m Matcher) Matcher {
+	return func(u RequestSnapshot) bool {
+		return m == nil || !m(u)
+	}
+}
+
+// matchSet 构造字符串集合判断函数。
+func matchSet(values []string) func(string) bool {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return func(v string) bool {
+		_, ok := set[v]
+		return ok
+	}
+}
+
+// MatchSender 返回发送者为任一指定用户时匹配的 Matcher，常用于管理员专属命令树。
+// Parameters:
+//   - ids: 用户 ID 列表
+//
+// Returns:
+//   - Matcher: 发送者匹配器
+func MatchSender(ids ...string) Matcher {
+	contains := matchSet(ids)
+	return func(u RequestSnapshot) bool {
+		return u.SenderID != "" && contains(u.SenderID)
+	}
+}
+
+// MatchChat 返回会话为任一指定会话时匹配的 Matcher。
+// Parameters:
+//   - ids: 会话 ID 列表
+//
+// Returns:
+//   - Matcher: 会话匹配器
+func MatchChat(ids ...string) Matcher {
+	contains := matchSet(ids)
+	return func(u RequestSnapshot) bool {
+		return u.ChatID != "" && contains(u.ChatID)
+	}
+}
+
+// MatchChatType 返回会话类型为任一指定类型时匹配的 Matcher，例如 MatchChatType(ChatTypeChatroom) 只匹配群聊。
+// Parameters:
+//   - types: 会话类型列表
+//
+// Returns:
+//   - Matcher: 会话类型匹配器
+func MatchChatType(types ...ChatType) Matcher {
+	return func(u RequestSnapshot) bool {
+		for _, t := range types {
+			if u.ChatType == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchMetadata 返回 Metadata[key] 等于 value 时匹配的 Matcher。
+// Parameters:
+//   - key: 元数据键
+//   - value: 期望值
+//
+// Returns:
+//   - Matcher: 元数据匹配器
+func MatchMetadata(key, value string) Matcher {
+	return func(u RequestSnapshot) bool {
+		v, ok := u.Metadata[key]
+		return ok && v == value
+	}
+}
+
+// MatchMentioned 返回按 @ 提及匹配的 Matcher：不传 ids 时匹配显式 @ 了机器人的消息，
+// 否则匹配提及了任一指定对象的消息。群聊机器人只在被 @ 时响应，可与单聊组合：
+// Or(MatchChatType(ChatTypeSingle), MatchMentioned())。
+// Parameters:
+//   - ids: 被提及对象列表（可为空）
+//
+// Returns:
+//   - Matcher: 提及匹配器
+func MatchMentioned(ids ...string) Matcher {
+	if len(ids) == 0 {
+		return func(u RequestSnapshot) bool {
+			return u.MentionedBot
+		}
+	}
+	contains := matchSet(ids)
+	return func(u RequestSnapshot) bool {
+		for _, m := range u.Mentions {
+			if contains(m) {
+				return true
+			}
+		}
+		return false
+	}
+}
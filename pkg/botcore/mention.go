@@ -0,0 +1,93 @@
+package botcore
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// maxDisplayNameRunes 为插值后显示名的最大字符数。
+const maxDisplayNameRunes = 64
+
+// MentionStyle 描述平台的 @ 提及语法及合法用户 ID 格式。
+type MentionStyle struct {
+	Format    string         // 提及格式，需包含一个 %s 占位符
+	IDPattern *regexp.Regexp // 合法用户 ID 正则；为 nil 时不校验
+}
+
+var (
+	// MentionStyleWeCom 为企业微信的提及语法：<@userid>。
+	MentionStyleWeCom = MentionStyle{
+		Format:    "<@%s>",
+		IDPattern: regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,64}$`),
+	}
+	// MentionStyleSlack 为 Slack 的提及语法：<@U...>。
+	MentionStyleSlack = MentionStyle{
+		Format:    "<@%s>",
+		IDPattern: regexp.MustCompile(`^[UW][A-Z0-9]{2,}$`),
+	}
+)
+
+// Mention 生成提及标记；用户 ID 不合法时返回错误，避免拼出残缺或被注入的标记。
+func (s MentionStyle) Mention(userID string) (string, error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return "", fmt.Errorf("mention: empty user id")
+	}
+	if s.IDPattern != nil && !s.IDPattern.MatchString(userID) {
+		return "", fmt.Errorf("mention: invalid user id %q", userID)
+	}
+	return fmt.Sprintf(s.Format, userID), nil
+}
+
+// displayNameEscaper 转义会被解析为 Markdown 或提及标记的字符。
+var displayNameEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"*", "\\*",
+	"_", "\\_",
+	"`", "\\`",
+	"[", "\\[",
+	"]", "\\]",
+	"<", "＜",
+	">", "＞",
+	"@", "＠",
+)
+
+// EscapeDisplayName 将用户显示名处理为可安全插入回复的纯文本：
+// 去除控制字符与换行、转义 Markdown 与提及标记、并限制长度。
+func EscapeDisplayName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			return ' '
+		case unicode.IsControl(r):
+			return -1
+		default:
+			return r
+		}
+	}, name)
+	name = strings.TrimSpace(name)
+	if runes := []rune(name); len(runes) > maxDisplayNameRunes {
+		name = string(runes[:maxDisplayNameRunes]) + "…"
+	}
+	return displayNameEscaper.Replace(name)
+}
+
+// TemplateFuncs 返回供 text/template 使用的插值函数：
+//   - mention: 生成平台提及标记，ID 不合法时回退为转义后的纯文本
+//   - name: 安全插入显示名
+//
+// 示例：{{mention .UserID}} 你好，{{name .DisplayName}}
+func (s MentionStyle) TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"mention": func(userID string) string {
+			if m, err := s.Mention(userID); err == nil {
+				return m
+			}
+			return EscapeDisplayName(userID)
+		},
+		"name": EscapeDisplayName,
+	}
+}
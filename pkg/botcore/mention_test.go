@@ -0,0 +1,33 @@
+package botcore
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestMentionStyleRejectsMalformedIDs(t *testing.T) {
+	if got, err := MentionStyleWeCom.Mention("zhangsan"); err != nil || got != "<@zhangsan>" {
+		t.Fatalf("unexpected wecom mention: %q %v", got, err)
+	}
+	if got, err := MentionStyleSlack.Mention("U024BE7LH"); err != nil || got != "<@U024BE7LH>" {
+		t.Fatalf("unexpected slack mention: %q %v", got, err)
+	}
+	if _, err := MentionStyleWeCom.Mention("bad> id"); err == nil {
+		t.Fatalf("malformed id should be rejected")
+	}
+}
+
+func TestTemplateFuncsEscapeDisplayName(t *testing.T) {
+	tpl := template.Must(template.New("t").Funcs(MentionStyleWeCom.TemplateFuncs()).
+		Parse("{{mention .ID}} hi {{name .Name}}"))
+	var sb strings.Builder
+	err := tpl.Execute(&sb, map[string]string{"ID": "<@all>", "Name": "**boss**\n<@all>"})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	got := sb.String()
+	if strings.Contains(got, "<@") || strings.Contains(got, "\n") || strings.Contains(got, "**") {
+		t.Fatalf("markup leaked into output: %q", got)
+	}
+}
@@ -0,0 +1,22 @@
+package botcore
+
+// Middleware 包装 PipelineInvoker，用于在路由前后插入横切逻辑（计时、限流、恢复等）。
+type Middleware func(next PipelineInvoker) PipelineInvoker
+
+// Wrap 按顺序为 handler 套上中间件。
+// 第一个中间件位于最外层，即最先拿到请求、最后拿到输出。
+// Parameters:
+//   - handler: 被包装的 PipelineInvoker
+//   - mws: 中间件列表（nil 项会被跳过）
+//
+// Returns:
+//   - PipelineInvoker: 包装后的执行器
+func Wrap(handler PipelineInvoker, mws ...Middleware) PipelineInvoker {
+	for i := len(mws) - 1; i >= 0; i-- {
+		if mws[i] == nil {
+			continue
+		}
+		handler = mws[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,1068 @@
+package botcore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// staticPipeline 返回按序输出给定片段的 PipelineInvoker。
+func staticPipeline(chunks ...StreamChunk) PipelineInvoker {
+	return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		out := make(chan StreamChunk, len(chunks))
+		for _, chunk := range chunks {
+			out <- chunk
+		}
+		close(out)
+		return out
+	})
+}
+
+// collectChunks 读取通道直到关闭。
+func collectChunks(ch <-chan StreamChunk) []StreamChunk {
+	var chunks []StreamChunk
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func TestWrapAppliesMiddlewaresOutermostFirst(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next PipelineInvoker) PipelineInvoker {
+			return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+				order = append(order, name)
+				return next.Trigger(ctx)
+			})
+		}
+	}
+
+	handler := Wrap(staticPipeline(StreamChunk{IsFinal: true}), mark("outer"), nil, mark("inner"))
+	collectChunks(handler.Trigger(PipelineContext{}))
+
+	if strings.Join(order, ",") != "outer,inner" {
+		t.Fatalf("unexpected middleware order: %v", order)
+	}
+}
+
+func TestTrackLatencyReportsAndAppendsSuffix(t *testing.T) {
+	var reported []Latency
+	handler := Wrap(
+		staticPipeline(StreamChunk{Content: "hello"}, StreamChunk{IsFinal: true}),
+		TrackLatency(func(snapshot RequestSnapshot, latency Latency) {
+			reported = append(reported, latency)
+		}, WithLatencySuffix(" (%.1fs)")),
+	)
+
+	chunks := collectChunks(handler.Trigger(PipelineContext{Snapshot: RequestSnapshot{ID: "m1"}}))
+	if len(chunks) != 2 {
+		t.Fatalf("unexpected chunk count: %d", len(chunks))
+	}
+	if !strings.HasPrefix(chunks[1].Content, " (") || !strings.HasSuffix(chunks[1].Content, "s)") {
+		t.Fatalf("unexpected final content: %q", chunks[1].Content)
+	}
+	if len(reported) != 1 {
+		t.Fatalf("expected one report, got %d", len(reported))
+	}
+	if reported[0].FirstChunkAt.IsZero() || reported[0].FinalAt.IsZero() {
+		t.Fatalf("latency timestamps not recorded: %+v", reported[0])
+	}
+	if reported[0].Total() < reported[0].FirstChunk() {
+		t.Fatalf("total shorter than first chunk: %+v", reported[0])
+	}
+}
+
+func TestExclusiveRunsOncePerMessage(t *testing.T) {
+	store := NewMemoryClaimStore()
+	runs := 0
+	handler := Wrap(PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		runs++
+		return singleChunk(StreamChunk{Content: "ok", IsFinal: true})
+	}), Exclusive(store, time.Minute, nil))
+
+	snapshot := RequestSnapshot{ID: "stream-a", Metadata: map[string]string{"msgid": "m1"}}
+	first := collectChunks(handler.Trigger(PipelineContext{Snapshot: snapshot}))
+	snapshot.ID = "stream-b"
+	second := collectChunks(handler.Trigger(PipelineContext{Snapshot: snapshot}))
+
+	if runs != 1 {
+		t.Fatalf("expected handler to run once, got %d", runs)
+	}
+	if first[0].Content != "ok" || second[0].Payload != NoResponse {
+		t.Fatalf("unexpected outputs: first=%+v second=%+v", first, second)
+	}
+}
+
+func TestExclusiveWithRedisClaimStore(t *testing.T) {
+	claimed := make(map[string]bool)
+	store := NewRedisClaimStore(func(_ context.Context, script string, keys []string, args ...any) (any, error) {
+		if script != redisClaimScript || args[0] != int64(60000) {
+			t.Fatalf("unexpected eval: %q %v", script, args)
+		}
+		if claimed[keys[0]] {
+			return int64(0), nil
+		}
+		claimed[keys[0]] = true
+		return int64(1), nil
+	}, "bot:")
+	runs := 0
+	handler := Wrap(PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		runs++
+		return singleChunk(StreamChunk{Content: "ok", IsFinal: true})
+	}), Exclusive(store, time.Minute, nil))
+
+	snapshot := RequestSnapshot{Metadata: map[string]string{"msgid": "m1"}}
+	collectChunks(handler.Trigger(PipelineContext{Snapshot: snapshot}))
+	second := collectChunks(handler.Trigger(PipelineContext{Snapshot: snapshot}))
+
+	if runs != 1 || second[0].Payload != NoResponse {
+		t.Fatalf("expected one run across replicas, got runs=%d second=%+v", runs, second)
+	}
+	if len(claimed) != 1 {
+		t.Fatalf("unexpected claimed keys: %v", claimed)
+	}
+}
+
+type stubTranscriber string
+
+func (s stubTranscriber) Transcribe(ctx context.Context, audio Attachment) (string, error) {
+	return string(s), nil
+}
+
+func TestTranscribeFillsEmptyVoiceText(t *testing.T) {
+	var got RequestSnapshot
+	handler := Wrap(PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		got = ctx.Snapshot
+		return nil
+	}), Transcribe(stubTranscriber("hello from audio")))
+
+	handler.Trigger(PipelineContext{Snapshot: RequestSnapshot{
+		Attachments: []Attachment{{Type: AttachmentTypeVoice, Data: []byte("amr")}},
+	}})
+	if got.Text != "hello from audio" || got.Metadata["transcribed"] != "true" {
+		t.Fatalf("voice not transcribed: %+v", got)
+	}
+
+	handler.Trigger(PipelineContext{Snapshot: RequestSnapshot{
+		Text:        "platform transcript",
+		Attachments: []Attachment{{Type: AttachmentTypeVoice, Data: []byte("amr")}},
+	}})
+	if got.Text != "platform transcript" {
+		t.Fatalf("existing transcript should be kept: %q", got.Text)
+	}
+}
+
+type stubSynthesizer struct{ spoken []string }
+
+func (s *stubSynthesizer) Synthesize(ctx context.Context, text string) (Attachment, error) {
+	s.spoken = append(s.spoken, text)
+	return Attachment{Data: []byte("mp3:" + text)}, nil
+}
+
+func TestSpeakRepliesAttachesVoiceToVoiceQuestions(t *testing.T) {
+	synth := &stubSynthesizer{}
+	handler := Wrap(PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		out := make(chan StreamChunk, 4)
+		out <- ReasoningChunk("thinking")
+		out <- StreamChunk{Content: "hel"}
+		out <- StreamChunk{Content: "lo", IsFinal: true}
+		close(out)
+		return out
+	}), SpeakReplies(synth, nil))
+
+	var final StreamChunk
+	for chunk := range handler.Trigger(PipelineContext{Snapshot: RequestSnapshot{Metadata: map[string]string{"transcribed": "true"}}}) {
+		final = chunk
+	}
+	if len(final.Attachments) != 1 || final.Attachments[0].Type != AttachmentTypeVoice || string(final.Attachments[0].Data) != "mp3:hello" {
+		t.Fatalf("unexpected final chunk: %+v", final)
+	}
+
+	for range handler.Trigger(PipelineContext{Snapshot: RequestSnapshot{Text: "typed"}}) {
+	}
+	if len(synth.spoken) != 1 {
+		t.Fatalf("text questions should not be spoken: %v", synth.spoken)
+	}
+
+	voiceQuestion := RequestSnapshot{Metadata: map[string]string{"transcribed": "true"}}
+	for chunk := range handler.Trigger(PipelineContext{Snapshot: voiceQuestion, Responser: &noVoiceResponser{}}) {
+		final = chunk
+	}
+	if len(synth.spoken) != 1 || len(final.Attachments) != 0 {
+		t.Fatalf("platforms without voice replies should skip synthesis: %v %+v", synth.spoken, final)
+	}
+}
+
+// noVoiceResponser 声明不支持语音回复。
+type noVoiceResponser struct{ retractRecorder }
+
+func (noVoiceResponser) CanSendVoice() bool { return false }
+
+func TestShortCircuitSmallTalk(t *testing.T) {
+	runs := 0
+	handler := Wrap(PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		runs++
+		return singleChunk(StreamChunk{Content: "llm", IsFinal: true})
+	}), ShortCircuitSmallTalk(nil))
+
+	for _, text := range []string{"你好", "Hello!", "谢谢啦~"} {
+		chunks := collectChunks(handler.Trigger(PipelineContext{Snapshot: RequestSnapshot{Text: text}}))
+		if len(chunks) != 1 || chunks[0].Content == "llm" {
+			t.Fatalf("%q should be answered from templates: %+v", text, chunks)
+		}
+	}
+	if runs != 0 {
+		t.Fatalf("small talk should not reach the handler")
+	}
+
+	chunks := collectChunks(handler.Trigger(PipelineContext{Snapshot: RequestSnapshot{Text: "你好，帮我写个脚本"}}))
+	if runs != 1 || chunks[0].Content != "llm" {
+		t.Fatalf("real request should reach the handler: %+v", chunks)
+	}
+}
+
+type retractRecorder struct {
+	retracted []string
+}
+
+func (r *retractRecorder) Response(responseURL string, msg any) error              { return nil }
+func (r *retractRecorder) ResponseMarkdown(responseURL, content string) error      { return nil }
+func (r *retractRecorder) ResponseTemplateCard(responseURL string, card any) error { return nil }
+func (r *retractRecorder) Retract(snapshot RequestSnapshot, messageID string) error {
+	r.retracted = append(r.retracted, messageID)
+	return nil
+}
+
+func TestHandleRevisionsRetractsOnRecall(t *testing.T) {
+	runs := 0
+	handler := Wrap(PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		runs++
+		return singleChunk(StreamChunk{Content: "answer", IsFinal: true})
+	}), HandleRevisions(nil))
+
+	responser := &retractRecorder{}
+	chunks := collectChunks(handler.Trigger(PipelineContext{
+		Snapshot:  RequestSnapshot{Revision: &Revision{Kind: RevisionRecall, MessageID: "m1"}},
+		Responser: responser,
+	}))
+	if runs != 0 || chunks[0].Payload != NoResponse {
+		t.Fatalf("recall should not reach the handler: runs=%d chunks=%+v", runs, chunks)
+	}
+	if len(responser.retracted) != 1 || responser.retracted[0] != "m1" {
+		t.Fatalf("answer not retracted: %v", responser.retracted)
+	}
+
+	collectChunks(handler.Trigger(PipelineContext{
+		Snapshot: RequestSnapshot{Text: "edited", Revision: &Revision{Kind: RevisionEdit, MessageID: "m1"}},
+	}))
+	if runs != 1 {
+		t.Fatalf("edit should re-run the handler, got %d runs", runs)
+	}
+}
+
+func TestCoalesceMergesSmallDeltas(t *testing.T) {
+	handler := Wrap(staticPipeline(
+		StreamChunk{Content: "a"},
+		StreamChunk{Content: "b"},
+		StreamChunk{Content: "cd"},
+		StreamChunk{Content: "e"},
+		StreamChunk{Content: "!", IsFinal: true},
+	), Coalesce(time.Hour, 4))
+
+	chunks := collectChunks(handler.Trigger(PipelineContext{}))
+	if len(chunks) != 2 || chunks[0].Content != "abcd" || chunks[1].Content != "e!" || !chunks[1].IsFinal {
+		t.Fatalf("unexpected coalesced chunks: %+v", chunks)
+	}
+}
+
+func TestCoalesceMergesChunksWithEqualMetadata(t *testing.T) {
+	thread := map[string]string{MetaThreadID: "m1"}
+	handler := Wrap(staticPipeline(
+		StreamChunk{Content: "a", Metadata: thread},
+		StreamChunk{Content: "b", Metadata: map[string]string{MetaThreadID: "m1"}},
+		StreamChunk{Content: "cd", Metadata: thread},
+		StreamChunk{Content: "x", Metadata: map[string]string{MetaThreadID: "other"}},
+		StreamChunk{Content: "!", IsFinal: true, Metadata: thread},
+	), Coalesce(time.Hour, 4))
+
+	chunks := collectChunks(handler.Trigger(PipelineContext{}))
+	if len(chunks) != 3 {
+		t.Fatalf("chunks with equal metadata should coalesce: %+v", chunks)
+	}
+	if chunks[0].Content != "abcd" || chunks[0].Metadata[MetaThreadID] != "m1" {
+		t.Fatalf("merged chunk should keep its metadata: %+v", chunks[0])
+	}
+	if chunks[1].Content != "x" || chunks[1].Metadata[MetaThreadID] != "other" {
+		t.Fatalf("chunks with different metadata must not merge: %+v", chunks[1])
+	}
+	if chunks[2].Content != "!" || !chunks[2].IsFinal || chunks[2].Metadata[MetaThreadID] != "m1" {
+		t.Fatalf("unexpected final chunk: %+v", chunks[2])
+	}
+}
+
+func TestDisclaimerOncePerDay(t *testing.T) {
+	now := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	handler := Wrap(staticPipeline(StreamChunk{Content: "answer", IsFinal: true}),
+		Disclaimer("AI 生成", WithDisclaimerScope(DisclaimerOncePerDay), WithDisclaimerClock(func() time.Time { return now })))
+
+	run := func() string {
+		chunks := collectChunks(handler.Trigger(PipelineContext{Snapshot: RequestSnapshot{ChatID: "c1"}}))
+		return chunks[len(chunks)-1].Content
+	}
+	if got := run(); got != "answer\n\nAI 生成" {
+		t.Fatalf("first answer should carry disclaimer: %q", got)
+	}
+	if got := run(); got != "answer" {
+		t.Fatalf("second answer on the same day should not: %q", got)
+	}
+	now = now.Add(24 * time.Hour)
+	if got := run(); got != "answer\n\nAI 生成" {
+		t.Fatalf("next day should show disclaimer again: %q", got)
+	}
+}
+
+func TestBufferCoalescesWhenConsumerIsSlow(t *testing.T) {
+	handler := Wrap(staticPipeline(
+		StreamChunk{Content: "a"},
+		StreamChunk{Content: "b"},
+		StreamChunk{Content: "c"},
+		StreamChunk{Content: "d", IsFinal: true},
+	), Buffer(1, OverflowCoalesce))
+
+	out := handler.Trigger(PipelineContext{})
+	// 等待生产者写满队列后再开始消费。
+	time.Sleep(20 * time.Millisecond)
+	var text string
+	for chunk := range out {
+		text += chunk.Content
+	}
+	if text != "abcd" {
+		t.Fatalf("coalescing lost content: %q", text)
+	}
+}
+
+func TestBufferCoalescesThreadedChunks(t *testing.T) {
+	handler := Wrap(staticPipeline(
+		StreamChunk{Content: "a"},
+		StreamChunk{Content: "b"},
+		StreamChunk{Content: "c", Metadata: map[string]string{MetaThreadID: "other"}},
+		StreamChunk{Content: "d", IsFinal: true},
+	), Buffer(1, OverflowCoalesce), ReplyInThread())
+
+	out := handler.Trigger(PipelineContext{Snapshot: RequestSnapshot{ID: "m1"}})
+	time.Sleep(20 * time.Millisecond)
+	chunks := collectChunks(out)
+	if len(chunks) != 3 {
+		t.Fatalf("expected chunks of the same thread to coalesce, got %+v", chunks)
+	}
+	if chunks[0].Content != "ab" || chunks[0].Metadata[MetaThreadID] != "m1" {
+		t.Fatalf("unexpected merged chunk: %+v", chunks[0])
+	}
+	if chunks[1].Content != "c" || chunks[1].Metadata[MetaThreadID] != "other" {
+		t.Fatalf("chunks of different threads must not merge: %+v", chunks[1])
+	}
+	if chunks[2].Content != "d" || !chunks[2].IsFinal {
+		t.Fatalf("unexpected final chunk: %+v", chunks[2])
+	}
+}
+
+func TestBufferKeepsTrailingErrorChunk(t *testing.T) {
+	boom := errors.New("boom")
+	handler := Wrap(staticPipeline(
+		StreamChunk{Content: "a"},
+		StreamChunk{Content: "b"},
+		ErrorChunk(boom),
+	), Buffer(1, OverflowCoalesce))
+
+	out := handler.Trigger(PipelineContext{})
+	time.Sleep(20 * time.Millisecond)
+	chunks := collectChunks(out)
+	last := chunks[len(chunks)-1]
+	if !last.IsFinal || !errors.Is(last.Err, boom) {
+		t.Fatalf("error chunk lost while coalescing: %+v", chunks)
+	}
+	var text string
+	for _, chunk := range chunks {
+		text += chunk.Content
+	}
+	if text != "ab" {
+		t.Fatalf("unexpected content: %q", text)
+	}
+}
+
+func TestBufferBlockDegradesAfterTimeout(t *testing.T) {
+	produced := make(chan struct{})
+	handler := Wrap(PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		out := make(chan StreamChunk)
+		go func() {
+			defer close(out)
+			defer close(produced)
+			for i := 0; i < 5; i++ {
+				out <- StreamChunk{Content: "x"}
+			}
+		}()
+		return out
+	}), Buffer(1, OverflowBlock(10*time.Millisecond)))
+
+	out := handler.Trigger(PipelineContext{})
+	select {
+	case <-produced:
+	case <-time.After(time.Second):
+		t.Fatalf("producer wedged by a stalled consumer")
+	}
+	var text string
+	for chunk := range out {
+		text += chunk.Content
+	}
+	if text != "xxxxx" {
+		t.Fatalf("unexpected content: %q", text)
+	}
+}
+
+func TestObserveReportsUpdateAndReplies(t *testing.T) {
+	var updates, replies int
+	handler := Wrap(staticPipeline(StreamChunk{Content: "a"}, StreamChunk{IsFinal: true}), Observe(Hooks{
+		OnUpdate: func(RequestSnapshot) { updates++ },
+		OnReply:  func(RequestSnapshot, StreamChunk) { replies++ },
+	}))
+	collectChunks(handler.Trigger(PipelineContext{}))
+	if updates != 1 || replies != 2 {
+		t.Fatalf("unexpected hook counts: updates=%d replies=%d", updates, replies)
+	}
+}
+
+type spanKey struct{}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+func (r *recordingTracer) Start(ctx context.Context, name string, attrs map[string]string) (context.Context, Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if parent, ok := ctx.Value(spanKey{}).(string); ok {
+		name = parent + "/" + name
+	}
+	if route := attrs["imbot.route"]; route != "" {
+		name += "(" + route + ")"
+	}
+	r.spans = append(r.spans, name)
+	return context.WithValue(ctx, spanKey{}, name), noopSpan{}
+}
+
+func TestTracePropagatesSpanContext(t *testing.T) {
+	tracer := &recordingTracer{}
+	var seen string
+	leaf := PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		seen, _ = ctx.Context().Value(spanKey{}).(string)
+		return staticPipeline(StreamChunk{IsFinal: true}).Trigger(ctx)
+	})
+	chain := NewChain(nil, WithChainTracer(tracer))
+	chain.AddRoute("ai", MatchAny(), leaf)
+
+	collectChunks(Wrap(chain, Trace(tracer, "pipeline")).Trigger(PipelineContext{}))
+	if seen != "pipeline/route(ai)" {
+		t.Fatalf("unexpected span context: %q (spans=%v)", seen, tracer.spans)
+	}
+}
+
+func TestStopCommandCancelsInflightRun(t *testing.T) {
+	started := make(chan struct{})
+	handler := Wrap(PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		out := make(chan StreamChunk)
+		go func() {
+			defer close(out)
+			out <- StreamChunk{Content: "thinking"}
+			close(started)
+			<-ctx.Context().Done()
+		}()
+		return out
+	}), StopCommand())
+
+	snapshot := RequestSnapshot{ChatID: "c1", SenderID: "u1", Text: "long question"}
+	out := handler.Trigger(PipelineContext{Snapshot: snapshot})
+	first := <-out
+	<-started
+
+	snapshot.Text = " /STOP "
+	reply := collectChunks(handler.Trigger(PipelineContext{Snapshot: snapshot}))
+	if len(reply) != 1 || reply[0].Content != "已停止进行中的回答。" {
+		t.Fatalf("unexpected stop reply: %+v", reply)
+	}
+	rest := collectChunks(out)
+	if first.Content != "thinking" || len(rest) != 1 || !rest[0].IsFinal || rest[0].Content != Localize(RequestSnapshot{}, MsgStopped) {
+		t.Fatalf("unexpected stopped output: first=%+v rest=%+v", first, rest)
+	}
+
+	reply = collectChunks(handler.Trigger(PipelineContext{Snapshot: snapshot}))
+	if reply[0].Content != "当前没有进行中的回答。" {
+		t.Fatalf("unexpected idle stop reply: %+v", reply)
+	}
+}
+
+func TestRenderErrorsUsesRendererAndReports(t *testing.T) {
+	var reported error
+	boom := errors.New("boom")
+	handler := Wrap(staticPipeline(StreamChunk{Content: "partial"}, ErrorChunk(boom)), RenderErrors(nil, func(_ RequestSnapshot, err error) {
+		reported = err
+	}))
+	chunks := collectChunks(handler.Trigger(PipelineContext{}))
+	last := chunks[len(chunks)-1]
+	if reported != boom || last.Err != nil || !last.IsFinal || last.Content != "❌ 处理出错: boom" {
+		t.Fatalf("unexpected rendering: reported=%v last=%+v", reported, last)
+	}
+}
+
+func TestCollectFeedbackCorrelatesAnswer(t *testing.T) {
+	sink := NewMemoryFeedbackSink()
+	handler := Wrap(staticPipeline(StreamChunk{Content: "42"}, StreamChunk{IsFinal: true}), CollectFeedback(sink))
+
+	question := RequestSnapshot{ChatID: "c1", Text: "answer?", Metadata: map[string]string{"msgid": "m1"}}
+	collectChunks(handler.Trigger(PipelineContext{Snapshot: question}))
+
+	event := RequestSnapshot{ChatID: "c1", SenderID: "u1", Feedback: &Feedback{ID: "m1", Kind: FeedbackNegative, Reasons: []string{"2"}}}
+	reply := collectChunks(handler.Trigger(PipelineContext{Snapshot: event}))
+	if len(reply) != 1 || reply[0].Payload != NoResponse {
+		t.Fatalf("feedback event should not be answered: %+v", reply)
+	}
+	records := sink.Records()
+	if len(records) != 1 || records[0].Question != "answer?" || records[0].Answer != "42" || records[0].Kind != FeedbackNegative {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+}
+
+func TestRecoverCatchesSyncAndStreamPanics(t *testing.T) {
+	var reported []*PanicError
+	report := func(_ RequestSnapshot, err *PanicError) {
+		reported = append(reported, err)
+	}
+
+	syncPanic := Wrap(PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		panic("sync boom")
+	}), Recover(report))
+	chunks := collectChunks(syncPanic.Trigger(PipelineContext{}))
+	if len(chunks) != 1 || !chunks[0].IsFinal || chunks[0].Err == nil {
+		t.Fatalf("unexpected sync panic chunks: %+v", chunks)
+	}
+
+	streamPanic := Wrap(StreamFunc(func(ctx PipelineContext, out chan<- StreamChunk) {
+		out <- StreamChunk{Content: "partial"}
+		panic("stream boom")
+	}), Recover(report))
+	chunks = collectChunks(streamPanic.Trigger(PipelineContext{}))
+	if len(chunks) != 2 || chunks[0].Content != "partial" || chunks[1].Err == nil || !chunks[1].IsFinal {
+		t.Fatalf("unexpected stream panic chunks: %+v", chunks)
+	}
+
+	if len(reported) != 2 || reported[0].Value != "sync boom" || reported[1].Value != "stream boom" || len(reported[1].Stack) == 0 {
+		t.Fatalf("unexpected reports: %+v", reported)
+	}
+}
+
+func TestRateLimitRejectsBurstAndRefills(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+	handler := Wrap(staticPipeline(StreamChunk{Content: "ok", IsFinal: true}), RateLimit(2, time.Minute, nil, WithRateLimitStore(store)))
+
+	request := PipelineContext{Snapshot: RequestSnapshot{SenderID: "u1"}}
+	for i := 0; i < 2; i++ {
+		if chunks := collectChunks(handler.Trigger(request)); chunks[0].Content != "ok" {
+			t.Fatalf("request %d should pass: %+v", i, chunks)
+		}
+	}
+	chunks := collectChunks(handler.Trigger(request))
+	if len(chunks) != 1 || chunks[0].Content != "请求过于频繁，请 30 秒后再试。" {
+		t.Fatalf("third request should be limited: %+v", chunks)
+	}
+	if chunks := collectChunks(handler.Trigger(PipelineContext{Snapshot: RequestSnapshot{SenderID: "u2"}})); chunks[0].Content != "ok" {
+		t.Fatalf("other sender should pass: %+v", chunks)
+	}
+
+	now = now.Add(30 * time.Second)
+	if chunks := collectChunks(handler.Trigger(request)); chunks[0].Content != "ok" {
+		t.Fatalf("refilled token should pass: %+v", chunks)
+	}
+}
+
+func TestRedisRateLimitStoreParsesScriptResult(t *testing.T) {
+	var gotKeys []string
+	store := NewRedisRateLimitStore(func(_ context.Context, _ string, keys []string, args ...any) (any, error) {
+		gotKeys = keys
+		return []any{int64(0), int64(1500)}, nil
+	}, "bot:")
+	allowed, retryAfter, err := store.Take(context.Background(), "ratelimit:u1", 5, time.Minute)
+	if err != nil || allowed || retryAfter != 1500*time.Millisecond || gotKeys[0] != "bot:ratelimit:u1" {
+		t.Fatalf("unexpected result: allowed=%v retryAfter=%v err=%v keys=%v", allowed, retryAfter, err, gotKeys)
+	}
+}
+
+func TestFanOutMergeModes(t *testing.T) {
+	var logged int
+	var mu sync.Mutex
+	logger := PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		mu.Lock()
+		logged++
+		mu.Unlock()
+		return singleChunk(StreamChunk{Payload: NoResponse, IsFinal: true})
+	})
+	branches := []FanOutBranch{
+		{Label: "日志", Handler: logger},
+		{Label: "天气", Handler: staticPipeline(StreamChunk{Content: "晴"}, StreamChunk{Content: "，25°C", IsFinal: true})},
+		{Label: "新闻", Handler: staticPipeline(StreamChunk{Content: "无", IsFinal: true})},
+	}
+	text := func(chunks []StreamChunk) string {
+		var b strings.Builder
+		for _, chunk := range chunks {
+			b.WriteString(chunk.Content)
+		}
+		return b.String()
+	}
+	cases := map[FanOutMode]string{
+		FanOutFirst:    "晴，25°C",
+		FanOutConcat:   "晴，25°C\n\n无",
+		FanOutSections: "**天气**\n\n晴，25°C\n\n**新闻**\n\n无",
+	}
+	for mode, want := range cases {
+		chunks := collectChunks(FanOut(mode, branches...).Trigger(PipelineContext{}))
+		finals := 0
+		for _, chunk := range chunks {
+			if chunk.IsFinal {
+				finals++
+			}
+		}
+		if got := text(chunks); got != want || finals != 1 || !chunks[len(chunks)-1].IsFinal {
+			t.Fatalf("mode %d: got %q (finals=%d), want %q", mode, got, finals, want)
+		}
+	}
+	if logged != len(cases) {
+		t.Fatalf("logger branch should run for every request, ran %d times", logged)
+	}
+
+	silent := collectChunks(FanOut(FanOutConcat, branches[0]).Trigger(PipelineContext{}))
+	if len(silent) != 1 || silent[0].Payload != NoResponse {
+		t.Fatalf("all-empty branches should yield NoResponse: %+v", silent)
+	}
+}
+
+func TestFanOutBoundsBufferedBranch(t *testing.T) {
+	produced := make(chan struct{})
+	slow := PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		out := make(chan StreamChunk)
+		go func() {
+			defer close(out)
+			// 关键步骤：等后续分支全部输出完毕再结束，使其输出只能进入缓冲。
+			<-produced
+			out <- StreamChunk{Content: "first", IsFinal: true}
+		}()
+		return out
+	})
+	chatty := PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		out := make(chan StreamChunk)
+		go func() {
+			defer close(out)
+			defer close(produced)
+			for i := 0; i < 1000; i++ {
+				out <- StreamChunk{Content: "x"}
+			}
+		}()
+		return out
+	})
+
+	chunks := collectChunks(FanOut(FanOutConcat, FanOutBranch{Handler: slow}, FanOutBranch{Handler: chatty}).Trigger(PipelineContext{}))
+	var text strings.Builder
+	for _, chunk := range chunks {
+		text.WriteString(chunk.Content)
+	}
+	if text.String() != "first\n\n"+strings.Repeat("x", 1000) {
+		t.Fatalf("buffered output lost: %d bytes", text.Len())
+	}
+	// first + 分隔符 + 不超过缓冲上限的合并片段 + 结束包
+	if len(chunks) > fanOutBufferSize+3 {
+		t.Fatalf("expected buffered chunks to be coalesced, got %d chunks", len(chunks))
+	}
+}
+
+func TestTimeoutTruncatesAndCancelsDownstream(t *testing.T) {
+	canceled := make(chan struct{})
+	slow := PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		out := make(chan StreamChunk)
+		go func() {
+			defer close(out)
+			out <- StreamChunk{Content: "partial"}
+			<-ctx.Context().Done()
+			close(canceled)
+		}()
+		return out
+	})
+
+	chunks := collectChunks(Wrap(slow, Timeout(20*time.Millisecond)).Trigger(PipelineContext{}))
+	if len(chunks) != 2 || chunks[0].Content != "partial" || chunks[1].Content != Localize(RequestSnapshot{}, MsgTruncated) || !chunks[1].IsFinal {
+		t.Fatalf("unexpected chunks: %+v", chunks)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("downstream context was not canceled")
+	}
+
+	fast := collectChunks(Wrap(staticPipeline(StreamChunk{Content: "done", IsFinal: true}), Timeout(time.Second)).Trigger(PipelineContext{}))
+	if len(fast) != 1 || fast[0].Content != "done" {
+		t.Fatalf("fast pipeline should pass through: %+v", fast)
+	}
+}
+
+func TestChunkTypesDropAndQuote(t *testing.T) {
+	pipeline := staticPipeline(
+		ReasoningChunk("step 1\nstep 2"),
+		ToolCallChunk("call-1", "search", `{"q":"go"}`),
+		StreamChunk{Content: "answer"},
+		StreamChunk{IsFinal: true},
+	)
+
+	dropped := collectChunks(Wrap(pipeline, DropChunkTypes(ChunkReasoning, ChunkToolCall)).Trigger(PipelineContext{}))
+	if len(dropped) != 2 || dropped[0].Content != "answer" || !dropped[1].IsFinal {
+		t.Fatalf("unexpected dropped output: %+v", dropped)
+	}
+
+	var text strings.Builder
+	for _, chunk := range collectChunks(Wrap(pipeline, DropChunkTypes(ChunkToolCall), QuoteReasoning()).Trigger(PipelineContext{})) {
+		if chunk.Type != ChunkContent {
+			t.Fatalf("reasoning should render as content: %+v", chunk)
+		}
+		text.WriteString(chunk.Content)
+	}
+	if text.String() != "> step 1\n> step 2\n\nanswer" {
+		t.Fatalf("unexpected quoted output: %q", text.String())
+	}
+}
+
+func TestEnrichPopulatesMetadataBeforeRouting(t *testing.T) {
+	var seen RequestSnapshot
+	var reported error
+	pipeline := Wrap(PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		seen = ctx.Snapshot
+		return nil
+	}), Enrich(func(snapshot RequestSnapshot, err error) { reported = err },
+		DetectLanguage(),
+		TagPII(),
+		LookupProfile(func(ctx context.Context, senderID string) (map[string]string, error) {
+			return map[string]string{"dept": "sales"}, nil
+		}),
+		EnricherFunc(func(ctx context.Context, snapshot *RequestSnapshot) error {
+			snapshot.Metadata["partial"] = "true"
+			return errors.New("lookup down")
+		}),
+	))
+
+	original := map[string]string{"msgid": "m1"}
+	pipeline.Trigger(PipelineContext{Snapshot: RequestSnapshot{SenderID: "u1", Text: "联系我 13812345678", Metadata: original}})
+	if seen.Metadata[MetaLanguage] != "zh" || seen.Metadata[MetaPII] != "phone" || seen.Metadata["profile.dept"] != "sales" {
+		t.Fatalf("unexpected metadata: %v", seen.Metadata)
+	}
+	if seen.Metadata["partial"] != "" || reported == nil {
+		t.Fatalf("failed enricher should be discarded and reported: %v, %v", seen.Metadata, reported)
+	}
+	if len(original) != 1 {
+		t.Fatalf("caller metadata mutated: %v", original)
+	}
+}
+
+func TestTagPIIDistinguishesIDCardsFromBankCards(t *testing.T) {
+	cases := map[string]string{
+		"身份证 110105199003071239":                        "id_card",
+		"身份证 11010519491231002X":                        "id_card",
+		"卡号 6222021234567890128":                        "bank_card",
+		"订单号 1234567890123456":                          "",
+		"卡号 6222021234567890128，身份证 110105199003071247": "bank_card,id_card",
+	}
+	for text, want := range cases {
+		snapshot := RequestSnapshot{Text: text, Metadata: map[string]string{}}
+		if err := TagPII().Enrich(context.Background(), &snapshot); err != nil {
+			t.Fatal(err)
+		}
+		if got := snapshot.Metadata[MetaPII]; got != want {
+			t.Errorf("TagPII(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestReplyInThreadTagsChunks(t *testing.T) {
+	pipeline := Wrap(staticPipeline(
+		StreamChunk{Content: "a"},
+		InThread(StreamChunk{Content: "b"}, "other"),
+		StreamChunk{IsFinal: true},
+	), ReplyInThread())
+
+	chunks := collectChunks(pipeline.Trigger(PipelineContext{Snapshot: RequestSnapshot{ID: "m1"}}))
+	if chunks[0].Metadata[MetaThreadID] != "m1" || chunks[1].Metadata[MetaThreadID] != "other" || chunks[2].Metadata[MetaThreadID] != "m1" {
+		t.Fatalf("unexpected thread tags: %+v", chunks)
+	}
+	chunks = collectChunks(pipeline.Trigger(PipelineContext{Snapshot: RequestSnapshot{ID: "m2", ThreadID: "t1"}}))
+	if chunks[0].Metadata[MetaThreadID] != "t1" {
+		t.Fatalf("reply should stay in existing thread: %+v", chunks[0])
+	}
+}
+
+func TestNormalizeMarkdownAcrossDeltas(t *testing.T) {
+	source := "- [x] done ~~old~~ <b>new</b>\n`~~keep~~` <@alice>\n```\n~~code~~\n```\n"
+	var deltas []StreamChunk
+	for _, r := range source {
+		deltas = append(deltas, StreamChunk{Content: string(r)})
+	}
+	deltas = append(deltas, StreamChunk{IsFinal: true})
+
+	var text strings.Builder
+	for _, chunk := range collectChunks(Wrap(staticPipeline(deltas...), NormalizeMarkdown(MarkdownDialectWeCom)).Trigger(PipelineContext{})) {
+		text.WriteString(chunk.Content)
+	}
+	want := "- ☑ done old **new**\n`~~keep~~` <@alice>\n```\n~~code~~\n```\n"
+	if text.String() != want {
+		t.Fatalf("unexpected normalized markdown:\n%q\nwant\n%q", text.String(), want)
+	}
+}
+
+func TestLimitBytesTruncatesOnRuneBoundary(t *testing.T) {
+	pipeline := Wrap(staticPipeline(
+		StreamChunk{Content: "你好"},
+		StreamChunk{Content: "世界"},
+		StreamChunk{Content: "!"},
+		StreamChunk{IsFinal: true},
+	), LimitBytes(10, "…"))
+
+	var text strings.Builder
+	for _, chunk := range collectChunks(pipeline.Trigger(PipelineContext{})) {
+		text.WriteString(chunk.Content)
+	}
+	if text.String() != "你好…" {
+		t.Fatalf("unexpected truncated output: %q", text.String())
+	}
+}
+
+func TestSplitTextKeepsFencesBalanced(t *testing.T) {
+	text := "intro paragraph\n\n```go\nline one\nline two\nline three\n```\ntail"
+	pages := SplitText(text, 24)
+	if len(pages) < 2 {
+		t.Fatalf("expected multiple pages: %q", pages)
+	}
+	for _, page := range pages {
+		if len(page) > 24 || countFences(page)%2 != 0 {
+			t.Fatalf("page exceeds limit or has unbalanced fence: %q", pages)
+		}
+	}
+}
+
+type markdownRecorder struct {
+	retractRecorder
+	sent []string
+}
+
+func (r *markdownRecorder) ResponseMarkdown(responseURL, content string) error {
+	r.sent = append(r.sent, content)
+	return nil
+}
+
+func TestSplitLongReplySendsFollowUps(t *testing.T) {
+	long := strings.Repeat("a", 150) + " " + strings.Repeat("b", 150)
+	recorder := &markdownRecorder{}
+	pipeline := Wrap(staticPipeline(StreamChunk{Content: long}, StreamChunk{IsFinal: true}), SplitLongReply(200, 1))
+
+	ctx := PipelineContext{Snapshot: RequestSnapshot{ResponseURL: "https://example.com/r"}, Responser: recorder}
+	chunks := collectChunks(pipeline.Trigger(ctx))
+	if !strings.HasSuffix(chunks[0].Content, Localize(RequestSnapshot{}, MsgReplyContinued)) || len(chunks[0].Content) > 200 {
+		t.Fatalf("unexpected passive part: %q", chunks[0].Content)
+	}
+	if len(recorder.sent) != 1 || !strings.HasSuffix(recorder.sent[0], PageFooter(RequestSnapshot{}, 2, 2)) {
+		t.Fatalf("unexpected follow-ups: %q", recorder.sent)
+	}
+	passive := strings.TrimSuffix(chunks[0].Content, Localize(RequestSnapshot{}, MsgReplyContinued))
+	rest := strings.TrimSuffix(recorder.sent[0], PageFooter(RequestSnapshot{}, 2, 2))
+	if !strings.HasSuffix(rest, Localize(RequestSnapshot{}, MsgReplyOversize)) || !strings.HasPrefix(long, passive) {
+		t.Fatalf("follow-up should continue the passive part and note truncation: %q", rest)
+	}
+}
+
+func TestDedupReplaysFirstReply(t *testing.T) {
+	var runs int
+	pipeline := Wrap(PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		runs++
+		return staticPipeline(StreamChunk{Content: "hello "}, StreamChunk{Content: "world", IsFinal: true}).Trigger(ctx)
+	}), Dedup(NewMemoryDedupStore(), time.Minute, WithDedupReplay()))
+
+	ctx := PipelineContext{Snapshot: RequestSnapshot{Metadata: map[string]string{"msgid": "m1"}}}
+	collectChunks(pipeline.Trigger(ctx))
+	replayed := collectChunks(pipeline.Trigger(ctx))
+	if runs != 1 || len(replayed) != 1 || replayed[0].Content != "hello world" || !replayed[0].IsFinal {
+		t.Fatalf("unexpected replay: runs=%d chunks=%+v", runs, replayed)
+	}
+}
+
+func TestRedisDedupStoreParsesScriptResults(t *testing.T) {
+	store := NewRedisDedupStore(func(_ context.Context, script string, keys []string, args ...any) (any, error) {
+		if script == redisGetScript {
+			return []any{int64(1), "cached"}, nil
+		}
+		return int64(0), nil
+	}, "bot:")
+	claimed, err := store.Claim(context.Background(), "dedup:m1", time.Minute)
+	if err != nil || claimed {
+		t.Fatalf("unexpected claim: %v %v", claimed, err)
+	}
+	reply, ok, err := store.LoadReply(context.Background(), "dedup:m1")
+	if err != nil || !ok || reply.Content != "cached" {
+		t.Fatalf("unexpected reply: %+v %v %v", reply, ok, err)
+	}
+}
+
+func TestConcurrencySerializesPerKey(t *testing.T) {
+	release := make(chan struct{})
+	var running, peak int
+	var mu sync.Mutex
+	handler := PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		out := make(chan StreamChunk)
+		go func() {
+			defer close(out)
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+			<-release
+			mu.Lock()
+			running--
+			mu.Unlock()
+			out <- StreamChunk{Content: ctx.Snapshot.Text, IsFinal: true}
+		}()
+		return out
+	})
+	ctx := func(text string) PipelineContext {
+		return PipelineContext{Snapshot: RequestSnapshot{SenderID: "u1", Text: text}}
+	}
+
+	queued := Wrap(handler, Concurrency(1, nil))
+	first, second := queued.Trigger(ctx("a")), queued.Trigger(ctx("b"))
+	close(release)
+	if got := collectChunks(first); got[0].Content != "a" {
+		t.Fatalf("unexpected first output: %+v", got)
+	}
+	if got := collectChunks(second); got[0].Content != "b" || peak != 1 {
+		t.Fatalf("second run should be queued: %+v peak=%d", got, peak)
+	}
+
+	block := make(chan struct{})
+	rejecting := Wrap(PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		out := make(chan StreamChunk)
+		go func() {
+			defer close(out)
+			<-block
+		}()
+		return out
+	}), Concurrency(1, nil, WithConcurrencyWait(0)))
+	inflight := rejecting.Trigger(ctx("a"))
+	if got := collectChunks(rejecting.Trigger(ctx("b"))); len(got) != 1 || got[0].Content != Localize(RequestSnapshot{}, MsgConcurrencyBusy) {
+		t.Fatalf("expected rejection: %+v", got)
+	}
+	close(block)
+	collectChunks(inflight)
+}
+
+// englishSnapshot 返回语言为英文的快照。
+func englishSnapshot(text string) RequestSnapshot {
+	return RequestSnapshot{ChatID: "c1", SenderID: "u1", Text: text, Metadata: map[string]string{MetaLanguage: "en"}}
+}
+
+func TestStopCommandRepliesInEnglish(t *testing.T) {
+	started := make(chan struct{})
+	handler := Wrap(PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		out := make(chan StreamChunk)
+		go func() {
+			defer close(out)
+			close(started)
+			<-ctx.Context().Done()
+		}()
+		return out
+	}), StopCommand())
+
+	out := handler.Trigger(PipelineContext{Snapshot: englishSnapshot("long question")})
+	<-started
+	reply := collectChunks(handler.Trigger(PipelineContext{Snapshot: englishSnapshot("/stop")}))
+	if len(reply) != 1 || reply[0].Content != "Stopped the answer in progress." {
+		t.Fatalf("unexpected stop reply: %+v", reply)
+	}
+	if rest := collectChunks(out); len(rest) != 1 || rest[0].Content != "\n\n> Generation stopped." {
+		t.Fatalf("unexpected stopped notice: %+v", rest)
+	}
+}
+
+func TestTimeoutNoticeInEnglish(t *testing.T) {
+	slow := PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		out := make(chan StreamChunk)
+		go func() {
+			defer close(out)
+			<-ctx.Context().Done()
+		}()
+		return out
+	})
+	chunks := collectChunks(Wrap(slow, Timeout(10*time.Millisecond)).Trigger(PipelineContext{Snapshot: englishSnapshot("q")}))
+	if len(chunks) != 1 || chunks[0].Content != "\n\n> Timed out, the answer was cut short." {
+		t.Fatalf("unexpected timeout notice: %+v", chunks)
+	}
+}
+
+func TestSplitLongReplyNoticesInEnglish(t *testing.T) {
+	long := strings.Repeat("a", 150) + " " + strings.Repeat("b", 150)
+	recorder := &markdownRecorder{}
+	snapshot := englishSnapshot("q")
+	snapshot.ResponseURL = "https://example.com/r"
+	chunks := collectChunks(Wrap(staticPipeline(StreamChunk{Content: long}, StreamChunk{IsFinal: true}), SplitLongReply(200, 1)).
+		Trigger(PipelineContext{Snapshot: snapshot, Responser: recorder}))
+	if !strings.HasSuffix(chunks[0].Content, "\n\n(Continued in the next message)") {
+		t.Fatalf("unexpected passive part: %q", chunks[0].Content)
+	}
+	if len(recorder.sent) != 1 || !strings.HasSuffix(recorder.sent[0], "… (Too long, truncated)\n\n(2/2)") {
+		t.Fatalf("unexpected follow-ups: %q", recorder.sent)
+	}
+}
+
+func TestSmallTalkRepliesInEnglish(t *testing.T) {
+	handler := Wrap(staticPipeline(StreamChunk{Content: "llm", IsFinal: true}), ShortCircuitSmallTalk(nil))
+	english := map[string]bool{
+		Messages.Lookup("en", MsgGreeting1): true,
+		Messages.Lookup("en", MsgGreeting2): true,
+	}
+	chunks := collectChunks(handler.Trigger(PipelineContext{Snapshot: englishSnapshot("Hello!")}))
+	if len(chunks) != 1 || !english[chunks[0].Content] {
+		t.Fatalf("expected an english greeting: %+v", chunks)
+	}
+}
+
+func TestLocalizeUsesSnapshotLanguage(t *testing.T) {
+	en := RequestSnapshot{Metadata: map[string]string{MetaLanguage: "en-US"}}
+	if got := Localize(en, MsgRateLimited, 3); got != "Too many requests, please try again in 3 seconds." {
+		t.Fatalf("unexpected english message: %q", got)
+	}
+	und := RequestSnapshot{Metadata: map[string]string{MetaLanguage: "und"}}
+	if got := Localize(und, MsgRateLimited, 3); got != "请求过于频繁，请 3 秒后再试。" {
+		t.Fatalf("expected fallback to chinese: %q", got)
+	}
+
+	catalog := NewCatalog("en")
+	catalog.Add("fr", map[string]string{"greet": "bonjour %s"})
+	if got := catalog.Sprintf("fr_FR", "greet", "bob"); got != "bonjour bob" {
+		t.Fatalf("unexpected regional lookup: %q", got)
+	}
+	if got := catalog.Lookup("de", "missing"); got != "missing" {
+		t.Fatalf("expected key for missing message: %q", got)
+	}
+
+	chunk := DefaultErrorRenderer(en, ErrorChunk(errors.New("boom")))
+	if !strings.HasPrefix(chunk.Content, "❌ Something went wrong") {
+		t.Fatalf("unexpected localized error: %q", chunk.Content)
+	}
+}
@@ -0,0 +1,110 @@
+package botcore
+
+import "strings"
+
+// Message 是平台无关的出站消息，作为 StreamChunk.Payload 发送，由平台适配层翻译为具体协议，
+// 使 handler 无需依赖平台结构（如 wecom.TemplateCard）即可在不同平台间复用。
+// 平台无法原生承载的消息按 MessageText 降级为文本。请以值类型发送。
+type Message interface {
+	outboundMessage()
+}
+
+// TextMessage 为纯文本消息。
+type TextMessage struct {
+	Text string
+}
+
+// MarkdownMessage 为 Markdown 消息；不支持 Markdown 的平台按原文展示。
+type MarkdownMessage struct {
+	Content string
+}
+
+// Card 为平台无关的卡片消息。
+// 平台约束不同：企业微信的无按钮卡片必须提供 URL，否则降级为文本。
+type Card struct {
+	Title       string       // 标题
+	Description string       // 描述
+	Fields      []CardField  // 键值列表
+	URL         string       // 点击卡片跳转的地址（可为空）
+	Buttons     []CardButton // 交互按钮，点击后随卡片交互事件回传（企业微信为 Metadata 中的 task_id / event_key）
+	TaskID      string       // 交互卡片 ID，用于关联点击事件；为空时由平台适配层生成
+}
+
+// CardField 为卡片中的一行键值。
+type CardField struct {
+	Name  string
+	Value string
+}
+
+// CardButton 为卡片按钮。
+type CardButton struct {
+	Text  string // 按钮文案
+	Value string // 按钮值，点击后回传
+}
+
+// Image 为图片消息；优先使用 Data，平台不支持原始字节时使用 URL。
+type Image struct {
+	Data []byte
+	URL  string
+}
+
+// File 为文件消息；平台不支持文件时降级为下载链接。
+type File struct {
+	Name string
+	Data []byte
+	URL  string
+}
+
+func (TextMessage) outboundMessage()     {}
+func (MarkdownMessage) outboundMessage() {}
+func (Card) outboundMessage()            {}
+func (Image) outboundMessage()           {}
+func (File) outboundMessage()            {}
+
+// MessageChunk 构造携带出站消息的非最终片段。
+func MessageChunk(msg Message) StreamChunk {
+	return StreamChunk{Payload: msg}
+}
+
+// MessageText 将出站消息降级为 Markdown 文本，供不支持该消息类型的平台使用。
+// Parameters:
+//   - msg: 出站消息
+//
+// Returns:
+//   - string: 降级文本（无可展示内容时为空）
+func MessageText(msg Message) string {
+	switch m := msg.(type) {
+	case TextMessage:
+		return m.Text
+	case MarkdownMessage:
+		return m.Content
+	case Card:
+		var lines []string
+		if m.Title != "" {
+			lines = append(lines, "**"+m.Title+"**")
+		}
+		if m.Description != "" {
+			lines = append(lines, m.Description)
+		}
+		for _, field := range m.Fields {
+			lines = append(lines, field.Name+"："+field.Value)
+		}
+		if m.URL != "" {
+			lines = append(lines, "[查看详情]("+m.URL+")")
+		}
+		return strings.Join(lines, "\n")
+	case Image:
+		if m.URL != "" {
+			return "![图片](" + m.URL + ")"
+		}
+	case File:
+		name := m.Name
+		if name == "" {
+			name = "文件"
+		}
+		if m.URL != "" {
+			return "[" + name + "](" + m.URL + ")"
+		}
+	}
+	return ""
+}
@@ -1,16 +1,56 @@
 package botcore
 
+import "context"
+
 // StreamChunk 描述流式输出片段。
 type StreamChunk struct {
 	Content string
 	Payload any // 扩展：支持携带复杂对象（如 TemplateCard），用于非流式回复
 	IsFinal bool
+	// Attachments 为流式结束包携带的图文混排附件（如生成的图片）。
+	// 平台支持度不同：企业微信仅在 IsFinal=true 时生效，且只接受带 Data 的图片附件。
+	Attachments []Attachment
+	// Err 非空表示处理失败；Content 为可选的兜底文本。
+	// 平台适配层或 RenderErrors 中间件通过 ErrorRenderer 将其渲染为用户可见的回复。
+	Err error
+	// Type 区分正文、推理过程与工具调用等片段（零值为 ChunkContent），参见 ChunkType。
+	Type ChunkType
+	// Metadata 为片段的扩展键值，如工具名称（MetaToolName）、调用 ID 等。
+	Metadata map[string]string
+}
+
+// ImageChunk 构造携带图片原始字节的非最终片段。
+// 对仅支持在结束包展示图片的平台（如企业微信），适配层会暂存图片并合并到最终片段。
+// Parameters:
+//   - data: 图片原始字节（JPG/PNG）
+//
+// Returns:
+//   - StreamChunk: 携带图片附件的片段
+func ImageChunk(data []byte) StreamChunk {
+	return StreamChunk{
+		Attachments: []Attachment{{Type: AttachmentTypeImage, Data: data}},
+	}
 }
 
 // NoResponse 是一个哨兵值，用于标记不需要被动回复。
 // 当 StreamChunk.Payload == NoResponse 时，Bot 层应直接返回 HTTP 200 OK 空包。
 var NoResponse = struct{}{}
 
+// CardUpdate 是平台无关的“更新模板卡片”负载，用于响应卡片交互事件。
+// 作为 StreamChunk.Payload 发送，由平台适配层编码为具体协议（如企业微信 update_template_card）。
+type CardUpdate struct {
+	Card    any      // 平台卡片结构（如 *wecom.TemplateCard）
+	UserIDs []string // 仅替换指定用户的卡片；为空表示替换所有人
+}
+
+// StreamCard 是平台无关的“随流式回答展示的卡片”负载（如 AI 回答下方的反馈卡片）。
+// 作为任意片段的 Payload 发送时不会打断文本累积：平台适配层暂存卡片，并在最终片段中与完整回答一并输出
+// （企业微信编码为 stream + template_card）。多次发送时以最后一张为准；最终片段自带无法携带卡片的负载时，
+// 卡片单独发送（企业微信经 response_url 或长连接推送）。
+type StreamCard struct {
+	Card any // 平台卡片结构（如 *wecom.TemplateCard）
+}
+
 // PipelineContext 承载 Pipeline 执行所需的显式上下文。
 // Fields:
 //   - Snapshot: 标准化首包快照
@@ -18,6 +58,23 @@ var NoResponse = struct{}{}
 type PipelineContext struct {
 	Snapshot  RequestSnapshot
 	Responser Responser
+
+	// ctx 为请求级 context.Context（链路追踪、取消信号），通过 WithContext 设置。
+	ctx context.Context
+}
+
+// Context 返回请求级 context.Context；未设置时返回 context.Background()。
+func (c PipelineContext) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// WithContext 返回替换了 context.Context 的副本（ctx 为 nil 时视为 context.Background()）。
+func (c PipelineContext) WithContext(ctx context.Context) PipelineContext {
+	c.ctx = ctx
+	return c
 }
 
 // PipelineInvoker 抽象命令/业务执行器。
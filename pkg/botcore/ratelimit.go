@@ -0,0 +1,243 @@
+package botcore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitStore 提供令牌桶限流能力。
+// 多副本部署时应使用共享存储实现（如 RedisRateLimitStore），保证限额在副本间共享。
+type RateLimitStore interface {
+	// Take 尝试从 key 对应的令牌桶中取出一个令牌。
+	// 令牌桶容量为 limit，每 window 匀速补满。
+	// Returns:
+	//   - bool: true 表示放行
+	//   - time.Duration: 被拒绝时距下一个令牌可用的等待时间
+	//   - error: 存储不可用时返回
+	Take(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error)
+}
+
+// SenderKey 按发送者限流。
+func SenderKey(snapshot RequestSnapshot) string {
+	return snapshot.SenderID
+}
+
+// ChatKey 按会话限流（群聊内所有成员共享限额）。
+func ChatKey(snapshot RequestSnapshot) string {
+	return snapshot.ChatID
+}
+
+// RateLimitOption 自定义 RateLimit 行为。
+type RateLimitOption func(*rateLimitConfig)
+
+type rateLimitConfig struct {
+	store RateLimitStore
+	reply func(retryAfter time.Duration) string // 为 nil 时使用本地化的默认回复
+}
+
+// WithRateLimitStore 设置限流存储（默认每个中间件独立的 MemoryRateLimitStore）。
+// 多个路由共享同一存储时，应在 KeyFunc 中加入路由前缀以区分限额。
+func WithRateLimitStore(store RateLimitStore) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		if store != nil {
+			c.store = store
+		}
+	}
+}
+
+// WithRateLimitReply 自定义被限流时的回复文本（默认按快照语言回复 MsgRateLimited）；返回空字符串表示静默丢弃。
+func WithRateLimitReply(reply func(retryAfter time.Duration) string) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		if reply != nil {
+			c.reply = reply
+		}
+	}
+}
+
+// defaultRateLimitReply 为默认的限流回复，按快照语言本地化。
+func defaultRateLimitReply(snapshot RequestSnapshot, retryAfter time.Duration) string {
+	return Localize(snapshot, MsgRateLimited, RetrySeconds(retryAfter))
+}
+
+// RetrySeconds 将等待时间向上取整为秒（至少 1 秒），用于“请 N 秒后再试”一类的提示。
+func RetrySeconds(retryAfter time.Duration) int {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// RateLimit 返回令牌桶限流中间件：同一键在 window 内最多执行 limit 次（允许突发，匀速恢复），
+// 超出时直接回复提示而不调用下游。存储出错时放行，避免因存储故障导致服务不可用。
+// 用于单条路由时包装该路由的 handler，如 chain.AddRoute("ai", m, Wrap(h, RateLimit(...)))。
+// Parameters:
+//   - limit: 窗口内允许的次数（<=0 表示不限流）
+//   - window: 窗口长度
+//   - key: 键提取函数；为 nil 时使用 SenderKey，返回空字符串时跳过限流
+//   - opts: 可选配置（存储、回复文本）
+//
+// Returns:
+//   - Middleware: 限流中间件
+func RateLimit(limit int, window time.Duration, key KeyFunc, opts ...RateLimitOption) Middleware {
+	if key == nil {
+		key = SenderKey
+	}
+	cfg := rateLimitConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.store == nil {
+		cfg.store = NewMemoryRateLimitStore()
+	}
+
+	return func(next PipelineInvoker) PipelineInvoker {
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			k := key(ctx.Snapshot)
+			if limit <= 0 || window <= 0 || k == "" {
+				return next.Trigger(ctx)
+			}
+
+			allowed, retryAfter, err := cfg.store.Take(ctx.Context(), "ratelimit:"+k, limit, window)
+			if err != nil || allowed {
+				return next.Trigger(ctx)
+			}
+			text := defaultRateLimitReply(ctx.Snapshot, retryAfter)
+			if cfg.reply != nil {
+				text = cfg.reply(retryAfter)
+			}
+			if text != "" {
+				return singleChunk(StreamChunk{Content: text, IsFinal: true})
+			}
+			return singleChunk(StreamChunk{Payload: NoResponse, IsFinal: true})
+		})
+	}
+}
+
+// tokenBucket 记录令牌桶的剩余令牌与上次补充时间。
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	window time.Duration
+}
+
+// MemoryRateLimitStore 是进程内 RateLimitStore 实现，适用于单副本或测试。
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryRateLimitStore 创建进程内限流存储。
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*tokenBucket), now: time.Now}
+}
+
+// Take 实现 RateLimitStore 接口。
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 关键步骤：定期清理已补满的桶，避免长期运行时无界增长。
+	if now.Sub(s.lastSweep) >= time.Minute {
+		for k, b := range s.buckets {
+			if now.Sub(b.last) >= b.window {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	capacity := float64(limit)
+	rate := capacity / float64(window) // 每纳秒补充的令牌数
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+	}
+	b.window = window
+	b.tokens = math.Min(capacity, b.tokens+float64(now.Sub(b.last))*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	return false, time.Duration(math.Ceil((1 - b.tokens) / rate)), nil
+}
+
+// RedisEval 执行 Redis Lua 脚本（EVAL），返回脚本结果。
+// 以函数形式接入以避免绑定具体客户端，例如 go-redis：
+//
+//	func(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+//		return rdb.Eval(ctx, script, keys, args...).Result()
+//	}
+type RedisEval func(ctx context.Context, script string, keys []string, args ...any) (any, error)
+
+// redisTokenBucketScript 在 Redis 中原子地执行令牌桶扣减，时间取 Redis 服务器时钟以避免副本间时钟偏差。
+// 返回 {是否放行(0/1), 需等待的毫秒数}。
+const redisTokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or capacity
+local ts = tonumber(data[2]) or now
+local rate = capacity / window
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  wait = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], window)
+return {allowed, wait}
+`
+
+// RedisRateLimitStore 是基于 Redis 的 RateLimitStore 实现，限额在所有副本间共享。
+type RedisRateLimitStore struct {
+	eval   RedisEval
+	prefix string
+}
+
+// NewRedisRateLimitStore 创建 Redis 限流存储。
+// Parameters:
+//   - eval: Redis EVAL 执行函数
+//   - prefix: 键前缀（可为空），用于与其他业务隔离
+//
+// Returns:
+//   - *RedisRateLimitStore: 限流存储
+func NewRedisRateLimitStore(eval RedisEval, prefix string) *RedisRateLimitStore {
+	return &RedisRateLimitStore{eval: eval, prefix: prefix}
+}
+
+// Take 实现 RateLimitStore 接口。
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	windowMS := window.Milliseconds()
+	if windowMS < 1 {
+		windowMS = 1
+	}
+	res, err := s.eval(ctx, redisTokenBucketScript, []string{s.prefix + key}, limit, windowMS)
+	if err != nil {
+		return false, 0, fmt.Errorf("redis rate limit: %w", err)
+	}
+	values, ok := res.([]any)
+	if !ok || len(values) != 2 {
+		return false, 0, fmt.Errorf("redis rate limit: unexpected result %v", res)
+	}
+	allowed, ok1 := values[0].(int64)
+	waitMS, ok2 := values[1].(int64)
+	if !ok1 || !ok2 {
+		return false, 0, fmt.Errorf("redis rate limit: unexpected result %v", res)
+	}
+	return allowed == 1, time.Duration(waitMS) * time.Millisecond, nil
+}
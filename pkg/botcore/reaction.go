@@ -0,0 +1,21 @@
+package botcore
+
+// Reaction 描述用户对消息的表情回应事件。
+type Reaction struct {
+	Emoji     string // 表情标识（平台原生名称或 Unicode 表情）
+	MessageID string // 被回应的消息 ID
+	Removed   bool   // true 表示撤销回应
+}
+
+// Reactor 是 Responser 的可选能力：为消息添加表情回应。
+// 平台不支持回应时可不实现该接口，或实现为空操作（如企业微信）。
+type Reactor interface {
+	// React 为指定消息添加表情回应。
+	// Parameters:
+	//   - snapshot: 被回应消息所在的首包快照
+	//   - emoji: 表情标识
+	//
+	// Returns:
+	//   - error: 发送失败时返回
+	React(snapshot RequestSnapshot, emoji string) error
+}
@@ -0,0 +1,91 @@
+package botcore
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError 包装 pipeline 中被恢复的 panic。
+type PanicError struct {
+	Value any    // panic 值
+	Stack []byte // 发生 panic 时的调用栈
+}
+
+// Error 实现 error 接口。
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("pipeline panic: %v", e.Value)
+}
+
+// PanicReporter 在恢复 panic 后被调用，常用于告警与记录调用栈。
+type PanicReporter func(snapshot RequestSnapshot, err *PanicError)
+
+// panicReporterKey 是 context 中存储 PanicReporter 的键。
+type panicReporterKey struct{}
+
+// Recover 返回恢复 panic 的中间件：下游 Trigger 同步 panic 时输出失败片段（ErrorChunk）并调用 report。
+// Go 无法跨 goroutine 捕获 panic，下游在自建 goroutine 中输出时需 defer RecoverInto，
+// 或直接使用 StreamFunc；二者会沿 PipelineContext.Context() 找到这里配置的 report。
+// Parameters:
+//   - report: panic 回调，可为 nil
+//
+// Returns:
+//   - Middleware: panic 恢复中间件
+func Recover(report PanicReporter) Middleware {
+	return func(next PipelineInvoker) PipelineInvoker {
+		return PipelineFunc(func(ctx PipelineContext) (out <-chan StreamChunk) {
+			if report != nil {
+				ctx = ctx.WithContext(context.WithValue(ctx.Context(), panicReporterKey{}, report))
+			}
+			defer func() {
+				if v := recover(); v != nil {
+					err := &PanicError{Value: v, Stack: debug.Stack()}
+					reportPanic(ctx, err)
+					out = singleChunk(ErrorChunk(err))
+				}
+			}()
+			return next.Trigger(ctx)
+		})
+	}
+}
+
+// RecoverInto 用于在 handler 的输出 goroutine 中 defer 调用：
+// 恢复 panic，向 out 写入失败片段并上报给 Recover 配置的回调。
+// 必须直接以 defer RecoverInto(ctx, out) 的形式调用，且应在 defer close(out) 之后声明。
+func RecoverInto(ctx PipelineContext, out chan<- StreamChunk) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	err := &PanicError{Value: v, Stack: debug.Stack()}
+	reportPanic(ctx, err)
+	select {
+	case out <- ErrorChunk(err):
+	case <-ctx.Context().Done():
+	}
+}
+
+// StreamFunc 以 fn 在独立 goroutine 中产出片段，负责关闭通道并恢复 panic。
+// Parameters:
+//   - fn: 输出函数，向 out 写入片段，返回后通道自动关闭
+//
+// Returns:
+//   - PipelineInvoker: 执行器
+func StreamFunc(fn func(ctx PipelineContext, out chan<- StreamChunk)) PipelineInvoker {
+	return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+		out := make(chan StreamChunk)
+		go func() {
+			defer close(out)
+			defer RecoverInto(ctx, out)
+			fn(ctx, out)
+		}()
+		return out
+	})
+}
+
+// reportPanic 调用 context 中的 PanicReporter（若存在）。
+func reportPanic(ctx PipelineContext, err *PanicError) {
+	if report, ok := ctx.Context().Value(panicReporterKey{}).(PanicReporter); ok {
+		report(ctx.Snapshot, err)
+	}
+}
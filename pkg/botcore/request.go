@@ -2,6 +2,7 @@ package botcore
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -30,13 +31,20 @@ type RequestSnapshot struct {
 	SenderID string   // 触发用户标识
 	ChatID   string   // 会话 ID（群、私聊等）
 	ChatType ChatType // 会话类型，示例：single/chatroom（企业微信为 single/group，内部映射为 chatroom）
-
-	Text        string            // 主要文本内容（若适用）
-	Attachments []Attachment      // 标准化附件列表（图片/文件等）
-	Reference   *Reference        // 引用消息（若存在）
-	Raw         any               // 平台原始结构引用，便于 Pipeline 深度使用
-	ResponseURL string            // 主动回复 URL（部分平台返回）
-	Metadata    map[string]string // 扩展键值，如语言、平台等
+	ThreadID string   // 消息所在的线程/话题 ID（如 Slack thread_ts），不在线程中或平台不支持时为空
+
+	Text         string            // 主要文本内容（若适用），已去除开头的 @机器人 提及
+	Mentions     []string          // 消息中 @ 提及的其他对象（用户 ID 或显示名，取决于平台），不含机器人自身
+	MentionedBot bool              // 消息是否显式 @ 了机器人（群聊中使用；单聊不设置）
+	Attachments  []Attachment      // 标准化附件列表（图片/文件等）
+	Reference    *Reference        // 引用消息（若存在）
+	Reaction     *Reaction         // 表情回应事件（仅支持该能力的平台填充）
+	Revision     *Revision         // 编辑/撤回事件（仅支持该能力的平台填充）
+	Feedback     *Feedback         // 回答反馈事件（点赞/点踩，仅支持该能力的平台填充）
+	Action       *Action           // 交互式应用按钮回调（仅支持该能力的平台填充）
+	Raw          any               // 平台原始结构引用，便于 Pipeline 深度使用
+	ResponseURL  string            // 主动回复 URL（部分平台返回）
+	Metadata     map[string]string // 扩展键值，如语言、平台等
 }
 
 // AttachmentType 描述附件类型。
@@ -49,6 +57,8 @@ const (
 	AttachmentTypeFile AttachmentType = "file"
 	// AttachmentTypeVideo 表示视频附件。
 	AttachmentTypeVideo AttachmentType = "video"
+	// AttachmentTypeVoice 表示语音附件。
+	AttachmentTypeVoice AttachmentType = "voice"
 )
 
 // Reference 描述消息中的引用内容。
@@ -77,6 +87,33 @@ type Attachment struct {
 	DownloadTransform AttachmentDownloadTransform
 }
 
+// Load 返回附件的原始字节：优先使用 Data，否则下载 URL 并执行 DownloadTransform（如解密）。
+// Parameters:
+//   - ctx: 下载上下文
+//
+// Returns:
+//   - []byte: 附件内容
+//   - error: 无可用数据、下载或变换失败时返回
+func (a Attachment) Load(ctx context.Context) ([]byte, error) {
+	if len(a.Data) > 0 {
+		return a.Data, nil
+	}
+	if strings.TrimSpace(a.URL) == "" {
+		return nil, errors.New("attachment has no data and no url")
+	}
+	client := &http.Client{Timeout: resolveDurationFromEnv(envSaveAttachTimeout, 2*time.Minute)}
+	data, err := downloadAttachmentData(ctx, client, a.URL)
+	if err != nil {
+		return nil, err
+	}
+	if a.DownloadTransform != nil {
+		if data, err = a.DownloadTransform(data); err != nil {
+			return nil, fmt.Errorf("transform attachment: %w", err)
+		}
+	}
+	return data, nil
+}
+
 // SavedAttachment 表示附件保存结果。
 type SavedAttachment struct {
 	Attachment Attachment // 原始附件信息
@@ -154,7 +191,7 @@ func saveAttachments(attachments []Attachment, dir string) ([]SavedAttachment, e
 		if len(att.Data) > 0 {
 			data = att.Data
 		} else {
-			downloaded, err := downloadAttachmentData(client, att.URL)
+			downloaded, err := downloadAttachmentData(context.Background(), client, att.URL)
 			if err != nil {
 				result.Err = err
 				results = append(results, result)
@@ -334,8 +371,8 @@ func uniqueAttachmentPath(dir, filename string) (string, error) {
 }
 
 // downloadAttachmentData 下载远程资源并返回原始字节。
-func downloadAttachmentData(client *http.Client, rawURL string) ([]byte, error) {
-	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+func downloadAttachmentData(ctx context.Context, client *http.Client, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
@@ -1,5 +1,7 @@
 package botcore
 
+import "context"
+
 // Responser 定义主动发送能力的抽象接口。
 // Parameters:
 //   - responseURL: 平台回调中提供的 response_url
@@ -16,3 +18,24 @@ type Responser interface {
 }
 
 // 注意：Responser 仅定义能力抽象，具体注入请使用 (*Manager).WithResponser 方法。
+
+// ChatSender 是 Responser 的可选能力：不依赖 response_url，直接向指定会话推送消息
+// （如企业应用消息、群机器人 Webhook）。平台不支持时可不实现该接口。
+type ChatSender interface {
+	// SendToChat 向 chatID 会话发送消息。
+	// Parameters:
+	//   - ctx: 请求上下文
+	//   - chatID: 目标会话 ID
+	//   - msg: 平台消息负载
+	//
+	// Returns:
+	//   - error: 发送失败时返回
+	SendToChat(ctx context.Context, chatID string, msg any) error
+}
+
+// VoiceReplier 是 Responser 的可选能力：声明平台能否在回复中发送语音附件（AttachmentTypeVoice）。
+// SpeakReplies 在 Responser 声明不支持时跳过合成，避免为无法送达的语音付费；未实现该接口时视为支持。
+type VoiceReplier interface {
+	// CanSendVoice 返回平台能否发送语音回复。
+	CanSendVoice() bool
+}
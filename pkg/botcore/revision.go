@@ -0,0 +1,67 @@
+package botcore
+
+// RevisionKind 描述源消息的变更类型。
+type RevisionKind string
+
+const (
+	// RevisionEdit 表示用户编辑了源消息。
+	RevisionEdit RevisionKind = "edit"
+	// RevisionRecall 表示用户撤回（删除）了源消息。
+	RevisionRecall RevisionKind = "recall"
+)
+
+// Revision 描述对已发送消息的编辑或撤回事件。
+type Revision struct {
+	Kind      RevisionKind // 变更类型
+	MessageID string       // 被编辑/撤回的源消息 ID
+}
+
+// Retractor 是 Responser 的可选能力：撤回机器人针对某条源消息发出的回答。
+// 平台不支持撤回时可不实现该接口。
+type Retractor interface {
+	// Retract 撤回针对 messageID 的回答。
+	// Parameters:
+	//   - snapshot: 撤回事件所在的快照
+	//   - messageID: 源消息 ID
+	//
+	// Returns:
+	//   - error: 撤回失败时返回
+	Retract(snapshot RequestSnapshot, messageID string) error
+}
+
+// RevisionHandler 处理编辑/撤回事件，返回值语义与 PipelineInvoker.Trigger 相同。
+type RevisionHandler func(ctx PipelineContext, revision Revision) <-chan StreamChunk
+
+// HandleRevisions 返回拦截编辑/撤回事件的中间件，普通消息原样交给下游。
+// handler 为 nil 时使用默认策略：撤回事件调用 Responser 的 Retractor 能力（若支持），
+// 编辑事件重新执行下游 handler，两者均不再回复撤回事件本身。
+// Parameters:
+//   - handler: 自定义事件处理函数，可为 nil
+//
+// Returns:
+//   - Middleware: 编辑/撤回处理中间件
+func HandleRevisions(handler RevisionHandler) Middleware {
+	return func(next PipelineInvoker) PipelineInvoker {
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			revision := ctx.Snapshot.Revision
+			if revision == nil {
+				return next.Trigger(ctx)
+			}
+			if handler != nil {
+				return handler(ctx, *revision)
+			}
+
+			switch revision.Kind {
+			case RevisionEdit:
+				// 关键步骤：编辑后的文本按新消息重新回答。
+				return next.Trigger(ctx)
+			case RevisionRecall:
+				// 关键步骤：源消息被撤回时同步撤回回答，满足合规要求。
+				if retractor, ok := ctx.Responser.(Retractor); ok {
+					_ = retractor.Retract(ctx.Snapshot, revision.MessageID)
+				}
+			}
+			return singleChunk(StreamChunk{Payload: NoResponse, IsFinal: true})
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package botcore
+
+import (
+	"math/rand"
+	"regexp"
+	"strings"
+)
+
+// SmallTalkClassifier 判断一条消息是否为可直接模板回复的寒暄（问候、致谢等）。
+// 可以是正则匹配，也可以是基于 embedding 的轻量分类器。
+type SmallTalkClassifier interface {
+	// Classify 返回模板回复；ok=false 表示不是寒暄，应交给下游处理。
+	Classify(snapshot RequestSnapshot) (reply string, ok bool)
+}
+
+// SmallTalkRule 描述一条正则寒暄规则：整句命中 Pattern 时随机选择一条 Replies 回复。
+// Replies 可以是文本，也可以是消息键（按快照语言本地化）。
+type SmallTalkRule struct {
+	Pattern *regexp.Regexp
+	Replies []string
+}
+
+// RegexSmallTalk 是基于正则规则的 SmallTalkClassifier，按规则顺序匹配。
+type RegexSmallTalk struct {
+	rules []SmallTalkRule
+}
+
+// NewRegexSmallTalk 创建正则寒暄分类器。
+// Parameters:
+//   - rules: 匹配规则；为空时使用 DefaultSmallTalkRules
+//
+// Returns:
+//   - *RegexSmallTalk: 分类器实例
+func NewRegexSmallTalk(rules ...SmallTalkRule) *RegexSmallTalk {
+	if len(rules) == 0 {
+		rules = DefaultSmallTalkRules()
+	}
+	return &RegexSmallTalk{rules: rules}
+}
+
+// DefaultSmallTalkRules 返回内置的中英文问候与致谢规则，回复为本地化的消息键（MsgGreeting1 等）。
+// 规则要求整条消息都是寒暄（允许标点与语气词），避免误拦截“你好，帮我写个脚本”这类请求。
+func DefaultSmallTalkRules() []SmallTalkRule {
+	return []SmallTalkRule{
+		{
+			Pattern: regexp.MustCompile(`(?i)^(你好|您好|hi|hello|hey|嗨|哈喽|早上好|下午好|晚上好|在吗)[呀啊哈~～!！。.\s]*$`),
+			Replies: []string{MsgGreeting1, MsgGreeting2},
+		},
+		{
+			Pattern: regexp.MustCompile(`(?i)^(谢谢|多谢|感谢|谢啦|thanks|thank you|thx)[你您了啦呀~～!！。.\s]*$`),
+			Replies: []string{MsgThanks1, MsgThanks2},
+		},
+	}
+}
+
+// Classify 实现 SmallTalkClassifier。仅处理无附件的纯文本消息。
+func (r *RegexSmallTalk) Classify(snapshot RequestSnapshot) (string, bool) {
+	if len(snapshot.Attachments) > 0 {
+		return "", false
+	}
+	text := strings.TrimSpace(snapshot.Text)
+	if text == "" {
+		return "", false
+	}
+	for _, rule := range r.rules {
+		if rule.Pattern == nil || len(rule.Replies) == 0 {
+			continue
+		}
+		if rule.Pattern.MatchString(text) {
+			return Localize(snapshot, rule.Replies[rand.Intn(len(rule.Replies))]), true
+		}
+	}
+	return "", false
+}
+
+// ShortCircuitSmallTalk 返回寒暄短路中间件：分类命中时直接返回模板回复，
+// 不再调用下游 handler（通常是 LLM），以节省高频琐碎消息的成本与延迟。
+// Parameters:
+//   - classifier: 寒暄分类器；为 nil 时使用 NewRegexSmallTalk()
+//
+// Returns:
+//   - Middleware: 寒暄短路中间件
+func ShortCircuitSmallTalk(classifier SmallTalkClassifier) Middleware {
+	if classifier == nil {
+		classifier = NewRegexSmallTalk()
+	}
+	return func(next PipelineInvoker) PipelineInvoker {
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			if reply, ok := classifier.Classify(ctx.Snapshot); ok {
+				return singleChunk(StreamChunk{Content: reply, IsFinal: true})
+			}
+			return next.Trigger(ctx)
+		})
+	}
+}
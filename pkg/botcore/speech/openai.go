@@ -0,0 +1,255 @@
+// Package speech 提供语音转写（STT）与语音合成（TTS）服务的实现，
+// 分别满足 botcore.Transcriber 与 botcore.Synthesizer，配合 botcore.Transcribe / botcore.SpeakReplies 接入路由。
+package speech
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+)
+
+// DefaultBaseURL 为 OpenAI API 的默认地址。
+const DefaultBaseURL = "https://api.openai.com/v1"
+
+// ErrUnsupportedAudio 表示语音格式不被 Whisper 接受（如企业微信语音使用的 AMR）且未配置 WithAudioConverter。
+var ErrUnsupportedAudio = errors.New("speech: unsupported audio format")
+
+// AudioConverter 将 Whisper 不支持的音频转换为受支持的格式（如调用 ffmpeg 把 AMR 转为 WAV/MP3）。
+// Parameters:
+//   - ctx: 调用上下文
+//   - data: 原始音频
+//
+// Returns:
+//   - []byte: 转换后的音频（格式按文件头重新识别）
+//   - error: 转换失败时返回
+type AudioConverter func(ctx context.Context, data []byte) ([]byte, error)
+
+// Option 自定义 OpenAI 客户端行为。
+type Option func(*OpenAI)
+
+// WithBaseURL 设置 API 地址（默认 DefaultBaseURL），可指向兼容 OpenAI 协议的自建服务或代理。
+func WithBaseURL(baseURL string) Option {
+	return func(o *OpenAI) {
+		if baseURL != "" {
+			o.baseURL = strings.TrimRight(baseURL, "/")
+		}
+	}
+}
+
+// WithHTTPClient 设置 HTTP 客户端（默认 60 秒超时）。
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *OpenAI) {
+		if client != nil {
+			o.client = client
+		}
+	}
+}
+
+// WithTranscriptionModel 设置转写模型（默认 "whisper-1"）。
+func WithTranscriptionModel(model string) Option {
+	return func(o *OpenAI) {
+		if model != "" {
+			o.sttModel = model
+		}
+	}
+}
+
+// WithLanguage 设置转写语言提示（ISO-639-1，如 "zh"），为空时由模型自动识别。
+func WithLanguage(language string) Option {
+	return func(o *OpenAI) {
+		o.language = language
+	}
+}
+
+// WithSpeechModel 设置合成模型（默认 "tts-1"）。
+func WithSpeechModel(model string) Option {
+	return func(o *OpenAI) {
+		if model != "" {
+			o.ttsModel = model
+		}
+	}
+}
+
+// WithVoice 设置合成音色（默认 "alloy"）。
+func WithVoice(voice string) Option {
+	return func(o *OpenAI) {
+		if voice != "" {
+			o.voice = voice
+		}
+	}
+}
+
+// WithFormat 设置合成音频格式（默认 "mp3"，可选 opus/aac/flac/wav/pcm）。
+func WithFormat(format string) Option {
+	return func(o *OpenAI) {
+		if format != "" {
+			o.format = format
+		}
+	}
+}
+
+// WithAudioConverter 设置 Whisper 不支持的格式（目前为 AMR）的转换函数。
+// 未设置时转写此类语音直接返回 ErrUnsupportedAudio，不再调用 API。
+func WithAudioConverter(convert AudioConverter) Option {
+	return func(o *OpenAI) {
+		o.convert = convert
+	}
+}
+
+// OpenAI 基于 OpenAI Audio API 的语音服务：Whisper 转写（/audio/transcriptions）与 TTS 合成（/audio/speech）。
+type OpenAI struct {
+	apiKey   string
+	baseURL  string
+	client   *http.Client
+	sttModel string
+	language string
+	ttsModel string
+	voice    string
+	format   string
+	convert  AudioConverter
+}
+
+var (
+	_ botcore.Transcriber = (*OpenAI)(nil)
+	_ botcore.Synthesizer = (*OpenAI)(nil)
+)
+
+// NewOpenAI 创建 OpenAI 语音服务。
+// Parameters:
+//   - apiKey: API 密钥
+//   - opts: 可选配置（地址、HTTP 客户端、模型、语言、音色、格式）
+//
+// Returns:
+//   - *OpenAI: 同时实现 botcore.Transcriber 与 botcore.Synthesizer
+func NewOpenAI(apiKey string, opts ...Option) *OpenAI {
+	o := &OpenAI{
+		apiKey:   apiKey,
+		baseURL:  DefaultBaseURL,
+		client:   &http.Client{Timeout: 60 * time.Second},
+		sttModel: "whisper-1",
+		ttsModel: "tts-1",
+		voice:    "alloy",
+		format:   "mp3",
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Transcribe 实现 botcore.Transcriber 接口。
+// Whisper 按文件扩展名识别格式（flac/m4a/mp3/ogg/wav/webm 等），AMR 语音先经 WithAudioConverter 转换。
+func (o *OpenAI) Transcribe(ctx context.Context, audio botcore.Attachment) (string, error) {
+	data, err := audio.Load(ctx)
+	if err != nil {
+		return "", fmt.Errorf("transcribe: %w", err)
+	}
+	if audioExt(data) == ".amr" {
+		// 关键步骤：Whisper 拒绝 AMR，未配置转换时直接失败，避免一次必然失败的上传。
+		if o.convert == nil {
+			return "", fmt.Errorf("transcribe: %w: amr", ErrUnsupportedAudio)
+		}
+		if data, err = o.convert(ctx, data); err != nil {
+			return "", fmt.Errorf("transcribe: convert audio: %w", err)
+		}
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", "audio"+audioExt(data))
+	if err != nil {
+		return "", fmt.Errorf("transcribe: %w", err)
+	}
+	file.Write(data)
+	form.WriteField("model", o.sttModel)
+	if o.language != "" {
+		form.WriteField("language", o.language)
+	}
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("transcribe: %w", err)
+	}
+
+	resp, err := o.post(ctx, "/audio/transcriptions", form.FormDataContentType(), &body)
+	if err != nil {
+		return "", fmt.Errorf("transcribe: %w", err)
+	}
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return "", fmt.Errorf("transcribe: decode response: %w", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
+
+// Synthesize 实现 botcore.Synthesizer 接口。
+func (o *OpenAI) Synthesize(ctx context.Context, text string) (botcore.Attachment, error) {
+	payload, err := json.Marshal(map[string]string{
+		"model":           o.ttsModel,
+		"input":           text,
+		"voice":           o.voice,
+		"response_format": o.format,
+	})
+	if err != nil {
+		return botcore.Attachment{}, fmt.Errorf("synthesize: %w", err)
+	}
+	data, err := o.post(ctx, "/audio/speech", "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return botcore.Attachment{}, fmt.Errorf("synthesize: %w", err)
+	}
+	return botcore.Attachment{Type: botcore.AttachmentTypeVoice, Data: data}, nil
+}
+
+// post 发送请求并返回响应体，非 2xx 时返回带状态码与响应内容的错误。
+func (o *OpenAI) post(ctx context.Context, path, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("status=%d: %s", resp.StatusCode, botcore.TruncateUTF8(strings.TrimSpace(string(data)), 200))
+	}
+	return data, nil
+}
+
+// audioExt 按文件头识别常见音频格式的扩展名，无法识别时返回 ".wav"。
+func audioExt(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("#!AMR")):
+		return ".amr"
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return ".ogg"
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return ".flac"
+	case bytes.HasPrefix(data, []byte("ID3")), len(data) > 1 && data[0] == 0xff && data[1]&0xe0 == 0xe0:
+		return ".mp3"
+	case bytes.HasPrefix(data, []byte{0x1a, 0x45, 0xdf, 0xa3}):
+		return ".webm"
+	case len(data) >= 8 && string(data[4:8]) == "ftyp":
+		return ".m4a"
+	default:
+		return ".wav"
+	}
+}
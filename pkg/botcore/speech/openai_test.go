@@ -0,0 +1,86 @@
+package speech
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+)
+
+func TestOpenAITranscribeAndSynthesize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("missing api key: %q", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/v1/audio/transcriptions":
+			file, header, err := r.FormFile("file")
+			if err != nil {
+				t.Fatalf("form file: %v", err)
+			}
+			data, _ := io.ReadAll(file)
+			if header.Filename != "audio.ogg" || string(data) != "OggS-audio" || r.FormValue("model") != "whisper-1" || r.FormValue("language") != "zh" {
+				t.Errorf("unexpected upload: %s %q model=%s", header.Filename, data, r.FormValue("model"))
+			}
+			w.Write([]byte(`{"text":" 你好 "}`))
+		case "/v1/audio/speech":
+			var req map[string]string
+			json.NewDecoder(r.Body).Decode(&req)
+			if req["input"] != "hello" || req["voice"] != "nova" || req["model"] != "tts-1" {
+				t.Errorf("unexpected speech request: %v", req)
+			}
+			w.Write([]byte("mp3-bytes"))
+		default:
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewOpenAI("sk-test", WithBaseURL(srv.URL+"/v1/"), WithLanguage("zh"), WithVoice("nova"))
+	text, err := client.Transcribe(t.Context(), botcore.Attachment{Type: botcore.AttachmentTypeVoice, Data: []byte("OggS-audio")})
+	if err != nil || text != "你好" {
+		t.Fatalf("Transcribe = %q, %v", text, err)
+	}
+	voice, err := client.Synthesize(t.Context(), "hello")
+	if err != nil || voice.Type != botcore.AttachmentTypeVoice || string(voice.Data) != "mp3-bytes" {
+		t.Fatalf("Synthesize = %+v, %v", voice, err)
+	}
+
+	_, err = NewOpenAI("sk-test", WithBaseURL(srv.URL)).Synthesize(t.Context(), "hello")
+	if err == nil || !strings.Contains(err.Error(), "status=404") {
+		t.Fatalf("expected status error, got %v", err)
+	}
+}
+
+func TestOpenAITranscribeConvertsAMR(t *testing.T) {
+	var uploads []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatalf("form file: %v", err)
+		}
+		uploads = append(uploads, header.Filename)
+		w.Write([]byte(`{"text":"ok"}`))
+	}))
+	defer srv.Close()
+
+	amr := botcore.Attachment{Type: botcore.AttachmentTypeVoice, Data: []byte("#!AMR\nvoice")}
+	if _, err := NewOpenAI("", WithBaseURL(srv.URL)).Transcribe(t.Context(), amr); !errors.Is(err, ErrUnsupportedAudio) {
+		t.Fatalf("expected ErrUnsupportedAudio, got %v", err)
+	}
+	if len(uploads) != 0 {
+		t.Fatalf("amr should not be uploaded: %v", uploads)
+	}
+
+	toOgg := func(ctx context.Context, data []byte) ([]byte, error) { return []byte("OggS-converted"), nil }
+	text, err := NewOpenAI("", WithBaseURL(srv.URL), WithAudioConverter(toOgg)).Transcribe(t.Context(), amr)
+	if err != nil || text != "ok" || len(uploads) != 1 || uploads[0] != "audio.ogg" {
+		t.Fatalf("Transcribe = %q, %v, uploads=%v", text, err, uploads)
+	}
+}
@@ -0,0 +1,183 @@
+package botcore
+
+import "strings"
+
+const (
+	// pageFooterReserve 为分页页脚与截断提示预留的字节数。
+	pageFooterReserve = 64
+	// fenceReopen / fenceClose 为代码块跨页时补齐的围栏。
+	fenceReopen = "```\n"
+	fenceClose  = "\n```"
+)
+
+// SplitText 按字节上限把文本拆分为多段，优先在段落、换行、空格处断开，且不截断多字节字符。
+// 断点落在代码块内时，在段尾补齐围栏并在下一段重新打开，保证每段 Markdown 独立可渲染。
+// Parameters:
+//   - text: 原文
+//   - maxBytes: 每段字节上限（<=0 表示不拆分）
+//
+// Returns:
+//   - []string: 拆分结果（text 为空时为 nil）
+func SplitText(text string, maxBytes int) []string {
+	if text == "" {
+		return nil
+	}
+	if maxBytes <= 0 || len(text) <= maxBytes {
+		return []string{text}
+	}
+
+	var pages []string
+	inFence := false
+	for text != "" {
+		prefix := ""
+		if inFence {
+			prefix = fenceReopen
+		}
+		if len(prefix)+len(text) <= maxBytes {
+			pages = append(pages, prefix+text)
+			break
+		}
+		limit := maxBytes - len(prefix) - len(fenceClose)
+		if limit <= 0 {
+			limit = maxBytes
+		}
+		cut := splitPoint(text, limit)
+		page := text[:cut]
+		text = strings.TrimLeft(text[cut:], "\n")
+
+		open := inFence != (countFences(page)%2 == 1)
+		page = prefix + page
+		if open {
+			page = strings.TrimRight(page, "\n") + fenceClose
+		}
+		inFence = open
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// splitPoint 返回 limit 字节内最合适的断点：段落 > 换行 > 空格 > 字符边界。
+// 过于靠前（不足一半）的自然断点会被放弃，避免产生过短的分段。
+func splitPoint(text string, limit int) int {
+	window := TruncateUTF8(text, limit)
+	if window == "" {
+		// limit 小于首个字符的长度时，至少前进一个字符，保证拆分能结束。
+		for i := range text {
+			if i > 0 {
+				return i
+			}
+		}
+		return len(text)
+	}
+	for _, sep := range []string{"\n\n", "\n", " "} {
+		if i := strings.LastIndex(window, sep); i > len(window)/2 {
+			return i + len(sep)
+		}
+	}
+	return len(window)
+}
+
+// countFences 统计文本中的代码块围栏行数。
+func countFences(text string) int {
+	n := 0
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimLeft(line, " \t"), "```") {
+			n++
+		}
+	}
+	return n
+}
+
+// PageFooter 按快照语言返回分页页脚（MsgPageFooter），如 "（2/3）"。
+func PageFooter(snapshot RequestSnapshot, page, total int) string {
+	return Localize(snapshot, MsgPageFooter, page, total)
+}
+
+// SplitLongReply 返回把超长回答拆分为多条消息的中间件：被动回复只输出前 maxBytes 字节并提示“续见下条消息”（MsgReplyContinued），
+// 其余正文在最终片段之后按 maxBytes 拆分（参见 SplitText），带 "（2/3）" 页脚通过 Responser.ResponseMarkdown 依次补发。
+// 无 Responser、无 ResponseURL 或 maxFollowUps 为 0 时退化为截断（同 LimitBytes）；
+// 补发条数超过 maxFollowUps（<0 表示不限制）时，最后一条截断并附提示。仅适用于增量语义（ContentDelta）。
+// Parameters:
+//   - maxBytes: 单条消息字节上限（<=0 表示不拆分）
+//   - maxFollowUps: 最多补发的消息条数，应与平台对 ResponseURL 的调用次数限制一致（企业微信为 1）
+//
+// Returns:
+//   - Middleware: 长回答拆分中间件
+func SplitLongReply(maxBytes, maxFollowUps int) Middleware {
+	return func(next PipelineInvoker) PipelineInvoker {
+		if maxBytes <= 0 {
+			return next
+		}
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			in := next.Trigger(ctx)
+			if in == nil {
+				return nil
+			}
+			followUp := ctx.Responser != nil && ctx.Snapshot.ResponseURL != "" && maxFollowUps != 0
+			notice := Localize(ctx.Snapshot, MsgReplyOversize)
+			if followUp {
+				notice = Localize(ctx.Snapshot, MsgReplyContinued)
+			}
+			budget := maxBytes - len(notice) - len(fenceClose)
+
+			out := make(chan StreamChunk)
+			go func() {
+				defer close(out)
+				total := 0
+				overflowed := false
+				var sent, overflow strings.Builder
+				for chunk := range in {
+					if chunk.Type == ChunkContent || chunk.Type == ChunkReasoning {
+						switch {
+						case overflowed:
+							if chunk.Type == ChunkContent {
+								overflow.WriteString(chunk.Content)
+							}
+							chunk.Content = ""
+						case total+len(chunk.Content) > budget:
+							// 关键步骤：被动回复在字符边界处截断，剩余正文留待补发。
+							overflowed = true
+							head := TruncateUTF8(chunk.Content, budget-total)
+							if chunk.Type == ChunkContent {
+								rest := chunk.Content[len(head):]
+								sent.WriteString(head)
+								// 截断点位于代码块内时，两侧分别补齐围栏。
+								if countFences(sent.String())%2 == 1 {
+									head += fenceClose
+									rest = fenceReopen + rest
+								}
+								overflow.WriteString(rest)
+							}
+							chunk.Content = head + notice
+						default:
+							total += len(chunk.Content)
+							if chunk.Type == ChunkContent {
+								sent.WriteString(chunk.Content)
+							}
+						}
+					}
+					out <- chunk
+				}
+				if followUp && overflow.Len() > 0 {
+					sendPages(ctx, overflow.String(), maxBytes, maxFollowUps)
+				}
+			}()
+			return out
+		})
+	}
+}
+
+// sendPages 将溢出正文拆分后通过 Responser 依次补发，首条被动回复计为第 1 页。
+func sendPages(ctx PipelineContext, text string, maxBytes, maxFollowUps int) {
+	pages := SplitText(text, maxBytes-pageFooterReserve)
+	if maxFollowUps > 0 && len(pages) > maxFollowUps {
+		pages = pages[:maxFollowUps]
+		pages[len(pages)-1] += Localize(ctx.Snapshot, MsgReplyOversize)
+	}
+	total := len(pages) + 1
+	for i, page := range pages {
+		if err := ctx.Responser.ResponseMarkdown(ctx.Snapshot.ResponseURL, page+PageFooter(ctx.Snapshot, i+2, total)); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package botcore
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// defaultStopCommand 为默认的停止指令。
+const defaultStopCommand = "/stop"
+
+// StopOption 自定义 StopCommand 行为。
+type StopOption func(*stopConfig)
+
+type stopConfig struct {
+	command string
+	key     KeyFunc
+	reply   func(stopped int) string // 为 nil 时按快照语言回复 MsgStopNone / MsgStopDone
+}
+
+// WithStopKeyword 设置停止指令文本（默认 "/stop"，比较时忽略首尾空白与大小写）。
+func WithStopKeyword(command string) StopOption {
+	return func(c *stopConfig) {
+		if command = strings.TrimSpace(command); command != "" {
+			c.command = command
+		}
+	}
+}
+
+// WithStopKey 自定义停止范围（默认按 ChatID + SenderID，即只停止本人在当前会话中的回答）。
+func WithStopKey(key KeyFunc) StopOption {
+	return func(c *stopConfig) {
+		if key != nil {
+			c.key = key
+		}
+	}
+}
+
+// WithStopReply 自定义停止指令的回复文本（默认为本地化的 MsgStopNone / MsgStopDone）。
+func WithStopReply(reply func(stopped int) string) StopOption {
+	return func(c *stopConfig) {
+		if reply != nil {
+			c.reply = reply
+		}
+	}
+}
+
+// stopReply 返回停止指令的回复：配置了 WithStopReply 时使用自定义文本，否则按快照语言回复。
+func (c stopConfig) stopReply(snapshot RequestSnapshot, stopped int) string {
+	if c.reply != nil {
+		return c.reply(stopped)
+	}
+	if stopped == 0 {
+		return Localize(snapshot, MsgStopNone)
+	}
+	return Localize(snapshot, MsgStopDone)
+}
+
+// StopCommand 返回支持用户中止进行中回答的中间件。
+// 每次执行都会登记一个可取消的 context（通过 PipelineContext.Context() 传给下游）；
+// 收到停止指令时取消同一键下的全部在途执行，被停止的回答以本地化的 MsgStopped 提示收尾。
+// 应放在 Chain 之外，使停止指令先于命令路由被拦截。
+// Parameters:
+//   - opts: 可选配置（指令文本、停止范围、回复文本）
+//
+// Returns:
+//   - Middleware: 停止指令中间件
+func StopCommand(opts ...StopOption) Middleware {
+	cfg := stopConfig{
+		command: defaultStopCommand,
+		key:     func(s RequestSnapshot) string { return s.ChatID + ":" + s.SenderID },
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	runs := newRunRegistry()
+
+	return func(next PipelineInvoker) PipelineInvoker {
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			key := cfg.key(ctx.Snapshot)
+			if strings.EqualFold(strings.TrimSpace(ctx.Snapshot.Text), cfg.command) {
+				return singleChunk(StreamChunk{Content: cfg.stopReply(ctx.Snapshot, runs.stop(key)), IsFinal: true})
+			}
+			if key == "" {
+				return next.Trigger(ctx)
+			}
+
+			runCtx, cancel := context.WithCancel(ctx.Context())
+			id := runs.add(key, cancel)
+			in := next.Trigger(ctx.WithContext(runCtx))
+			if in == nil {
+				runs.remove(key, id)
+				cancel()
+				return nil
+			}
+			out := make(chan StreamChunk)
+			go func() {
+				defer close(out)
+				defer cancel()
+				forwardUntil(in, out, runCtx.Done(), Localize(ctx.Snapshot, MsgStopped))
+				runs.remove(key, id)
+			}()
+			return out
+		})
+	}
+}
+
+// forwardUntil 将 in 转发到 out，直到 in 关闭或 done 关闭。
+// 因 done 提前结束且尚未发送最终片段时，以 notice 作为最终片段收尾，并在后台丢弃下游剩余输出。
+// Returns:
+//   - bool: 是否因 done 被截断
+func forwardUntil(in <-chan StreamChunk, out chan<- StreamChunk, done <-chan struct{}, notice string) bool {
+	finalSent := false
+	for {
+		select {
+		case chunk, ok := <-in:
+			if !ok {
+				return false
+			}
+			finalSent = finalSent || chunk.IsFinal
+			out <- chunk
+		case <-done:
+			if !finalSent {
+				out <- StreamChunk{Content: notice, IsFinal: true}
+			}
+			go func() {
+				for range in {
+				}
+			}()
+			return true
+		}
+	}
+}
+
+// runRegistry 按键登记在途执行的取消函数。
+type runRegistry struct {
+	mu   sync.Mutex
+	next uint64
+	runs map[string]map[uint64]context.CancelFunc
+}
+
+// newRunRegistry 创建登记表。
+func newRunRegistry() *runRegistry {
+	return &runRegistry{runs: make(map[string]map[uint64]context.CancelFunc)}
+}
+
+// add 登记一次执行，返回其编号。
+func (r *runRegistry) add(key string, cancel context.CancelFunc) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next++
+	if r.runs[key] == nil {
+		r.runs[key] = make(map[uint64]context.CancelFunc)
+	}
+	r.runs[key][r.next] = cancel
+	return r.next
+}
+
+// remove 注销一次执行。
+func (r *runRegistry) remove(key string, id uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.runs[key], id)
+	if len(r.runs[key]) == 0 {
+		delete(r.runs, key)
+	}
+}
+
+// stop 取消键下的全部在途执行，返回取消数量。
+func (r *runRegistry) stop(key string) int {
+	r.mu.Lock()
+	runs := r.runs[key]
+	delete(r.runs, key)
+	r.mu.Unlock()
+	for _, cancel := range runs {
+		cancel()
+	}
+	return len(runs)
+}
@@ -0,0 +1,52 @@
+package botcore
+
+// MetaThreadID 为 StreamChunk.Metadata 中的回复线程键：发送端据此把片段发到对应线程/话题。
+// 不支持线程的平台（如企业微信，其被动回复天然关联源消息）忽略该键。
+const MetaThreadID = "thread_id"
+
+// ReplyThreadID 返回回复应进入的线程：消息已在线程中时沿用 ThreadID，否则以源消息 ID 开启新线程。
+func (r RequestSnapshot) ReplyThreadID() string {
+	if r.ThreadID != "" {
+		return r.ThreadID
+	}
+	return r.ID
+}
+
+// InThread 返回标记了回复线程的片段副本（Metadata 会被复制，不影响原片段）。
+// threadID 为空时原样返回。
+func InThread(chunk StreamChunk, threadID string) StreamChunk {
+	if threadID == "" {
+		return chunk
+	}
+	chunk.Metadata = cloneMetadata(chunk.Metadata)
+	chunk.Metadata[MetaThreadID] = threadID
+	return chunk
+}
+
+// ReplyInThread 返回把下游全部输出标记为回复到当前线程（参见 ReplyThreadID）的中间件。
+// 已显式标记线程的片段保持不变。
+func ReplyInThread() Middleware {
+	return MapChunksWith(func(ctx PipelineContext) ChunkTransform {
+		threadID := ctx.Snapshot.ReplyThreadID()
+		return func(chunk StreamChunk) StreamChunk {
+			if chunk.Metadata[MetaThreadID] != "" {
+				return chunk
+			}
+			return InThread(chunk, threadID)
+		}
+	})
+}
+
+// ThreadResponder 是 Responser 的可选能力：主动发送消息到指定线程/话题。
+// 平台不支持线程时可不实现该接口。
+type ThreadResponder interface {
+	// ResponseInThread 发送消息到 snapshot 所在会话的 threadID 线程。
+	// Parameters:
+	//   - snapshot: 源消息快照
+	//   - threadID: 目标线程 ID
+	//   - msg: 待发送内容
+	//
+	// Returns:
+	//   - error: 发送失败时返回
+	ResponseInThread(snapshot RequestSnapshot, threadID string, msg any) error
+}
@@ -0,0 +1,47 @@
+package botcore
+
+import (
+	"context"
+	"time"
+)
+
+// Timeout 返回超时中间件：下游在 d 内未结束输出时取消其 context（通过 PipelineContext.Context() 传递），
+// 并以截断提示作为最终片段收尾；此前已输出的内容保持不变（增量语义下即为截至超时的部分结果）。
+// 用于保护平台会话不被永不结束的 pipeline 占满（如企业微信流式会话的 6 分钟上限）。
+// Parameters:
+//   - d: 超时时间（<=0 表示不限制）
+//   - notice: 可选的截断提示文本或消息键（按快照语言本地化），省略时使用 MsgTruncated
+//
+// Returns:
+//   - Middleware: 超时中间件
+func Timeout(d time.Duration, notice ...string) Middleware {
+	text := MsgTruncated
+	if len(notice) > 0 {
+		text = notice[0]
+	}
+	return func(next PipelineInvoker) PipelineInvoker {
+		if d <= 0 {
+			return next
+		}
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			runCtx, cancel := context.WithTimeout(ctx.Context(), d)
+			in := next.Trigger(ctx.WithContext(runCtx))
+			if in == nil {
+				cancel()
+				return nil
+			}
+			out := make(chan StreamChunk)
+			go func() {
+				defer close(out)
+				defer cancel()
+				forwardUntil(in, out, runCtx.Done(), Localize(ctx.Snapshot, text))
+			}()
+			return out
+		})
+	}
+}
+
+// WithRouteTimeout 为路由设置超时，等价于 WithRouteMiddleware(Timeout(d))。
+func WithRouteTimeout(d time.Duration) RouteOption {
+	return WithRouteMiddleware(Timeout(d))
+}
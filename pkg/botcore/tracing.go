@@ -0,0 +1,88 @@
+package botcore
+
+import "context"
+
+// Tracer 是链路追踪的最小抽象，便于对接 OpenTelemetry 等实现而不引入依赖。
+// 典型 OpenTelemetry 适配：Start 调用 otel Tracer.Start 并以 attribute.String 写入 attrs，
+// Span.End 在 err 非空时调用 RecordError/SetStatus 后结束 span。
+type Tracer interface {
+	// Start 以 ctx 为父级创建 span，返回携带新 span 的 context。
+	Start(ctx context.Context, name string, attrs map[string]string) (context.Context, Span)
+}
+
+// Span 表示一次进行中的追踪区间。
+type Span interface {
+	// End 结束 span；err 非空表示该区间以失败告终。
+	End(err error)
+}
+
+// TracerFunc 便于直接以函数充当 Tracer。
+type TracerFunc func(ctx context.Context, name string, attrs map[string]string) (context.Context, Span)
+
+// Start 实现 Tracer 接口。
+func (f TracerFunc) Start(ctx context.Context, name string, attrs map[string]string) (context.Context, Span) {
+	return f(ctx, name, attrs)
+}
+
+// noopSpan 是未配置 Tracer 时使用的空 span。
+type noopSpan struct{}
+
+// End 实现 Span 接口。
+func (noopSpan) End(error) {}
+
+// StartSpan 以 tracer 创建 span；tracer 为 nil 时返回原 ctx 与空 span，调用方无需判空。
+func StartSpan(tracer Tracer, ctx context.Context, name string, attrs map[string]string) (context.Context, Span) {
+	if tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return tracer.Start(ctx, name, attrs)
+}
+
+// SnapshotAttributes 返回快照的通用追踪属性（消息 ID、会话、发送者）。
+func SnapshotAttributes(snapshot RequestSnapshot) map[string]string {
+	return map[string]string{
+		"imbot.message_id": snapshot.ID,
+		"imbot.chat_id":    snapshot.ChatID,
+		"imbot.chat_type":  string(snapshot.ChatType),
+		"imbot.sender_id":  snapshot.SenderID,
+	}
+}
+
+// Trace 返回为每次执行创建 span 的中间件。
+// span 以 PipelineContext.Context() 为父级，覆盖从 Trigger 到输出通道关闭的全过程，
+// 并通过 WithContext 传给下游，使下游（命令、LLM 调用）创建的 span 成为其子级。
+// Parameters:
+//   - tracer: 追踪实现；为 nil 时中间件不做任何事
+//   - name: span 名称，例如 "pipeline" 或 "route:ai"
+//
+// Returns:
+//   - Middleware: 追踪中间件
+func Trace(tracer Tracer, name string) Middleware {
+	return func(next PipelineInvoker) PipelineInvoker {
+		if tracer == nil {
+			return next
+		}
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			return triggerTraced(tracer, name, SnapshotAttributes(ctx.Snapshot), next, ctx)
+		})
+	}
+}
+
+// triggerTraced 在 span 内执行 next，span 于输出通道关闭时结束。
+func triggerTraced(tracer Tracer, name string, attrs map[string]string, next PipelineInvoker, ctx PipelineContext) <-chan StreamChunk {
+	spanCtx, span := tracer.Start(ctx.Context(), name, attrs)
+	in := next.Trigger(ctx.WithContext(spanCtx))
+	if in == nil {
+		span.End(nil)
+		return nil
+	}
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		defer span.End(nil)
+		for chunk := range in {
+			out <- chunk
+		}
+	}()
+	return out
+}
@@ -0,0 +1,106 @@
+package botcore
+
+import (
+	"context"
+	"strings"
+)
+
+// Transcriber 将语音附件转写为文本（如 Whisper 等 STT 服务）。
+type Transcriber interface {
+	// Transcribe 转写单个语音附件。
+	// Parameters:
+	//   - ctx: 调用上下文
+	//   - audio: 语音附件（Data 或 URL 至少一项可用）
+	//
+	// Returns:
+	//   - string: 转写文本
+	//   - error: 转写失败时返回
+	Transcribe(ctx context.Context, audio Attachment) (string, error)
+}
+
+// Transcribe 返回语音转写中间件。
+// 当快照 Text 为空且携带语音附件时，调用 Transcriber 填充 Text，并写入 Metadata["transcribed"]="true"。
+// 平台已提供转写结果（如企业微信 voice.content）时不会重复转写；转写失败时保持原快照继续路由。
+func Transcribe(t Transcriber) Middleware {
+	return func(next PipelineInvoker) PipelineInvoker {
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			if t == nil || strings.TrimSpace(ctx.Snapshot.Text) != "" {
+				return next.Trigger(ctx)
+			}
+			for _, att := range ctx.Snapshot.Attachments {
+				if att.Type != AttachmentTypeVoice {
+					continue
+				}
+				text, err := t.Transcribe(ctx.Context(), att)
+				if err != nil || strings.TrimSpace(text) == "" {
+					break
+				}
+				ctx.Snapshot.Text = text
+				ctx.Snapshot.Metadata = cloneMetadata(ctx.Snapshot.Metadata)
+				ctx.Snapshot.Metadata["transcribed"] = "true"
+				break
+			}
+			return next.Trigger(ctx)
+		})
+	}
+}
+
+// Synthesizer 将文本合成为语音（如 OpenAI TTS 等 TTS 服务）。
+type Synthesizer interface {
+	// Synthesize 合成语音。
+	// Parameters:
+	//   - ctx: 调用上下文
+	//   - text: 待朗读的文本
+	//
+	// Returns:
+	//   - Attachment: Type 为 AttachmentTypeVoice、Data 为音频内容的附件
+	//   - error: 合成失败时返回
+	Synthesize(ctx context.Context, text string) (Attachment, error)
+}
+
+// SpeakReplies 返回语音回复中间件：when 为真时把本次回答的正文合成语音，附加到最终片段的 Attachments。
+// when 为 nil 时仅对经 Transcribe 转写的语音提问（Metadata["transcribed"]="true"）生效。
+// 文本照常输出；推理过程、工具片段与 Payload 不参与朗读，合成失败时保持原最终片段。
+// Responser 实现 VoiceReplier 且声明不支持语音时（如企业微信智能机器人）不进行合成。
+func SpeakReplies(s Synthesizer, when func(snapshot RequestSnapshot) bool) Middleware {
+	if when == nil {
+		when = func(snapshot RequestSnapshot) bool { return snapshot.Metadata["transcribed"] == "true" }
+	}
+	return MapChunksWith(func(ctx PipelineContext) ChunkTransform {
+		if s == nil || !when(ctx.Snapshot) {
+			return nil
+		}
+		if r, ok := ctx.Responser.(VoiceReplier); ok && !r.CanSendVoice() {
+			return nil
+		}
+		var answer strings.Builder
+		return func(chunk StreamChunk) StreamChunk {
+			if chunk.Kind() == ChunkContent && chunk.Payload == nil {
+				answer.WriteString(chunk.Content)
+			}
+			if !chunk.IsFinal || chunk.Err != nil || chunk.Payload != nil {
+				return chunk
+			}
+			text := strings.TrimSpace(answer.String())
+			if text == "" {
+				return chunk
+			}
+			voice, err := s.Synthesize(ctx.Context(), text)
+			if err != nil {
+				return chunk
+			}
+			voice.Type = AttachmentTypeVoice
+			chunk.Attachments = append(chunk.Attachments[:len(chunk.Attachments):len(chunk.Attachments)], voice)
+			return chunk
+		}
+	})
+}
+
+// cloneMetadata 复制元数据，避免中间件修改调用方持有的 map。
+func cloneMetadata(meta map[string]string) map[string]string {
+	cloned := make(map[string]string, len(meta)+1)
+	for k, v := range meta {
+		cloned[k] = v
+	}
+	return cloned
+}
@@ -0,0 +1,40 @@
+package botcore
+
+// ChunkTransform 对单个输出片段做格式转换（如 Markdown 方言转换、代码块包装）。
+type ChunkTransform func(chunk StreamChunk) StreamChunk
+
+// MapChunks 返回对下游输出逐片段应用 transform 的中间件。
+func MapChunks(transform ChunkTransform) Middleware {
+	if transform == nil {
+		return MapChunksWith(nil)
+	}
+	return MapChunksWith(func(PipelineContext) ChunkTransform { return transform })
+}
+
+// MapChunksWith 与 MapChunks 相同，但每个请求通过 factory 创建独立的 transform，
+// 便于转换逻辑读取请求快照或持有单个流内的状态。
+func MapChunksWith(factory func(ctx PipelineContext) ChunkTransform) Middleware {
+	return func(next PipelineInvoker) PipelineInvoker {
+		if factory == nil {
+			return next
+		}
+		return PipelineFunc(func(ctx PipelineContext) <-chan StreamChunk {
+			in := next.Trigger(ctx)
+			if in == nil {
+				return nil
+			}
+			transform := factory(ctx)
+			if transform == nil {
+				return in
+			}
+			out := make(chan StreamChunk)
+			go func() {
+				defer close(out)
+				for chunk := range in {
+					out <- transform(chunk)
+				}
+			}()
+			return out
+		})
+	}
+}
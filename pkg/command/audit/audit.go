@@ -0,0 +1,447 @@
+// Package audit 记录命令执行的审计日志：谁在哪个会话、何时执行了什么命令及参数，结果与耗时。
+// 以 command.WithCommandMiddleware(audit.Middleware(store)) 接入，记录写入 Store（SQLite、Postgres），
+// 并提供供管理员查询的 /audit 命令。
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/internal/sqlutil"
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/IMBotPlatform/IMBotCore/pkg/command"
+	"github.com/spf13/cobra"
+)
+
+// Status 为命令执行结果。
+type Status string
+
+const (
+	StatusSuccess   Status = "success"   // 执行成功
+	StatusError     Status = "error"     // 命令返回错误
+	StatusCancelled Status = "cancelled" // 被 /cancel 中止或超时
+	StatusDenied    Status = "denied"    // 权限校验未通过，未执行
+	StatusThrottled Status = "throttled" // 被限流拦截，未执行
+)
+
+// /audit 使用的消息键。
+const (
+	MsgAuditEmpty = "audit.empty" // 没有匹配的记录
+	MsgAuditTitle = "audit.title" // 列表标题
+	MsgAuditError = "audit.error" // 参数：错误信息
+)
+
+func init() {
+	botcore.Messages.Add("zh", map[string]string{
+		MsgAuditEmpty: "没有匹配的审计记录。",
+		MsgAuditTitle: "**审计日志**",
+		MsgAuditError: "（%s）",
+	})
+	botcore.Messages.Add("en", map[string]string{
+		MsgAuditEmpty: "No matching audit entries.",
+		MsgAuditTitle: "**Audit log**",
+		MsgAuditError: " (%s)",
+	})
+}
+
+// Entry 为一条命令执行审计记录。
+type Entry struct {
+	Command   string           `json:"command"` // 命令路径，如 "deploy prod"；未知命令时为空
+	Args      []string         `json:"args"`    // 完整参数（含命令路径，经 WithRedactor 脱敏）
+	SenderID  string           `json:"sender_id"`
+	ChatID    string           `json:"chat_id"`
+	ChatType  botcore.ChatType `json:"chat_type,omitempty"`
+	Status    Status           `json:"status"`
+	Error     string           `json:"error,omitempty"`
+	StartedAt time.Time        `json:"started_at"`
+	Duration  time.Duration    `json:"duration"`
+}
+
+// Query 为审计记录查询条件，零值字段表示不限制。
+type Query struct {
+	SenderID string
+	ChatID   string
+	Command  string // 命令路径前缀，如 "deploy" 同时匹配 "deploy prod"
+	Since    time.Time
+	Limit    int // 返回条数上限（<=0 时为 20）
+}
+
+// Store 持久化并查询审计记录。
+type Store interface {
+	// Record 写入一条审计记录。
+	Record(ctx context.Context, entry Entry) error
+	// Query 按条件查询审计记录，按执行时间倒序返回。
+	Query(ctx context.Context, q Query) ([]Entry, error)
+}
+
+// defaultLimit 为未指定 Limit 时的返回条数。
+const defaultLimit = 20
+
+// Option 自定义 Middleware 行为。
+type Option func(*config)
+
+type config struct {
+	redact  func(path string, args []string) []string
+	onError func(err error)
+}
+
+// WithRedactor 在写入前脱敏参数（如隐藏 token、密码类参数），返回值作为记录的 Args。
+func WithRedactor(fn func(path string, args []string) []string) Option {
+	return func(c *config) {
+		c.redact = fn
+	}
+}
+
+// WithErrorHandler 设置写入失败时的回调（默认忽略）。
+func WithErrorHandler(fn func(err error)) Option {
+	return func(c *config) {
+		c.onError = fn
+	}
+}
+
+// Middleware 返回记录每次命令执行的审计中间件。记录在命令结束后写入，写入失败不影响命令结果。
+// 被权限校验或限流拦截的请求不会进入中间件，需同时注册 command.WithRejectionHook(RejectionHook(store))
+// 以 StatusDenied / StatusThrottled 记录。
+// Parameters:
+//   - store: 审计存储
+//   - opts: 可选配置（参数脱敏、错误回调）
+//
+// Returns:
+//   - command.CommandMiddleware: 审计中间件
+func Middleware(store Store, opts ...Option) command.CommandMiddleware {
+	cfg := newConfig(opts)
+	return func(next command.CommandHandler) command.CommandHandler {
+		return func(ctx context.Context, inv *command.Invocation) error {
+			startedAt := time.Now()
+			err := next(ctx, inv)
+
+			status := StatusSuccess
+			switch {
+			case ctx.Err() != nil:
+				status = StatusCancelled
+			case err != nil:
+				status = StatusError
+			}
+			cfg.record(ctx, store, inv, status, err, startedAt)
+			return err
+		}
+	}
+}
+
+// RejectionHook 返回记录被拦截命令请求的回调（权限不足记为 StatusDenied，限流记为 StatusThrottled），
+// 以 command.WithRejectionHook 注册，与 Middleware 共用同一存储即可在审计日志中看到全部尝试。
+// Parameters:
+//   - store: 审计存储
+//   - opts: 可选配置（参数脱敏、错误回调）
+//
+// Returns:
+//   - command.RejectionHook: 拦截回调
+func RejectionHook(store Store, opts ...Option) command.RejectionHook {
+	cfg := newConfig(opts)
+	return func(ctx context.Context, inv *command.Invocation, reason command.Rejection) {
+		status := StatusDenied
+		if reason == command.RejectedRateLimit {
+			status = StatusThrottled
+		}
+		cfg.record(ctx, store, inv, status, nil, time.Now())
+	}
+}
+
+// newConfig 应用可选配置。
+func newConfig(opts []Option) config {
+	cfg := config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// record 组装并写入一条审计记录，写入失败时交给 WithErrorHandler。
+func (c config) record(ctx context.Context, store Store, inv *command.Invocation, status Status, err error, startedAt time.Time) {
+	snapshot := inv.Exec.RequestSnapshot
+	entry := Entry{
+		Command:   inv.Path,
+		Args:      append([]string(nil), inv.Args...),
+		SenderID:  snapshot.SenderID,
+		ChatID:    snapshot.ChatID,
+		ChatType:  snapshot.ChatType,
+		Status:    status,
+		StartedAt: startedAt,
+		Duration:  time.Since(startedAt),
+	}
+	if c.redact != nil {
+		entry.Args = c.redact(inv.Path, entry.Args)
+	}
+	if status == StatusError {
+		entry.Error = err.Error()
+	}
+	// 关键步骤：命令被取消时 ctx 已结束，写入使用不随之取消的 context。
+	if recErr := store.Record(context.WithoutCancel(ctx), entry); recErr != nil && c.onError != nil {
+		c.onError(recErr)
+	}
+}
+
+// Command 返回供管理员查询审计记录的 /audit 命令，仅允许拥有 roles 中任一角色的用户执行（默认 "admin"，
+// 需配合 command.WithRoleProvider）。
+//
+//	/audit                                   最近 20 条记录
+//	/audit --user alice --command deploy     按执行者与命令过滤
+//	/audit --chat c1 --since 24h --limit 50  按会话与时间范围过滤
+//
+// Parameters:
+//   - store: 审计存储
+//   - roles: 允许查询的角色
+//
+// Returns:
+//   - *cobra.Command: 可挂载到命令树的 /audit 命令
+func Command(store Store, roles ...string) *cobra.Command {
+	if len(roles) == 0 {
+		roles = []string{"admin"}
+	}
+	var (
+		q     Query
+		since time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "查询命令执行审计日志",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if since > 0 {
+				q.Since = time.Now().Add(-since)
+			}
+			entries, err := store.Query(cmd.Context(), q)
+			if err != nil {
+				return err
+			}
+			snapshot := botcore.RequestSnapshot{}
+			if execCtx := command.FromContext(cmd.Context()); execCtx != nil {
+				snapshot = execCtx.RequestSnapshot
+			}
+			cmd.Print(Format(snapshot, entries))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&q.SenderID, "user", "", "执行者 ID")
+	cmd.Flags().StringVar(&q.ChatID, "chat", "", "会话 ID")
+	cmd.Flags().StringVar(&q.Command, "command", "", "命令路径前缀")
+	cmd.Flags().DurationVar(&since, "since", 0, "时间范围，如 24h")
+	cmd.Flags().IntVar(&q.Limit, "limit", defaultLimit, "返回条数")
+	return command.RequireRoles(cmd, roles...)
+}
+
+// Format 按快照语言将审计记录渲染为 Markdown 列表。
+// Parameters:
+//   - snapshot: 请求快照（决定语言，见 botcore.Localize）
+//   - entries: 审计记录
+//
+// Returns:
+//   - string: Markdown 文本
+func Format(snapshot botcore.RequestSnapshot, entries []Entry) string {
+	if len(entries) == 0 {
+		return botcore.Localize(snapshot, MsgAuditEmpty)
+	}
+	var b strings.Builder
+	b.WriteString(botcore.Localize(snapshot, MsgAuditTitle))
+	for _, e := range entries {
+		fmt.Fprintf(&b, "\n- %s %s@%s `%s` %s %s",
+			e.StartedAt.Local().Format("01-02 15:04:05"), e.SenderID, e.ChatID,
+			command.JoinArgs(e.Args), e.Status, e.Duration.Round(time.Millisecond))
+		if e.Error != "" {
+			b.WriteString(botcore.Localize(snapshot, MsgAuditError, e.Error))
+		}
+	}
+	return b.String()
+}
+
+// sqlMigrations 为审计表的版本化迁移（见 sqlutil.Migrate）。
+var sqlMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS {table} (
+		command TEXT,
+		args TEXT,
+		sender_id TEXT,
+		chat_id TEXT,
+		chat_type TEXT,
+		status TEXT NOT NULL,
+		error TEXT,
+		started_at {time} NOT NULL,
+		duration_ms BIGINT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS {table}_started_at ON {table} (started_at)`,
+}
+
+// SQLStore 将审计记录写入 database/sql 数据库（需调用方导入驱动）。
+type SQLStore struct {
+	db      *sql.DB
+	table   string
+	dialect sqlutil.Dialect
+}
+
+// NewSQLiteStore 创建 SQLite 审计存储并自动建表（建表使用 CREATE INDEX IF NOT EXISTS，不适用于 MySQL）。
+// Parameters:
+//   - db: 已打开的数据库连接
+//   - table: 表名；为空时使用 "command_audit"
+//
+// Returns:
+//   - *SQLStore: 审计存储
+//   - error: 表名非法或建表失败时返回
+func NewSQLiteStore(db *sql.DB, table string) (*SQLStore, error) {
+	return newSQLStore(db, table, sqlutil.SQLite)
+}
+
+// NewPostgresStore 创建 Postgres 审计存储并自动建表（使用 $n 占位符，时间列为 TIMESTAMPTZ）。
+// Parameters:
+//   - db: 已打开的数据库连接（如 pgx/stdlib、lib/pq）
+//   - table: 表名；为空时使用 "command_audit"
+//
+// Returns:
+//   - *SQLStore: 审计存储
+//   - error: 表名非法或建表失败时返回
+func NewPostgresStore(db *sql.DB, table string) (*SQLStore, error) {
+	return newSQLStore(db, table, sqlutil.Postgres)
+}
+
+// newSQLStore 按方言建表。
+func newSQLStore(db *sql.DB, table string, d sqlutil.Dialect) (*SQLStore, error) {
+	table, err := sqlutil.TableName(table, "command_audit")
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlutil.Migrate(context.Background(), db, d, table, sqlMigrations); err != nil {
+		return nil, fmt.Errorf("create audit table: %w", err)
+	}
+	return &SQLStore{db: db, table: table, dialect: d}, nil
+}
+
+// Record 实现 Store 接口。
+func (s *SQLStore) Record(ctx context.Context, entry Entry) error {
+	args, err := json.Marshal(entry.Args)
+	if err != nil {
+		return fmt.Errorf("marshal audit args: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO `+s.table+` (command, args, sender_id, chat_id, chat_type, status, error, started_at, duration_ms)
+		VALUES (`+s.dialect.Placeholders(1, 9)+`)`,
+		entry.Command, string(args), entry.SenderID, entry.ChatID, string(entry.ChatType),
+		string(entry.Status), entry.Error, s.dialect.TimeValue(entry.StartedAt), entry.Duration.Milliseconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("insert audit entry: %w", err)
+	}
+	return nil
+}
+
+// Query 实现 Store 接口。
+func (s *SQLStore) Query(ctx context.Context, q Query) ([]Entry, error) {
+	var (
+		conds []string
+		args  []any
+	)
+	add := func(cond string, value any) {
+		args = append(args, value)
+		conds = append(conds, strings.ReplaceAll(cond, "?", s.dialect.Placeholder(len(args))))
+	}
+	if q.SenderID != "" {
+		add("sender_id = ?", q.SenderID)
+	}
+	if q.ChatID != "" {
+		add("chat_id = ?", q.ChatID)
+	}
+	if q.Command != "" {
+		// 关键步骤：按路径前缀匹配时以空格为边界，避免 "deploy" 匹配 "deployment"。
+		args = append(args, q.Command, q.Command+" %")
+		conds = append(conds, fmt.Sprintf("(command = %s OR command LIKE %s)",
+			s.dialect.Placeholder(len(args)-1), s.dialect.Placeholder(len(args))))
+	}
+	if !q.Since.IsZero() {
+		add("started_at >= ?", s.dialect.TimeValue(q.Since))
+	}
+	stmt := `SELECT command, args, sender_id, chat_id, chat_type, status, error, started_at, duration_ms FROM ` + s.table
+	if len(conds) > 0 {
+		stmt += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	stmt += ` ORDER BY started_at DESC LIMIT ` + strconv.Itoa(limitOf(q))
+
+	rows, err := s.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var (
+			e          Entry
+			argsJSON   sql.NullString
+			chatType   sql.NullString
+			errText    sql.NullString
+			startedAt  any
+			durationMS int64
+		)
+		if err := rows.Scan(&e.Command, &argsJSON, &e.SenderID, &e.ChatID, &chatType, &e.Status, &errText, &startedAt, &durationMS); err != nil {
+			return nil, fmt.Errorf("scan audit entry: %w", err)
+		}
+		if argsJSON.String != "" {
+			_ = json.Unmarshal([]byte(argsJSON.String), &e.Args)
+		}
+		e.ChatType = botcore.ChatType(chatType.String)
+		e.Error = errText.String
+		if e.StartedAt, err = sqlutil.ScanTime(startedAt); err != nil {
+			return nil, fmt.Errorf("scan audit entry: %w", err)
+		}
+		e.Duration = time.Duration(durationMS) * time.Millisecond
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// limitOf 返回查询条数上限。
+func limitOf(q Query) int {
+	if q.Limit <= 0 {
+		return defaultLimit
+	}
+	return q.Limit
+}
+
+// MemoryStore 是进程内 Store 实现，适用于测试。
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewMemoryStore 创建进程内审计存储。
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Record 实现 Store 接口。
+func (s *MemoryStore) Record(ctx context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+// Query 实现 Store 接口。
+func (s *MemoryStore) Query(ctx context.Context, q Query) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matched []Entry
+	for i := len(s.entries) - 1; i >= 0 && len(matched) < limitOf(q); i-- {
+		e := s.entries[i]
+		if (q.SenderID != "" && e.SenderID != q.SenderID) ||
+			(q.ChatID != "" && e.ChatID != q.ChatID) ||
+			(q.Command != "" && e.Command != q.Command && !strings.HasPrefix(e.Command, q.Command+" ")) ||
+			(!q.Since.IsZero() && e.StartedAt.Before(q.Since)) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	return matched, nil
+}
@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/IMBotPlatform/IMBotCore/pkg/command"
+	"github.com/spf13/cobra"
+	_ "modernc.org/sqlite"
+)
+
+func newManager(store Store) *command.Manager {
+	return command.NewManager(func() *cobra.Command {
+		root := &cobra.Command{Use: "bot"}
+		deploy := &cobra.Command{Use: "deploy", RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 && args[0] == "broken" {
+				return errors.New("boom")
+			}
+			cmd.Print("ok")
+			return nil
+		}}
+		deploy.Flags().String("token", "", "部署凭据")
+		root.AddCommand(deploy)
+		root.AddCommand(Command(store))
+		return root
+	},
+		command.WithRoleProvider(command.StaticRoles{"root": {"admin"}}),
+		command.WithCommandMiddleware(Middleware(store, WithRedactor(func(path string, args []string) []string {
+			for i, arg := range args {
+				if strings.HasPrefix(arg, "--token=") {
+					args[i] = "--token=***"
+				}
+			}
+			return args
+		}))),
+	)
+}
+
+func send(mgr *command.Manager, sender, text string) string {
+	var out strings.Builder
+	snapshot := botcore.RequestSnapshot{SenderID: sender, ChatID: "c1", Text: text}
+	for chunk := range mgr.Trigger(botcore.PipelineContext{Snapshot: snapshot}) {
+		out.WriteString(chunk.Content)
+	}
+	return out.String()
+}
+
+func TestMiddlewareRecordsExecutions(t *testing.T) {
+	db, err := sql.Open("sqlite", t.TempDir()+"/audit.db")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	store, err := NewSQLiteStore(db, "")
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	mgr := newManager(store)
+
+	send(mgr, "alice", "/deploy prod --token=secret")
+	send(mgr, "bob", "/deploy broken")
+
+	entries, err := store.Query(context.Background(), Query{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	failed, ok := entries[0], entries[1]
+	if failed.SenderID != "bob" || failed.Status != StatusError || failed.Error != "boom" || failed.Command != "deploy" {
+		t.Fatalf("unexpected failed entry: %+v", failed)
+	}
+	if ok.SenderID != "alice" || ok.Status != StatusSuccess || ok.ChatID != "c1" ||
+		strings.Join(ok.Args, " ") != "deploy prod --token=***" || ok.StartedAt.IsZero() {
+		t.Fatalf("unexpected success entry: %+v", ok)
+	}
+
+	entries, _ = store.Query(context.Background(), Query{SenderID: "alice", Command: "deploy", Since: time.Now().Add(-time.Hour)})
+	if len(entries) != 1 || entries[0].SenderID != "alice" {
+		t.Fatalf("unexpected filtered entries: %+v", entries)
+	}
+	if entries, _ = store.Query(context.Background(), Query{Command: "dep"}); len(entries) != 0 {
+		t.Fatalf("expected prefix match on path boundary, got %+v", entries)
+	}
+}
+
+func TestAuditCommandRequiresAdmin(t *testing.T) {
+	store := NewMemoryStore()
+	mgr := newManager(store)
+	send(mgr, "alice", "/deploy prod")
+
+	if got := send(mgr, "alice", "/audit"); strings.Contains(got, "审计日志") {
+		t.Fatalf("expected non-admin to be denied, got %q", got)
+	}
+	got := send(mgr, "root", "/audit --user alice")
+	if !strings.Contains(got, "**审计日志**") || !strings.Contains(got, "alice@c1 `deploy prod` success") {
+		t.Fatalf("unexpected audit output: %q", got)
+	}
+	if got := send(mgr, "root", "/audit --user nobody"); got != "没有匹配的审计记录。" {
+		t.Fatalf("unexpected empty output: %q", got)
+	}
+}
+
+func TestRejectionHookRecordsDeniedAndThrottled(t *testing.T) {
+	store := NewMemoryStore()
+	mgr := command.NewManager(func() *cobra.Command {
+		root := &cobra.Command{Use: "bot"}
+		root.AddCommand(&cobra.Command{Use: "deploy", Run: func(cmd *cobra.Command, args []string) { cmd.Print("ok") }})
+		root.AddCommand(Command(store))
+		return root
+	},
+		command.WithRoleProvider(command.StaticRoles{"root": {"admin"}}),
+		command.WithRateLimit(command.RateLimitPolicy{Commands: map[string]command.RateLimitRule{"deploy": {Limit: 1, Window: time.Hour}}}),
+		command.WithCommandMiddleware(Middleware(store)),
+		command.WithRejectionHook(RejectionHook(store)),
+	)
+
+	send(mgr, "alice", "/audit")
+	send(mgr, "alice", "/deploy prod")
+	send(mgr, "alice", "/deploy prod")
+
+	entries, _ := store.Query(context.Background(), Query{SenderID: "alice"})
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", entries)
+	}
+	throttled, success, denied := entries[0], entries[1], entries[2]
+	if denied.Status != StatusDenied || denied.Command != "audit" {
+		t.Fatalf("unexpected denied entry: %+v", denied)
+	}
+	if success.Status != StatusSuccess || throttled.Status != StatusThrottled || strings.Join(throttled.Args, " ") != "deploy prod" {
+		t.Fatalf("unexpected entries: %+v %+v", success, throttled)
+	}
+}
+
+func TestFormatLocalizes(t *testing.T) {
+	en := botcore.RequestSnapshot{Metadata: map[string]string{botcore.MetaLanguage: "en"}}
+	if got := Format(en, nil); got != "No matching audit entries." {
+		t.Fatalf("unexpected empty output: %q", got)
+	}
+	got := Format(en, []Entry{{Command: "deploy", Args: []string{"deploy"}, SenderID: "a", ChatID: "c", Status: StatusError, Error: "boom"}})
+	if !strings.HasPrefix(got, "**Audit log**") || !strings.HasSuffix(got, "error 0s (boom)") {
+		t.Fatalf("unexpected output: %q", got)
+	}
+}
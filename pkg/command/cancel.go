@@ -0,0 +1,141 @@
+package command
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
+)
+
+// 命令取消与超时使用的消息键。
+const (
+	MsgCommandCancelled = "command.cancelled" // 命令被 /cancel 中止
+	MsgCommandTimeout   = "command.timeout"   // 命令执行超时
+	MsgCancelDone       = "command.cancel"    // /cancel 的回复，参数：中止的命令数
+	MsgCancelNone       = "command.cancel.none"
+)
+
+func init() {
+	botcore.Messages.Add("zh", map[string]string{
+		MsgCommandCancelled: "⏹ 命令已取消。",
+		MsgCommandTimeout:   "⏱ 命令执行超时，已终止。",
+		MsgCancelDone:       "已取消 %d 个运行中的命令。",
+		MsgCancelNone:       "当前没有运行中的命令。",
+	})
+	botcore.Messages.Add("en", map[string]string{
+		MsgCommandCancelled: "⏹ Command cancelled.",
+		MsgCommandTimeout:   "⏱ Command timed out and was stopped.",
+		MsgCancelDone:       "Cancelled %d running command(s).",
+		MsgCancelNone:       "No command is running.",
+	})
+}
+
+// defaultCancelCommand 为内置取消指令的默认名称。
+const defaultCancelCommand = "cancel"
+
+// WithTimeout 为每次命令执行设置超时：到期后取消 cmd.Context() 并以超时提示结束回复。
+// 命令实现需响应 cmd.Context() 的取消信号才能真正停止。
+func WithTimeout(d time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.timeout = d
+	}
+}
+
+// WithCancelCommand 设置内置取消指令的名称（默认 "cancel"，即 /cancel），传入空字符串禁用。
+// 取消指令会中止同一会话（见 WithConversationKey，默认 chatID:senderID）所有运行中的命令；命令树中已定义同名命令时以命令树为准。
+func WithCancelCommand(name string) ManagerOption {
+	return func(m *Manager) {
+		m.cancelName = name
+	}
+}
+
+// executions 按会话登记运行中命令的取消函数。
+type executions struct {
+	mu     sync.Mutex
+	nextID uint64
+	byKey  map[string]map[uint64]context.CancelFunc
+}
+
+// add 登记一次执行，返回注销函数。
+func (e *executions) add(key string, cancel context.CancelFunc) func() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.byKey == nil {
+		e.byKey = make(map[string]map[uint64]context.CancelFunc)
+	}
+	e.nextID++
+	id := e.nextID
+	if e.byKey[key] == nil {
+		e.byKey[key] = make(map[uint64]context.CancelFunc)
+	}
+	e.byKey[key][id] = cancel
+	return func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		delete(e.byKey[key], id)
+		if len(e.byKey[key]) == 0 {
+			delete(e.byKey, key)
+		}
+	}
+}
+
+// cancel 取消 key 下全部运行中的执行，返回取消数量。
+func (e *executions) cancel(key string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	running := e.byKey[key]
+	delete(e.byKey, key)
+	for _, cancel := range running {
+		cancel()
+	}
+	return len(running)
+}
+
+// isCancelCommand 判断 args 是否为内置取消指令（命令树未定义同名命令时）。
+func (m *Manager) isCancelCommand(root *cobra.Command, args []string) bool {
+	if m.cancelName == "" || len(args) == 0 || !strings.EqualFold(args[0], m.cancelName) {
+		return false
+	}
+	target, _, err := root.Find(args)
+	return err != nil || target == root
+}
+
+// watchExecution 在执行被取消或超时时立即以提示结束回复，返回用于等待监视结束的函数。
+func watchExecution(ctx context.Context, execCtx *ExecutionContext, snapshot botcore.RequestSnapshot) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			key := MsgCommandCancelled
+			if ctx.Err() == context.DeadlineExceeded {
+				key = MsgCommandTimeout
+			}
+			execCtx.sendFinal(botcore.StreamChunk{Content: botcore.Localize(snapshot, key)})
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// abortableWriter 在执行被中止后丢弃输出，避免 Cobra 在命令返回 context 错误后继续打印错误与用法。
+type abortableWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+// Write 实现 io.Writer 接口。
+func (a abortableWriter) Write(p []byte) (int, error) {
+	if a.ctx.Err() != nil {
+		return len(p), nil
+	}
+	return a.w.Write(p)
+}
@@ -0,0 +1,62 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
+)
+
+// newSlowCommands 返回一个阻塞到 context 结束的 /slow 命令，started 在命令开始执行时收到通知。
+func newSlowCommands(started chan<- struct{}) CommandFunc {
+	return func() *cobra.Command {
+		root := &cobra.Command{Use: "bot"}
+		root.AddCommand(&cobra.Command{Use: "slow", RunE: func(cmd *cobra.Command, args []string) error {
+			started <- struct{}{}
+			<-cmd.Context().Done()
+			return cmd.Context().Err()
+		}})
+		return root
+	}
+}
+
+func TestManagerCancelCommand(t *testing.T) {
+	started := make(chan struct{}, 1)
+	mgr := NewManager(newSlowCommands(started))
+
+	done := make(chan string, 1)
+	go func() { done <- run(mgr, "u1", "/slow") }()
+	<-started
+
+	if got := run(mgr, "u2", "/cancel"); got != "当前没有运行中的命令。" {
+		t.Fatalf("expected other user to have nothing to cancel, got %q", got)
+	}
+	if got := run(mgr, "u1", "/cancel"); got != "已取消 1 个运行中的命令。" {
+		t.Fatalf("unexpected cancel reply: %q", got)
+	}
+	select {
+	case got := <-done:
+		if got != "⏹ 命令已取消。" {
+			t.Fatalf("unexpected cancelled output: %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("command was not cancelled")
+	}
+}
+
+func TestManagerTimeout(t *testing.T) {
+	started := make(chan struct{}, 1)
+	mgr := NewManager(newSlowCommands(started), WithTimeout(20*time.Millisecond), WithCancelCommand(""))
+
+	var chunks []botcore.StreamChunk
+	for chunk := range mgr.Trigger(botcore.PipelineContext{Snapshot: botcore.RequestSnapshot{SenderID: "u1", Text: "/slow"}}) {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 1 || chunks[0].Content != "⏱ 命令执行超时，已终止。" || !chunks[0].IsFinal || chunks[0].Err != nil {
+		t.Fatalf("unexpected timeout chunks: %+v", chunks)
+	}
+	if got := run(mgr, "u1", "/cancel"); got == "当前没有运行中的命令。" {
+		t.Fatalf("expected disabled cancel command to be treated as unknown")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/tmc/langchaingo/llms"
 )
 
 // keyExecutionContext 是 context.Context 中存储 ExecutionContext 的键。
@@ -18,6 +19,7 @@ var (
 	errSendFuncMissing         = errors.New("send function is nil")
 	errSendMarkdownMissing     = errors.New("send markdown function is nil")
 	errSendTemplateCardMissing = errors.New("send template card function is nil")
+	errChatSenderMissing       = errors.New("responser does not support sending to chat")
 )
 
 // ExecutionContext 为命令 handler 提供必要的环境信息。
@@ -30,6 +32,48 @@ type ExecutionContext struct {
 
 	// responsers 由 Manager 注入，负责主动推送。
 	responser botcore.Responser
+
+	// flush 由 Manager 注入，在发送最终片段前发送缓冲中的输出。
+	flush func() error
+
+	// conversationKey 为当前请求所属会话的键，由 Manager 按 WithConversationKey 计算。
+	conversationKey string
+
+	// llm 为 Manager 注入的模型（见 WithLLM）。
+	llm llms.Model
+
+	// dryRun 为 Manager 级演练模式（见 WithDryRun），dryRunFlag 由全局 --dry-run 参数写入（见 WithDryRunFlag）。
+	dryRun     bool
+	dryRunFlag bool
+}
+
+// QuotedText 返回当前消息引用内容中的文本，便于 /summarize 等命令直接处理被引用消息。
+// Returns:
+//   - string: 引用文本
+//   - bool: 当前消息是否携带引用
+func (ctx *ExecutionContext) QuotedText() (string, bool) {
+	if ctx == nil || ctx.RequestSnapshot.Reference == nil {
+		return "", false
+	}
+	return ctx.RequestSnapshot.Reference.Text, true
+}
+
+// Action 返回触发当前命令的交互式应用按钮回调。
+// Returns:
+//   - botcore.Action: 回调内容
+//   - bool: 当前消息是否为按钮回调
+func (ctx *ExecutionContext) Action() (botcore.Action, bool) {
+	if ctx == nil || ctx.RequestSnapshot.Action == nil {
+		return botcore.Action{}, false
+	}
+	return *ctx.RequestSnapshot.Action, true
+}
+
+// AnswerAction 以被动回复应答按钮回调并结束本次输出；需要替换原卡片时使用 UpdateCard。
+// Parameters:
+//   - content: 回复文本
+func (ctx *ExecutionContext) AnswerAction(content string) {
+	ctx.sendFinal(botcore.StreamChunk{Content: content})
 }
 
 // Response 发送主动回复消息。
@@ -83,6 +127,110 @@ func (ctx *ExecutionContext) ResponseTemplateCard(card any) error {
 	return ctx.responser.ResponseTemplateCard(responseURL, card)
 }
 
+// Responser 返回注入的主动推送器（平台实现，如 *wecom.Bot），可类型断言以使用平台特有能力；未注入时为 nil。
+func (ctx *ExecutionContext) Responser() botcore.Responser {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.responser
+}
+
+// ReplyMarkdown 回复 Markdown 文本：当前请求带有 response_url 且已注入 Responser 时主动推送，
+// 否则作为被动回复结束本次输出。命令无需关心 response_url 是否可用。
+// Parameters:
+//   - content: Markdown 文本内容
+//
+// Returns:
+//   - error: 主动推送失败时返回
+func (ctx *ExecutionContext) ReplyMarkdown(content string) error {
+	if ctx == nil {
+		return errExecutionContextNil
+	}
+	if ctx.canRespond() {
+		return ctx.ResponseMarkdown(content)
+	}
+	ctx.sendFinal(botcore.StreamChunk{Content: content})
+	return nil
+}
+
+// ReplyCard 回复模板卡片：可主动推送时经 response_url 发送，否则作为被动回复的 Payload 结束本次输出。
+// Parameters:
+//   - card: 模板卡片负载
+//
+// Returns:
+//   - error: 主动推送失败时返回
+func (ctx *ExecutionContext) ReplyCard(card any) error {
+	if ctx == nil {
+		return errExecutionContextNil
+	}
+	if ctx.canRespond() {
+		return ctx.ResponseTemplateCard(card)
+	}
+	ctx.SendPayload(card)
+	return nil
+}
+
+// SendToChat 向指定会话推送消息（不依赖 response_url），需注入的 Responser 实现 botcore.ChatSender。
+// Parameters:
+//   - chatID: 目标会话 ID（为空时使用当前会话）
+//   - msg: 平台消息负载
+//
+// Returns:
+//   - error: 平台不支持或发送失败时返回
+func (ctx *ExecutionContext) SendToChat(chatID string, msg any) error {
+	if ctx == nil {
+		return errExecutionContextNil
+	}
+	sender, ok := ctx.responser.(botcore.ChatSender)
+	if !ok {
+		return errChatSenderMissing
+	}
+	if chatID == "" {
+		chatID = ctx.RequestSnapshot.ChatID
+	}
+	return sender.SendToChat(context.Background(), chatID, msg)
+}
+
+// canRespond 判断当前请求能否主动推送。
+func (ctx *ExecutionContext) canRespond() bool {
+	return ctx.responser != nil && strings.TrimSpace(ctx.RequestSnapshot.ResponseURL) != ""
+}
+
+// ResponseInThread 发送主动回复到当前消息所在的线程（参见 botcore.RequestSnapshot.ReplyThreadID）。
+// 当注入的 Responser 未实现 botcore.ThreadResponder 时退化为 Response。
+// Parameters:
+//   - msg: 平台消息负载
+//
+// Returns:
+//   - error: 发送失败时返回
+func (ctx *ExecutionContext) ResponseInThread(msg any) error {
+	if ctx == nil {
+		return errExecutionContextNil
+	}
+	if threaded, ok := ctx.responser.(botcore.ThreadResponder); ok {
+		return threaded.ResponseInThread(ctx.RequestSnapshot, ctx.RequestSnapshot.ReplyThreadID(), msg)
+	}
+	return ctx.Response(msg)
+}
+
+// React 为当前消息添加表情回应。
+// 当注入的 Responser 未实现 botcore.Reactor 时视为平台不支持，直接返回 nil。
+// Parameters:
+//   - emoji: 表情标识
+//
+// Returns:
+//   - error: 发送失败时返回
+func (ctx *ExecutionContext) React(emoji string) error {
+	if ctx == nil {
+		return errExecutionContextNil
+	}
+	reactor, ok := ctx.responser.(botcore.Reactor)
+	if !ok {
+		return nil
+	}
+	return reactor.React(ctx.RequestSnapshot, emoji)
+}
+
 // SendPayload 立即发送非流式响应对象。
 func (ctx *ExecutionContext) SendPayload(payload any) {
 	ctx.sendFinal(botcore.StreamChunk{
@@ -90,6 +238,26 @@ func (ctx *ExecutionContext) SendPayload(payload any) {
 	})
 }
 
+// SendImage 在流式输出中追加一张图片。
+// 平台不支持中途展示图片时（如企业微信），图片会随最终片段一并发送。
+func (ctx *ExecutionContext) SendImage(data []byte) {
+	if ctx.ch == nil || len(data) == 0 {
+		return
+	}
+	ctx.ch <- botcore.ImageChunk(data)
+}
+
+// UpdateCard 以被动回复方式更新触发事件的模板卡片。
+// Parameters:
+//   - card: 平台卡片结构（如 *wecom.TemplateCard）
+//   - userIDs: 仅替换指定用户的卡片；为空表示替换所有人
+func (ctx *ExecutionContext) UpdateCard(card any, userIDs ...string) {
+	ctx.SendPayload(botcore.CardUpdate{
+		Card:    card,
+		UserIDs: userIDs,
+	})
+}
+
 // SendNoResponse 立即发送静默信号。
 // Bot 层收到此信号后将直接返回 HTTP 200 OK 空包。
 func (ctx *ExecutionContext) SendNoResponse() {
@@ -104,6 +272,9 @@ func (ctx *ExecutionContext) sendFinal(chunk botcore.StreamChunk) {
 	}
 
 	chunk.IsFinal = true
+	if ctx.flush != nil {
+		_ = ctx.flush()
+	}
 	ctx.finalOnce.Do(func() {
 		ctx.ch <- chunk
 	})
@@ -0,0 +1,90 @@
+package command
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+)
+
+func TestExecutionContextQuotedText(t *testing.T) {
+	ctx := &ExecutionContext{RequestSnapshot: botcore.RequestSnapshot{
+		Text:      "/summarize",
+		Reference: &botcore.Reference{Type: "text", Text: "a long quoted message"},
+	}}
+	text, ok := ctx.QuotedText()
+	if !ok || text != "a long quoted message" {
+		t.Fatalf("unexpected quoted text: %q ok=%v", text, ok)
+	}
+
+	if _, ok := (&ExecutionContext{}).QuotedText(); ok {
+		t.Fatal("expected no quote when Reference is nil")
+	}
+}
+
+func TestExecutionContextAnswerAction(t *testing.T) {
+	ch := make(chan botcore.StreamChunk, 2)
+	ctx := &ExecutionContext{
+		RequestSnapshot: botcore.RequestSnapshot{Action: &botcore.Action{CallbackID: "approve", Value: "/approve 1"}},
+		ch:              ch,
+	}
+	action, ok := ctx.Action()
+	if !ok || action.CallbackID != "approve" {
+		t.Fatalf("unexpected action: %+v ok=%v", action, ok)
+	}
+	ctx.AnswerAction("approved")
+	ctx.AnswerAction("ignored")
+	close(ch)
+	var chunks []botcore.StreamChunk
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 1 || chunks[0].Content != "approved" || !chunks[0].IsFinal {
+		t.Fatalf("unexpected chunks: %+v", chunks)
+	}
+}
+
+// chatResponser 在 recordResponser 基础上实现 botcore.ChatSender。
+type chatResponser struct {
+	recordResponser
+	chats []string
+}
+
+func (r *chatResponser) SendToChat(ctx context.Context, chatID string, msg any) error {
+	r.chats = append(r.chats, chatID)
+	return nil
+}
+
+func TestExecutionContextReplyHelpers(t *testing.T) {
+	// 有 response_url 时主动推送。
+	responser := &chatResponser{}
+	ctx := &ExecutionContext{
+		RequestSnapshot: botcore.RequestSnapshot{ChatID: "c1", ResponseURL: "https://example.com/resp"},
+		responser:       responser,
+	}
+	if err := ctx.ReplyMarkdown("**done**"); err != nil {
+		t.Fatalf("ReplyMarkdown: %v", err)
+	}
+	if sent := responser.messages(); len(sent) != 1 || sent[0] != "**done**" || responser.urls[0] != "https://example.com/resp" {
+		t.Fatalf("unexpected active reply: %q", sent)
+	}
+	if ctx.Responser() != botcore.Responser(responser) {
+		t.Fatal("expected Responser to expose the injected responser")
+	}
+	if err := ctx.SendToChat("", "hello"); err != nil || len(responser.chats) != 1 || responser.chats[0] != "c1" {
+		t.Fatalf("unexpected SendToChat: err=%v chats=%v", err, responser.chats)
+	}
+
+	// 无 response_url 时退化为被动回复。
+	ch := make(chan botcore.StreamChunk, 1)
+	passive := &ExecutionContext{ch: ch}
+	if err := passive.ReplyMarkdown("**done**"); err != nil {
+		t.Fatalf("ReplyMarkdown: %v", err)
+	}
+	if chunk := <-ch; chunk.Content != "**done**" || !chunk.IsFinal {
+		t.Fatalf("unexpected passive reply: %+v", chunk)
+	}
+	if err := passive.SendToChat("c2", "hello"); err == nil {
+		t.Fatal("expected SendToChat to fail without ChatSender")
+	}
+}
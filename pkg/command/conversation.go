@@ -0,0 +1,40 @@
+package command
+
+import "github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+
+// 会话范围预设，用于 WithConversationKey。
+var (
+	// PerChatUser 每个会话成员独立（chatID:senderID），为默认范围。
+	PerChatUser botcore.KeyFunc = func(s botcore.RequestSnapshot) string { return s.ChatID + ":" + s.SenderID }
+	// PerChat 同一会话内所有成员共享（如群聊共同维护的上下文）。
+	PerChat botcore.KeyFunc = func(s botcore.RequestSnapshot) string { return s.ChatID }
+	// PerUser 同一用户跨会话共享。
+	PerUser botcore.KeyFunc = func(s botcore.RequestSnapshot) string { return s.SenderID }
+	// PerThread 同一线程/话题内共享；不在线程中时退化为 PerChatUser。
+	PerThread botcore.KeyFunc = func(s botcore.RequestSnapshot) string {
+		if s.ThreadID == "" {
+			return PerChatUser(s)
+		}
+		return s.ChatID + "#" + s.ThreadID
+	}
+)
+
+// WithConversationKey 设置命令的会话范围（默认 PerChatUser），
+// 决定 ExecutionContext.ConversationKey 的取值以及内置 /cancel 可中止的命令范围。
+// 群聊需要共享上下文时可使用 PerChat，按话题隔离时使用 PerThread。
+func WithConversationKey(key botcore.KeyFunc) ManagerOption {
+	return func(m *Manager) {
+		if key != nil {
+			m.conversationKey = key
+		}
+	}
+}
+
+// ConversationKey 返回当前请求所属会话的键（见 WithConversationKey），
+// 命令可据此在外部存储中保存、读取跨消息的上下文。
+func (ctx *ExecutionContext) ConversationKey() string {
+	if ctx == nil {
+		return ""
+	}
+	return ctx.conversationKey
+}
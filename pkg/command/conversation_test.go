@@ -0,0 +1,61 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
+)
+
+func TestConversationKeyPresets(t *testing.T) {
+	s := botcore.RequestSnapshot{ChatID: "c1", SenderID: "u1"}
+	threaded := botcore.RequestSnapshot{ChatID: "c1", SenderID: "u1", ThreadID: "t9"}
+	cases := []struct {
+		name string
+		key  botcore.KeyFunc
+		snap botcore.RequestSnapshot
+		want string
+	}{
+		{"PerChatUser", PerChatUser, s, "c1:u1"},
+		{"PerChat", PerChat, s, "c1"},
+		{"PerUser", PerUser, s, "u1"},
+		{"PerThread", PerThread, threaded, "c1#t9"},
+		{"PerThreadOutsideThread", PerThread, s, "c1:u1"},
+	}
+	for _, tc := range cases {
+		if got := tc.key(tc.snap); got != tc.want {
+			t.Errorf("%s = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestWithConversationKey(t *testing.T) {
+	var got string
+	mgr := NewManager(func() *cobra.Command {
+		root := &cobra.Command{Use: "bot"}
+		root.AddCommand(&cobra.Command{Use: "whoami", Run: func(cmd *cobra.Command, args []string) {
+			got = FromContext(cmd.Context()).ConversationKey()
+		}})
+		return root
+	}, WithConversationKey(PerUser))
+	run(mgr, "u1", "/whoami")
+	if got != "u1" {
+		t.Fatalf("unexpected conversation key: %q", got)
+	}
+
+	// 关键步骤：按会话共享时，群内其他成员的 /cancel 也能中止命令。
+	started := make(chan struct{}, 1)
+	shared := NewManager(newSlowCommands(started), WithConversationKey(PerChat))
+	done := make(chan string, 1)
+	go func() { done <- run(shared, "u1", "/slow") }()
+	<-started
+	if got := run(shared, "u2", "/cancel"); got != "已取消 1 个运行中的命令。" {
+		t.Fatalf("unexpected cancel reply: %q", got)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("command was not cancelled")
+	}
+}
@@ -0,0 +1,625 @@
+// Package declarative 从 YAML 文件构建简单命令：声明名称、参数与一次模板化的 HTTP 调用或脚本执行，
+// 再以模板渲染回复。Loader 实现 command.Module，文件变更后自动重新加载，无需重新编译部署。
+//
+//	commands:
+//	  - name: weather
+//	    short: 查询天气
+//	    args:
+//	      - name: city
+//	        default: 北京
+//	    http:
+//	      url: "https://api.example.com/weather?city={{ urlquery .Args.city }}"
+//	      timeout: 5s
+//	    response: "{{ .Args.city }}：{{ .JSON.temp }}℃"
+//	  - name: disk
+//	    roles: [ops]
+//	    script:
+//	      command: ["df", "-h", "{{ .Args.path }}"]
+//	    args:
+//	      - name: path
+//	        required: true
+//
+// 脚本参数以 "-" 开头时会被目标程序当作选项解析（如 "--output=/etc/passwd"），因此默认拒绝；
+// 确需传入选项的参数声明 allow_flags: true。模板中的 env 只能读取 WithEnv 放行的环境变量。
+package declarative
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/IMBotPlatform/IMBotCore/pkg/command"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// defaultTimeout 为未声明 timeout 时 HTTP 调用与脚本的执行超时。
+	defaultTimeout = 10 * time.Second
+	// defaultPollInterval 为检查文件变更的默认间隔。
+	defaultPollInterval = 2 * time.Second
+	// maxOutputSize 限制读取的 HTTP 响应体与脚本输出大小。
+	maxOutputSize = 1 << 20
+)
+
+// File 为 YAML 文件的顶层结构。
+type File struct {
+	Commands []Spec `yaml:"commands"`
+}
+
+// Spec 声明一条命令，HTTP 与 Script 必须且只能设置一个。
+type Spec struct {
+	// Name 为命令名，如 "weather" 即 /weather。
+	Name string `yaml:"name"`
+	// Short 为帮助中显示的简介。
+	Short string `yaml:"short"`
+	// Args 按顺序声明位置参数。
+	Args []ArgSpec `yaml:"args"`
+	// Roles 为允许执行的角色（见 command.RequireRoles），为空表示对所有人开放。
+	Roles []string `yaml:"roles"`
+	// HTTP 为模板化的 HTTP 调用。
+	HTTP *HTTPSpec `yaml:"http"`
+	// Script 为模板化的脚本执行。
+	Script *ScriptSpec `yaml:"script"`
+	// Response 为回复模板；为空时直接回复响应体或脚本输出。
+	Response string `yaml:"response"`
+}
+
+// ArgSpec 声明一个位置参数。
+type ArgSpec struct {
+	Name     string `yaml:"name"`
+	Required bool   `yaml:"required"`
+	Default  string `yaml:"default"`
+	// AllowFlags 允许脚本命令的该参数以 "-" 开头（默认拒绝，避免用户输入被目标程序解析为选项）。
+	AllowFlags bool `yaml:"allow_flags"`
+}
+
+// HTTPSpec 声明一次 HTTP 调用，URL、请求头与请求体均为模板。
+type HTTPSpec struct {
+	Method  string            `yaml:"method"` // 默认 GET
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+	Timeout time.Duration     `yaml:"timeout"`
+}
+
+// ScriptSpec 声明一次脚本执行。Command 的每一项分别渲染后作为 argv 直接执行（不经过 shell），
+// 参数值不会被解释为 shell 语法。
+type ScriptSpec struct {
+	Command []string      `yaml:"command"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Data 为模板可用的数据。
+type Data struct {
+	// Args 为参数名到取值的映射。
+	Args map[string]string
+	// SenderID、ChatID 为发起请求的用户与会话。
+	SenderID string
+	ChatID   string
+	// Status 为 HTTP 状态码（仅 HTTP 命令，渲染回复时可用）。
+	Status int
+	// Body 为 HTTP 响应体或脚本的标准输出（渲染回复时可用）。
+	Body string
+	// JSON 为按 JSON 解析的 Body，解析失败时为 nil（渲染回复时可用）。
+	JSON any
+}
+
+// newFuncs 返回模板附加的函数，env 只能读取 allowed 中的环境变量，其余名称在渲染时报错。
+func newFuncs(allowed map[string]struct{}) template.FuncMap {
+	return template.FuncMap{
+		"env": func(name string) (string, error) {
+			if _, ok := allowed[name]; !ok {
+				return "", fmt.Errorf("env %q is not allowed (see declarative.WithEnv)", name)
+			}
+			return os.Getenv(name), nil
+		},
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}
+}
+
+// Loader 从 YAML 文件加载命令并作为 command.Module 挂载到命令树；
+// Init 后按间隔检查文件修改时间，变更时重新加载，加载失败时保留上一版命令。
+type Loader struct {
+	path         string
+	name         string
+	client       *http.Client
+	pollInterval time.Duration
+	onError      func(err error)
+	env          map[string]struct{}
+
+	commands atomic.Pointer[[]*compiled]
+	modTime  time.Time
+
+	mu   sync.Mutex
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// Option 自定义 Loader 行为。
+type Option func(*Loader)
+
+// WithHTTPClient 设置 HTTP 命令使用的客户端（默认 http.DefaultClient）。
+func WithHTTPClient(c *http.Client) Option {
+	return func(l *Loader) {
+		l.client = c
+	}
+}
+
+// WithPollInterval 设置检查文件变更的间隔（默认 2s），<=0 时不自动重新加载。
+func WithPollInterval(d time.Duration) Option {
+	return func(l *Loader) {
+		l.pollInterval = d
+	}
+}
+
+// WithErrorHandler 设置自动重新加载失败时的回调（默认忽略）。
+func WithErrorHandler(fn func(err error)) Option {
+	return func(l *Loader) {
+		l.onError = fn
+	}
+}
+
+// WithName 设置模块名称（默认 "declarative:<path>"），同一 Manager 加载多个文件时用于区分。
+func WithName(name string) Option {
+	return func(l *Loader) {
+		l.name = name
+	}
+}
+
+// WithEnv 允许模板通过 env 读取指定的环境变量（默认一个都不允许），
+// 避免可编辑 YAML 的人经回复模板读出进程中的密钥。
+func WithEnv(names ...string) Option {
+	return func(l *Loader) {
+		if l.env == nil {
+			l.env = make(map[string]struct{}, len(names))
+		}
+		for _, name := range names {
+			l.env[name] = struct{}{}
+		}
+	}
+}
+
+// NewLoader 创建 YAML 命令加载器，文件在 Init 或 Reload 时读取。
+// Parameters:
+//   - path: YAML 文件路径
+//   - opts: 可选配置（HTTP 客户端、检查间隔、错误回调）
+//
+// Returns:
+//   - *Loader: 加载器，以 command.WithModules(loader) 接入
+func NewLoader(path string, opts ...Option) *Loader {
+	l := &Loader{
+		path:         path,
+		name:         "declarative:" + path,
+		client:       http.DefaultClient,
+		pollInterval: defaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Name 实现 command.Module 接口。
+func (l *Loader) Name() string {
+	return l.name
+}
+
+// Register 实现 command.Module 接口，挂载当前版本的命令。
+func (l *Loader) Register(root *cobra.Command) {
+	cmds := l.commands.Load()
+	if cmds == nil {
+		return
+	}
+	for _, c := range *cmds {
+		root.AddCommand(c.cobra(l.client))
+	}
+}
+
+// Init 实现 command.ModuleInitializer：加载文件并启动变更检查。
+func (l *Loader) Init(ctx context.Context) error {
+	if err := l.Reload(); err != nil {
+		return err
+	}
+	if l.pollInterval <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stop != nil {
+		return nil
+	}
+	watchCtx, stop := context.WithCancel(context.WithoutCancel(ctx))
+	l.stop = stop
+	l.done = make(chan struct{})
+	go l.watch(watchCtx, l.done)
+	return nil
+}
+
+// Shutdown 实现 command.ModuleShutdowner：停止变更检查。
+func (l *Loader) Shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	stop, done := l.stop, l.done
+	l.stop, l.done = nil, nil
+	l.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+	stop()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reload 立即重新读取文件；校验失败时返回错误并保留上一版命令。
+func (l *Loader) Reload() error {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return fmt.Errorf("load commands: %w", err)
+	}
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return fmt.Errorf("load commands: %w", err)
+	}
+	cmds, err := parse(data, newFuncs(l.env))
+	if err != nil {
+		return fmt.Errorf("load commands from %s: %w", l.path, err)
+	}
+	l.mu.Lock()
+	l.modTime = info.ModTime()
+	l.mu.Unlock()
+	l.commands.Store(&cmds)
+	return nil
+}
+
+// watch 按间隔检查文件修改时间，变更时重新加载。
+func (l *Loader) watch(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(l.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(l.path)
+		if err == nil {
+			l.mu.Lock()
+			changed := !info.ModTime().Equal(l.modTime)
+			// 先记录修改时间，加载失败时不在每个周期重复报错，等待下一次修改。
+			l.modTime = info.ModTime()
+			l.mu.Unlock()
+			if !changed {
+				continue
+			}
+			err = l.Reload()
+		}
+		if err != nil && l.onError != nil {
+			l.onError(err)
+		}
+	}
+}
+
+// compiled 为校验并预编译模板后的命令声明。
+type compiled struct {
+	spec     Spec
+	url      *template.Template
+	headers  map[string]*template.Template
+	body     *template.Template
+	argv     []*template.Template
+	response *template.Template
+}
+
+// Validate 校验 YAML 命令声明（格式、命令名、模板语法），可在 CI 中检查配置文件。
+func Validate(data []byte) error {
+	_, err := parse(data, newFuncs(nil))
+	return err
+}
+
+// parse 解析并校验 YAML 命令声明，以 funcs 预编译模板。
+func parse(data []byte, funcs template.FuncMap) ([]*compiled, error) {
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("decode yaml: %w", err)
+	}
+	seen := make(map[string]struct{}, len(file.Commands))
+	cmds := make([]*compiled, 0, len(file.Commands))
+	for _, spec := range file.Commands {
+		if spec.Name == "" || strings.ContainsAny(spec.Name, " \t\n") {
+			return nil, fmt.Errorf("invalid command name %q", spec.Name)
+		}
+		if _, ok := seen[spec.Name]; ok {
+			return nil, fmt.Errorf("duplicate command %q", spec.Name)
+		}
+		seen[spec.Name] = struct{}{}
+		c, err := compile(spec, funcs)
+		if err != nil {
+			return nil, fmt.Errorf("command %q: %w", spec.Name, err)
+		}
+		cmds = append(cmds, c)
+	}
+	return cmds, nil
+}
+
+// compile 校验单条声明并编译其中的模板。
+func compile(spec Spec, funcs template.FuncMap) (*compiled, error) {
+	if (spec.HTTP == nil) == (spec.Script == nil) {
+		return nil, errors.New("exactly one of http or script is required")
+	}
+	for i, arg := range spec.Args {
+		if arg.Name == "" {
+			return nil, fmt.Errorf("arg %d: name is required", i+1)
+		}
+	}
+	c := &compiled{spec: spec}
+	var err error
+	parse := func(name, text string) *template.Template {
+		if err != nil {
+			return nil
+		}
+		var t *template.Template
+		t, err = template.New(name).Funcs(funcs).Option("missingkey=zero").Parse(text)
+		return t
+	}
+	if spec.HTTP != nil {
+		if spec.HTTP.URL == "" {
+			return nil, errors.New("http.url is required")
+		}
+		c.url = parse("url", spec.HTTP.URL)
+		c.body = parse("body", spec.HTTP.Body)
+		c.headers = make(map[string]*template.Template, len(spec.HTTP.Headers))
+		for key, value := range spec.HTTP.Headers {
+			c.headers[key] = parse("header "+key, value)
+		}
+	} else {
+		if len(spec.Script.Command) == 0 {
+			return nil, errors.New("script.command is required")
+		}
+		for i, arg := range spec.Script.Command {
+			c.argv = append(c.argv, parse(fmt.Sprintf("argv[%d]", i), arg))
+		}
+	}
+	if spec.Response != "" {
+		c.response = parse("response", spec.Response)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// cobra 为本次请求创建 Cobra 命令。
+func (c *compiled) cobra(client *http.Client) *cobra.Command {
+	var required int
+	for _, arg := range c.spec.Args {
+		if arg.Required {
+			required++
+		}
+	}
+	use := c.spec.Name
+	for _, arg := range c.spec.Args {
+		if arg.Required {
+			use += " <" + arg.Name + ">"
+		} else {
+			use += " [" + arg.Name + "]"
+		}
+	}
+	cmd := &cobra.Command{
+		Use:          use,
+		Short:        c.spec.Short,
+		Args:         cobra.RangeArgs(required, len(c.spec.Args)),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data := Data{Args: make(map[string]string, len(c.spec.Args))}
+			execCtx := command.FromContext(cmd.Context())
+			if execCtx != nil {
+				data.SenderID = execCtx.RequestSnapshot.SenderID
+				data.ChatID = execCtx.RequestSnapshot.ChatID
+			}
+			for i, arg := range c.spec.Args {
+				data.Args[arg.Name] = arg.Default
+				if i < len(args) {
+					// 关键步骤：脚本参数默认不得以 "-" 开头，防止被目标程序解析为选项。
+					if c.spec.Script != nil && !arg.AllowFlags && strings.HasPrefix(args[i], "-") {
+						return fmt.Errorf("argument %s must not start with \"-\"", arg.Name)
+					}
+					data.Args[arg.Name] = args[i]
+				}
+			}
+			if execCtx.DryRun() {
+				// 关键步骤：演练模式只展示渲染后的请求或命令行，不发起调用。
+				action, err := c.describe(data)
+				if err != nil {
+					return err
+				}
+				cmd.Print(execCtx.DryRunMessage(action))
+				return nil
+			}
+			var err error
+			if c.spec.HTTP != nil {
+				err = c.callHTTP(cmd.Context(), client, &data)
+			} else {
+				err = c.runScript(cmd.Context(), &data)
+			}
+			if err != nil {
+				return err
+			}
+			if c.response == nil {
+				cmd.Print(data.Body)
+				return nil
+			}
+			out, err := render(c.response, data)
+			if err != nil {
+				return err
+			}
+			cmd.Print(out)
+			return nil
+		},
+	}
+	if len(c.spec.Roles) > 0 {
+		command.RequireRoles(cmd, c.spec.Roles...)
+	}
+	return cmd
+}
+
+// describe 渲染将要发起的 HTTP 请求（方法与 URL）或脚本命令行，用于演练模式的提示。
+func (c *compiled) describe(data Data) (string, error) {
+	if c.spec.HTTP != nil {
+		url, err := render(c.url, data)
+		if err != nil {
+			return "", err
+		}
+		return "`" + c.method() + " " + url + "`", nil
+	}
+	argv, err := c.renderArgv(data)
+	if err != nil {
+		return "", err
+	}
+	return "`" + command.JoinArgs(argv) + "`", nil
+}
+
+// method 返回 HTTP 方法（默认 GET）。
+func (c *compiled) method() string {
+	if method := strings.ToUpper(c.spec.HTTP.Method); method != "" {
+		return method
+	}
+	return http.MethodGet
+}
+
+// callHTTP 渲染并发起 HTTP 调用，将响应写入 data。
+func (c *compiled) callHTTP(ctx context.Context, client *http.Client, data *Data) error {
+	ctx, cancel := context.WithTimeout(ctx, timeoutOr(c.spec.HTTP.Timeout))
+	defer cancel()
+	url, err := render(c.url, *data)
+	if err != nil {
+		return err
+	}
+	body, err := render(c.body, *data)
+	if err != nil {
+		return err
+	}
+	method := c.method()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	for key, tmpl := range c.headers {
+		value, err := render(tmpl, *data)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxOutputSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("http status %d: %s", resp.StatusCode, botcore.TruncateUTF8(strings.TrimSpace(string(raw)), 200))
+	}
+	data.Status = resp.StatusCode
+	setBody(data, raw)
+	return nil
+}
+
+// runScript 渲染 argv 并执行脚本，将标准输出写入 data。
+func (c *compiled) runScript(ctx context.Context, data *Data) error {
+	ctx, cancel := context.WithTimeout(ctx, timeoutOr(c.spec.Script.Timeout))
+	defer cancel()
+	argv, err := c.renderArgv(*data)
+	if err != nil {
+		return err
+	}
+	var stdout, stderr bytes.Buffer
+	proc := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	proc.Stdout = &limitedBuffer{buf: &stdout}
+	proc.Stderr = &limitedBuffer{buf: &stderr}
+	if err := proc.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, botcore.TruncateUTF8(msg, 200))
+		}
+		return err
+	}
+	setBody(data, stdout.Bytes())
+	return nil
+}
+
+// renderArgv 逐项渲染脚本的 argv。
+func (c *compiled) renderArgv(data Data) ([]string, error) {
+	argv := make([]string, len(c.argv))
+	for i, tmpl := range c.argv {
+		arg, err := render(tmpl, data)
+		if err != nil {
+			return nil, err
+		}
+		argv[i] = arg
+	}
+	return argv, nil
+}
+
+// setBody 写入原始输出，并尝试按 JSON 解析。
+func setBody(data *Data, raw []byte) {
+	data.Body = string(raw)
+	var parsed any
+	if json.Unmarshal(raw, &parsed) == nil {
+		data.JSON = parsed
+	}
+}
+
+// render 以 data 执行模板。
+func render(t *template.Template, data Data) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// timeoutOr 返回声明的超时或默认值。
+func timeoutOr(d time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return defaultTimeout
+}
+
+// limitedBuffer 丢弃超出 maxOutputSize 的输出，避免脚本输出过大占用内存。
+type limitedBuffer struct {
+	buf *bytes.Buffer
+}
+
+// Write 实现 io.Writer 接口。
+func (w *limitedBuffer) Write(p []byte) (int, error) {
+	if remain := maxOutputSize - w.buf.Len(); remain > 0 {
+		w.buf.Write(p[:min(len(p), remain)])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,250 @@
+package declarative
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/IMBotPlatform/IMBotCore/pkg/command"
+)
+
+// run 以 sender 身份执行一条消息并拼接输出。
+func run(p botcore.PipelineInvoker, sender, text string) string {
+	var out strings.Builder
+	for chunk := range p.Trigger(botcore.PipelineContext{Snapshot: botcore.RequestSnapshot{SenderID: sender, ChatID: "c1", Text: text}}) {
+		out.WriteString(chunk.Content)
+	}
+	return out.String()
+}
+
+func TestLoaderHTTPAndScriptCommands(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-User") != "alice" {
+			http.Error(w, "missing user", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"temp": 21, "city": "` + r.URL.Query().Get("city") + `"}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "commands.yaml")
+	writeFile(t, path, `
+commands:
+  - name: weather
+    short: 查询天气
+    args:
+      - name: city
+        default: 北京
+    http:
+      url: "`+server.URL+`?city={{ urlquery .Args.city }}"
+      headers:
+        X-User: "{{ .SenderID }}"
+    response: "{{ .JSON.city }}：{{ .JSON.temp }}℃"
+  - name: say
+    roles: [ops]
+    args:
+      - name: text
+        required: true
+    script:
+      command: ["echo", "-n", "{{ .Args.text }}; rm -rf /"]
+`)
+	loader := NewLoader(path, WithPollInterval(0))
+	mgr := command.NewManager(nil, command.WithModules(loader), command.WithRoleProvider(command.StaticRoles{"alice": {"ops"}}))
+	if err := mgr.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	if got := run(mgr, "alice", "/weather 上海"); got != "上海：21℃" {
+		t.Fatalf("unexpected weather reply: %q", got)
+	}
+	if got := run(mgr, "alice", "/weather"); got != "北京：21℃" {
+		t.Fatalf("expected default arg, got %q", got)
+	}
+	if got := run(mgr, "bob", "/weather"); !strings.Contains(got, "401") {
+		t.Fatalf("expected http error, got %q", got)
+	}
+	// 参数不经过 shell，分号等字符原样输出。
+	if got := run(mgr, "alice", "/say hi"); got != "hi; rm -rf /" {
+		t.Fatalf("unexpected script output: %q", got)
+	}
+	if got := run(mgr, "bob", "/say hi"); !strings.Contains(got, "权限不足") {
+		t.Fatalf("expected roles to be enforced, got %q", got)
+	}
+	if got := run(mgr, "alice", "/say"); !strings.Contains(got, "accepts between 1 and 1 arg") {
+		t.Fatalf("expected missing arg error, got %q", got)
+	}
+}
+
+func TestLoaderRejectsFlagArgsAndUnlistedEnv(t *testing.T) {
+	t.Setenv("DECLARATIVE_PUBLIC", "visible")
+	t.Setenv("DECLARATIVE_SECRET", "hidden")
+	path := filepath.Join(t.TempDir(), "commands.yaml")
+	writeFile(t, path, `
+commands:
+  - name: say
+    args:
+      - name: text
+    script:
+      command: ["echo", "-n", "{{ .Args.text }}"]
+  - name: grep
+    args:
+      - name: pattern
+        allow_flags: true
+    script:
+      command: ["echo", "-n", "{{ .Args.pattern }}"]
+  - name: public
+    script:
+      command: ["echo", "-n", "{{ env \"DECLARATIVE_PUBLIC\" }}"]
+  - name: secret
+    script:
+      command: ["echo", "-n", "{{ env \"DECLARATIVE_SECRET\" }}"]
+`)
+	loader := NewLoader(path, WithPollInterval(0), WithEnv("DECLARATIVE_PUBLIC"))
+	mgr := command.NewManager(nil, command.WithModules(loader))
+	if err := mgr.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	if got := run(mgr, "alice", "/say -- --output=/etc/passwd"); !strings.Contains(got, `must not start with "-"`) {
+		t.Fatalf("expected flag-like arg to be rejected, got %q", got)
+	}
+	if got := run(mgr, "alice", "/grep -- -i"); got != "-i" {
+		t.Fatalf("expected allow_flags to pass the arg through, got %q", got)
+	}
+	if got := run(mgr, "alice", "/public"); got != "visible" {
+		t.Fatalf("unexpected allowed env: %q", got)
+	}
+	if got := run(mgr, "alice", "/secret"); strings.Contains(got, "hidden") || !strings.Contains(got, "not allowed") {
+		t.Fatalf("expected unlisted env to be refused, got %q", got)
+	}
+}
+
+func TestLoaderHotReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.yaml")
+	writeFile(t, path, `
+commands:
+  - name: version
+    script:
+      command: ["echo", "-n", "v1"]
+`)
+	errs := make(chan error, 10)
+	loader := NewLoader(path, WithPollInterval(10*time.Millisecond), WithErrorHandler(func(err error) { errs <- err }))
+	mgr := command.NewManager(nil, command.WithModules(loader))
+	if err := mgr.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer mgr.Shutdown(context.Background())
+	if got := run(mgr, "alice", "/version"); got != "v1" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+
+	// 非法配置不替换已加载的命令。
+	writeFile(t, path, "commands:\n  - name: broken\n")
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "exactly one of http or script") {
+			t.Fatalf("unexpected reload error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected reload error")
+	}
+	if got := run(mgr, "alice", "/version"); got != "v1" {
+		t.Fatalf("expected previous commands after failed reload, got %q", got)
+	}
+
+	writeFile(t, path, `
+commands:
+  - name: version
+    script:
+      command: ["echo", "-n", "v2"]
+`)
+	deadline := time.Now().Add(2 * time.Second)
+	for run(mgr, "alice", "/version") != "v2" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected commands to reload")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLoaderDryRun(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "commands.yaml")
+	writeFile(t, path, `
+commands:
+  - name: restart
+    args:
+      - name: host
+        required: true
+    http:
+      method: post
+      url: "`+server.URL+`/restart/{{ .Args.host }}"
+  - name: wipe
+    script:
+      command: ["touch", "`+filepath.Join(t.TempDir(), "wiped")+`"]
+`)
+	loader := NewLoader(path, WithPollInterval(0))
+	mgr := command.NewManager(nil, command.WithModules(loader), command.WithDryRunFlag())
+	if err := mgr.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	want := "🧪 [演练] 将会：`POST " + server.URL + "/restart/web-1`"
+	if got := run(mgr, "alice", "/restart web-1 --dry-run"); got != want {
+		t.Fatalf("unexpected dry-run reply: %q", got)
+	}
+	if got := run(mgr, "alice", "/wipe --dry-run"); !strings.Contains(got, "`touch ") {
+		t.Fatalf("unexpected dry-run reply: %q", got)
+	}
+	if calls != 0 {
+		t.Fatalf("dry run should not call the endpoint, got %d calls", calls)
+	}
+	run(mgr, "alice", "/restart web-1")
+	if calls != 1 {
+		t.Fatalf("expected a real call without --dry-run, got %d", calls)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := map[string]string{
+		"duplicate":    "commands:\n  - {name: a, script: {command: [true]}}\n  - {name: a, script: {command: [true]}}\n",
+		"bad template": "commands:\n  - {name: a, http: {url: '{{ .Args.x '}}\n",
+		"no name":      "commands:\n  - {script: {command: [true]}}\n",
+	}
+	for name, data := range cases {
+		if err := Validate([]byte(data)); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+	if err := Validate([]byte("commands:\n  - {name: a, script: {command: [true], timeout: 3s}}\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// writeFile 写入文件并推进修改时间，确保轮询能观察到变更。
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime().Add(time.Second)
+	} else {
+		modTime = time.Now()
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+}
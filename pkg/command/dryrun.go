@@ -0,0 +1,72 @@
+package command
+
+import (
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
+)
+
+// DryRunFlag 为 WithDryRunFlag 注入的全局参数名（--dry-run）。
+const DryRunFlag = "dry-run"
+
+// MsgDryRun 为演练模式下描述将执行操作的提示，参数：操作描述。
+const MsgDryRun = "command.dryrun"
+
+func init() {
+	botcore.Messages.Add("zh", map[string]string{
+		MsgDryRun: "🧪 [演练] 将会：%s",
+	})
+	botcore.Messages.Add("en", map[string]string{
+		MsgDryRun: "🧪 [dry-run] would %s",
+	})
+}
+
+// WithDryRun 让所有命令以演练模式执行：有副作用的命令应检查 ExecutionContext.DryRun()，
+// 只输出将执行的操作而不实际执行。适用于培训用户或在生产会话中安全地测试。
+func WithDryRun() ManagerOption {
+	return func(m *Manager) {
+		m.dryRun = true
+	}
+}
+
+// WithDryRunFlag 为命令树注入全局参数 --dry-run，用户可按次开启演练模式（如 /deploy prod --dry-run）。
+// 命令自身已定义同名参数时以命令的定义为准，不再经 DryRun() 反映。
+func WithDryRunFlag() ManagerOption {
+	return func(m *Manager) {
+		m.dryRunFlag = true
+	}
+}
+
+// DryRun 报告当前执行是否处于演练模式（WithDryRun 或用户传入 --dry-run）。
+// 命令应在产生副作用前检查，例如：
+//
+//	if execCtx.DryRun() {
+//		cmd.Println(execCtx.DryRunMessage("重启 web-1"))
+//		return nil
+//	}
+func (ctx *ExecutionContext) DryRun() bool {
+	if ctx == nil {
+		return false
+	}
+	return ctx.dryRun || ctx.dryRunFlag
+}
+
+// DryRunMessage 返回本地化的演练提示，用于描述演练模式下将会执行的操作。
+// Parameters:
+//   - action: 操作描述
+//
+// Returns:
+//   - string: 提示文本
+func (ctx *ExecutionContext) DryRunMessage(action string) string {
+	if ctx == nil {
+		return botcore.Localize(botcore.RequestSnapshot{}, MsgDryRun, action)
+	}
+	return botcore.Localize(ctx.RequestSnapshot, MsgDryRun, action)
+}
+
+// installDryRunFlag 在根命令上注册全局 --dry-run 参数并绑定到 execCtx；根命令已定义同名参数时跳过。
+func installDryRunFlag(root *cobra.Command, execCtx *ExecutionContext) {
+	if root.PersistentFlags().Lookup(DryRunFlag) != nil || root.Flags().Lookup(DryRunFlag) != nil {
+		return
+	}
+	root.PersistentFlags().BoolVar(&execCtx.dryRunFlag, DryRunFlag, false, "演练模式：只显示将执行的操作")
+}
@@ -0,0 +1,44 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// newRestartCommands 返回一个 /restart <host> 命令，演练模式下只描述操作。
+func newRestartCommands() *cobra.Command {
+	root := &cobra.Command{Use: "bot"}
+	root.AddCommand(&cobra.Command{Use: "restart", Run: func(cmd *cobra.Command, args []string) {
+		execCtx := FromContext(cmd.Context())
+		if execCtx.DryRun() {
+			cmd.Print(execCtx.DryRunMessage("重启 " + strings.Join(args, ",")))
+			return
+		}
+		cmd.Print("restarted " + strings.Join(args, ","))
+	}})
+	return root
+}
+
+func TestDryRunFlag(t *testing.T) {
+	mgr := NewManager(newRestartCommands, WithDryRunFlag())
+	if got := run(mgr, "alice", "/restart web-1 --dry-run"); got != "🧪 [演练] 将会：重启 web-1" {
+		t.Fatalf("unexpected dry-run output: %q", got)
+	}
+	if got := run(mgr, "alice", "/restart web-1"); got != "restarted web-1" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+
+	// 未启用时 --dry-run 为未知参数。
+	if got := run(NewManager(newRestartCommands), "alice", "/restart web-1 --dry-run"); !strings.Contains(got, "unknown flag") {
+		t.Fatalf("expected unknown flag error, got %q", got)
+	}
+}
+
+func TestDryRunManager(t *testing.T) {
+	mgr := NewManager(newRestartCommands, WithDryRun(), WithDryRunFlag())
+	if got := run(mgr, "alice", "/restart web-1 --dry-run=false"); got != "🧪 [演练] 将会：重启 web-1" {
+		t.Fatalf("expected manager dry-run to win, got %q", got)
+	}
+}
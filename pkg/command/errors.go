@@ -8,4 +8,6 @@ var (
 	ErrCommandNotFound = errors.New("command not found")
 	// ErrCommandRequired 表示未提供任何命令关键字。
 	ErrCommandRequired = errors.New("command required")
+	// ErrPermissionDenied 表示发送者不具备执行命令所需的角色。
+	ErrPermissionDenied = errors.New("permission denied")
 )
@@ -0,0 +1,152 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// 帮助文档使用的消息键。
+const (
+	MsgHelpUsage       = "help.usage"       // “用法”标题
+	MsgHelpCommands    = "help.commands"    // “可用命令”标题
+	MsgHelpFlags       = "help.flags"       // “参数”标题
+	MsgHelpExamples    = "help.examples"    // “示例”标题
+	MsgHelpRoles       = "help.roles"       // 权限说明，参数：所需角色
+	MsgHelpMoreDetails = "help.more"        // 查看子命令详情的提示，参数：帮助命令示例
+	MsgHelpDefault     = "help.flagDefault" // 参数默认值，参数：默认值
+)
+
+func init() {
+	botcore.Messages.Add("zh", map[string]string{
+		MsgHelpUsage:       "用法",
+		MsgHelpCommands:    "可用命令",
+		MsgHelpFlags:       "参数",
+		MsgHelpExamples:    "示例",
+		MsgHelpRoles:       "🔒 仅限 %s 使用",
+		MsgHelpMoreDetails: "发送 `%s` 查看命令详情。",
+		MsgHelpDefault:     "（默认 %s）",
+	})
+	botcore.Messages.Add("en", map[string]string{
+		MsgHelpUsage:       "Usage",
+		MsgHelpCommands:    "Commands",
+		MsgHelpFlags:       "Flags",
+		MsgHelpExamples:    "Examples",
+		MsgHelpRoles:       "🔒 Restricted to %s",
+		MsgHelpMoreDetails: "Send `%s` for details on a command.",
+		MsgHelpDefault:     " (default %s)",
+	})
+}
+
+// HelpRenderer 将命令渲染为发送给用户的帮助文本。
+// Parameters:
+//   - cmd: 需要展示帮助的命令（根命令时展示命令列表）
+//   - snapshot: 当前请求快照（用于本地化等）
+//
+// Returns:
+//   - string: 帮助文本
+type HelpRenderer func(cmd *cobra.Command, snapshot botcore.RequestSnapshot) string
+
+// MarkdownHelp 返回以 Markdown 渲染帮助的 HelpRenderer：包含说明、用法、子命令列表、参数与示例，
+// 命令以 prefix 开头展示（如 "/deploy prod"），不含根命令名。隐藏命令与 help/completion 等内置命令不会列出。
+// Parameters:
+//   - prefix: 命令前缀，如 "/"
+//
+// Returns:
+//   - HelpRenderer: Markdown 帮助渲染器
+func MarkdownHelp(prefix string) HelpRenderer {
+	return func(cmd *cobra.Command, snapshot botcore.RequestSnapshot) string {
+		locale := botcore.Locale(snapshot)
+		t := func(key string, args ...any) string {
+			return botcore.Messages.Sprintf(locale, key, args...)
+		}
+		root := cmd.Root()
+		name := func(c *cobra.Command) string {
+			return prefix + commandPath(root, c)
+		}
+
+		var b strings.Builder
+		if cmd != root {
+			fmt.Fprintf(&b, "**%s**", name(cmd))
+			if cmd.Short != "" {
+				fmt.Fprintf(&b, " — %s", cmd.Short)
+			}
+			b.WriteString("\n")
+		} else if cmd.Short != "" {
+			fmt.Fprintf(&b, "**%s**\n", cmd.Short)
+		}
+		if cmd.Long != "" {
+			fmt.Fprintf(&b, "\n%s\n", strings.TrimSpace(cmd.Long))
+		}
+		if roles := RequiredRoles(cmd); len(roles) > 0 {
+			fmt.Fprintf(&b, "\n%s\n", t(MsgHelpRoles, strings.Join(roles, " / ")))
+		}
+
+		if cmd != root && cmd.Runnable() {
+			fmt.Fprintf(&b, "\n**%s**\n`%s`\n", t(MsgHelpUsage), prefix+strings.TrimPrefix(strings.TrimPrefix(cmd.UseLine(), root.Name()), " "))
+		}
+
+		var subs []*cobra.Command
+		for _, sub := range cmd.Commands() {
+			if sub.IsAvailableCommand() && sub.Name() != "help" {
+				subs = append(subs, sub)
+			}
+		}
+		if len(subs) > 0 {
+			fmt.Fprintf(&b, "\n**%s**\n", t(MsgHelpCommands))
+			for _, sub := range subs {
+				line := fmt.Sprintf("- `%s`", name(sub))
+				if sub.Short != "" {
+					line += " — " + sub.Short
+				}
+				if len(RequiredRoles(sub)) > 0 && len(RequiredRoles(cmd)) == 0 {
+					line += " 🔒"
+				}
+				b.WriteString(line + "\n")
+			}
+		}
+
+		var flags []string
+		cmd.NonInheritedFlags().VisitAll(func(f *pflag.Flag) {
+			if f.Hidden || f.Name == "help" {
+				return
+			}
+			line := "- `--" + f.Name + "`"
+			if f.Shorthand != "" {
+				line = "- `-" + f.Shorthand + "`, `--" + f.Name + "`"
+			}
+			if f.Usage != "" {
+				line += "：" + f.Usage
+			}
+			if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "[]" {
+				line += t(MsgHelpDefault, f.DefValue)
+			}
+			flags = append(flags, line)
+		})
+		if len(flags) > 0 {
+			fmt.Fprintf(&b, "\n**%s**\n%s\n", t(MsgHelpFlags), strings.Join(flags, "\n"))
+		}
+
+		if cmd.Example != "" {
+			fmt.Fprintf(&b, "\n**%s**\n```\n%s\n```\n", t(MsgHelpExamples), strings.Trim(cmd.Example, "\n"))
+		}
+		if len(subs) > 0 {
+			fmt.Fprintf(&b, "\n%s\n", t(MsgHelpMoreDetails, prefix+"help "+strings.TrimSpace(commandPath(root, cmd)+" <command>")))
+		}
+		return strings.TrimSpace(b.String())
+	}
+}
+
+// installHelp 让命令树的帮助（/help、--help 与出错时的用法提示）使用 renderer 输出到命令的输出流。
+func installHelp(root *cobra.Command, renderer HelpRenderer, snapshot botcore.RequestSnapshot) {
+	root.SetHelpFunc(func(cmd *cobra.Command, _ []string) {
+		cmd.Println(renderer(cmd, snapshot))
+	})
+	root.SetUsageFunc(func(cmd *cobra.Command) error {
+		cmd.Println(renderer(cmd, snapshot))
+		return nil
+	})
+}
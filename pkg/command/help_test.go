@@ -0,0 +1,54 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
+)
+
+func newHelpCommands() *cobra.Command {
+	root := newOpsCommands()
+	report := &cobra.Command{
+		Use:     "report [date]",
+		Short:   "生成日报",
+		Example: "/report 2024-01-01",
+		RunE:    func(cmd *cobra.Command, args []string) error { return nil },
+	}
+	report.Flags().StringP("format", "f", "md", "输出格式")
+	root.AddCommand(report, &cobra.Command{Use: "secret", Hidden: true, Run: func(*cobra.Command, []string) {}})
+	return root
+}
+
+func TestManagerRendersMarkdownHelp(t *testing.T) {
+	mgr := NewManager(newHelpCommands)
+
+	got := run(mgr, "u1", "/help")
+	for _, want := range []string{"**可用命令**", "- `/report` — 生成日报", "- `/deploy` 🔒", "`/help <command>`"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("root help missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "secret") || strings.Contains(got, "Usage:") {
+		t.Fatalf("unexpected root help content:\n%s", got)
+	}
+
+	got = run(mgr, "u1", "/report --help")
+	for _, want := range []string{"**/report** — 生成日报", "`/report [date] [flags]`", "- `-f`, `--format`：输出格式（默认 md）", "```\n/report 2024-01-01\n```"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("command help missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestMarkdownHelpLocalizesHeadings(t *testing.T) {
+	root := newHelpCommands()
+	deploy, _, _ := root.Find([]string{"deploy"})
+	got := MarkdownHelp("!")(deploy, botcore.RequestSnapshot{Metadata: map[string]string{botcore.MetaLanguage: "en"}})
+	for _, want := range []string{"**!deploy**", "🔒 Restricted to ops", "**Commands**", "- `!deploy prod`", "`!help deploy <command>`"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("help missing %q:\n%s", want, got)
+		}
+	}
+}
@@ -1,13 +1,26 @@
 package command
 
 import (
+	"bytes"
+	"sync"
+	"time"
+
 	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
 )
 
 // StreamWriter 实现 io.Writer 接口，将输出重定向到 StreamChunk 通道。
 // 这允许 Cobra 命令像操作 stdout 一样直接打印，而结果会被流式传输给用户。
+// 由 NewStreamWriter 创建时每次 Write 立即发送；由 NewStreamWriterBuffered 创建时合并输出后按间隔或大小发送。
 type StreamWriter struct {
 	Ch chan<- botcore.StreamChunk
+
+	mu       sync.Mutex
+	buffered bool
+	interval time.Duration
+	maxSize  int
+	buf      bytes.Buffer
+	timer    *time.Timer
+	closed   bool
 }
 
 // NewStreamWriter 创建一个新的 StreamWriter。
@@ -15,18 +28,102 @@ func NewStreamWriter(ch chan<- botcore.StreamChunk) *StreamWriter {
 	return &StreamWriter{Ch: ch}
 }
 
-// Write 将字节切片转换为 StreamChunk 发送。
+// NewStreamWriterBuffered 创建带缓冲的 StreamWriter，避免逐行打印的命令产生大量碎小片段。
+// 输出在首次写入 interval 后整体发送；缓冲达到 maxSize 时立即发送到最后一个完整行（无换行时全部发送），
+// 剩余的半行留待后续合并。命令结束时需调用 Close 发送剩余内容（Manager 会自动调用）。
+// Parameters:
+//   - ch: 输出通道
+//   - interval: 最长缓冲时间（<=0 表示只按大小与 Close 发送）
+//   - maxSize: 缓冲字节上限（<=0 表示只按时间与 Close 发送）
+//
+// Returns:
+//   - *StreamWriter: 带缓冲的写入器
+func NewStreamWriterBuffered(ch chan<- botcore.StreamChunk, interval time.Duration, maxSize int) *StreamWriter {
+	return &StreamWriter{Ch: ch, buffered: true, interval: interval, maxSize: maxSize}
+}
+
+// Write 将字节切片转换为 StreamChunk 发送（缓冲模式下先写入缓冲）。
 func (w *StreamWriter) Write(p []byte) (n int, err error) {
 	if len(p) == 0 {
 		return 0, nil
 	}
-	// 注意：这里直接将字节转为字符串。
-	// 如果 Cobra 输出非常碎小的包，可能需要在此处做缓冲（Buffer）。
-	// 但对于常规 CLI 输出，直接转发通常是可以接受的，也能体现“流式”感。
-	msg := string(p)
-	w.Ch <- botcore.StreamChunk{
-		Content: msg,
-		IsFinal: false,
+	if !w.buffered {
+		w.Ch <- botcore.StreamChunk{
+			Content: string(p),
+			IsFinal: false,
+		}
+		return len(p), nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		// 关键步骤：Close 之后输出通道可能已结束，直接丢弃迟到的输出。
+		return len(p), nil
+	}
+	w.buf.Write(p)
+	if w.maxSize > 0 && w.buf.Len() >= w.maxSize {
+		cut := bytes.LastIndexByte(w.buf.Bytes(), '\n') + 1
+		if cut == 0 {
+			cut = w.buf.Len()
+		}
+		w.send(w.buf.Next(cut))
+	}
+	if w.buf.Len() > 0 && w.timer == nil && w.interval > 0 {
+		w.timer = time.AfterFunc(w.interval, w.flushTimer)
 	}
 	return len(p), nil
 }
+
+// Flush 立即发送缓冲中的全部内容（非缓冲模式下无操作）。
+func (w *StreamWriter) Flush() error {
+	if !w.buffered {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.flushLocked()
+	}
+	return nil
+}
+
+// Close 发送剩余内容并停止定时发送，之后的写入会被丢弃。
+func (w *StreamWriter) Close() error {
+	if !w.buffered {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.flushLocked()
+		w.closed = true
+	}
+	return nil
+}
+
+// flushTimer 由定时器触发发送。
+func (w *StreamWriter) flushTimer() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timer = nil
+	if !w.closed {
+		w.flushLocked()
+	}
+}
+
+// flushLocked 发送全部缓冲并停止定时器，调用方需持有锁。
+func (w *StreamWriter) flushLocked() {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if w.buf.Len() > 0 {
+		w.send(w.buf.Next(w.buf.Len()))
+	}
+}
+
+// send 发送一个片段，调用方需持有锁。
+func (w *StreamWriter) send(p []byte) {
+	w.Ch <- botcore.StreamChunk{Content: string(p)}
+}
@@ -1,9 +1,12 @@
 package command
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
 )
 
 func TestStreamWriterIncremental(t *testing.T) {
@@ -43,3 +46,66 @@ func TestStreamWriterIncremental(t *testing.T) {
 		t.Fatal("Expected second chunk available")
 	}
 }
+
+func TestStreamWriterBufferedCoalescesLines(t *testing.T) {
+	ch := make(chan botcore.StreamChunk, 10)
+	w := NewStreamWriterBuffered(ch, time.Hour, 10)
+
+	for _, line := range []string{"one\n", "two\n", "three"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	// 达到 10 字节后发送到最后一个完整行，半行 "three" 留在缓冲中。
+	if len(ch) != 1 {
+		t.Fatalf("expected one chunk after size flush, got %d", len(ch))
+	}
+	if got := (<-ch).Content; got != "one\ntwo\n" {
+		t.Fatalf("unexpected size flush: %q", got)
+	}
+
+	_ = w.Close()
+	if got := (<-ch).Content; got != "three" {
+		t.Fatalf("unexpected close flush: %q", got)
+	}
+	_, _ = w.Write([]byte("late"))
+	_ = w.Flush()
+	if len(ch) != 0 {
+		t.Fatalf("expected writes after close to be dropped")
+	}
+}
+
+func TestStreamWriterBufferedFlushesOnInterval(t *testing.T) {
+	ch := make(chan botcore.StreamChunk, 10)
+	w := NewStreamWriterBuffered(ch, 10*time.Millisecond, 0)
+	_, _ = w.Write([]byte("a"))
+	_, _ = w.Write([]byte("b"))
+
+	select {
+	case chunk := <-ch:
+		if chunk.Content != "ab" {
+			t.Fatalf("unexpected interval flush: %q", chunk.Content)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected interval flush")
+	}
+	_ = w.Close()
+}
+
+func TestManagerOutputBuffering(t *testing.T) {
+	factory := func() *cobra.Command {
+		return &cobra.Command{Use: "bot", Run: func(cmd *cobra.Command, args []string) {
+			for i := 0; i < 5; i++ {
+				cmd.Println("line")
+			}
+			FromContext(cmd.Context()).SendPayload("done")
+		}}
+	}
+	var chunks []botcore.StreamChunk
+	for chunk := range NewManager(factory, WithOutputBuffering(time.Hour, 0)).Trigger(botcore.PipelineContext{Snapshot: botcore.RequestSnapshot{Text: "/bot"}}) {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 2 || chunks[0].Content != strings.Repeat("line\n", 5) || chunks[1].Payload != "done" || !chunks[1].IsFinal {
+		t.Fatalf("unexpected chunks: %+v", chunks)
+	}
+}
@@ -0,0 +1,397 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/google/uuid"
+)
+
+// 后台任务使用的消息键。
+const (
+	MsgJobStarted  = "job.started"  // 任务已提交，参数：任务名、任务 ID
+	MsgJobProgress = "job.progress" // 任务进度，参数：任务名、进度文本
+	MsgJobDone     = "job.done"     // 任务完成且无结果文本，参数：任务名
+	MsgJobFailed   = "job.failed"   // 任务失败，参数：任务名、错误
+	MsgJobAction   = "job.action"   // 演练模式下描述将启动的任务，参数：任务名
+)
+
+func init() {
+	botcore.Messages.Add("zh", map[string]string{
+		MsgJobStarted:  "⏳ 已在后台执行「%s」（任务 %s），完成后将通知你。",
+		MsgJobProgress: "⏳「%s」进度：%s",
+		MsgJobDone:     "✅「%s」已完成。",
+		MsgJobFailed:   "❌「%s」执行失败: %v",
+		MsgJobAction:   "在后台执行「%s」",
+	})
+	botcore.Messages.Add("en", map[string]string{
+		MsgJobStarted:  "⏳ Running \"%s\" in the background (job %s), you'll be notified when it's done.",
+		MsgJobProgress: "⏳ \"%s\" progress: %s",
+		MsgJobDone:     "✅ \"%s\" finished.",
+		MsgJobFailed:   "❌ \"%s\" failed: %v",
+		MsgJobAction:   "run \"%s\" in the background",
+	})
+}
+
+var (
+	// ErrJobRunnerClosed 表示 JobRunner 已停止接收新任务。
+	ErrJobRunnerClosed = errors.New("job runner closed")
+	// errJobNoDelivery 表示无法推送任务结果（缺少 Responser 或 response_url）。
+	errJobNoDelivery = errors.New("job: no way to deliver results")
+)
+
+// JobFunc 为后台任务的执行函数，返回的文本作为完成通知发送（为空时发送默认完成提示）。
+// ctx 与发起命令的会话解耦，仅在任务被取消、超时或 JobRunner 停止时结束。
+type JobFunc func(ctx context.Context, job *Job) (string, error)
+
+// JobNotifier 将任务的进度与结果推送给用户。
+type JobNotifier func(snapshot botcore.RequestSnapshot, text string) error
+
+// JobState 描述任务状态。
+type JobState string
+
+const (
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+)
+
+// Job 为一个后台任务。
+type Job struct {
+	ID        string
+	Name      string
+	Snapshot  botcore.RequestSnapshot // 发起任务的请求
+	StartedAt time.Time
+
+	runner *JobRunner
+	cancel context.CancelFunc
+	notify JobNotifier
+
+	mu        sync.Mutex
+	state     JobState
+	progress  string
+	pushes    int
+	lastPush  time.Time
+	updatedAt time.Time
+}
+
+// JobStatus 为任务的状态快照。
+type JobStatus struct {
+	ID        string
+	Name      string
+	ChatID    string
+	SenderID  string
+	State     JobState
+	Progress  string
+	StartedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Progress 上报任务进度。进度总会记录在 JobStatus 中；
+// 在推送次数与间隔允许时（见 WithJobPushes、WithJobProgressInterval）同时推送给用户。
+func (j *Job) Progress(text string) {
+	now := time.Now()
+	j.mu.Lock()
+	j.progress = text
+	j.updatedAt = now
+	// 关键步骤：为最终结果保留一次推送机会。
+	push := j.pushes+1 < j.runner.maxPushes && now.Sub(j.lastPush) >= j.runner.progressInterval
+	if push {
+		j.pushes++
+		j.lastPush = now
+	}
+	j.mu.Unlock()
+	if push {
+		j.runner.deliver(j, botcore.Localize(j.Snapshot, MsgJobProgress, j.Name, text))
+	}
+}
+
+// Status 返回任务的状态快照。
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return JobStatus{
+		ID:        j.ID,
+		Name:      j.Name,
+		ChatID:    j.Snapshot.ChatID,
+		SenderID:  j.Snapshot.SenderID,
+		State:     j.state,
+		Progress:  j.progress,
+		StartedAt: j.StartedAt,
+		UpdatedAt: j.updatedAt,
+	}
+}
+
+// JobOption 自定义 JobRunner 行为。
+type JobOption func(*JobRunner)
+
+// WithJobNotifier 自定义进度与结果的推送方式（如企业应用消息推送）。
+// 默认通过 Responser.ResponseMarkdown 推送到发起请求的 response_url。
+func WithJobNotifier(fn JobNotifier) JobOption {
+	return func(r *JobRunner) {
+		r.notify = fn
+	}
+}
+
+// WithJobPushes 设置每个任务最多推送的消息数（含最终结果，默认 1）。
+// 企业微信的 response_url 只能调用一次，使用其他推送方式时可调大以启用进度推送。
+func WithJobPushes(n int) JobOption {
+	return func(r *JobRunner) {
+		if n > 0 {
+			r.maxPushes = n
+		}
+	}
+}
+
+// WithJobProgressInterval 设置两次进度推送的最小间隔（默认 30 秒）。
+func WithJobProgressInterval(d time.Duration) JobOption {
+	return func(r *JobRunner) {
+		r.progressInterval = d
+	}
+}
+
+// WithJobTimeout 设置单个任务的最长执行时间（默认不限制）。
+func WithJobTimeout(d time.Duration) JobOption {
+	return func(r *JobRunner) {
+		r.timeout = d
+	}
+}
+
+// WithJobRetention 设置已结束任务保留在 Get / Jobs 中的时长（默认 1 小时），
+// 过期的任务在下次 Start、Get 或 Jobs 时清理；<=0 表示结束后立即清理。
+func WithJobRetention(d time.Duration) JobOption {
+	return func(r *JobRunner) {
+		r.retention = d
+	}
+}
+
+// WithJobErrorHandler 设置推送失败时的回调（默认忽略）。
+func WithJobErrorHandler(fn func(job *Job, err error)) JobOption {
+	return func(r *JobRunner) {
+		r.onError = fn
+	}
+}
+
+// JobRunner 在后台执行超出会话时长的长任务，并在完成时主动推送结果，避免任务随会话过期而静默失败。
+// 任务状态保存在进程内存中，进程重启后丢失；已结束的任务按 WithJobRetention 过期清理。
+type JobRunner struct {
+	responser        botcore.Responser
+	notify           JobNotifier
+	maxPushes        int
+	progressInterval time.Duration
+	timeout          time.Duration
+	retention        time.Duration
+	onError          func(job *Job, err error)
+
+	ctx    context.Context
+	stop   context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	closed bool
+	jobs   map[string]*Job
+}
+
+// NewJobRunner 创建后台任务执行器。
+// Parameters:
+//   - responser: 默认的主动推送器；为 nil 时使用发起命令的 ExecutionContext 中的 Responser
+//   - opts: 可选配置（推送方式、推送次数、超时）
+//
+// Returns:
+//   - *JobRunner: 任务执行器
+func NewJobRunner(responser botcore.Responser, opts ...JobOption) *JobRunner {
+	ctx, stop := context.WithCancel(context.Background())
+	r := &JobRunner{
+		responser:        responser,
+		maxPushes:        1,
+		progressInterval: 30 * time.Second,
+		retention:        time.Hour,
+		ctx:              ctx,
+		stop:             stop,
+		jobs:             make(map[string]*Job),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Start 在后台启动任务，并以“已提交”提示结束当前命令的被动回复。
+// 任务完成后推送 fn 返回的文本，失败时推送错误提示。
+// 演练模式（ExecutionContext.DryRun）下不启动任务，只回复演练提示并返回 nil Job。
+// Parameters:
+//   - execCtx: 发起任务的命令上下文（可通过 FromContext(cmd.Context()) 获取）
+//   - name: 任务名称，用于提示文本
+//   - fn: 任务函数
+//
+// Returns:
+//   - *Job: 已启动的任务（演练模式下为 nil）
+//   - error: JobRunner 已停止或缺少推送方式时返回
+func (r *JobRunner) Start(execCtx *ExecutionContext, name string, fn JobFunc) (*Job, error) {
+	if execCtx == nil {
+		return nil, errExecutionContextNil
+	}
+	if execCtx.DryRun() {
+		execCtx.sendFinal(botcore.StreamChunk{Content: execCtx.DryRunMessage(botcore.Localize(execCtx.RequestSnapshot, MsgJobAction, name))})
+		return nil, nil
+	}
+	responser := r.responser
+	if responser == nil {
+		responser = execCtx.responser
+	}
+	if r.notify == nil && (responser == nil || execCtx.RequestSnapshot.ResponseURL == "") {
+		return nil, errJobNoDelivery
+	}
+
+	notify := r.notify
+	if notify == nil {
+		notify = func(snapshot botcore.RequestSnapshot, text string) error {
+			return responser.ResponseMarkdown(snapshot.ResponseURL, text)
+		}
+	}
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, ErrJobRunnerClosed
+	}
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if r.timeout > 0 {
+		ctx, cancel = context.WithTimeout(r.ctx, r.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(r.ctx)
+	}
+	now := time.Now()
+	r.pruneLocked(now)
+	job := &Job{
+		ID:        uuid.NewString()[:8],
+		Name:      name,
+		Snapshot:  execCtx.RequestSnapshot,
+		StartedAt: now,
+		runner:    r,
+		cancel:    cancel,
+		notify:    notify,
+		state:     JobRunning,
+		updatedAt: now,
+	}
+	r.jobs[job.ID] = job
+	r.wg.Add(1)
+	r.mu.Unlock()
+
+	go r.run(ctx, job, fn)
+
+	execCtx.sendFinal(botcore.StreamChunk{Content: botcore.Localize(job.Snapshot, MsgJobStarted, name, job.ID)})
+	return job, nil
+}
+
+// run 执行任务并推送结果。
+func (r *JobRunner) run(ctx context.Context, job *Job, fn JobFunc) {
+	defer r.wg.Done()
+	defer job.cancel()
+
+	result, err := func() (result string, err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = fmt.Errorf("job panic: %v", rec)
+			}
+		}()
+		return fn(ctx, job)
+	}()
+
+	state := JobSucceeded
+	text := result
+	if err != nil {
+		state = JobFailed
+		text = botcore.Localize(job.Snapshot, MsgJobFailed, job.Name, err)
+	} else if text == "" {
+		text = botcore.Localize(job.Snapshot, MsgJobDone, job.Name)
+	}
+	job.mu.Lock()
+	job.state = state
+	job.updatedAt = time.Now()
+	job.pushes++
+	job.mu.Unlock()
+	r.deliver(job, text)
+}
+
+// deliver 推送一条任务消息。
+func (r *JobRunner) deliver(job *Job, text string) {
+	if err := job.notify(job.Snapshot, text); err != nil && r.onError != nil {
+		r.onError(job, err)
+	}
+}
+
+// pruneLocked 清理结束时间早于保留时长的任务，调用方需持有 r.mu。
+func (r *JobRunner) pruneLocked(now time.Time) {
+	for id, job := range r.jobs {
+		job.mu.Lock()
+		expired := job.state != JobRunning && now.Sub(job.updatedAt) >= r.retention
+		job.mu.Unlock()
+		if expired {
+			delete(r.jobs, id)
+		}
+	}
+}
+
+// Get 返回指定 ID 的任务（已过保留时长的结束任务视为不存在）。
+func (r *JobRunner) Get(id string) (*Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pruneLocked(time.Now())
+	job, ok := r.jobs[id]
+	return job, ok
+}
+
+// Jobs 返回全部任务的状态快照（按开始时间排序），包括保留时长内已结束的任务。
+func (r *JobRunner) Jobs() []JobStatus {
+	r.mu.Lock()
+	r.pruneLocked(time.Now())
+	jobs := make([]*Job, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		jobs = append(jobs, job)
+	}
+	r.mu.Unlock()
+	statuses := make([]JobStatus, 0, len(jobs))
+	for _, job := range jobs {
+		statuses = append(statuses, job.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].StartedAt.Before(statuses[j].StartedAt) })
+	return statuses
+}
+
+// Cancel 取消运行中的任务，返回是否找到该任务。
+func (r *JobRunner) Cancel(id string) bool {
+	job, ok := r.Get(id)
+	if ok {
+		job.cancel()
+	}
+	return ok
+}
+
+// Close 停止接收新任务并等待运行中的任务结束；ctx 到期时取消剩余任务并返回其错误。
+func (r *JobRunner) Close(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		r.stop()
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,212 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
+)
+
+// recordResponser 记录主动推送的 Markdown 消息。
+type recordResponser struct {
+	mu   sync.Mutex
+	sent []string
+	urls []string
+}
+
+func (r *recordResponser) Response(string, any) error             { return nil }
+func (r *recordResponser) ResponseTemplateCard(string, any) error { return nil }
+func (r *recordResponser) ResponseMarkdown(url, content string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.urls = append(r.urls, url)
+	r.sent = append(r.sent, content)
+	return nil
+}
+
+func (r *recordResponser) messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.sent...)
+}
+
+// newJobCommands 返回一个 /build 命令，通过 runner 在后台执行 fn。
+func newJobCommands(runner *JobRunner, fn JobFunc) CommandFunc {
+	return func() *cobra.Command {
+		root := &cobra.Command{Use: "bot"}
+		root.AddCommand(&cobra.Command{Use: "build", RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := runner.Start(FromContext(cmd.Context()), "build", fn)
+			return err
+		}})
+		return root
+	}
+}
+
+func triggerJob(mgr *Manager) string {
+	var out string
+	snapshot := botcore.RequestSnapshot{SenderID: "u1", ChatID: "c1", Text: "/build", ResponseURL: "https://example.com/resp"}
+	for chunk := range mgr.Trigger(botcore.PipelineContext{Snapshot: snapshot}) {
+		out += chunk.Content
+	}
+	return out
+}
+
+func TestJobRunnerOutlivesCommand(t *testing.T) {
+	responser := &recordResponser{}
+	runner := NewJobRunner(responser)
+	release := make(chan struct{})
+	mgr := NewManager(newJobCommands(runner, func(ctx context.Context, job *Job) (string, error) {
+		<-release
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		job.Progress("50%") // 默认只推送最终结果
+		return "构建完成", nil
+	}), WithTimeout(10*time.Millisecond))
+
+	got := triggerJob(mgr)
+	statuses := runner.Jobs()
+	if len(statuses) != 1 || statuses[0].State != JobRunning {
+		t.Fatalf("unexpected job statuses: %+v", statuses)
+	}
+	if want := "⏳ 已在后台执行「build」（任务 " + statuses[0].ID + "），完成后将通知你。"; got != want {
+		t.Fatalf("unexpected reply: %q", got)
+	}
+
+	// 关键步骤：命令已结束且超时已过，任务仍应正常完成。
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if err := runner.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if sent := responser.messages(); len(sent) != 1 || sent[0] != "构建完成" {
+		t.Fatalf("unexpected pushes: %q", sent)
+	}
+	if responser.urls[0] != "https://example.com/resp" {
+		t.Fatalf("unexpected response url: %q", responser.urls[0])
+	}
+	if status := runner.Jobs()[0]; status.State != JobSucceeded || status.Progress != "50%" {
+		t.Fatalf("unexpected final status: %+v", status)
+	}
+}
+
+func TestJobRunnerDryRun(t *testing.T) {
+	runner := NewJobRunner(&recordResponser{})
+	ran := false
+	mgr := NewManager(newJobCommands(runner, func(ctx context.Context, job *Job) (string, error) {
+		ran = true
+		return "", nil
+	}), WithDryRun())
+
+	if got := triggerJob(mgr); got != "🧪 [演练] 将会：在后台执行「build」" {
+		t.Fatalf("unexpected reply: %q", got)
+	}
+	if err := runner.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if ran || len(runner.Jobs()) != 0 {
+		t.Fatalf("dry run should not start jobs: ran=%v jobs=%+v", ran, runner.Jobs())
+	}
+}
+
+func TestJobRunnerProgressAndFailure(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent []string
+	)
+	runner := NewJobRunner(nil,
+		WithJobPushes(3),
+		WithJobProgressInterval(0),
+		WithJobNotifier(func(snapshot botcore.RequestSnapshot, text string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, snapshot.SenderID+": "+text)
+			return nil
+		}),
+	)
+	mgr := NewManager(newJobCommands(runner, func(ctx context.Context, job *Job) (string, error) {
+		job.Progress("1/3")
+		job.Progress("2/3")
+		job.Progress("3/3") // 超出推送次数，仅记录
+		return "", errors.New("disk full")
+	}))
+
+	triggerJob(mgr)
+	if err := runner.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	want := []string{"u1: ⏳「build」进度：1/3", "u1: ⏳「build」进度：2/3", "u1: ❌「build」执行失败: disk full"}
+	if len(sent) != len(want) {
+		t.Fatalf("unexpected pushes: %q", sent)
+	}
+	for i := range want {
+		if sent[i] != want[i] {
+			t.Fatalf("push %d: got %q, want %q", i, sent[i], want[i])
+		}
+	}
+	if status := runner.Jobs()[0]; status.State != JobFailed || status.Progress != "3/3" {
+		t.Fatalf("unexpected final status: %+v", status)
+	}
+}
+
+func TestJobRunnerEvictsFinishedJobs(t *testing.T) {
+	runner := NewJobRunner(nil,
+		WithJobRetention(100*time.Millisecond),
+		WithJobNotifier(func(snapshot botcore.RequestSnapshot, text string) error { return nil }),
+	)
+	release := make(chan struct{})
+	mgr := NewManager(newJobCommands(runner, func(ctx context.Context, job *Job) (string, error) {
+		select {
+		case <-release:
+			return "done", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}))
+
+	triggerJob(mgr)
+	time.Sleep(150 * time.Millisecond)
+	if jobs := runner.Jobs(); len(jobs) != 1 || jobs[0].State != JobRunning {
+		t.Fatalf("running jobs must not be evicted: %+v", jobs)
+	}
+	close(release)
+	if err := runner.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if jobs := runner.Jobs(); len(jobs) != 1 || jobs[0].State != JobSucceeded {
+		t.Fatalf("finished job should be kept within retention: %+v", jobs)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if jobs := runner.Jobs(); len(jobs) != 0 {
+		t.Fatalf("expected finished job to be evicted, got %+v", jobs)
+	}
+}
+
+func TestJobRunnerCancelAndClose(t *testing.T) {
+	responser := &recordResponser{}
+	runner := NewJobRunner(responser)
+	mgr := NewManager(newJobCommands(runner, func(ctx context.Context, job *Job) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}), WithResponser(responser))
+
+	triggerJob(mgr)
+	id := runner.Jobs()[0].ID
+	if !runner.Cancel(id) || runner.Cancel("missing") {
+		t.Fatal("unexpected cancel result")
+	}
+	if err := runner.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if sent := responser.messages(); len(sent) != 1 || sent[0] != "❌「build」执行失败: context canceled" {
+		t.Fatalf("unexpected pushes: %q", sent)
+	}
+	if got := triggerJob(mgr); !strings.Contains(got, ErrJobRunnerClosed.Error()) {
+		t.Fatalf("expected closed runner to reject jobs, got %q", got)
+	}
+}
@@ -0,0 +1,44 @@
+package command
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ErrLLMNotConfigured 表示 Manager 未通过 WithLLM 注入模型。
+var ErrLLMNotConfigured = errors.New("llm not configured")
+
+// WithLLM 注入命令可用的大模型，命令通过 ExecutionContext.LLM 或 Ask 调用，
+// 便于实现 /summarize、/translate 等 AI 命令而无需各自持有模型实例。
+func WithLLM(model llms.Model) ManagerOption {
+	return func(m *Manager) {
+		m.llm = model
+	}
+}
+
+// LLM 返回 Manager 注入的模型（见 WithLLM），未注入时为 nil。
+func (ctx *ExecutionContext) LLM() llms.Model {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.llm
+}
+
+// Ask 以单轮提示调用注入的模型并返回生成的文本。
+// Parameters:
+//   - c: 调用上下文（通常为 cmd.Context()，随命令超时与 /cancel 取消）
+//   - prompt: 提示词
+//   - opts: 模型调用选项
+//
+// Returns:
+//   - string: 模型输出
+//   - error: 未注入模型或调用失败时返回
+func (ctx *ExecutionContext) Ask(c context.Context, prompt string, opts ...llms.CallOption) (string, error) {
+	model := ctx.LLM()
+	if model == nil {
+		return "", ErrLLMNotConfigured
+	}
+	return llms.GenerateFromSinglePrompt(c, model, prompt, opts...)
+}
@@ -0,0 +1,47 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// prefixModel 以固定前缀回显提示词。
+type prefixModel struct{}
+
+func (prefixModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	prompt := messages[len(messages)-1].Parts[0].(llms.TextContent).Text
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: "摘要：" + prompt}}}, nil
+}
+
+func (prefixModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return "", nil
+}
+
+func newSummarizeCommands() *cobra.Command {
+	root := &cobra.Command{Use: "bot"}
+	root.AddCommand(&cobra.Command{Use: "summarize", RunE: func(cmd *cobra.Command, args []string) error {
+		answer, err := FromContext(cmd.Context()).Ask(cmd.Context(), "今天的会议")
+		if err != nil {
+			return err
+		}
+		cmd.Print(answer)
+		return nil
+	}})
+	return root
+}
+
+func TestWithLLM(t *testing.T) {
+	mgr := NewManager(newSummarizeCommands, WithLLM(prefixModel{}))
+	if got := run(mgr, "u1", "/summarize"); got != "摘要：今天的会议" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+
+	var execCtx *ExecutionContext
+	if _, err := execCtx.Ask(context.Background(), "x"); !errors.Is(err, ErrLLMNotConfigured) {
+		t.Fatalf("expected ErrLLMNotConfigured, got %v", err)
+	}
+}
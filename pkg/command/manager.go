@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
+	"github.com/tmc/langchaingo/llms"
 )
 
 // Manager 实现 PipelineInvoker，负责串联解析、构建 Cobra 命令树并执行。
@@ -16,6 +19,28 @@ type Manager struct {
 	logger  *log.Logger
 
 	responser botcore.Responser
+
+	roles        RoleProvider
+	deniedFormat string
+	rateLimit    *RateLimitPolicy
+	help         HelpRenderer
+	helpSet      bool
+
+	flushInterval time.Duration
+	flushSize     int
+
+	timeout    time.Duration
+	cancelName string
+	running    executions
+
+	conversationKey botcore.KeyFunc
+	middlewares     []CommandMiddleware
+	rejectionHooks  []RejectionHook
+	llm             llms.Model
+	metrics         *Metrics
+	modules         modules
+	dryRun          bool
+	dryRunFlag      bool
 }
 
 // ManagerOption 自定义 Manager 行为。
@@ -35,15 +60,63 @@ func WithResponser(r botcore.Responser) ManagerOption {
 	}
 }
 
+// WithParser 自定义命令解析规则，如多前缀 NewParser("/", "!", "#") 或启用 Parser.MentionCommands。
+func WithParser(p Parser) ManagerOption {
+	return func(m *Manager) {
+		m.parser = p
+	}
+}
+
+// WithOutputBuffering 合并命令的 stdout/stderr 输出后再发送（见 NewStreamWriterBuffered），
+// 避免逐行打印的命令产生大量碎小片段；命令结束或发送最终片段前自动发送剩余内容。
+func WithOutputBuffering(interval time.Duration, maxSize int) ManagerOption {
+	return func(m *Manager) {
+		m.flushInterval = interval
+		m.flushSize = maxSize
+	}
+}
+
+// WithHelpRenderer 自定义 /help、--help 的输出（默认为以首个命令前缀渲染的 MarkdownHelp）；
+// 传入 nil 恢复 Cobra 的终端风格帮助。
+func WithHelpRenderer(r HelpRenderer) ManagerOption {
+	return func(m *Manager) {
+		m.help = r
+		m.helpSet = true
+	}
+}
+
+// WithRoleProvider 启用命令权限校验：声明了 AnnotationRoles 的命令仅允许拥有对应角色的发送者执行。
+// 未配置时，声明了角色的命令一律拒绝执行。
+func WithRoleProvider(p RoleProvider) ManagerOption {
+	return func(m *Manager) {
+		m.roles = p
+	}
+}
+
+// WithPermissionDeniedMessage 自定义权限不足时的回复（默认为本地化的 MsgPermissionDenied），
+// format 可包含一个 %s 占位符（所需角色列表）。
+func WithPermissionDeniedMessage(format string) ManagerOption {
+	return func(m *Manager) {
+		m.deniedFormat = format
+	}
+}
+
 // NewManager 绑定命令构建函数，返回实现 PipelineInvoker 的管理器。
+// 命令全部由模块提供时 factory 可为 nil（见 WithModules）。
 func NewManager(factory CommandFunc, opts ...ManagerOption) *Manager {
 	mgr := &Manager{
 		factory: factory,
 		parser:  NewParser(), // 保留 Parser 用于判断是否为命令（前缀检查）
+
+		cancelName:      defaultCancelCommand,
+		conversationKey: PerChatUser,
 	}
 	for _, opt := range opts {
 		opt(mgr)
 	}
+	if !mgr.helpSet {
+		mgr.help = MarkdownHelp(mgr.parser.prefixes()[0])
+	}
 	return mgr
 }
 
@@ -52,57 +125,121 @@ func (m *Manager) Trigger(pipelineCtx botcore.PipelineContext) <-chan botcore.St
 	outCh := make(chan botcore.StreamChunk, 1)
 	go func() {
 		defer close(outCh)
+		defer botcore.RecoverInto(pipelineCtx, outCh)
 
-		if m == nil || m.factory == nil {
+		if !m.ready() {
 			outCh <- botcore.StreamChunk{Content: "Error: Command Manager not initialized", IsFinal: true}
 			return
 		}
 
 		update := pipelineCtx.Snapshot
 		// 1. 初步解析
-		parsed := m.parser.Parse(update.Text)
+		parsed := m.parser.ParseSnapshot(update)
 		if !parsed.IsCommand {
 			if strings.TrimSpace(update.Text) == "" {
-				outCh <- botcore.StreamChunk{Content: "请输入命令 (e.g. /help)", IsFinal: true}
+				outCh <- botcore.StreamChunk{Content: botcore.Localize(update, MsgCommandRequired), IsFinal: true}
 			} else {
-				outCh <- botcore.StreamChunk{Content: fmt.Sprintf("未识别的命令: %s\n请尝试 /help", parsed.Raw), IsFinal: true}
+				outCh <- botcore.StreamChunk{Content: botcore.Localize(update, MsgCommandUnknown, parsed.Raw), IsFinal: true}
 			}
 			return
 		}
 
 		// 2. 创建 Cobra 命令树
-		rootCmd := m.factory()
+		rootCmd := m.newRoot()
 
 		// 3. 配置 IO 重定向
 		writer := NewStreamWriter(outCh)
-		rootCmd.SetOut(writer)
-		rootCmd.SetErr(writer)
+		if m.flushInterval > 0 || m.flushSize > 0 {
+			writer = NewStreamWriterBuffered(outCh, m.flushInterval, m.flushSize)
+		}
 		rootCmd.CompletionOptions.DisableDefaultCmd = true
+		if m.help != nil {
+			installHelp(rootCmd, m.help, update)
+		}
 
 		// 4. 准备上下文
 		execCtx := &ExecutionContext{
 			RequestSnapshot: update,
 			ch:              outCh,
 			responser:       pipelineCtx.Responser,
+			flush:           writer.Flush,
+			conversationKey: m.conversationKey(update),
+			llm:             m.llm,
+			dryRun:          m.dryRun,
 		}
 		if execCtx.responser == nil {
 			execCtx.responser = m.responser
 		}
+		if m.dryRunFlag {
+			installDryRunFlag(rootCmd, execCtx)
+		}
 
-		ctx := WithExecutionContext(context.Background(), execCtx)
+		// 关键步骤：以请求级 context 为父级，命令实现可经 cmd.Context() 继承追踪与取消信号。
+		ctx := WithExecutionContext(pipelineCtx.Context(), execCtx)
 
 		// 5. 设置参数并执行
-		args := parsed.Tokens
-		// 如果第一个 token 匹配 root command 的 name，移除它以避免 "unknown command X for X" 错误
-		if len(args) > 0 && strings.EqualFold(args[0], rootCmd.Name()) {
-			args = args[1:]
+		args := commandArgs(rootCmd, parsed.Tokens)
+		if m.isCancelCommand(rootCmd, args) {
+			reply := botcore.Localize(update, MsgCancelNone)
+			if n := m.running.cancel(execCtx.conversationKey); n > 0 {
+				reply = botcore.Localize(update, MsgCancelDone, n)
+			}
+			outCh <- botcore.StreamChunk{Content: reply, IsFinal: true}
+			return
+		}
+		// 关键步骤：执行前按命令声明的角色校验权限，拒绝时不进入 Cobra 执行流程。
+		if required, err := m.authorize(ctx, rootCmd, args, update); err != nil {
+			m.logf("Permission denied: %v for user %s", args, update.SenderID)
+			m.reject(ctx, execCtx, rootCmd, args, RejectedPermission)
+			roles := strings.Join(required, " / ")
+			msg := botcore.Localize(update, MsgPermissionDenied, roles)
+			if m.deniedFormat != "" {
+				msg = m.deniedFormat
+				if strings.Contains(msg, "%s") {
+					msg = fmt.Sprintf(msg, roles)
+				}
+			}
+			outCh <- botcore.StreamChunk{Content: msg, IsFinal: true}
+			return
+		}
+		if reply, limited := m.throttle(ctx, rootCmd, args, update); limited {
+			m.logf("Rate limited: %v for user %s", args, update.SenderID)
+			m.reject(ctx, execCtx, rootCmd, args, RejectedRateLimit)
+			if reply == "" {
+				outCh <- botcore.StreamChunk{Payload: botcore.NoResponse, IsFinal: true}
+			} else {
+				outCh <- botcore.StreamChunk{Content: reply, IsFinal: true}
+			}
+			return
 		}
-		rootCmd.SetArgs(args)
 		m.logf("Executing command: %v for user %s", args, update.SenderID)
 
-		if err := rootCmd.ExecuteContext(ctx); err != nil {
+		// 关键步骤：登记可取消的执行（/cancel 与超时），被中止时立即以提示结束回复。
+		var cancel context.CancelFunc
+		if m.timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		} else {
+			ctx, cancel = context.WithCancel(ctx)
+		}
+		// 输出在执行被中止后丢弃，避免 Cobra 继续打印 context 错误与用法。
+		rootCmd.SetOut(abortableWriter{ctx: ctx, w: writer})
+		rootCmd.SetErr(abortableWriter{ctx: ctx, w: writer})
+		unregister := m.running.add(execCtx.conversationKey, cancel)
+		stopWatching := watchExecution(ctx, execCtx, update)
+
+		handler := chainCommand(executeRoot, m.middlewares)
+		if m.metrics != nil {
+			handler = m.metrics.middleware(handler)
+		}
+		err := handler(ctx, newInvocation(execCtx, rootCmd, args))
+		aborted := ctx.Err() != nil
+		stopWatching()
+		unregister()
+		cancel()
+		_ = writer.Close()
+		if err != nil && !aborted {
 			m.logf("Command execution error: %v", err)
-			outCh <- botcore.StreamChunk{Content: fmt.Sprintf("❌ 执行出错: %v\n", err)}
+			outCh <- botcore.StreamChunk{Content: botcore.Localize(update, MsgCommandFailed, err), Err: err}
 		}
 
 		// 执行结束后，如果没有发送过任何显式信号，也没有流式输出（StreamWriter自动处理），
@@ -114,6 +251,40 @@ func (m *Manager) Trigger(pipelineCtx botcore.PipelineContext) <-chan botcore.St
 	return outCh
 }
 
+// Matcher 返回判断消息是否应交给该 Manager 的 Matcher，用于 Chain 路由（替代 botcore.MatchPrefix）。
+// 带前缀的消息一律匹配；提及触发的无前缀消息（Parser.MentionCommands）仅在首个词是已注册命令时匹配，
+// 避免把 "@bot 你好" 之类的普通对话当作命令。
+func (m *Manager) Matcher() botcore.Matcher {
+	return func(u botcore.RequestSnapshot) bool {
+		if !m.ready() {
+			return false
+		}
+		parsed := m.parser.ParseSnapshot(u)
+		if !parsed.IsCommand {
+			return false
+		}
+		if parsed.Prefix != "" {
+			return true
+		}
+		rootCmd := m.newRoot()
+		args := commandArgs(rootCmd, parsed.Tokens)
+		if m.isCancelCommand(rootCmd, args) {
+			return true
+		}
+		target, _, err := rootCmd.Find(args)
+		return err == nil && target != rootCmd
+	}
+}
+
+// commandArgs 将解析出的 token 转换为命令树参数。
+// 如果第一个 token 匹配 root command 的 name，移除它以避免 "unknown command X for X" 错误。
+func commandArgs(rootCmd *cobra.Command, tokens []string) []string {
+	if len(tokens) > 0 && strings.EqualFold(tokens[0], rootCmd.Name()) {
+		return tokens[1:]
+	}
+	return tokens
+}
+
 func (m *Manager) logf(format string, args ...any) {
 	if m == nil || m.logger == nil {
 		return
@@ -0,0 +1,29 @@
+package command
+
+import "github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+
+// 命令管理器使用的消息键，文案注册在 botcore.Messages 中，可通过 botcore.Messages.Add 覆盖或补充其他语言。
+const (
+	MsgCommandRequired    = "command.required"    // 未输入命令
+	MsgCommandUnknown     = "command.unknown"     // 未识别的命令，参数：原始文本
+	MsgCommandFailed      = "command.failed"      // 执行出错，参数：错误
+	MsgPermissionDenied   = "command.denied"      // 权限不足，参数：所需角色
+	MsgCommandRateLimited = "command.ratelimited" // 命令限流，参数：命令路径、等待秒数
+)
+
+func init() {
+	botcore.Messages.Add("zh", map[string]string{
+		MsgCommandRequired:    "请输入命令 (e.g. /help)",
+		MsgCommandUnknown:     "未识别的命令: %s\n请尝试 /help",
+		MsgCommandFailed:      "❌ 执行出错: %v\n",
+		MsgPermissionDenied:   "⛔ 权限不足：该命令仅限 %s 使用",
+		MsgCommandRateLimited: "命令 /%s 调用过于频繁，请 %d 秒后再试。",
+	})
+	botcore.Messages.Add("en", map[string]string{
+		MsgCommandRequired:    "Please enter a command (e.g. /help)",
+		MsgCommandUnknown:     "Unknown command: %s\nTry /help",
+		MsgCommandFailed:      "❌ Command failed: %v\n",
+		MsgPermissionDenied:   "⛔ Permission denied: this command is restricted to %s",
+		MsgCommandRateLimited: "Command /%s is being used too often, please try again in %d seconds.",
+	})
+}
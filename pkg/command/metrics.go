@@ -0,0 +1,279 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/internal/metricsutil"
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
+)
+
+// defaultCommandBuckets 为命令耗时直方图的默认分桶（秒）。
+var defaultCommandBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// /stats 使用的消息键。
+const (
+	MsgStatsEmpty     = "stats.empty"     // 暂无命令执行记录
+	MsgStatsTitle     = "stats.title"     // 统计标题
+	MsgStatsLine      = "stats.line"      // 单个命令，参数：命令、次数、失败数、失败率（%）、平均耗时、最长耗时
+	MsgStatsCancelled = "stats.cancelled" // 追加在单个命令后的取消次数，参数：取消数
+)
+
+func init() {
+	botcore.Messages.Add("zh", map[string]string{
+		MsgStatsEmpty:     "暂无命令执行记录。",
+		MsgStatsTitle:     "**命令使用统计**",
+		MsgStatsLine:      "- `%s` %d 次，失败 %d（%.1f%%），平均 %s，最长 %s",
+		MsgStatsCancelled: "，取消 %d",
+	})
+	botcore.Messages.Add("en", map[string]string{
+		MsgStatsEmpty:     "No commands have run yet.",
+		MsgStatsTitle:     "**Command usage**",
+		MsgStatsLine:      "- `%s` %d runs, %d failed (%.1f%%), avg %s, max %s",
+		MsgStatsCancelled: ", %d cancelled",
+	})
+}
+
+// unknownCommandLabel 为未匹配到命令（未知命令）时使用的统计名称。
+const unknownCommandLabel = "(unknown)"
+
+// CommandStats 为单个命令的累计使用统计。
+type CommandStats struct {
+	// Command 为命令路径，如 "deploy prod"；未知命令为 "(unknown)"。
+	Command string
+	// Count 为执行次数。
+	Count uint64
+	// Errors 为返回错误的次数（不含被取消的执行）。
+	Errors uint64
+	// Cancelled 为被 /cancel 或超时中止的次数。
+	Cancelled uint64
+	// Total 为累计耗时。
+	Total time.Duration
+	// Max 为单次最长耗时。
+	Max time.Duration
+}
+
+// ErrorRate 返回失败次数占执行次数的比例。
+func (s CommandStats) ErrorRate() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Count)
+}
+
+// Average 返回平均耗时。
+func (s CommandStats) Average() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// Metrics 按命令统计执行次数、失败率与耗时，并以 Prometheus 文本格式暴露（可直接挂载为 /metrics）。
+// 被权限校验或限流拦截的请求不进入执行流程，不在统计范围内。
+type Metrics struct {
+	mu       sync.Mutex
+	buckets  []float64
+	commands map[string]*commandMetrics
+}
+
+// commandMetrics 为单个命令的统计数据。
+type commandMetrics struct {
+	stats   CommandStats
+	latency *metricsutil.Histogram
+}
+
+// NewMetrics 创建命令指标收集器。
+// Parameters:
+//   - buckets: 耗时直方图分桶（秒，升序）；为空时使用默认分桶
+//
+// Returns:
+//   - *Metrics: 指标收集器
+func NewMetrics(buckets ...float64) *Metrics {
+	return &Metrics{buckets: metricsutil.Buckets(buckets, defaultCommandBuckets), commands: make(map[string]*commandMetrics)}
+}
+
+// WithMetrics 为 Manager 启用命令使用统计。统计位于所有命令中间件外层，耗时包含中间件的执行时间。
+func WithMetrics(metrics *Metrics) ManagerOption {
+	return func(m *Manager) {
+		m.metrics = metrics
+	}
+}
+
+// middleware 返回记录每次执行结果与耗时的命令中间件。
+func (m *Metrics) middleware(next CommandHandler) CommandHandler {
+	return func(ctx context.Context, inv *Invocation) error {
+		startedAt := time.Now()
+		err := next(ctx, inv)
+		m.observe(inv.Path, time.Since(startedAt), err, ctx.Err() != nil)
+		return err
+	}
+}
+
+// observe 记录一次命令执行。
+func (m *Metrics) observe(path string, elapsed time.Duration, err error, aborted bool) {
+	if path == "" {
+		path = unknownCommandLabel
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cm, ok := m.commands[path]
+	if !ok {
+		cm = &commandMetrics{stats: CommandStats{Command: path}, latency: metricsutil.NewHistogram(m.buckets)}
+		m.commands[path] = cm
+	}
+	cm.stats.Count++
+	switch {
+	case aborted:
+		cm.stats.Cancelled++
+	case err != nil:
+		cm.stats.Errors++
+	}
+	cm.stats.Total += elapsed
+	cm.stats.Max = max(cm.stats.Max, elapsed)
+	cm.latency.Observe(elapsed.Seconds())
+}
+
+// Snapshot 返回各命令的统计，按执行次数降序（次数相同时按命令名）排列。
+func (m *Metrics) Snapshot() []CommandStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := make([]CommandStats, 0, len(m.commands))
+	for _, cm := range m.commands {
+		stats = append(stats, cm.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		return stats[i].Command < stats[j].Command
+	})
+	return stats
+}
+
+// Reset 清空已收集的统计。
+func (m *Metrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.commands = make(map[string]*commandMetrics)
+}
+
+// ServeHTTP 以 Prometheus 文本格式输出指标。
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.Write(w)
+}
+
+// Write 将指标以 Prometheus 文本格式写入 w，命令路径作为 command 标签。
+func (m *Metrics) Write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.commands))
+	for name := range m.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	counters := []struct {
+		name, help string
+		value      func(CommandStats) uint64
+	}{
+		{"imbot_command_invocations_total", "Command executions.", func(s CommandStats) uint64 { return s.Count }},
+		{"imbot_command_errors_total", "Command executions that returned an error.", func(s CommandStats) uint64 { return s.Errors }},
+		{"imbot_command_cancelled_total", "Command executions stopped by /cancel or timeout.", func(s CommandStats) uint64 { return s.Cancelled }},
+	}
+	for _, c := range counters {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name); err != nil {
+			return err
+		}
+		for _, name := range names {
+			if _, err := fmt.Fprintf(w, "%s{command=%q} %d\n", c.name, name, c.value(m.commands[name].stats)); err != nil {
+				return err
+			}
+		}
+	}
+
+	const latency = "imbot_command_duration_seconds"
+	if err := metricsutil.WriteHeader(w, latency, "Command execution time."); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := m.commands[name].latency.WriteSamples(w, latency, fmt.Sprintf("command=%q", name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StatsCommand 返回查看命令使用统计的 /stats 命令，仅允许拥有 roles 中任一角色的用户执行（默认 "admin"，
+// 需配合 WithRoleProvider）。
+//
+//	/stats            按执行次数列出全部命令
+//	/stats --top 5    仅列出前 5 个命令
+//	/stats --reset    输出后清空统计
+//
+// Parameters:
+//   - metrics: 指标收集器（与 WithMetrics 传入的为同一实例）
+//   - roles: 允许查看的角色
+//
+// Returns:
+//   - *cobra.Command: 可挂载到命令树的 /stats 命令
+func StatsCommand(metrics *Metrics, roles ...string) *cobra.Command {
+	if len(roles) == 0 {
+		roles = []string{"admin"}
+	}
+	var (
+		top   int
+		reset bool
+	)
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "查看命令使用统计",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stats := metrics.Snapshot()
+			if top > 0 && len(stats) > top {
+				stats = stats[:top]
+			}
+			cmd.Print(FormatStats(FromContext(cmd.Context()).RequestSnapshot, stats))
+			if reset {
+				metrics.Reset()
+			}
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&top, "top", 0, "仅列出执行次数最多的前 N 个命令")
+	cmd.Flags().BoolVar(&reset, "reset", false, "输出后清空统计")
+	return RequireRoles(cmd, roles...)
+}
+
+// FormatStats 将命令统计按请求语言渲染为 Markdown 列表。
+// Parameters:
+//   - snapshot: 发起查询的请求（决定文案语言）
+//   - stats: 命令统计
+//
+// Returns:
+//   - string: Markdown 文本
+func FormatStats(snapshot botcore.RequestSnapshot, stats []CommandStats) string {
+	if len(stats) == 0 {
+		return botcore.Localize(snapshot, MsgStatsEmpty)
+	}
+	var b strings.Builder
+	b.WriteString(botcore.Localize(snapshot, MsgStatsTitle))
+	for _, s := range stats {
+		b.WriteString("\n" + botcore.Localize(snapshot, MsgStatsLine,
+			s.Command, s.Count, s.Errors, s.ErrorRate()*100,
+			s.Average().Round(time.Millisecond), s.Max.Round(time.Millisecond)))
+		if s.Cancelled > 0 {
+			b.WriteString(botcore.Localize(snapshot, MsgStatsCancelled, s.Cancelled))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package command
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
+)
+
+func TestMetricsRecordsCommandsAndStats(t *testing.T) {
+	metrics := NewMetrics()
+	roles := NewMemoryRoleStore()
+	roles.Grant("admin", "admin")
+	factory := func() *cobra.Command {
+		root := &cobra.Command{Use: "bot"}
+		root.AddCommand(&cobra.Command{Use: "ping", Run: func(cmd *cobra.Command, args []string) { cmd.Print("pong") }})
+		root.AddCommand(&cobra.Command{Use: "fail", SilenceUsage: true, RunE: func(cmd *cobra.Command, args []string) error {
+			return errors.New("boom")
+		}})
+		root.AddCommand(StatsCommand(metrics))
+		return root
+	}
+	mgr := NewManager(factory, WithMetrics(metrics), WithRoleProvider(roles))
+
+	run(mgr, "alice", "/ping")
+	run(mgr, "alice", "/ping")
+	run(mgr, "alice", "/fail")
+	if got := run(mgr, "alice", "/stats"); !strings.Contains(got, "权限不足") {
+		t.Fatalf("expected /stats to require admin, got %q", got)
+	}
+
+	stats := metrics.Snapshot()
+	if len(stats) != 2 || stats[0].Command != "ping" || stats[0].Count != 2 || stats[1].Command != "fail" || stats[1].Errors != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	got := run(mgr, "admin", "/stats --top 1")
+	if !strings.Contains(got, "`ping` 2 次，失败 0（0.0%）") || strings.Contains(got, "fail") {
+		t.Fatalf("unexpected /stats output: %q", got)
+	}
+
+	var b strings.Builder
+	if err := metrics.Write(&b); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	for _, want := range []string{
+		`imbot_command_invocations_total{command="ping"} 2`,
+		`imbot_command_errors_total{command="fail"} 1`,
+		`imbot_command_duration_seconds_count{command="ping"} 2`,
+		`imbot_command_duration_seconds_bucket{command="fail",le="+Inf"} 1`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Fatalf("missing %q in:\n%s", want, b.String())
+		}
+	}
+
+	run(mgr, "admin", "/stats --reset")
+	// 统计在命令结束后记录，清空后仅剩本次 /stats。
+	if stats := metrics.Snapshot(); len(stats) != 1 || stats[0].Command != "stats" {
+		t.Fatalf("expected stats to be reset, got %+v", stats)
+	}
+}
+
+func TestFormatStatsLocalizes(t *testing.T) {
+	en := botcore.RequestSnapshot{Metadata: map[string]string{"lang": "en"}}
+	if got := FormatStats(en, nil); got != "No commands have run yet." {
+		t.Fatalf("unexpected empty stats: %q", got)
+	}
+	got := FormatStats(en, []CommandStats{{Command: "deploy", Count: 4, Errors: 1, Cancelled: 1, Total: 4 * time.Second, Max: 2 * time.Second}})
+	if got != "**Command usage**\n- `deploy` 4 runs, 1 failed (25.0%), avg 1s, max 2s, 1 cancelled" {
+		t.Fatalf("unexpected stats: %q", got)
+	}
+}
@@ -0,0 +1,98 @@
+package command
+
+import (
+	"context"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
+)
+
+// Invocation 描述一次命令执行，供 CommandMiddleware 在执行前后读取或修改。
+type Invocation struct {
+	// Exec 为本次执行的命令上下文（请求快照、回包能力）。
+	Exec *ExecutionContext
+	// Root 为本次请求构建的命令树根节点。
+	Root *cobra.Command
+	// Command 为解析出的目标命令；未知命令时为 Root。
+	Command *cobra.Command
+	// Path 为目标命令相对根命令的路径，如 "deploy prod"；未知命令时为空。
+	Path string
+	// Args 为传给命令树的参数（含命令路径），可在调用 next 前修改以注入参数。
+	Args []string
+}
+
+// Reply 以被动回复结束本次输出，适用于中间件拦截执行（不调用 next）时给出提示。
+// Parameters:
+//   - content: 回复文本
+func (inv *Invocation) Reply(content string) {
+	inv.Exec.sendFinal(botcore.StreamChunk{Content: content})
+}
+
+// CommandHandler 执行一次命令。
+type CommandHandler func(ctx context.Context, inv *Invocation) error
+
+// CommandMiddleware 包装命令执行：在调用 next 前后执行审计、权限校验、参数注入、耗时统计等逻辑，
+// 不调用 next 即拦截本次执行；返回的错误按命令执行失败处理。
+type CommandMiddleware func(next CommandHandler) CommandHandler
+
+// WithCommandMiddleware 追加命令中间件，按添加顺序由外到内执行。
+// 中间件运行在权限校验与限流之后、Cobra 执行之前，ctx 即命令的 cmd.Context()（含超时与 /cancel 的取消信号）。
+func WithCommandMiddleware(mws ...CommandMiddleware) ManagerOption {
+	return func(m *Manager) {
+		m.middlewares = append(m.middlewares, mws...)
+	}
+}
+
+// Rejection 为命令请求在进入中间件前被拦截的原因。
+type Rejection string
+
+const (
+	RejectedPermission Rejection = "permission_denied" // 权限校验未通过
+	RejectedRateLimit  Rejection = "rate_limited"      // 触发 WithRateLimit 限流
+)
+
+// RejectionHook 在命令请求被权限校验或限流拦截时调用。被拦截的请求不会进入 CommandMiddleware，
+// 审计等需要记录拒绝情况的逻辑通过该回调获取；inv 描述被拦截的命令，修改其字段不影响拦截结果。
+type RejectionHook func(ctx context.Context, inv *Invocation, reason Rejection)
+
+// WithRejectionHook 追加命令请求被拦截时的回调，按添加顺序同步执行。
+func WithRejectionHook(hooks ...RejectionHook) ManagerOption {
+	return func(m *Manager) {
+		m.rejectionHooks = append(m.rejectionHooks, hooks...)
+	}
+}
+
+// reject 通知 WithRejectionHook 注册的回调。
+func (m *Manager) reject(ctx context.Context, execCtx *ExecutionContext, root *cobra.Command, args []string, reason Rejection) {
+	if len(m.rejectionHooks) == 0 {
+		return
+	}
+	inv := newInvocation(execCtx, root, args)
+	for _, hook := range m.rejectionHooks {
+		hook(ctx, inv, reason)
+	}
+}
+
+// newInvocation 为参数解析出目标命令与路径。
+func newInvocation(execCtx *ExecutionContext, root *cobra.Command, args []string) *Invocation {
+	inv := &Invocation{Exec: execCtx, Root: root, Command: root, Args: args}
+	if target, _, err := root.Find(args); err == nil && target != root {
+		inv.Command = target
+		inv.Path = commandPath(root, target)
+	}
+	return inv
+}
+
+// executeRoot 为中间件链末端：以 Invocation.Args 执行命令树。
+func executeRoot(ctx context.Context, inv *Invocation) error {
+	inv.Root.SetArgs(inv.Args)
+	return inv.Root.ExecuteContext(ctx)
+}
+
+// chainCommand 将中间件按添加顺序包装到 final 外层。
+func chainCommand(final CommandHandler, mws []CommandMiddleware) CommandHandler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		final = mws[i](final)
+	}
+	return final
+}
@@ -0,0 +1,69 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// newDeployByCommands 返回一个 /deploy <env> --by <user> 命令，输出收到的参数。
+func newDeployByCommands() *cobra.Command {
+	root := &cobra.Command{Use: "bot"}
+	deploy := &cobra.Command{Use: "deploy", Run: func(cmd *cobra.Command, args []string) {
+		by, _ := cmd.Flags().GetString("by")
+		cmd.Print("deploy " + strings.Join(args, ",") + " by " + by)
+	}}
+	deploy.Flags().String("by", "", "执行者")
+	root.AddCommand(deploy)
+	return root
+}
+
+func TestCommandMiddlewareOrderAndInjection(t *testing.T) {
+	var trace []string
+	record := func(name string) CommandMiddleware {
+		return func(next CommandHandler) CommandHandler {
+			return func(ctx context.Context, inv *Invocation) error {
+				trace = append(trace, name+">"+inv.Path)
+				err := next(ctx, inv)
+				trace = append(trace, "<"+name)
+				return err
+			}
+		}
+	}
+	inject := func(next CommandHandler) CommandHandler {
+		return func(ctx context.Context, inv *Invocation) error {
+			inv.Args = append(inv.Args, "--by", inv.Exec.RequestSnapshot.SenderID)
+			return next(ctx, inv)
+		}
+	}
+	mgr := NewManager(newDeployByCommands, WithCommandMiddleware(record("audit"), record("timing")), WithCommandMiddleware(inject))
+
+	if got := run(mgr, "alice", "/deploy prod"); got != "deploy prod by alice" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+	want := []string{"audit>deploy", "timing>deploy", "<timing", "<audit"}
+	if strings.Join(trace, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected trace: %v", trace)
+	}
+}
+
+func TestCommandMiddlewareIntercepts(t *testing.T) {
+	block := func(next CommandHandler) CommandHandler {
+		return func(ctx context.Context, inv *Invocation) error {
+			if inv.Path == "deploy" && len(inv.Args) > 1 && inv.Args[1] == "prod" {
+				inv.Reply("生产环境暂停发布")
+				return nil
+			}
+			return next(ctx, inv)
+		}
+	}
+	mgr := NewManager(newDeployByCommands, WithCommandMiddleware(block))
+	if got := run(mgr, "alice", "/deploy prod"); got != "生产环境暂停发布" {
+		t.Fatalf("expected middleware to intercept, got %q", got)
+	}
+	if got := run(mgr, "alice", "/deploy staging"); got != "deploy staging by " {
+		t.Fatalf("unexpected output: %q", got)
+	}
+}
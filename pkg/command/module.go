@@ -0,0 +1,165 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// ErrModuleExists 表示已注册同名的命令模块。
+var ErrModuleExists = errors.New("command module already registered")
+
+// Module 是可独立发布的命令包：由功能团队实现，宿主应用以 WithModules 或 Manager.Register 一次接入。
+type Module interface {
+	// Name 返回模块名称，在同一 Manager 内唯一。
+	Name() string
+	// Register 将模块的命令挂载到 root。每个请求都会构建新的命令树并调用一次 Register，
+	// 因此必须每次创建新的 cobra.Command 实例，不能复用已挂载过的命令。
+	Register(root *cobra.Command)
+}
+
+// ModuleInitializer 为需要启动准备（建立连接、加载配置等）的模块实现，由 Manager.Init 按注册顺序调用。
+type ModuleInitializer interface {
+	Init(ctx context.Context) error
+}
+
+// ModuleShutdowner 为需要释放资源的模块实现，由 Manager.Shutdown 按注册的逆序调用。
+type ModuleShutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// defaultRootName 为未提供 CommandFunc（仅由模块构成命令树）时根命令的名称。
+const defaultRootName = "bot"
+
+// modules 为 Manager 的模块注册表，并发安全。
+type modules struct {
+	mu    sync.RWMutex
+	items []Module
+	// err 为 WithModules 注册失败的错误（NewManager 无法返回错误），由 Manager.Init 返回。
+	err error
+}
+
+// add 按顺序登记模块，名称重复时整批拒绝。
+func (r *modules) add(mods ...Module) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seen := make(map[string]struct{}, len(r.items)+len(mods))
+	for _, mod := range r.items {
+		seen[mod.Name()] = struct{}{}
+	}
+	for _, mod := range mods {
+		if _, ok := seen[mod.Name()]; ok {
+			return fmt.Errorf("%w: %s", ErrModuleExists, mod.Name())
+		}
+		seen[mod.Name()] = struct{}{}
+	}
+	r.items = append(r.items, mods...)
+	return nil
+}
+
+// fail 记录 WithModules 的注册错误。
+func (r *modules) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = errors.Join(r.err, err)
+}
+
+// failure 返回 WithModules 的注册错误。
+func (r *modules) failure() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.err
+}
+
+// list 返回已注册模块的副本。
+func (r *modules) list() []Module {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Module(nil), r.items...)
+}
+
+// WithModules 在创建 Manager 时注册命令模块（见 Manager.Register）。
+// 名称与已注册模块重复时本批模块均不注册，ErrModuleExists 由 Manager.Init 返回。
+func WithModules(mods ...Module) ManagerOption {
+	return func(m *Manager) {
+		if err := m.modules.add(mods...); err != nil {
+			m.modules.fail(err)
+		}
+	}
+}
+
+// Register 注册命令模块，之后的请求构建命令树时会挂载模块的命令。
+// Parameters:
+//   - mods: 命令模块，按注册顺序挂载
+//
+// Returns:
+//   - error: 模块名称与已注册模块重复时返回 ErrModuleExists，本批模块均不注册
+func (m *Manager) Register(mods ...Module) error {
+	return m.modules.add(mods...)
+}
+
+// Modules 返回已注册的命令模块（按注册顺序）。
+func (m *Manager) Modules() []Module {
+	return m.modules.list()
+}
+
+// Init 按注册顺序初始化实现了 ModuleInitializer 的模块，遇到错误立即返回。
+// Parameters:
+//   - ctx: 初始化使用的 context
+//
+// Returns:
+//   - error: WithModules 的注册错误（如 ErrModuleExists），或首个初始化失败的模块错误（包含模块名称）
+func (m *Manager) Init(ctx context.Context) error {
+	if err := m.modules.failure(); err != nil {
+		return err
+	}
+	for _, mod := range m.modules.list() {
+		if initializer, ok := mod.(ModuleInitializer); ok {
+			if err := initializer.Init(ctx); err != nil {
+				return fmt.Errorf("init module %s: %w", mod.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// Shutdown 按注册的逆序关闭实现了 ModuleShutdowner 的模块；单个模块失败不影响其余模块关闭。
+// Parameters:
+//   - ctx: 控制关闭等待时长的 context
+//
+// Returns:
+//   - error: 所有关闭失败的模块错误（errors.Join）
+func (m *Manager) Shutdown(ctx context.Context) error {
+	mods := m.modules.list()
+	var errs []error
+	for i := len(mods) - 1; i >= 0; i-- {
+		if shutdown, ok := mods[i].(ModuleShutdowner); ok {
+			if err := shutdown.Shutdown(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("shutdown module %s: %w", mods[i].Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ready 判断 Manager 是否能构建命令树（提供了 CommandFunc 或已注册模块）。
+func (m *Manager) ready() bool {
+	return m != nil && (m.factory != nil || len(m.modules.list()) > 0)
+}
+
+// newRoot 构建本次请求的命令树：以 CommandFunc 的结果（未提供时为空根命令）为根，依次挂载模块命令。
+func (m *Manager) newRoot() *cobra.Command {
+	var root *cobra.Command
+	if m.factory != nil {
+		root = m.factory()
+	} else {
+		root = &cobra.Command{Use: defaultRootName}
+	}
+	for _, mod := range m.modules.list() {
+		mod.Register(root)
+	}
+	return root
+}
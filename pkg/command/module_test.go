@@ -0,0 +1,77 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// greetModule 提供 /greet 命令，并记录生命周期调用。
+type greetModule struct {
+	name  string
+	trace *[]string
+	fail  error
+}
+
+func (g greetModule) Name() string { return g.name }
+
+func (g greetModule) Register(root *cobra.Command) {
+	root.AddCommand(&cobra.Command{Use: g.name, Run: func(cmd *cobra.Command, args []string) {
+		cmd.Print("hello from " + g.name)
+	}})
+}
+
+func (g greetModule) Init(context.Context) error {
+	*g.trace = append(*g.trace, "init "+g.name)
+	return nil
+}
+
+func (g greetModule) Shutdown(context.Context) error {
+	*g.trace = append(*g.trace, "shutdown "+g.name)
+	return g.fail
+}
+
+func TestManagerModules(t *testing.T) {
+	var trace []string
+	boom := errors.New("boom")
+	mgr := NewManager(nil, WithModules(greetModule{name: "greet", trace: &trace}))
+	if err := mgr.Register(greetModule{name: "weather", trace: &trace, fail: boom}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := mgr.Register(greetModule{name: "greet", trace: &trace}); !errors.Is(err, ErrModuleExists) {
+		t.Fatalf("expected duplicate module error, got %v", err)
+	}
+
+	if err := mgr.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	for _, name := range []string{"greet", "weather"} {
+		if got := run(mgr, "alice", "/"+name); got != "hello from "+name {
+			t.Fatalf("unexpected output for %s: %q", name, got)
+		}
+	}
+	if err := mgr.Shutdown(context.Background()); !errors.Is(err, boom) {
+		t.Fatalf("expected shutdown error, got %v", err)
+	}
+	want := "init greet,init weather,shutdown weather,shutdown greet"
+	if strings.Join(trace, ",") != want {
+		t.Fatalf("unexpected lifecycle trace: %v", trace)
+	}
+}
+
+func TestWithModulesReportsDuplicatesFromInit(t *testing.T) {
+	var trace []string
+	mgr := NewManager(nil,
+		WithModules(greetModule{name: "greet", trace: &trace}),
+		WithModules(greetModule{name: "greet", trace: &trace}),
+	)
+	if err := mgr.Init(context.Background()); !errors.Is(err, ErrModuleExists) {
+		t.Fatalf("expected duplicate module error from Init, got %v", err)
+	}
+	if len(mgr.Modules()) != 1 || len(trace) != 0 {
+		t.Fatalf("duplicate batch should not be registered or initialized: %d modules, trace %v", len(mgr.Modules()), trace)
+	}
+}
@@ -0,0 +1,397 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// 自然语言路由使用的消息键。
+const (
+	MsgNLConfirm   = "nl.confirm"   // 执行前确认，参数：命令行
+	MsgNLCancelled = "nl.cancelled" // 用户取消执行
+	MsgNLNoMatch   = "nl.nomatch"   // 没有匹配的命令
+)
+
+func init() {
+	botcore.Messages.Add("zh", map[string]string{
+		MsgNLConfirm:   "将执行命令：`%s`\n回复“是”确认执行，回复“否”取消。",
+		MsgNLCancelled: "已取消。",
+		MsgNLNoMatch:   "没有找到匹配的命令，请尝试 /help",
+	})
+	botcore.Messages.Add("en", map[string]string{
+		MsgNLConfirm:   "About to run: `%s`\nReply \"yes\" to confirm or \"no\" to cancel.",
+		MsgNLCancelled: "Cancelled.",
+		MsgNLNoMatch:   "No matching command found, try /help",
+	})
+}
+
+// nlArgsParam 为工具参数中承载位置参数的字段名。
+const nlArgsParam = "args"
+
+// defaultNLPrompt 为默认的系统提示词。
+const defaultNLPrompt = "You map chat requests to bot commands. Call exactly one tool that fulfils the user's request, " +
+	"filling positional arguments in \"args\" and flags by name. If no tool fits, reply briefly without calling a tool."
+
+// NLOption 自定义 NewNLRouter 行为。
+type NLOption func(*nlRouter)
+
+// WithNLConfirm 设置是否在执行前向用户确认（默认确认），以及等待确认的时长（默认 2 分钟）。
+func WithNLConfirm(confirm bool, ttl time.Duration) NLOption {
+	return func(r *nlRouter) {
+		r.confirm = confirm
+		if ttl > 0 {
+			r.confirmTTL = ttl
+		}
+	}
+}
+
+// WithNLFallback 设置模型未选择任何命令时的处理器（默认回复 MsgNLNoMatch）。
+func WithNLFallback(fallback botcore.PipelineInvoker) NLOption {
+	return func(r *nlRouter) {
+		r.fallback = fallback
+	}
+}
+
+// WithNLPrompt 自定义系统提示词。
+func WithNLPrompt(prompt string) NLOption {
+	return func(r *nlRouter) {
+		r.prompt = prompt
+	}
+}
+
+// WithNLCallOptions 追加调用模型时使用的 llms.CallOption。
+func WithNLCallOptions(opts ...llms.CallOption) NLOption {
+	return func(r *nlRouter) {
+		r.callOptions = append(r.callOptions, opts...)
+	}
+}
+
+// pendingCommand 为等待用户确认的命令。
+type pendingCommand struct {
+	line     string
+	expireAt time.Time
+}
+
+// nlRouter 将自然语言请求映射为命令。
+type nlRouter struct {
+	mgr         *Manager
+	model       llms.Model
+	confirm     bool
+	confirmTTL  time.Duration
+	fallback    botcore.PipelineInvoker
+	prompt      string
+	callOptions []llms.CallOption
+	now         func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]pendingCommand
+}
+
+// NewNLRouter 创建自然语言命令路由：命令消息直接交给 mgr；其余消息连同命令树（序列化为工具定义）交给模型，
+// 由模型选择命令并填写参数，向用户确认后按普通命令执行（权限、限流等校验照常生效）。
+// 确认状态按会话成员（chatID:senderID）保存在进程内存中。
+// Parameters:
+//   - mgr: 命令管理器
+//   - model: 支持工具调用的 langchaingo 模型
+//   - opts: 可选配置（确认、兜底处理器、提示词）
+//
+// Returns:
+//   - botcore.PipelineInvoker: 自然语言路由处理器
+func NewNLRouter(mgr *Manager, model llms.Model, opts ...NLOption) botcore.PipelineInvoker {
+	r := &nlRouter{
+		mgr:        mgr,
+		model:      model,
+		confirm:    true,
+		confirmTTL: 2 * time.Minute,
+		prompt:     defaultNLPrompt,
+		now:        time.Now,
+		pending:    make(map[string]pendingCommand),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Trigger 实现 botcore.PipelineInvoker。
+func (r *nlRouter) Trigger(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+	snapshot := ctx.Snapshot
+	if r.mgr.parser.ParseSnapshot(snapshot).IsCommand {
+		return r.mgr.Trigger(ctx)
+	}
+
+	key := snapshot.ChatID + ":" + snapshot.SenderID
+	if line, ok := r.takePending(key); ok {
+		switch answer := strings.ToLower(strings.TrimSpace(snapshot.Text)); {
+		case isAffirmative(answer):
+			return r.execute(ctx, line)
+		case isNegative(answer):
+			return reply(botcore.Localize(snapshot, MsgNLCancelled))
+		}
+		// 既非确认也非取消：放弃待确认命令，按新请求处理。
+	}
+
+	out := make(chan botcore.StreamChunk, 1)
+	go func() {
+		defer close(out)
+		defer botcore.RecoverInto(ctx, out)
+
+		line, text, err := r.resolve(ctx.Context(), snapshot)
+		if err != nil {
+			out <- botcore.StreamChunk{Err: err, IsFinal: true}
+			return
+		}
+		if line == "" {
+			in := r.noMatch(ctx, text)
+			for chunk := range in {
+				out <- chunk
+			}
+			return
+		}
+		if !r.confirm {
+			for chunk := range r.execute(ctx, line) {
+				out <- chunk
+			}
+			return
+		}
+		r.mu.Lock()
+		r.pending[key] = pendingCommand{line: line, expireAt: r.now().Add(r.confirmTTL)}
+		r.mu.Unlock()
+		out <- botcore.StreamChunk{Content: botcore.Localize(snapshot, MsgNLConfirm, line), IsFinal: true}
+	}()
+	return out
+}
+
+// takePending 取出并删除未过期的待确认命令。
+func (r *nlRouter) takePending(key string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for k, p := range r.pending {
+		if now.After(p.expireAt) {
+			delete(r.pending, k)
+		}
+	}
+	p, ok := r.pending[key]
+	delete(r.pending, key)
+	return p.line, ok
+}
+
+// execute 以命令行替换消息文本后交给 Manager 执行。
+func (r *nlRouter) execute(ctx botcore.PipelineContext, line string) <-chan botcore.StreamChunk {
+	ctx.Snapshot.Text = line
+	return r.mgr.Trigger(ctx)
+}
+
+// noMatch 处理模型未选择命令的情况：优先交给兜底处理器，其次回复模型文本或默认提示。
+func (r *nlRouter) noMatch(ctx botcore.PipelineContext, text string) <-chan botcore.StreamChunk {
+	if r.fallback != nil {
+		if in := r.fallback.Trigger(ctx); in != nil {
+			return in
+		}
+	}
+	if strings.TrimSpace(text) == "" {
+		text = botcore.Localize(ctx.Snapshot, MsgNLNoMatch)
+	}
+	return reply(text)
+}
+
+// resolve 请求模型选择命令，返回命令行（未选择时为空）与模型的文本回复。
+func (r *nlRouter) resolve(ctx context.Context, snapshot botcore.RequestSnapshot) (string, string, error) {
+	if r.model == nil || !r.mgr.ready() {
+		return "", "", fmt.Errorf("nl router not initialized")
+	}
+	root := r.mgr.newRoot()
+	tools, commands := CommandTools(root)
+	if len(tools) == 0 {
+		return "", "", nil
+	}
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, r.prompt),
+		llms.TextParts(llms.ChatMessageTypeHuman, snapshot.Text),
+	}
+	opts := append([]llms.CallOption{llms.WithTools(tools)}, r.callOptions...)
+	resp, err := r.model.GenerateContent(ctx, messages, opts...)
+	if err != nil {
+		return "", "", err
+	}
+	if resp == nil || len(resp.Choices) == 0 {
+		return "", "", nil
+	}
+	choice := resp.Choices[0]
+	for _, call := range choice.ToolCalls {
+		if call.FunctionCall == nil {
+			continue
+		}
+		cmd, ok := commands[call.FunctionCall.Name]
+		if !ok {
+			continue
+		}
+		line, err := commandLine(r.mgr.parser.prefixes()[0], root, cmd, call.FunctionCall.Arguments)
+		if err != nil {
+			return "", "", err
+		}
+		return line, choice.Content, nil
+	}
+	return "", choice.Content, nil
+}
+
+// CommandTools 将命令树中可执行的命令序列化为模型工具定义（JSON Schema）。
+// 工具名为以下划线连接的命令路径（如 "deploy_prod"），位置参数放在 "args" 数组中，flag 以名称为字段。
+// 隐藏命令与 help 等内置命令不会导出。
+// Parameters:
+//   - root: 根命令
+//
+// Returns:
+//   - []llms.Tool: 工具定义（按名称排序）
+//   - map[string]*cobra.Command: 工具名到命令的映射
+func CommandTools(root *cobra.Command) ([]llms.Tool, map[string]*cobra.Command) {
+	commands := make(map[string]*cobra.Command)
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		for _, sub := range cmd.Commands() {
+			if !sub.IsAvailableCommand() || sub.Name() == "help" {
+				continue
+			}
+			if sub.Runnable() {
+				commands[strings.ReplaceAll(commandPath(root, sub), " ", "_")] = sub
+			}
+			walk(sub)
+		}
+	}
+	walk(root)
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tools := make([]llms.Tool, 0, len(names))
+	for _, name := range names {
+		cmd := commands[name]
+		properties := map[string]any{
+			nlArgsParam: map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Positional arguments. Usage: " + cmd.UseLine(),
+			},
+		}
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			if f.Hidden || f.Name == "help" {
+				return
+			}
+			properties[f.Name] = map[string]any{"type": flagSchemaType(f), "description": f.Usage}
+		})
+		description := cmd.Short
+		if cmd.Long != "" {
+			description = strings.TrimSpace(cmd.Short + "\n" + cmd.Long)
+		}
+		if cmd.Example != "" {
+			description += "\nExamples:\n" + cmd.Example
+		}
+		tools = append(tools, llms.Tool{
+			Type: "function",
+			Function: &llms.FunctionDefinition{
+				Name:        name,
+				Description: description,
+				Parameters:  map[string]any{"type": "object", "properties": properties},
+			},
+		})
+	}
+	return tools, commands
+}
+
+// flagSchemaType 将 pflag 类型映射为 JSON Schema 类型。
+func flagSchemaType(f *pflag.Flag) string {
+	switch f.Value.Type() {
+	case "bool":
+		return "boolean"
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		return "integer"
+	case "float32", "float64":
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// commandLine 将工具调用参数还原为可解析的命令行，参数按需加引号以便 Tokenize 还原，位置参数置于 -- 之后。
+func commandLine(prefix string, root, cmd *cobra.Command, arguments string) (string, error) {
+	params := map[string]any{}
+	if strings.TrimSpace(arguments) != "" {
+		if err := json.Unmarshal([]byte(arguments), &params); err != nil {
+			return "", fmt.Errorf("decode tool arguments: %w", err)
+		}
+	}
+	parts := []string{prefix + commandPath(root, cmd)}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		if name != nlArgsParam && cmd.Flags().Lookup(name) != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := params[name]
+		if b, ok := value.(bool); ok {
+			if b {
+				parts = append(parts, "--"+name)
+			}
+			continue
+		}
+		parts = append(parts, "--"+name+"="+quoteArg(fmt.Sprint(value)))
+	}
+	if args, ok := params[nlArgsParam].([]any); ok && len(args) > 0 {
+		// 关键步骤：以 -- 结束参数解析，模型生成的位置参数（如 "--force"）不会被当作 flag。
+		parts = append(parts, "--")
+		for _, arg := range args {
+			parts = append(parts, quoteArg(fmt.Sprint(arg)))
+		}
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// quoteArg 为含空白、引号或反斜杠的参数加单引号（内部单引号先闭合引号再以反斜杠转义），保证 Tokenize 还原出原值。
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// isAffirmative 判断是否为确认回复。
+func isAffirmative(text string) bool {
+	switch text {
+	case "是", "确认", "好", "好的", "执行", "y", "yes", "ok", "confirm":
+		return true
+	}
+	return false
+}
+
+// isNegative 判断是否为取消回复。
+func isNegative(text string) bool {
+	switch text {
+	case "否", "取消", "不", "不要", "n", "no", "cancel":
+		return true
+	}
+	return false
+}
+
+// reply 返回单条最终回复。
+func reply(text string) <-chan botcore.StreamChunk {
+	out := make(chan botcore.StreamChunk, 1)
+	out <- botcore.StreamChunk{Content: text, IsFinal: true}
+	close(out)
+	return out
+}
@@ -0,0 +1,94 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// toolModel 固定返回一次工具调用，并记录收到的工具定义。
+type toolModel struct {
+	call  *llms.FunctionCall
+	tools []llms.Tool
+}
+
+func (m *toolModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	var opts llms.CallOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
+	m.tools = opts.Tools
+	choice := &llms.ContentChoice{Content: "not sure"}
+	if m.call != nil {
+		choice.ToolCalls = []llms.ToolCall{{Type: "function", FunctionCall: m.call}}
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{choice}}, nil
+}
+
+func (m *toolModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return "", nil
+}
+
+func newEchoCommands() *cobra.Command {
+	root := &cobra.Command{Use: "bot"}
+	echo := &cobra.Command{Use: "echo [text...]", Short: "复读", RunE: func(cmd *cobra.Command, args []string) error {
+		upper, _ := cmd.Flags().GetBool("upper")
+		text := strings.Join(args, "|")
+		if upper {
+			text = strings.ToUpper(text)
+		}
+		cmd.Print(text)
+		return nil
+	}}
+	echo.Flags().Bool("upper", false, "转为大写")
+	root.AddCommand(echo)
+	return root
+}
+
+func TestNLRouterConfirmsAndExecutes(t *testing.T) {
+	model := &toolModel{call: &llms.FunctionCall{Name: "echo", Arguments: `{"args":["hello world","it's"],"upper":true}`}}
+	router := NewNLRouter(NewManager(newEchoCommands), model)
+
+	got := run(router, "u1", "请大声复读 hello world")
+	if !strings.Contains(got, "`/echo --upper -- 'hello world' 'it'\\''s'`") {
+		t.Fatalf("unexpected confirmation: %q", got)
+	}
+	if len(model.tools) != 1 || model.tools[0].Function.Name != "echo" {
+		t.Fatalf("unexpected tools: %+v", model.tools)
+	}
+	if got := run(router, "u1", "是"); got != "HELLO WORLD|IT'S" {
+		t.Fatalf("unexpected execution output: %q", got)
+	}
+
+	run(router, "u1", "再来一次")
+	if got := run(router, "u1", "no"); got != "已取消。" {
+		t.Fatalf("unexpected cancel reply: %q", got)
+	}
+	if got := run(router, "u1", "/echo direct"); got != "direct" {
+		t.Fatalf("expected slash command to bypass model: %q", got)
+	}
+}
+
+func TestNLRouterKeepsFlagLikeArgsPositional(t *testing.T) {
+	model := &toolModel{call: &llms.FunctionCall{Name: "echo", Arguments: `{"args":["--upper","-x"]}`}}
+	router := NewNLRouter(NewManager(newEchoCommands), model, WithNLConfirm(false, 0))
+	if got := run(router, "u1", "复读 --upper -x"); got != "--upper|-x" {
+		t.Fatalf("expected flag-like args to stay positional: %q", got)
+	}
+}
+
+func TestNLRouterFallsBackWithoutToolCall(t *testing.T) {
+	router := NewNLRouter(NewManager(newEchoCommands), &toolModel{}, WithNLConfirm(false, 0))
+	if got := run(router, "u1", "今天天气如何"); got != "not sure" {
+		t.Fatalf("expected model text as fallback: %q", got)
+	}
+
+	model := &toolModel{call: &llms.FunctionCall{Name: "echo", Arguments: `{"args":["hi"]}`}}
+	router = NewNLRouter(NewManager(newEchoCommands), model, WithNLConfirm(false, 0))
+	if got := run(router, "u1", "say hi"); got != "hi" {
+		t.Fatalf("expected direct execution without confirmation: %q", got)
+	}
+}
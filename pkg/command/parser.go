@@ -2,6 +2,9 @@ package command
 
 import (
 	"strings"
+	"unicode"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
 )
 
 // ParseResult 承载文本命令解析后的结构化结果。
@@ -10,36 +13,60 @@ type ParseResult struct {
 	Tokens      []string // 解析后的命令及参数 token（包含命令本身）
 	Raw         string   // 原始输入文本
 	ArgumentRaw string   // 去除命令后的原始参数串
+	Prefix      string   // 命中的命令前缀；提及触发的无前缀命令为空
 }
 
 // Parser 解析企业微信文本内容，判定是否命令并拆分 token。
 type Parser struct {
-	Prefix string // 命令前缀，默认 "/"
+	Prefix   string   // 命令前缀，默认 "/"；Prefixes 非空时忽略
+	Prefixes []string // 可接受的命令前缀集合（如 "/"、"!"、"#"），按顺序匹配
+	// MentionCommands 为 true 时，机器人被 @ 的消息即使没有前缀也按命令解析（如 "@bot deploy prod"），见 ParseSnapshot。
+	MentionCommands bool
+}
+
+// NewParser 创建解析器，未指定前缀时使用默认前缀 "/"。
+// Parameters:
+//   - prefixes: 可接受的命令前缀（可为空）
+//
+// Returns:
+//   - Parser: 解析器
+func NewParser(prefixes ...string) Parser {
+	if len(prefixes) == 0 {
+		return Parser{Prefix: "/"}
+	}
+	return Parser{Prefixes: prefixes}
 }
 
-// NewParser 创建带默认前缀的解析器。
-func NewParser() Parser {
-	return Parser{Prefix: "/"}
+// prefixes 返回生效的前缀集合。
+func (p Parser) prefixes() []string {
+	if len(p.Prefixes) > 0 {
+		return p.Prefixes
+	}
+	if p.Prefix == "" {
+		return []string{"/"}
+	}
+	return []string{p.Prefix}
 }
 
-// Parse 将文本拆解为命令 token。规则参考 Telegram Message.IsCommand。
+// Parse 将文本拆解为命令 token。规则参考 Telegram Message.IsCommand，参数按 Tokenize 的 shell 规则拆分。
 func (p Parser) Parse(text string) ParseResult {
 	trimmed := strings.TrimSpace(text)
 	if trimmed == "" {
 		return ParseResult{Raw: text}
 	}
 
-	prefix := p.Prefix
-	if prefix == "" {
-		prefix = "/"
+	first, rest := trimmed, ""
+	if idx := strings.IndexFunc(trimmed, unicode.IsSpace); idx >= 0 {
+		first, rest = trimmed[:idx], trimmed[idx:]
 	}
-
-	fields := strings.Fields(trimmed)
-	if len(fields) == 0 {
-		return ParseResult{Raw: text}
+	prefix, ok := "", false
+	for _, candidate := range p.prefixes() {
+		if candidate != "" && strings.HasPrefix(first, candidate) && len(first) > len(candidate) {
+			prefix, ok = candidate, true
+			break
+		}
 	}
-	first := fields[0]
-	if !strings.HasPrefix(first, prefix) || len(first) <= len(prefix) {
+	if !ok {
 		return ParseResult{Raw: text}
 	}
 
@@ -51,22 +78,131 @@ func (p Parser) Parse(text string) ParseResult {
 	if commandToken == "" {
 		return ParseResult{Raw: text}
 	}
+	return p.result(text, commandToken, prefix, rest)
+}
 
-	tokens := make([]string, 0, len(fields))
-	tokens = append(tokens, commandToken)
-	if len(fields) > 1 {
-		tokens = append(tokens, fields[1:]...)
+// ParseSnapshot 解析消息快照：先按前缀解析；未命中前缀、启用了 MentionCommands 且机器人被 @ 时，
+// 把首个词视为命令（平台适配层已剥离前导提及，如 "@bot deploy prod" 的 Text 为 "deploy prod"）。
+func (p Parser) ParseSnapshot(snapshot botcore.RequestSnapshot) ParseResult {
+	res := p.Parse(snapshot.Text)
+	if res.IsCommand || !p.MentionCommands || !snapshot.MentionedBot {
+		return res
 	}
-
-	argumentRaw := ""
-	if len(fields) > 1 {
-		argumentRaw = strings.TrimSpace(strings.TrimPrefix(trimmed, first))
+	trimmed := strings.TrimSpace(snapshot.Text)
+	if trimmed == "" {
+		return res
+	}
+	first, rest := trimmed, ""
+	if idx := strings.IndexFunc(trimmed, unicode.IsSpace); idx >= 0 {
+		first, rest = trimmed[:idx], trimmed[idx:]
 	}
+	return p.result(snapshot.Text, first, "", rest)
+}
+
+// result 组装命令解析结果。
+func (p Parser) result(text, commandToken, prefix, rest string) ParseResult {
+	tokens := append([]string{commandToken}, Tokenize(rest)...)
+	argumentRaw := strings.TrimSpace(rest)
 
 	return ParseResult{
 		IsCommand:   true,
 		Tokens:      tokens,
 		Raw:         text,
 		ArgumentRaw: argumentRaw,
+		Prefix:      prefix,
+	}
+}
+
+// Tokenize 按 shell 风格拆分参数：空白分隔，单引号内原样保留，双引号内支持 \" 与 \\ 转义，
+// 其余反斜杠在双引号内原样保留。引号外的反斜杠仅转义空白、引号与反斜杠，其余保持原样（如 C:\foo）。
+// 引号可出现在 token 中间，如 --flag="value with spaces" 得到 --flag=value with spaces。
+// 未闭合的引号按普通字符处理，其后的文本照常拆分，如 what's up 得到 what's 与 up。
+// Parameters:
+//   - text: 参数文本
+//
+// Returns:
+//   - []string: 拆分后的 token（无 token 时为 nil）
+func Tokenize(text string) []string {
+	var (
+		tokens  []string
+		current []rune
+		inToken bool // 当前是否已有 token（用于保留 "" 这样的空参数）
+		quote   rune // 当前所在引号，0 表示不在引号内
+		// 引号开启时的位置与 token 状态，引号未闭合时回退到此处重新拆分。
+		quoteStart   int
+		quoteCurrent int
+		quoteInToken bool
+		literalQuote = -1 // 按普通字符处理的未闭合引号位置
+	)
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current = append(current, r)
+			}
+		case quote == '"':
+			switch {
+			case r == '"':
+				quote = 0
+			case r == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\'):
+				// 双引号内仅 \" 与 \\ 为转义，其余反斜杠原样保留（如 JSON 中的 \n）。
+				i++
+				current = append(current, runes[i])
+			default:
+				current = append(current, r)
+			}
+		case (r == '\'' || r == '"') && i != literalQuote:
+			quote = r
+			quoteStart, quoteCurrent, quoteInToken = i, len(current), inToken
+			inToken = true
+		case r == '\\' && i+1 < len(runes) && isEscapable(runes[i+1]):
+			i++
+			current = append(current, runes[i])
+			inToken = true
+		case unicode.IsSpace(r):
+			if inToken {
+				tokens = append(tokens, string(current))
+				current = current[:0]
+				inToken = false
+			}
+		default:
+			current = append(current, r)
+			inToken = true
+		}
+
+		// 关键步骤：引号直到文本末尾都未闭合时，回到引号处，将其作为普通字符后继续拆分。
+		if i == len(runes)-1 && quote != 0 {
+			current, inToken = current[:quoteCurrent], quoteInToken
+			quote = 0
+			literalQuote = quoteStart
+			i = quoteStart - 1
+		}
+	}
+	if inToken {
+		tokens = append(tokens, string(current))
+	}
+	return tokens
+}
+
+// isEscapable 判断引号外的反斜杠能否转义 r。
+func isEscapable(r rune) bool {
+	return unicode.IsSpace(r) || r == '\'' || r == '"' || r == '\\'
+}
+
+// JoinArgs 为 Tokenize 的逆操作：将参数拼接为命令行文本，参数按需加引号，保证 Tokenize 还原出原参数。
+// Parameters:
+//   - args: 参数列表
+//
+// Returns:
+//   - string: 命令行文本
+func JoinArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteArg(arg)
 	}
+	return strings.Join(quoted, " ")
 }
@@ -0,0 +1,85 @@
+package command
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+)
+
+func TestParserTokenizesQuotedArguments(t *testing.T) {
+	res := NewParser().Parse(`/echo "hello world" --flag="value with spaces" 'it''s' a\ b`)
+	want := []string{"echo", "hello world", "--flag=value with spaces", "its", "a b"}
+	if !res.IsCommand || !reflect.DeepEqual(res.Tokens, want) {
+		t.Fatalf("unexpected tokens: %#v", res.Tokens)
+	}
+	if res.ArgumentRaw != `"hello world" --flag="value with spaces" 'it''s' a\ b` {
+		t.Fatalf("unexpected argument raw: %q", res.ArgumentRaw)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	cases := map[string][]string{
+		``:                         nil,
+		`a  b`:                     {"a", "b"},
+		`"" x`:                     {"", "x"},
+		`'{"k":"v\n"}'`:            {`{"k":"v\n"}`},
+		`"{\"k\":\"v\n\"}"`:        {`{"k":"v\n"}`},
+		`"unterminated value`:      {`"unterminated`, "value"},
+		`what's up`:                {"what's", "up"},
+		`what's "a b"`:             {"what's", "a b"},
+		`C:\Users\bot\file.txt`:    {`C:\Users\bot\file.txt`},
+		`a\ b trailing\`:           {"a b", `trailing\`},
+		"multi\nline\targs":        {"multi", "line", "args"},
+		`--json='{"a": [1, 2]}' z`: {`--json={"a": [1, 2]}`, "z"},
+	}
+	for in, want := range cases {
+		if got := Tokenize(in); !reflect.DeepEqual(got, want) {
+			t.Errorf("Tokenize(%q) = %#v, want %#v", in, got, want)
+		}
+	}
+}
+
+func TestJoinArgsRoundTrip(t *testing.T) {
+	args := []string{"/standup", "", "two words", `it's "quoted"`, `back\slash`}
+	if got := Tokenize(JoinArgs(args)); !reflect.DeepEqual(got, args) {
+		t.Fatalf("round trip = %#v, want %#v", got, args)
+	}
+}
+
+func TestParserMultiplePrefixes(t *testing.T) {
+	p := NewParser("/", "!", "#")
+	for _, text := range []string{"/deploy prod", "!deploy prod", "#deploy prod"} {
+		res := p.Parse(text)
+		if !res.IsCommand || res.Tokens[0] != "deploy" || res.Prefix != text[:1] {
+			t.Fatalf("unexpected parse of %q: %+v", text, res)
+		}
+	}
+	if p.Parse("deploy prod").IsCommand || p.Parse("!").IsCommand {
+		t.Fatalf("expected non-command")
+	}
+}
+
+func TestParserMentionCommands(t *testing.T) {
+	p := NewParser()
+	p.MentionCommands = true
+	res := p.ParseSnapshot(botcore.RequestSnapshot{Text: "deploy prod", MentionedBot: true})
+	if !res.IsCommand || !reflect.DeepEqual(res.Tokens, []string{"deploy", "prod"}) || res.Prefix != "" {
+		t.Fatalf("unexpected mention parse: %+v", res)
+	}
+	if p.ParseSnapshot(botcore.RequestSnapshot{Text: "deploy prod"}).IsCommand {
+		t.Fatalf("expected plain message without mention to stay non-command")
+	}
+
+	mgr := NewManager(newOpsCommands, WithParser(p))
+	match := mgr.Matcher()
+	if !match(botcore.RequestSnapshot{Text: "ping", MentionedBot: true}) || !match(botcore.RequestSnapshot{Text: "/unknown"}) {
+		t.Fatalf("expected mention command and prefixed text to match")
+	}
+	if match(botcore.RequestSnapshot{Text: "你好", MentionedBot: true}) {
+		t.Fatalf("expected unknown bare word not to match")
+	}
+	if got := run(mgr, "u1", "/ping"); got != "pong" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+}
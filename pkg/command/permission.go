@@ -0,0 +1,158 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
+)
+
+// AnnotationRoles 是声明命令所需角色的 Cobra annotation 键，值为逗号分隔的角色列表。
+// 子命令未声明时继承最近的父命令声明；均未声明的命令对所有人开放。
+const AnnotationRoles = "imbot.roles"
+
+// RoleProvider 返回消息发送者拥有的角色，用于命令权限校验。
+type RoleProvider interface {
+	// Roles 返回 snapshot 发送者的角色列表。
+	Roles(ctx context.Context, snapshot botcore.RequestSnapshot) ([]string, error)
+}
+
+// RoleProviderFunc 将函数适配为 RoleProvider，便于对接数据库、目录服务等外部存储。
+type RoleProviderFunc func(ctx context.Context, snapshot botcore.RequestSnapshot) ([]string, error)
+
+// Roles 实现 RoleProvider 接口。
+func (f RoleProviderFunc) Roles(ctx context.Context, snapshot botcore.RequestSnapshot) ([]string, error) {
+	return f(ctx, snapshot)
+}
+
+// StaticRoles 是基于静态配置的 RoleProvider：键为发送者 ID，值为其角色列表。
+type StaticRoles map[string][]string
+
+// Roles 实现 RoleProvider 接口。
+func (s StaticRoles) Roles(ctx context.Context, snapshot botcore.RequestSnapshot) ([]string, error) {
+	return s[snapshot.SenderID], nil
+}
+
+// MemoryRoleStore 是可在运行时授权与撤销的进程内 RoleProvider，并发安全。
+type MemoryRoleStore struct {
+	mu    sync.RWMutex
+	roles map[string]map[string]struct{}
+}
+
+// NewMemoryRoleStore 创建进程内角色存储。
+func NewMemoryRoleStore() *MemoryRoleStore {
+	return &MemoryRoleStore{roles: make(map[string]map[string]struct{})}
+}
+
+// Grant 为用户授予角色。
+func (s *MemoryRoleStore) Grant(userID string, roles ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set, ok := s.roles[userID]
+	if !ok {
+		set = make(map[string]struct{})
+		s.roles[userID] = set
+	}
+	for _, role := range roles {
+		set[role] = struct{}{}
+	}
+}
+
+// Revoke 撤销用户的角色。
+func (s *MemoryRoleStore) Revoke(userID string, roles ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, role := range roles {
+		delete(s.roles[userID], role)
+	}
+	if len(s.roles[userID]) == 0 {
+		delete(s.roles, userID)
+	}
+}
+
+// Roles 实现 RoleProvider 接口。
+func (s *MemoryRoleStore) Roles(ctx context.Context, snapshot botcore.RequestSnapshot) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	roles := make([]string, 0, len(s.roles[snapshot.SenderID]))
+	for role := range s.roles[snapshot.SenderID] {
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// RequireRoles 声明执行 cmd 需要的角色（拥有其中任意一个即可），返回 cmd 以便链式构建命令树。
+// Parameters:
+//   - cmd: Cobra 命令
+//   - roles: 允许的角色
+//
+// Returns:
+//   - *cobra.Command: 传入的命令
+func RequireRoles(cmd *cobra.Command, roles ...string) *cobra.Command {
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[AnnotationRoles] = strings.Join(roles, ",")
+	return cmd
+}
+
+// RequiredRoles 返回执行 cmd 需要的角色：取 cmd 或其最近父命令的 AnnotationRoles 声明，均未声明时返回 nil。
+func RequiredRoles(cmd *cobra.Command) []string {
+	for c := cmd; c != nil; c = c.Parent() {
+		value, ok := c.Annotations[AnnotationRoles]
+		if !ok {
+			continue
+		}
+		var roles []string
+		for _, role := range strings.Split(value, ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				roles = append(roles, role)
+			}
+		}
+		return roles
+	}
+	return nil
+}
+
+// hasAnyRole 判断 granted 是否包含 required 中的任意一个角色。
+func hasAnyRole(granted, required []string) bool {
+	for _, want := range required {
+		for _, have := range granted {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// authorize 校验发送者是否有权执行 args 指向的命令。
+// 命令声明了角色但未配置 RoleProvider 或查询失败时拒绝执行。
+// Returns:
+//   - []string: 命令所需角色（无权限时用于提示）
+//   - error: 权限不足时返回 ErrPermissionDenied
+func (m *Manager) authorize(ctx context.Context, root *cobra.Command, args []string, snapshot botcore.RequestSnapshot) ([]string, error) {
+	target, _, err := root.Find(args)
+	if err != nil {
+		// 未知命令交给 Cobra 报错。
+		return nil, nil
+	}
+	required := RequiredRoles(target)
+	if len(required) == 0 {
+		return nil, nil
+	}
+	if m.roles == nil {
+		return required, ErrPermissionDenied
+	}
+	granted, err := m.roles.Roles(ctx, snapshot)
+	if err != nil {
+		m.logf("Role lookup error for user %s: %v", snapshot.SenderID, err)
+		return required, ErrPermissionDenied
+	}
+	if !hasAnyRole(granted, required) {
+		return required, ErrPermissionDenied
+	}
+	return nil, nil
+}
@@ -0,0 +1,86 @@
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
+)
+
+func newOpsCommands() *cobra.Command {
+	root := &cobra.Command{Use: "bot"}
+	deploy := RequireRoles(&cobra.Command{Use: "deploy", RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.Print("deploying")
+		return nil
+	}}, "ops")
+	deploy.AddCommand(&cobra.Command{Use: "prod", RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.Print("deploying prod")
+		return nil
+	}})
+	root.AddCommand(deploy, &cobra.Command{Use: "ping", RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.Print("pong")
+		return nil
+	}})
+	return root
+}
+
+// run 以 sender 身份触发 invoker 并返回输出文本。
+func run(p botcore.PipelineInvoker, sender, text string) string {
+	var out strings.Builder
+	for chunk := range p.Trigger(botcore.PipelineContext{Snapshot: botcore.RequestSnapshot{SenderID: sender, Text: text}}) {
+		out.WriteString(chunk.Content)
+	}
+	return out.String()
+}
+
+func TestManagerEnforcesRoles(t *testing.T) {
+	store := NewMemoryRoleStore()
+	store.Grant("alice", "ops")
+	mgr := NewManager(newOpsCommands, WithRoleProvider(store))
+
+	if got := run(mgr, "alice", "/deploy"); got != "deploying" {
+		t.Fatalf("expected ops user to deploy, got %q", got)
+	}
+	if got := run(mgr, "alice", "/deploy prod"); got != "deploying prod" {
+		t.Fatalf("expected subcommand to inherit roles, got %q", got)
+	}
+	if got := run(mgr, "bob", "/deploy prod"); !strings.Contains(got, "权限不足") || !strings.Contains(got, "ops") {
+		t.Fatalf("expected permission denied, got %q", got)
+	}
+	if got := run(mgr, "bob", "/ping"); got != "pong" {
+		t.Fatalf("expected public command to run, got %q", got)
+	}
+
+	store.Revoke("alice", "ops")
+	if got := run(mgr, "alice", "/deploy"); !strings.Contains(got, "权限不足") {
+		t.Fatalf("expected revoked user to be denied, got %q", got)
+	}
+}
+
+func TestManagerDeniesWithoutRoleProvider(t *testing.T) {
+	mgr := NewManager(newOpsCommands, WithPermissionDeniedMessage("no"))
+	if got := run(mgr, "alice", "/deploy"); got != "no" {
+		t.Fatalf("expected custom denial, got %q", got)
+	}
+
+	mgr = NewManager(newOpsCommands, WithRoleProvider(StaticRoles{"alice": {"ops"}}))
+	if got := run(mgr, "alice", "/deploy"); got != "deploying" {
+		t.Fatalf("expected static role to allow, got %q", got)
+	}
+}
+
+func TestManagerLocalizesBuiltInMessages(t *testing.T) {
+	mgr := NewManager(newOpsCommands)
+	en := botcore.RequestSnapshot{SenderID: "bob", Text: "/deploy", Metadata: map[string]string{botcore.MetaLanguage: "en"}}
+	var out strings.Builder
+	for chunk := range mgr.Trigger(botcore.PipelineContext{Snapshot: en}) {
+		out.WriteString(chunk.Content)
+	}
+	if got := out.String(); got != "⛔ Permission denied: this command is restricted to ops" {
+		t.Fatalf("unexpected english denial: %q", got)
+	}
+	if got := run(mgr, "bob", "hello"); !strings.HasPrefix(got, "未识别的命令") {
+		t.Fatalf("expected chinese fallback, got %q", got)
+	}
+}
@@ -0,0 +1,86 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/spf13/cobra"
+)
+
+// RateLimitRule 描述单个命令的限额：每个用户在 Window 内最多执行 Limit 次。
+// Limit 为 1 即冷却时间（cooldown）；Limit <= 0 或 Window <= 0 表示不限制。
+type RateLimitRule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// RateLimitPolicy 为 Manager 的命令限流策略，按“用户 + 命令”计数，与路由层的 botcore.RateLimit 相互独立。
+type RateLimitPolicy struct {
+	// Default 为未在 Commands 中列出的命令的限额（零值表示不限制）。
+	Default RateLimitRule
+	// Commands 按命令路径（不含根命令，如 "ai"、"report daily"）覆盖限额；子命令未列出时沿用最近父命令的规则。
+	Commands map[string]RateLimitRule
+	// Store 为限流存储；为 nil 时使用 Manager 独立的 botcore.MemoryRateLimitStore。
+	// 多副本部署时应使用共享存储（如 botcore.RedisRateLimitStore）。
+	Store botcore.RateLimitStore
+	// Reply 自定义被限流时的回复；为 nil 时按快照语言回复 MsgCommandRateLimited，返回空字符串表示静默丢弃。
+	Reply func(command string, retryAfter time.Duration) string
+}
+
+// WithRateLimit 启用按用户、按命令的限流与冷却，保护 /ai、/report 等高成本命令。
+// 存储出错时放行。
+func WithRateLimit(policy RateLimitPolicy) ManagerOption {
+	return func(m *Manager) {
+		if policy.Store == nil {
+			policy.Store = botcore.NewMemoryRateLimitStore()
+		}
+		m.rateLimit = &policy
+	}
+}
+
+// commandPath 返回不含根命令的命令路径，如 "report daily"。
+func commandPath(root, cmd *cobra.Command) string {
+	return strings.TrimPrefix(strings.TrimPrefix(cmd.CommandPath(), root.Name()), " ")
+}
+
+// rule 返回 path 对应的限额：依次查找命令自身与父命令，均未配置时使用 Default。
+func (p *RateLimitPolicy) rule(path string) RateLimitRule {
+	for path != "" {
+		if rule, ok := p.Commands[path]; ok {
+			return rule
+		}
+		idx := strings.LastIndex(path, " ")
+		if idx < 0 {
+			break
+		}
+		path = path[:idx]
+	}
+	return p.Default
+}
+
+// throttle 按限流策略检查 args 指向的命令，被限流时返回回复文本与 true。
+func (m *Manager) throttle(ctx context.Context, root *cobra.Command, args []string, snapshot botcore.RequestSnapshot) (string, bool) {
+	policy := m.rateLimit
+	if policy == nil || snapshot.SenderID == "" {
+		return "", false
+	}
+	target, _, err := root.Find(args)
+	if err != nil || target == root {
+		return "", false
+	}
+	path := commandPath(root, target)
+	rule := policy.rule(path)
+	if rule.Limit <= 0 || rule.Window <= 0 {
+		return "", false
+	}
+	allowed, retryAfter, err := policy.Store.Take(ctx, "cmd:"+path+":"+snapshot.SenderID, rule.Limit, rule.Window)
+	if err != nil || allowed {
+		return "", false
+	}
+	if policy.Reply != nil {
+		return policy.Reply(path, retryAfter), true
+	}
+	return botcore.Localize(snapshot, MsgCommandRateLimited, path, botcore.RetrySeconds(retryAfter)), true
+}
@@ -0,0 +1,45 @@
+package command
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManagerRateLimitsPerUserAndCommand(t *testing.T) {
+	store := NewMemoryRoleStore()
+	store.Grant("alice", "ops")
+	mgr := NewManager(newOpsCommands, WithRoleProvider(store), WithRateLimit(RateLimitPolicy{
+		Commands: map[string]RateLimitRule{"deploy": {Limit: 1, Window: time.Hour}},
+	}))
+
+	if got := run(mgr, "alice", "/deploy"); got != "deploying" {
+		t.Fatalf("expected first deploy to run, got %q", got)
+	}
+	// 子命令沿用父命令的冷却规则，但按自身路径独立计数。
+	if got := run(mgr, "alice", "/deploy prod"); got != "deploying prod" {
+		t.Fatalf("expected subcommand to have its own counter, got %q", got)
+	}
+	if got := run(mgr, "alice", "/deploy"); !strings.Contains(got, "/deploy") || !strings.Contains(got, "过于频繁") {
+		t.Fatalf("expected cooldown reply, got %q", got)
+	}
+	for i := 0; i < 3; i++ {
+		if got := run(mgr, "alice", "/ping"); got != "pong" {
+			t.Fatalf("expected unlimited command to run, got %q", got)
+		}
+	}
+}
+
+func TestManagerRateLimitDefaultRuleAndReply(t *testing.T) {
+	mgr := NewManager(newOpsCommands, WithRateLimit(RateLimitPolicy{
+		Default: RateLimitRule{Limit: 1, Window: time.Hour},
+		Reply:   func(command string, _ time.Duration) string { return "slow down: " + command },
+	}))
+	run(mgr, "u1", "/ping")
+	if got := run(mgr, "u1", "/ping"); got != "slow down: ping" {
+		t.Fatalf("unexpected reply: %q", got)
+	}
+	if got := run(mgr, "u2", "/ping"); got != "pong" {
+		t.Fatalf("expected other user to be unaffected, got %q", got)
+	}
+}
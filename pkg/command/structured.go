@@ -0,0 +1,408 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// structuredAttempts 为结构化输出的最大尝试次数（首次调用 + 解析失败后的重试）。
+const structuredAttempts = 3
+
+// structuredPrompt 附加在提示词之后，要求模型按 Schema 只输出 JSON。
+const structuredPrompt = `
+
+请只输出一个符合以下 JSON Schema 的 JSON 值，不要包含解释或 Markdown 代码块：
+%s`
+
+// structuredRetryPrompt 在输出无效时反馈错误，要求模型重新输出。
+const structuredRetryPrompt = "上面的输出无效：%v。请修正后只输出符合 JSON Schema 的 JSON。"
+
+// ErrInvalidStructuredOutput 表示模型多次输出均无法解析为目标结构。
+var ErrInvalidStructuredOutput = errors.New("invalid structured output")
+
+// AskStructured 以结构化输出模式调用注入的模型，并把结果解析到 v。
+// 提示词后附加由 v 的类型推导的 JSON Schema（见 JSONSchema），同时开启 llms.WithJSONMode（不支持的模型会忽略）；
+// 输出不是合法 JSON 或不符合 Schema 时把错误反馈给模型重试，最多尝试 3 次。
+// Parameters:
+//   - c: 调用上下文（通常为 cmd.Context()）
+//   - prompt: 提示词
+//   - v: 指向目标结构的非 nil 指针
+//   - opts: 模型调用选项
+//
+// Returns:
+//   - error: 未注入模型、调用失败或多次输出均无效（ErrInvalidStructuredOutput）时返回
+func (ctx *ExecutionContext) AskStructured(c context.Context, prompt string, v any, opts ...llms.CallOption) error {
+	model := ctx.LLM()
+	if model == nil {
+		return ErrLLMNotConfigured
+	}
+	return GenerateStructured(c, model, prompt, v, opts...)
+}
+
+// GenerateStructured 与 ExecutionContext.AskStructured 相同，但直接使用给定模型，供命令之外的处理器复用。
+func GenerateStructured(ctx context.Context, model llms.Model, prompt string, v any, opts ...llms.CallOption) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("structured output: target must be a non-nil pointer, got %T", v)
+	}
+	schema := JSONSchema(v)
+	encoded, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return fmt.Errorf("structured output: %w", err)
+	}
+
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, prompt+fmt.Sprintf(structuredPrompt, encoded)),
+	}
+	opts = append([]llms.CallOption{llms.WithJSONMode()}, opts...)
+	var lastErr error
+	for range structuredAttempts {
+		resp, err := model.GenerateContent(ctx, messages, opts...)
+		if err != nil {
+			return err
+		}
+		if len(resp.Choices) == 0 {
+			return fmt.Errorf("structured output: empty response")
+		}
+		output := resp.Choices[0].Content
+		if lastErr = decodeStructured(output, schema, v); lastErr == nil {
+			return nil
+		}
+		// 关键步骤：把无效输出与错误原因放回对话，让模型在上下文中修正。
+		messages = append(messages,
+			llms.TextParts(llms.ChatMessageTypeAI, output),
+			llms.TextParts(llms.ChatMessageTypeHuman, fmt.Sprintf(structuredRetryPrompt, lastErr)),
+		)
+	}
+	return fmt.Errorf("%w after %d attempts: %v", ErrInvalidStructuredOutput, structuredAttempts, lastErr)
+}
+
+// decodeStructured 去除代码块包裹，按 Schema 校验后解析到 v。
+func decodeStructured(output string, schema map[string]any, v any) error {
+	output = strings.TrimSpace(output)
+	if strings.HasPrefix(output, "```") {
+		output = strings.TrimPrefix(output, "```json")
+		output = strings.TrimPrefix(output, "```")
+		output = strings.TrimSuffix(strings.TrimSpace(output), "```")
+	}
+	var raw any
+	if err := json.Unmarshal([]byte(output), &raw); err != nil {
+		return fmt.Errorf("not valid JSON: %w", err)
+	}
+	if err := validateSchema(schema, raw, "$"); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(output), v); err != nil {
+		return fmt.Errorf("cannot decode: %w", err)
+	}
+	return nil
+}
+
+// JSONSchema 由 v 的 Go 类型推导 JSON Schema：字段名取 json 标签，未标记 omitempty 的字段为必填，
+// 指针字段可省略或为 null，`desc:"..."` 标签作为字段说明，`enum:"a,b"` 标签限定字符串取值；time.Time 为 date-time 字符串。
+// 匿名嵌入的结构体字段按 encoding/json 的规则提升到外层；自引用类型（如树节点）以 $defs / $ref 表示。
+func JSONSchema(v any) map[string]any {
+	b := schemaBuilder{building: make(map[reflect.Type]bool), recursive: make(map[reflect.Type]bool), defs: make(map[string]any)}
+	schema := b.schemaFor(reflect.TypeOf(v))
+	if len(b.defs) > 0 {
+		schema = maps.Clone(schema)
+		schema["$defs"] = b.defs
+	}
+	return schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaBuilder 生成 Schema，并记录正在展开的具名类型以识别自引用。
+type schemaBuilder struct {
+	building  map[reflect.Type]bool // 正在展开的具名类型
+	recursive map[reflect.Type]bool // 被自身（间接）引用的具名类型
+	defs      map[string]any        // 自引用类型的定义
+}
+
+// schemaRef 返回指向 $defs 中类型定义的引用。
+func schemaRef(t reflect.Type) map[string]any {
+	return map[string]any{"$ref": "#/$defs/" + t.String()}
+}
+
+// schemaFor 递归生成类型的 Schema。
+func (b *schemaBuilder) schemaFor(t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		if t.Name() == "" {
+			return b.compositeSchema(t)
+		}
+		// 关键步骤：具名类型在展开过程中再次出现即为自引用，改为引用定义，避免无限递归。
+		if b.building[t] {
+			b.recursive[t] = true
+			return schemaRef(t)
+		}
+		b.building[t] = true
+		schema := b.compositeSchema(t)
+		delete(b.building, t)
+		if b.recursive[t] {
+			b.defs[t.String()] = schema
+			return schemaRef(t)
+		}
+		return schema
+	default:
+		return map[string]any{}
+	}
+}
+
+// compositeSchema 生成数组、映射与结构体的 Schema。
+func (b *schemaBuilder) compositeSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schemaFor(t.Elem())}
+	default:
+		return b.structSchema(t)
+	}
+}
+
+// structSchema 生成结构体的对象 Schema。
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := make([]string, 0)
+	for _, f := range jsonFields(t) {
+		prop := b.schemaFor(f.typ)
+		if _, ok := prop["$ref"]; ok {
+			prop = maps.Clone(prop)
+		}
+		if desc := f.tag.Get("desc"); desc != "" {
+			prop["description"] = desc
+		}
+		if enum := f.tag.Get("enum"); enum != "" {
+			prop["enum"] = strings.Split(enum, ",")
+		}
+		if f.typ.Kind() == reflect.Pointer {
+			prop["nullable"] = true
+		}
+		properties[f.name] = prop
+		if !f.omitempty {
+			required = append(required, f.name)
+		}
+	}
+	return map[string]any{"type": "object", "properties": properties, "required": required}
+}
+
+// jsonField 为结构体（含提升的嵌入字段）在 JSON 中的一个字段。
+type jsonField struct {
+	name      string
+	typ       reflect.Type
+	tag       reflect.StructTag
+	omitempty bool
+	tagged    bool
+}
+
+// jsonFields 按 encoding/json 的规则列出字段：未命名的匿名结构体字段提升到外层，
+// 同名时浅层优先，同层时带 json 名称标签者优先，仍有歧义的名称整体忽略。
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	seen := make(map[string]bool)             // 已由更浅层决定的名称
+	visited := map[reflect.Type]bool{t: true} // 已展开的嵌入类型，防止嵌入环
+	level := []reflect.Type{t}
+	for len(level) > 0 {
+		var next []reflect.Type
+		candidates := make(map[string][]jsonField)
+		var order []string
+		for _, st := range level {
+			for i := range st.NumField() {
+				sf := st.Field(i)
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, options, _ := strings.Cut(tag, ",")
+				ft := sf.Type
+				if sf.Anonymous {
+					et := ft
+					if et.Kind() == reflect.Pointer {
+						et = et.Elem()
+					}
+					if name == "" && et.Kind() == reflect.Struct {
+						if !visited[et] {
+							visited[et] = true
+							next = append(next, et)
+						}
+						continue
+					}
+					if !sf.IsExported() {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+				tagged := name != ""
+				if !tagged {
+					name = sf.Name
+				}
+				if seen[name] {
+					continue
+				}
+				if _, ok := candidates[name]; !ok {
+					order = append(order, name)
+				}
+				candidates[name] = append(candidates[name], jsonField{
+					name:      name,
+					typ:       ft,
+					tag:       sf.Tag,
+					omitempty: strings.Contains(options, "omitempty") || strings.Contains(options, "omitzero") || ft.Kind() == reflect.Pointer,
+					tagged:    tagged,
+				})
+			}
+		}
+		for _, name := range order {
+			seen[name] = true
+			if f, ok := dominantField(candidates[name]); ok {
+				fields = append(fields, f)
+			}
+		}
+		level = next
+	}
+	return fields
+}
+
+// dominantField 在同层同名字段中选出生效者：唯一字段，或唯一带名称标签的字段。
+func dominantField(candidates []jsonField) (jsonField, bool) {
+	if len(candidates) == 1 {
+		return candidates[0], true
+	}
+	var tagged []jsonField
+	for _, f := range candidates {
+		if f.tagged {
+			tagged = append(tagged, f)
+		}
+	}
+	if len(tagged) == 1 {
+		return tagged[0], true
+	}
+	return jsonField{}, false
+}
+
+// validateSchema 按 JSONSchema 生成的子集（type / properties / required / items / additionalProperties / enum / nullable / $ref）校验值。
+func validateSchema(schema map[string]any, value any, path string) error {
+	defs, _ := schema["$defs"].(map[string]any)
+	return schemaValidator{defs: defs}.validate(schema, value, path)
+}
+
+// schemaValidator 持有根 Schema 的 $defs，用于解析 $ref。
+type schemaValidator struct {
+	defs map[string]any
+}
+
+// validate 递归校验值。
+func (v schemaValidator) validate(schema map[string]any, value any, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		def, ok := v.defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: unresolved schema reference %q", path, ref)
+		}
+		if nullable, _ := schema["nullable"].(bool); nullable && value == nil {
+			return nil
+		}
+		schema = def
+	}
+	typ, _ := schema["type"].(string)
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable || typ == "" {
+			return nil
+		}
+		return fmt.Errorf("%s: expected %s, got null", path, typ)
+	}
+	switch typ {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string", path)
+		}
+		if enum, ok := schema["enum"].([]string); ok && !slices.Contains(enum, s) {
+			return fmt.Errorf("%s: %q is not one of %v", path, s, enum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean", path)
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: expected integer", path)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected number", path)
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		itemSchema, _ := schema["items"].(map[string]any)
+		for i, item := range items {
+			if err := v.validate(itemSchema, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		required, _ := schema["required"].([]string)
+		for _, name := range required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required field %q", path, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		additional, _ := schema["additionalProperties"].(map[string]any)
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			prop, ok := properties[key].(map[string]any)
+			if !ok {
+				prop = additional
+			}
+			if prop == nil {
+				continue
+			}
+			if err := v.validate(prop, obj[key], path+"."+key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// scriptedJSONModel 依次返回预设输出，并记录每次调用的消息数与是否开启 JSON 模式。
+type scriptedJSONModel struct {
+	outputs  []string
+	calls    []int
+	jsonMode bool
+	prompt   string
+}
+
+func (m *scriptedJSONModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	opts := llms.CallOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	m.jsonMode = opts.JSONMode
+	m.prompt = messages[0].Parts[0].(llms.TextContent).Text
+	m.calls = append(m.calls, len(messages))
+	output := m.outputs[min(len(m.calls)-1, len(m.outputs)-1)]
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: output}}}, nil
+}
+
+func (m *scriptedJSONModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return "", nil
+}
+
+type ticket struct {
+	Title    string   `json:"title" desc:"工单标题"`
+	Priority string   `json:"priority" enum:"low,high"`
+	Hours    int      `json:"hours"`
+	Tags     []string `json:"tags,omitempty"`
+	Owner    *string  `json:"owner"`
+}
+
+func TestAskStructuredRetriesUntilValid(t *testing.T) {
+	model := &scriptedJSONModel{outputs: []string{
+		"sure! here it is",
+		`{"title":"db down","priority":"urgent","hours":2}`,
+		"```json\n{\"title\":\"db down\",\"priority\":\"high\",\"hours\":2,\"owner\":null}\n```",
+	}}
+	execCtx := &ExecutionContext{llm: model}
+
+	var got ticket
+	if err := execCtx.AskStructured(context.Background(), "提取工单", &got); err != nil {
+		t.Fatalf("AskStructured: %v", err)
+	}
+	if got.Title != "db down" || got.Priority != "high" || got.Hours != 2 || got.Owner != nil {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+	// 每次重试追加 (无效输出, 错误反馈) 两条消息。
+	if len(model.calls) != 3 || model.calls[2] != 5 || !model.jsonMode {
+		t.Fatalf("unexpected calls %v jsonMode=%v", model.calls, model.jsonMode)
+	}
+	if !strings.Contains(model.prompt, `"description": "工单标题"`) || !strings.Contains(model.prompt, `"required": [`) {
+		t.Fatalf("schema missing from prompt: %s", model.prompt)
+	}
+}
+
+func TestAskStructuredGivesUp(t *testing.T) {
+	model := &scriptedJSONModel{outputs: []string{`{"title":"x","priority":"low","hours":1.5}`}}
+	var got ticket
+	err := GenerateStructured(context.Background(), model, "提取工单", &got)
+	if !errors.Is(err, ErrInvalidStructuredOutput) || !strings.Contains(err.Error(), "$.hours: expected integer") {
+		t.Fatalf("expected ErrInvalidStructuredOutput, got %v", err)
+	}
+	if len(model.calls) != structuredAttempts {
+		t.Fatalf("expected %d attempts, got %d", structuredAttempts, len(model.calls))
+	}
+	if err := GenerateStructured(context.Background(), model, "x", got); err == nil {
+		t.Fatal("expected non-pointer target to be rejected")
+	}
+	var execCtx *ExecutionContext
+	if err := execCtx.AskStructured(context.Background(), "x", &got); !errors.Is(err, ErrLLMNotConfigured) {
+		t.Fatalf("expected ErrLLMNotConfigured, got %v", err)
+	}
+}
+
+type treeNode struct {
+	Name     string     `json:"name"`
+	Children []treeNode `json:"children"`
+	Parent   *treeNode  `json:"parent"`
+}
+
+type auditFields struct {
+	CreatedBy string `json:"created_by"`
+	ID        string `json:"id"`
+}
+
+type taggedID struct {
+	Key string `json:"id"`
+}
+
+type embeddingTicket struct {
+	auditFields
+	*taggedID
+	Title string `json:"title"`
+}
+
+func TestJSONSchemaHandlesRecursionAndEmbedding(t *testing.T) {
+	schema := JSONSchema(&treeNode{})
+	if schema["$ref"] != "#/$defs/command.treeNode" {
+		t.Fatalf("expected root reference, got %v", schema)
+	}
+	if err := decodeStructured(`{"name":"a","children":[{"name":"b","children":[]}]}`, schema, &treeNode{}); err != nil {
+		t.Fatalf("valid tree rejected: %v", err)
+	}
+	if err := decodeStructured(`{"name":"a","children":[{"children":[]}]}`, schema, &treeNode{}); err == nil ||
+		!strings.Contains(err.Error(), `$.children[0]: missing required field "name"`) {
+		t.Fatalf("expected nested required error, got %v", err)
+	}
+
+	// 同层的 auditFields.ID 与 taggedID.Key 都叫 "id"，均带名称标签，按 encoding/json 规则整体忽略。
+	props := JSONSchema(&embeddingTicket{})["properties"].(map[string]any)
+	if _, ok := props["created_by"]; !ok || len(props) != 2 {
+		t.Fatalf("embedded fields not flattened like encoding/json: %v", props)
+	}
+	if _, ok := props["id"]; ok {
+		t.Fatalf("ambiguous field should be dropped: %v", props)
+	}
+}
@@ -0,0 +1,141 @@
+// Package feedback 提供 botcore.FeedbackSink 的持久化实现（JSON Lines 文件、Webhook、SQL）。
+package feedback
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/internal/sqlutil"
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+)
+
+// FileSink 以 JSON Lines 格式追加写入反馈记录。
+type FileSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileSink 创建文件反馈存储（文件不存在时自动创建）。
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+// Record 实现 botcore.FeedbackSink 接口。
+func (s *FileSink) Record(ctx context.Context, record botcore.FeedbackRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal feedback: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open feedback file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write feedback: %w", err)
+	}
+	return nil
+}
+
+// WebhookSink 将反馈记录以 JSON POST 到外部地址。
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink 创建 Webhook 反馈存储。
+// Parameters:
+//   - url: 接收地址
+//   - client: HTTP 客户端；为 nil 时使用 10 秒超时的默认客户端
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &WebhookSink{url: url, client: client}
+}
+
+// Record 实现 botcore.FeedbackSink 接口，非 2xx 响应视为失败。
+func (s *WebhookSink) Record(ctx context.Context, record botcore.FeedbackRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal feedback: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build feedback request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post feedback: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("post feedback: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sqlMigrations 为反馈表的版本化迁移（见 sqlutil.Migrate）。
+var sqlMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS {table} (
+		feedback_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		comment TEXT,
+		reasons TEXT,
+		chat_id TEXT,
+		sender_id TEXT,
+		question TEXT,
+		answer TEXT,
+		created_at TEXT NOT NULL
+	)`,
+}
+
+// SQLSink 将反馈记录写入 database/sql 数据库（需调用方导入驱动）。
+type SQLSink struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLSink 创建 SQL 反馈存储并自动建表。
+// Parameters:
+//   - db: 已打开的数据库连接（使用 ? 占位符的驱动，如 sqlite、mysql）
+//   - table: 表名；为空时使用 "feedback"
+//
+// Returns:
+//   - *SQLSink: 反馈存储
+//   - error: 表名非法或建表失败时返回
+func NewSQLSink(db *sql.DB, table string) (*SQLSink, error) {
+	table, err := sqlutil.TableName(table, "feedback")
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlutil.Migrate(context.Background(), db, sqlutil.SQLite, table, sqlMigrations); err != nil {
+		return nil, fmt.Errorf("create feedback table: %w", err)
+	}
+	return &SQLSink{db: db, table: table}, nil
+}
+
+// Record 实现 botcore.FeedbackSink 接口。
+func (s *SQLSink) Record(ctx context.Context, record botcore.FeedbackRecord) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO `+s.table+` (feedback_id, kind, comment, reasons, chat_id, sender_id, question, answer, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.ID, string(record.Kind), record.Comment, strings.Join(record.Reasons, ","),
+		record.ChatID, record.SenderID, record.Question, record.Answer, record.At.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("insert feedback: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package feedback
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	_ "modernc.org/sqlite"
+)
+
+func sampleRecord() botcore.FeedbackRecord {
+	return botcore.FeedbackRecord{
+		Feedback: botcore.Feedback{ID: "m1", Kind: botcore.FeedbackNegative, Comment: "wrong", Reasons: []string{"1", "3"}},
+		ChatID:   "c1",
+		SenderID: "u1",
+		Question: "q",
+		Answer:   "a",
+		At:       time.Unix(1700000000, 0),
+	}
+}
+
+func TestFileSinkAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feedback.jsonl")
+	sink := NewFileSink(path)
+	for i := 0; i < 2; i++ {
+		if err := sink.Record(context.Background(), sampleRecord()); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var got botcore.FeedbackRecord
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[0]), &got) != nil || got.ID != "m1" || got.Answer != "a" {
+		t.Fatalf("unexpected file content: %q", data)
+	}
+}
+
+func TestWebhookSinkPostsJSON(t *testing.T) {
+	var got botcore.FeedbackRecord
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	if err := NewWebhookSink(srv.URL, nil).Record(context.Background(), sampleRecord()); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if got.Kind != botcore.FeedbackNegative || got.Comment != "wrong" {
+		t.Fatalf("unexpected payload: %+v", got)
+	}
+}
+
+func TestSQLSinkInsertsRows(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "feedback.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	if _, err := NewSQLSink(db, "bad name"); err == nil {
+		t.Fatalf("expected invalid table name error")
+	}
+	sink, err := NewSQLSink(db, "")
+	if err != nil {
+		t.Fatalf("new sink: %v", err)
+	}
+	if err := sink.Record(context.Background(), sampleRecord()); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	var kind, reasons string
+	if err := db.QueryRow(`SELECT kind, reasons FROM feedback WHERE feedback_id = ?`, "m1").Scan(&kind, &reasons); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if kind != "negative" || reasons != "1,3" {
+		t.Fatalf("unexpected row: kind=%q reasons=%q", kind, reasons)
+	}
+}
@@ -0,0 +1,341 @@
+// Package notify 将 bot 生命周期事件（收到消息、用户反馈、处理错误）以签名 Webhook 推送到外部系统，
+// 便于接入现有的告警与运营平台。通过 Notifier.Hooks 订阅 botcore.Hooks。
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+)
+
+// EventType 描述推送的事件类型。
+type EventType string
+
+const (
+	// EventMessage 表示收到一条入站消息（不含反馈事件）。
+	EventMessage EventType = "message.received"
+	// EventFeedback 表示收到用户对回答的反馈。
+	EventFeedback EventType = "feedback.received"
+	// EventError 表示处理出错（如回复超时、主动发送失败）。
+	EventError EventType = "error"
+)
+
+const (
+	// SignatureHeader 携带请求体的 HMAC-SHA256 签名，格式为 "sha256=<hex>"。
+	// 签名内容为 "<timestamp>.<body>"，接收方应校验签名并拒绝过旧的时间戳以防重放。
+	SignatureHeader = "X-IMBot-Signature"
+	// TimestampHeader 携带签名时的 Unix 秒级时间戳。
+	TimestampHeader = "X-IMBot-Timestamp"
+	// EventHeader 携带事件类型。
+	EventHeader = "X-IMBot-Event"
+)
+
+// ErrQueueFull 表示推送队列已满，事件被丢弃。
+var ErrQueueFull = errors.New("notify: queue full")
+
+// Event 为推送的事件内容。
+type Event struct {
+	Type      EventType         `json:"type"`
+	At        time.Time         `json:"at"`
+	MessageID string            `json:"message_id,omitempty"`
+	ChatID    string            `json:"chat_id,omitempty"`
+	ChatType  botcore.ChatType  `json:"chat_type,omitempty"`
+	SenderID  string            `json:"sender_id,omitempty"`
+	Text      string            `json:"text,omitempty"`
+	Feedback  *botcore.Feedback `json:"feedback,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// Target 描述一个 Webhook 接收端。
+type Target struct {
+	URL    string      // 接收地址
+	Secret string      // 签名密钥；为空时不签名
+	Events []EventType // 订阅的事件；为空表示全部
+}
+
+// accepts 判断接收端是否订阅了该事件。
+func (t Target) accepts(event EventType) bool {
+	if len(t.Events) == 0 {
+		return true
+	}
+	for _, e := range t.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Option 自定义 Notifier 行为。
+type Option func(*Notifier)
+
+// WithHTTPClient 设置 HTTP 客户端（默认 10 秒超时）。
+func WithHTTPClient(client *http.Client) Option {
+	return func(n *Notifier) {
+		if client != nil {
+			n.client = client
+		}
+	}
+}
+
+// WithRetry 设置失败重试：最多尝试 attempts 次，第 i 次重试前等待 backoff * 2^(i-1)。
+// 网络错误、5xx 与 429 会重试，其余 4xx 视为接收端拒绝不再重试。默认 3 次、1 秒起。
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(n *Notifier) {
+		if attempts > 0 {
+			n.attempts = attempts
+		}
+		if backoff >= 0 {
+			n.backoff = backoff
+		}
+	}
+}
+
+// WithQueueSize 设置每个接收端的待推送事件队列长度（默认 256），队列满时丢弃新事件并报告 ErrQueueFull。
+func WithQueueSize(size int) Option {
+	return func(n *Notifier) {
+		if size > 0 {
+			n.queueSize = size
+		}
+	}
+}
+
+// WithErrorHandler 设置推送最终失败时的回调（默认忽略）。
+func WithErrorHandler(fn func(target Target, event Event, err error)) Option {
+	return func(n *Notifier) {
+		n.onError = fn
+	}
+}
+
+// worker 为单个接收端的推送队列，各接收端独立推送，一个接收端变慢或重试时不阻塞其他接收端。
+type worker struct {
+	target Target
+	queue  chan Event
+}
+
+// Notifier 异步把事件推送到多个 Webhook 接收端。
+type Notifier struct {
+	client    *http.Client
+	attempts  int
+	backoff   time.Duration
+	queueSize int
+	onError   func(target Target, event Event, err error)
+	now       func() time.Time
+
+	workers   []*worker
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	done      chan struct{}
+	closeOnce sync.Once
+	mu        sync.RWMutex
+	closed    bool
+}
+
+// NewNotifier 创建 Notifier 并为每个接收端启动一个后台推送 goroutine，使用完毕后应调用 Close。
+// Parameters:
+//   - targets: 接收端列表
+//   - opts: 可选配置
+//
+// Returns:
+//   - *Notifier: 推送器
+func NewNotifier(targets []Target, opts ...Option) *Notifier {
+	n := &Notifier{
+		client:    &http.Client{Timeout: 10 * time.Second},
+		attempts:  3,
+		backoff:   time.Second,
+		queueSize: 256,
+		now:       time.Now,
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	for _, target := range targets {
+		w := &worker{target: target, queue: make(chan Event, n.queueSize)}
+		n.workers = append(n.workers, w)
+		n.wg.Add(1)
+		go n.run(w)
+	}
+	go func() {
+		n.wg.Wait()
+		close(n.done)
+	}()
+	return n
+}
+
+// Hooks 返回订阅入站消息、反馈与错误事件的 botcore.Hooks，可传给平台适配层（如 wecom.WithHooks）
+// 或 botcore.Observe；与其他回调一起使用时可借助 botcore.CombineHooks。回调只入队，不会阻塞处理流程。
+func (n *Notifier) Hooks() botcore.Hooks {
+	return botcore.Hooks{
+		OnUpdate: func(snapshot botcore.RequestSnapshot) {
+			event := n.newEvent(EventMessage, snapshot)
+			if snapshot.Feedback != nil {
+				event.Type = EventFeedback
+				feedback := *snapshot.Feedback
+				event.Feedback = &feedback
+			}
+			n.Publish(event)
+		},
+		OnError: func(snapshot botcore.RequestSnapshot, err error) {
+			event := n.newEvent(EventError, snapshot)
+			event.Error = err.Error()
+			n.Publish(event)
+		},
+	}
+}
+
+// newEvent 从快照构造事件。
+func (n *Notifier) newEvent(t EventType, snapshot botcore.RequestSnapshot) Event {
+	return Event{
+		Type:      t,
+		At:        n.now(),
+		MessageID: botcore.MessageKey(snapshot),
+		ChatID:    snapshot.ChatID,
+		ChatType:  snapshot.ChatType,
+		SenderID:  snapshot.SenderID,
+		Text:      snapshot.Text,
+	}
+}
+
+// Publish 将事件加入推送队列，分发给所有订阅了该事件类型的接收端。
+// Close 之后的事件会被忽略。
+func (n *Notifier) Publish(event Event) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.closed {
+		return
+	}
+	for _, w := range n.workers {
+		if !w.target.accepts(event.Type) {
+			continue
+		}
+		select {
+		case w.queue <- event:
+		default:
+			n.report(w.target, event, ErrQueueFull)
+		}
+	}
+}
+
+// Close 停止接收新事件并等待队列中的事件推送完成；
+// ctx 到期时中止进行中的请求与重试等待，剩余事件以 ctx 的错误报告给错误回调，并返回该错误。
+func (n *Notifier) Close(ctx context.Context) error {
+	n.closeOnce.Do(func() {
+		n.mu.Lock()
+		n.closed = true
+		for _, w := range n.workers {
+			close(w.queue)
+		}
+		n.mu.Unlock()
+	})
+	select {
+	case <-n.done:
+		n.cancel()
+		return nil
+	case <-ctx.Done():
+		n.cancel()
+		return ctx.Err()
+	}
+}
+
+// run 依次推送单个接收端队列中的事件。
+func (n *Notifier) run(w *worker) {
+	defer n.wg.Done()
+	for event := range w.queue {
+		if err := n.deliver(n.ctx, w.target, event); err != nil {
+			n.report(w.target, event, err)
+		}
+	}
+}
+
+// deliver 推送单个事件，按配置重试；ctx 取消时立即放弃。
+func (n *Notifier) deliver(ctx context.Context, target Target, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("notify: marshal event: %w", err)
+	}
+	var lastErr error
+	for attempt := 0; attempt < n.attempts; attempt++ {
+		if attempt > 0 {
+			if err := sleepContext(ctx, n.backoff<<(attempt-1)); err != nil {
+				return fmt.Errorf("notify: %w (last error: %v)", err, lastErr)
+			}
+		}
+		retry, err := n.post(ctx, target, event.Type, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+	return lastErr
+}
+
+// post 发送一次请求，返回失败是否值得重试。
+func (n *Notifier) post(ctx context.Context, target Target, eventType EventType, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("notify: build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(eventType))
+	if target.Secret != "" {
+		timestamp := strconv.FormatInt(n.now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(target.Secret, timestamp, body))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("notify: post: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("notify: unexpected status %d", resp.StatusCode)
+}
+
+// sleepContext 等待 d，ctx 取消时提前返回其错误。
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// report 调用错误回调（nil 安全）。
+func (n *Notifier) report(target Target, event Event, err error) {
+	if n.onError != nil {
+		n.onError(target, event, err)
+	}
+}
+
+// Sign 计算 Webhook 签名："sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body))。
+// 接收方可用同一函数校验 SignatureHeader。
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+)
+
+func TestNotifierSignsAndFiltersEvents(t *testing.T) {
+	var mu sync.Mutex
+	var got []Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign("s3cret", r.Header.Get(TimestampHeader), body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var event Event
+		_ = json.Unmarshal(body, &event)
+		mu.Lock()
+		got = append(got, event)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	n := NewNotifier([]Target{{URL: srv.URL, Secret: "s3cret", Events: []EventType{EventFeedback, EventError}}})
+	hooks := botcore.CombineHooks(botcore.Hooks{}, n.Hooks())
+	snapshot := botcore.RequestSnapshot{ID: "m1", ChatID: "c1", SenderID: "u1", Text: "hi"}
+	hooks.Update(snapshot)
+	snapshot.Feedback = &botcore.Feedback{ID: "m0", Kind: botcore.FeedbackPositive}
+	hooks.Update(snapshot)
+	hooks.Error(snapshot, errors.New("boom"))
+	if err := n.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 delivered events, got %+v", got)
+	}
+	if got[0].Type != EventFeedback || got[0].Feedback == nil || got[0].Feedback.ID != "m0" || got[0].ChatID != "c1" {
+		t.Fatalf("unexpected feedback event: %+v", got[0])
+	}
+	if got[1].Type != EventError || got[1].Error != "boom" {
+		t.Fatalf("unexpected error event: %+v", got[1])
+	}
+}
+
+func TestNotifierRetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	n := NewNotifier([]Target{{URL: srv.URL}}, WithRetry(3, time.Millisecond))
+	n.Publish(Event{Type: EventMessage})
+	_ = n.Close(context.Background())
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestNotifierStopsOnClientError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	var failed error
+	n := NewNotifier([]Target{{URL: srv.URL}}, WithRetry(3, time.Millisecond), WithErrorHandler(func(_ Target, _ Event, err error) {
+		failed = err
+	}))
+	n.Publish(Event{Type: EventMessage})
+	_ = n.Close(context.Background())
+	if calls.Load() != 1 || failed == nil {
+		t.Fatalf("expected a single failed attempt, got calls=%d err=%v", calls.Load(), failed)
+	}
+}
+
+func TestNotifierIsolatesSlowTargets(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer slow.Close()
+	defer close(release)
+	delivered := make(chan struct{}, 2)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered <- struct{}{}
+	}))
+	defer fast.Close()
+
+	var mu sync.Mutex
+	var failures []error
+	n := NewNotifier([]Target{{URL: slow.URL}, {URL: fast.URL}}, WithRetry(5, time.Hour), WithErrorHandler(func(_ Target, _ Event, err error) {
+		mu.Lock()
+		failures = append(failures, err)
+		mu.Unlock()
+	}))
+	n.Publish(Event{Type: EventMessage})
+	n.Publish(Event{Type: EventMessage})
+	for i := 0; i < 2; i++ {
+		select {
+		case <-delivered:
+		case <-time.After(time.Second):
+			t.Fatalf("fast target was blocked by the slow one")
+		}
+	}
+
+	// 慢接收端卡在请求或一小时的退避中，Close 的 ctx 到期后应立即中止。
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := n.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected close to time out, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("close did not honor its context")
+	}
+	select {
+	case <-n.done:
+	case <-time.After(time.Second):
+		t.Fatalf("workers kept retrying after close was cancelled")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failures) != 2 {
+		t.Fatalf("expected both pending slow deliveries to be reported, got %v", failures)
+	}
+}
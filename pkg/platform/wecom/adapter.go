@@ -3,20 +3,55 @@
 package wecom
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
 	wecomproto "github.com/IMBotPlatform/bot-protocol-wecom/pkg/wecom"
 )
 
+// maxStreamMsgItems 为流式回复 msg_item 的数量上限。
+const maxStreamMsgItems = 10
+
+// ErrSessionExpired 表示 pipeline 运行超过会话存活上限，协议层会话已不再接收输出。
+var ErrSessionExpired = errors.New("wecom: stream session expired")
+
 // PipelineAdapter 将 botcore.PipelineInvoker 适配为 wecomproto.Handler。
 type PipelineAdapter struct {
 	pipeline botcore.PipelineInvoker
+
+	retryHint   *RetryHint
+	accumulator *botcore.Accumulator
+	contentMode botcore.ContentMode
+	deduper     botcore.DedupStore
+	dedupWindow time.Duration
+	coalesce    botcore.Middleware
+	formatting  []botcore.Middleware
+	buffer      botcore.Middleware
+	hooks       botcore.Hooks
+	logger      *slog.Logger
+	metrics     *Metrics
+	tracer      botcore.Tracer
+	lifetime    time.Duration
+	renderError botcore.ErrorRenderer
+	feedback    bool
+	eventText   map[string]string
+	pusher      ChatPusher
+
+	life lifecycle
 }
 
 // NewPipelineAdapter 创建适配器。
-func NewPipelineAdapter(pipeline botcore.PipelineInvoker) *PipelineAdapter {
-	return &PipelineAdapter{pipeline: pipeline}
+func NewPipelineAdapter(pipeline botcore.PipelineInvoker, opts ...AdapterOption) *PipelineAdapter {
+	a := &PipelineAdapter{pipeline: pipeline, formatting: defaultFormatting(), logger: slog.New(slog.DiscardHandler)}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
 
 // Handle 实现 wecomproto.Handler 接口。
@@ -25,47 +60,410 @@ func (a *PipelineAdapter) Handle(ctx wecomproto.Context) <-chan wecomproto.Chunk
 		return nil
 	}
 
+	// 关键步骤：停机期间不再创建新的 pipeline。
+	if !a.life.enter() {
+		a.logger.Info("wecom message rejected during shutdown", "stream_id", ctx.StreamID)
+		a.metrics.rejectedDuringShutdown()
+		return rejectChunk(buildSnapshot(ctx))
+	}
+
 	// 构建 botcore 快照
 	snapshot := buildSnapshot(ctx)
+	if text, ok := a.eventText[snapshot.Metadata["event_type"]]; ok && snapshot.Text == "" {
+		// 关键步骤：按配置把无文本事件改写为指令，使其复用命令路由。
+		snapshot.Text = text
+	}
+	a.hooks.Update(snapshot)
+	msgID := snapshot.Metadata["msgid"]
+	logger := a.logger.With("msgid", msgID, "stream_id", ctx.StreamID)
+
+	// 关键步骤：重试回调直接回放首次结果，不再重复执行 pipeline。
+	if !a.claimMessage(msgID) {
+		a.life.leave()
+		logger.Info("wecom duplicate callback replayed")
+		a.metrics.dedupReplayed()
+		return a.replayDedupReply(msgID)
+	}
 
 	// 创建 Responser 适配器
-	responser := &BotResponser{bot: ctx.Bot}
+	responser := &BotResponser{bot: ctx.Bot, pusher: a.pusher, onError: func(err error) {
+		logger.Error("wecom active response failed", "error", err)
+		a.hooks.Error(snapshot, err)
+	}}
+
+	// 关键步骤：根 span 覆盖 pipeline 执行到输出结束，其 context 向下传递给路由、命令与 LLM 调用。
+	attrs := botcore.SnapshotAttributes(snapshot)
+	attrs["wecom.stream_id"] = ctx.StreamID
+	spanCtx, span := botcore.StartSpan(a.tracer, context.Background(), "wecom.handle", attrs)
+	// 关键步骤：输出结束、回复超时或停机中断时取消 context，通知下游停止生成。
+	runCtx, cancel := context.WithCancel(spanCtx)
 
 	pipelineCtx := botcore.PipelineContext{
 		Snapshot:  snapshot,
 		Responser: responser,
+	}.WithContext(runCtx)
+
+	// 关键步骤：格式化与片段合并只能在增量语义上进行，启用时先把输出统一转换为增量。
+	pipeline := a.pipeline
+	contentMode := a.contentMode
+	if a.coalesce != nil || len(a.formatting) > 0 {
+		mws := append([]botcore.Middleware{a.coalesce}, a.formatting...)
+		pipeline = botcore.Wrap(pipeline, append(mws, botcore.ConvertContent(contentMode, botcore.ContentDelta))...)
+		contentMode = botcore.ContentDelta
+	}
+	if a.buffer != nil {
+		pipeline = botcore.Wrap(pipeline, a.buffer)
 	}
 
 	// 触发 pipeline 并转换输出
-	botcoreCh := a.pipeline.Trigger(pipelineCtx)
+	botcoreCh := pipeline.Trigger(pipelineCtx)
 	if botcoreCh == nil {
+		a.life.leave()
+		cancel()
+		span.End(nil)
+		logger.Debug("wecom pipeline produced no output")
 		return nil
 	}
+	logger.Debug("wecom pipeline started", "msgtype", snapshot.Metadata["msgtype"], "chat_type", snapshot.ChatType)
+	startedAt := time.Now()
+	markFirstChunk, markFinished := a.metrics.pipelineStarted()
 
 	// 转换 botcore.StreamChunk 到 wecomproto.Chunk
 	outCh := make(chan wecomproto.Chunk)
+	abort := a.life.aborted()
 	go func() {
+		var spanErr error
+		defer a.life.leave()
 		defer close(outCh)
-		for chunk := range botcoreCh {
-			// 转换 NoResponse
-			if chunk.Payload == botcore.NoResponse {
-				outCh <- wecomproto.Chunk{Payload: wecomproto.NoResponse}
-				continue
-			}
-			outCh <- wecomproto.Chunk{
-				Content: chunk.Content,
-				Payload: chunk.Payload,
-				IsFinal: chunk.IsFinal,
+		defer markFinished()
+		defer func() { span.End(spanErr) }()
+		defer cancel()
+		defer func() {
+			logger.Debug("wecom pipeline finished", "duration", time.Since(startedAt))
+		}()
+
+		var timeout <-chan time.Time
+		if a.retryHint != nil && a.retryHint.Timeout > 0 {
+			timer := time.NewTimer(a.retryHint.Timeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		var expired <-chan time.Time
+		if a.lifetime > 0 {
+			timer := time.NewTimer(a.lifetime)
+			defer timer.Stop()
+			expired = timer.C
+		}
+
+		accumulated := ""
+		// 协议层按增量累积 stream.content，全文语义的 pipeline 需先转换为增量。
+		converter := botcore.NewContentConverter(contentMode, botcore.ContentDelta)
+		thinking := &thinkRenderer{}
+		// pendingItems 暂存非最终片段中的图片，企业微信仅允许在 finish=true 时携带 msg_item。
+		var pendingItems []wecomproto.MixedItem
+		// pendingCard 暂存随流式回答展示的卡片，在最终片段中以 stream + template_card 输出。
+		var pendingCard *wecomproto.TemplateCard
+		finalSent := false
+		for {
+			select {
+			case chunk, ok := <-botcoreCh:
+				if !ok {
+					if !finalSent && pendingCard != nil {
+						a.hooks.Reply(snapshot, botcore.StreamChunk{IsFinal: true})
+						outCh <- wecomproto.Chunk{IsFinal: true, Payload: buildStreamWithCard(a.finalStreamBody(ctx.StreamID, accumulated, capStreamMsgItems(pendingItems), msgID), pendingCard)}
+					} else if !finalSent && len(pendingItems) > 0 {
+						a.hooks.Reply(snapshot, botcore.StreamChunk{IsFinal: true})
+						outCh <- wecomproto.Chunk{IsFinal: true, MsgItems: capStreamMsgItems(pendingItems)}
+					}
+					return
+				}
+				// 转换 NoResponse
+				if chunk.Payload == botcore.NoResponse {
+					outCh <- wecomproto.Chunk{Payload: wecomproto.NoResponse}
+					a.metrics.chunkPublished()
+					timeout = nil
+					continue
+				}
+				if chunk.Err != nil {
+					// 关键步骤：失败片段经 ErrorRenderer 渲染为友好提示，而不是静默等待超时。
+					logger.Error("wecom pipeline failed", "error", chunk.Err)
+					a.hooks.Error(snapshot, chunk.Err)
+					spanErr = chunk.Err
+					chunk = botcore.RenderError(a.renderError, snapshot, chunk)
+				}
+				chunk = translateMessage(chunk)
+				if card, ok := streamCardOf(chunk.Payload); ok {
+					pendingCard = card
+					chunk.Payload = nil
+				}
+				chunk = converter.Convert(chunk)
+				a.hooks.Reply(snapshot, chunk)
+				// 关键步骤：Reply 回调仍能看到片段类别，之后才将推理渲染为 <think> 标签、丢弃工具调用。
+				chunk = thinking.Render(chunk)
+				if chunk.Content != "" || chunk.Payload != nil || len(chunk.Attachments) > 0 {
+					markFirstChunk()
+				}
+				if chunk.Payload == nil {
+					accumulated += chunk.Content
+					if a.accumulator != nil {
+						a.accumulator.Append(ctx.StreamID, chunk.Content, chunk.IsFinal)
+					}
+				}
+				items := buildStreamMsgItems(chunk.Attachments)
+				if !chunk.IsFinal {
+					pendingItems = append(pendingItems, items...)
+					items = nil
+				} else if len(pendingItems) > 0 {
+					items = capStreamMsgItems(append(pendingItems, items...))
+					pendingItems = nil
+				}
+				payload := encodePayload(chunk.Payload)
+				if chunk.IsFinal && payload == nil && pendingCard != nil {
+					// 关键步骤：协议层对 Payload 片段原样返回，因此卡片与完整回答需一次性编码。
+					payload = buildStreamWithCard(a.finalStreamBody(ctx.StreamID, accumulated, items, msgID), pendingCard)
+					items = nil
+				} else if chunk.IsFinal && payload == nil && a.feedback && msgID != "" {
+					// 关键步骤：协议层的默认流式回复不带反馈信息，启用反馈时自行编码最终帧。
+					payload = wecomproto.StreamReply{MsgType: "stream", Stream: a.finalStreamBody(ctx.StreamID, accumulated, items, msgID)}
+					items = nil
+				} else if chunk.IsFinal && pendingCard != nil {
+					// 关键步骤：最终片段自带负载时，流式负载直接并入卡片，其它负载无法携带卡片，卡片改为单独发送。
+					if merged, ok := withStreamCard(payload, pendingCard); ok {
+						payload = merged
+					} else {
+						a.sendPendingCard(ctx, snapshot, responser, pendingCard, logger)
+					}
+				}
+				outCh <- wecomproto.Chunk{
+					Content:  chunk.Content,
+					Payload:  payload,
+					IsFinal:  chunk.IsFinal,
+					MsgItems: items,
+				}
+				a.metrics.chunkPublished()
+				if chunk.IsFinal {
+					finalSent = true
+					timeout = nil
+					a.saveDedupReply(msgID, botcore.DedupReply{Content: accumulated, Payload: payload})
+				}
+			case <-timeout:
+				// 关键步骤：超时后给出带重试按钮的最终回复，剩余输出在后台丢弃以免阻塞 pipeline。
+				logger.Warn("wecom reply timed out", "timeout", a.retryHint.Timeout, "accumulated_bytes", len(accumulated))
+				a.hooks.Error(snapshot, ErrReplyTimeout)
+				spanErr = ErrReplyTimeout
+				a.metrics.replyTimedOut()
+				card, err := a.retryHint.card(snapshot.Text)
+				if err != nil {
+					logger.Warn("wecom retry button omitted", "error", err, "prompt_bytes", len(snapshot.Text))
+				}
+				// 关键步骤：合成的最终片段同样经过 OnReply，使订阅方看到每个回复的结束。
+				a.hooks.Reply(snapshot, botcore.StreamChunk{Content: a.retryHint.increment(accumulated), IsFinal: true})
+				outCh <- a.retryHint.buildChunk(ctx.StreamID, accumulated, card)
+				if pusher := a.longConnPusher(ctx); pusher != nil && card != nil {
+					// 关键步骤：长连接的流式消息由协议层编号，无法与卡片合并，卡片单独推送到会话。
+					if err := pusher.SendTemplateCard(pushTarget(snapshot), card); err != nil {
+						responser.onError(err)
+					}
+				}
+				go drainStreamChunks(botcoreCh)
+				return
+			case <-expired:
+				// 关键步骤：会话已过期，输出无人接收；取消 context 让下游停止生成。
+				logger.Warn("wecom session expired, pipeline canceled", "lifetime", a.lifetime, "accumulated_bytes", len(accumulated))
+				a.hooks.Error(snapshot, ErrSessionExpired)
+				spanErr = ErrSessionExpired
+				go drainStreamChunks(botcoreCh)
+				return
+			case <-abort:
+				// 关键步骤：停机超时，以已输出内容加中断提示收尾，剩余输出在后台丢弃。
+				logger.Warn("wecom reply aborted by shutdown", "accumulated_bytes", len(accumulated))
+				a.hooks.Error(snapshot, ErrShutdownAborted)
+				spanErr = ErrShutdownAborted
+				a.metrics.shutdownAborted()
+				if !finalSent {
+					notice := botcore.Localize(snapshot, MsgShutdownAbort)
+					a.hooks.Reply(snapshot, botcore.StreamChunk{Content: notice, IsFinal: true})
+					outCh <- wecomproto.Chunk{Content: notice, IsFinal: true, MsgItems: capStreamMsgItems(pendingItems)}
+				}
+				go drainStreamChunks(botcoreCh)
+				return
 			}
 		}
 	}()
 
+	if pusher := a.longConnPusher(ctx); pusher != nil && snapshot.Metadata["retry"] == "true" {
+		// 关键步骤：长连接的卡片事件只能回复卡片更新，重试的回答改为推送到会话。
+		return forwardRetryReply(outCh, pusher, pushTarget(snapshot), responser.onError)
+	}
 	return outCh
 }
 
+// encodePayload 将 botcore 平台无关负载编码为企业微信协议结构，其余负载原样透传。
+func encodePayload(payload any) any {
+	switch p := payload.(type) {
+	case botcore.CardUpdate:
+		return buildUpdateTemplateCard(p)
+	case *botcore.CardUpdate:
+		if p == nil {
+			return nil
+		}
+		return buildUpdateTemplateCard(*p)
+	default:
+		return payload
+	}
+}
+
+// buildUpdateTemplateCard 构造 update_template_card 响应体。
+func buildUpdateTemplateCard(update botcore.CardUpdate) wecomproto.UpdateTemplateCardMessage {
+	msg := wecomproto.UpdateTemplateCardMessage{
+		ResponseType: "update_template_card",
+		UserIDs:      update.UserIDs,
+	}
+	msg.TemplateCard = templateCardOf(update.Card)
+	return msg
+}
+
+// templateCardOf 将平台无关的卡片字段转换为企业微信模板卡片，类型不匹配时返回 nil。
+func templateCardOf(card any) *wecomproto.TemplateCard {
+	switch c := card.(type) {
+	case *wecomproto.TemplateCard:
+		return c
+	case wecomproto.TemplateCard:
+		return &c
+	case botcore.Card:
+		return buildTemplateCard(c)
+	default:
+		return nil
+	}
+}
+
+// streamCardOf 识别 botcore.StreamCard 负载并返回其中的模板卡片。
+func streamCardOf(payload any) (*wecomproto.TemplateCard, bool) {
+	var card any
+	switch p := payload.(type) {
+	case botcore.StreamCard:
+		card = p.Card
+	case *botcore.StreamCard:
+		if p == nil {
+			return nil, false
+		}
+		card = p.Card
+	default:
+		return nil, false
+	}
+	return templateCardOf(card), true
+}
+
+// withStreamCard 将卡片并入流式负载（StreamReply 或尚未携带卡片的 StreamWithTemplateCardMessage）；
+// 其它负载返回 false。
+func withStreamCard(payload any, card *wecomproto.TemplateCard) (any, bool) {
+	switch p := payload.(type) {
+	case wecomproto.StreamReply:
+		return buildStreamWithCard(p.Stream, card), true
+	case *wecomproto.StreamReply:
+		if p != nil {
+			return buildStreamWithCard(p.Stream, card), true
+		}
+	case wecomproto.StreamWithTemplateCardMessage:
+		if p.TemplateCard == nil {
+			return buildStreamWithCard(p.Stream, card), true
+		}
+	case *wecomproto.StreamWithTemplateCardMessage:
+		if p != nil && p.TemplateCard == nil {
+			return buildStreamWithCard(p.Stream, card), true
+		}
+	}
+	return payload, false
+}
+
+// sendPendingCard 在最终片段无法携带卡片时单独发送卡片：长连接模式推送到会话，否则经 response_url 回复。
+func (a *PipelineAdapter) sendPendingCard(ctx wecomproto.Context, snapshot botcore.RequestSnapshot, responser *BotResponser, card *wecomproto.TemplateCard, logger *slog.Logger) {
+	if pusher := a.longConnPusher(ctx); pusher != nil {
+		if err := pusher.SendTemplateCard(pushTarget(snapshot), card); err != nil {
+			responser.onError(err)
+		}
+		return
+	}
+	if snapshot.ResponseURL == "" {
+		logger.Warn("wecom stream card dropped: final chunk has its own payload and no response_url")
+		return
+	}
+	_ = responser.ResponseTemplateCard(snapshot.ResponseURL, card)
+}
+
+// finalStreamBody 构造携带完整回答的最终流式消息体；启用反馈时以 msgid 作为反馈 ID。
+func (a *PipelineAdapter) finalStreamBody(streamID, content string, items []wecomproto.MixedItem, msgID string) wecomproto.StreamReplyBody {
+	body := wecomproto.StreamReplyBody{
+		ID:      streamID,
+		Finish:  true,
+		Content: content,
+		MsgItem: items,
+	}
+	if a.feedback && msgID != "" {
+		body.Feedback = &wecomproto.FeedbackInfo{ID: msgID}
+	}
+	return body
+}
+
+// buildStreamWithCard 构造携带模板卡片的最终流式回复。
+func buildStreamWithCard(body wecomproto.StreamReplyBody, card *wecomproto.TemplateCard) wecomproto.StreamWithTemplateCardMessage {
+	return wecomproto.StreamWithTemplateCardMessage{
+		MsgType:      "stream",
+		Stream:       body,
+		TemplateCard: card,
+	}
+}
+
+// buildStreamMsgItems 将带数据的图片附件编码为流式回复 msg_item（base64 + md5）。
+// 企业微信最多支持 10 个图片子项，超出部分与非图片附件（如语音，见 BotResponser.CanSendVoice）会被忽略。
+func buildStreamMsgItems(attachments []botcore.Attachment) []wecomproto.MixedItem {
+	if len(attachments) == 0 {
+		return nil
+	}
+	items := make([]wecomproto.MixedItem, 0, len(attachments))
+	for _, att := range attachments {
+		if att.Type != botcore.AttachmentTypeImage || len(att.Data) == 0 {
+			continue
+		}
+		item, err := wecomproto.BuildStreamImageItemFromBytes(att.Data)
+		if err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return capStreamMsgItems(items)
+}
+
+// capStreamMsgItems 截断超出企业微信上限的 msg_item。
+func capStreamMsgItems(items []wecomproto.MixedItem) []wecomproto.MixedItem {
+	if len(items) > maxStreamMsgItems {
+		return items[:maxStreamMsgItems]
+	}
+	return items
+}
+
+// drainStreamChunks 丢弃通道中剩余的片段直到关闭。
+func drainStreamChunks(ch <-chan botcore.StreamChunk) {
+	for range ch {
+	}
+}
+
 // BotResponser 适配 wecomproto.Bot 为 botcore.Responser。
 type BotResponser struct {
-	bot *wecomproto.Bot
+	bot     *wecomproto.Bot
+	pusher  ChatPusher
+	onError func(err error)
+}
+
+// report 将主动发送失败上报给 Hooks.OnError。
+func (r *BotResponser) report(err error) error {
+	if err != nil && r.onError != nil {
+		r.onError(err)
+	}
+	return err
 }
 
 // Response 实现 botcore.Responser 接口。
@@ -73,7 +471,7 @@ func (r *BotResponser) Response(responseURL string, msg any) error {
 	if r.bot == nil {
 		return nil
 	}
-	return r.bot.Response(responseURL, msg)
+	return r.report(r.bot.Response(responseURL, msg))
 }
 
 // ResponseMarkdown 实现 botcore.Responser 接口。
@@ -81,7 +479,7 @@ func (r *BotResponser) ResponseMarkdown(responseURL, content string) error {
 	if r.bot == nil {
 		return nil
 	}
-	return r.bot.ResponseMarkdown(responseURL, content)
+	return r.report(r.bot.ResponseMarkdown(responseURL, content))
 }
 
 // ResponseTemplateCard 实现 botcore.Responser 接口。
@@ -89,11 +487,23 @@ func (r *BotResponser) ResponseTemplateCard(responseURL string, card any) error
 	if r.bot == nil {
 		return nil
 	}
-	typedCard, ok := card.(*wecomproto.TemplateCard)
-	if !ok {
+	typedCard := templateCardOf(card)
+	if typedCard == nil {
 		return nil
 	}
-	return r.bot.ResponseTemplateCard(responseURL, typedCard)
+	return r.report(r.bot.ResponseTemplateCard(responseURL, typedCard))
+}
+
+// React 实现 botcore.Reactor 接口。
+// 企业微信智能机器人不支持表情回应，这里为空操作。
+func (r *BotResponser) React(snapshot botcore.RequestSnapshot, emoji string) error {
+	return nil
+}
+
+// CanSendVoice 实现 botcore.VoiceReplier 接口。
+// 企业微信智能机器人的流式回复只能携带图片子项，无法发送语音，botcore.SpeakReplies 因此不会合成语音。
+func (r *BotResponser) CanSendVoice() bool {
+	return false
 }
 
 // buildSnapshot 将 wecomproto.Context 转换为 botcore.RequestSnapshot。
@@ -106,25 +516,103 @@ func buildSnapshot(ctx wecomproto.Context) botcore.RequestSnapshot {
 
 	meta := map[string]string{
 		"platform":     "wecom",
+		"msgid":        msg.MsgID,
 		"msgtype":      msg.MsgType,
 		"response_url": msg.ResponseURL,
 	}
 	if msg.Stream != nil {
 		meta["stream_id"] = msg.Stream.ID
 	}
+	if msg.Event != nil {
+		meta["event_type"] = msg.Event.EventType
+		if evt := msg.Event.TemplateCardEvent; evt != nil {
+			meta["card_type"] = evt.CardType
+			meta["event_key"] = evt.EventKey
+			meta["task_id"] = evt.TaskID
+		}
+	}
+	action := buildAction(msg.Attachment)
+	if action != nil {
+		meta["callback_id"] = action.CallbackID
+		meta["action_name"] = action.Name
+		meta["action_value"] = action.Value
+		meta["action_type"] = action.Type
+	}
+	feedback := buildFeedback(msg)
+	if feedback != nil {
+		meta["feedback_id"] = feedback.ID
+		meta["feedback_kind"] = string(feedback.Kind)
+	}
+
+	text := strings.TrimSpace(extractMessageText(msg))
+	var mentions []string
+	mentionedBot := false
+	if msg.ChatType == "group" {
+		text, mentions = parseMentions(text)
+		// 企业微信只在群成员 @机器人 时推送群消息，因此群内的非事件消息都视为已提及。
+		mentionedBot = msg.Event == nil
+	}
+	if action != nil && text == "" && action.IsCommand() {
+		// 关键步骤：指令形式的动作值转换为文本，使应用按钮直接驱动命令。
+		text = strings.TrimSpace(action.Value)
+	}
+	if prompt, ok := parseRetryEvent(msg); ok {
+		// 关键步骤：重试按钮回调还原为原始提问，重新进入路由。
+		text = prompt
+		meta["retry"] = "true"
+	}
 
 	return botcore.RequestSnapshot{
-		ID:          streamID,
-		SenderID:    msg.From.UserID,
-		ChatID:      msg.ChatID,
-		ChatType:    mapWecomChatType(msg.ChatType),
-		Text:        extractMessageText(msg),
-		Attachments: collectMessageAttachments(msg, ctx),
-		Reference:   buildReference(msg.Quote, ctx),
-		Raw:         msg,
-		ResponseURL: msg.ResponseURL,
-		Metadata:    meta,
+		ID:           streamID,
+		SenderID:     msg.From.UserID,
+		ChatID:       msg.ChatID,
+		ChatType:     mapWecomChatType(msg.ChatType),
+		Text:         text,
+		Mentions:     mentions,
+		MentionedBot: mentionedBot,
+		Attachments:  collectMessageAttachments(msg, ctx),
+		Reference:    buildReference(msg.Quote, ctx),
+		Feedback:     feedback,
+		Action:       action,
+		Raw:          msg,
+		ResponseURL:  msg.ResponseURL,
+		Metadata:     meta,
+	}
+}
+
+// buildAction 将智能应用回调附件转换为 botcore.Action（仅取第一个动作）。
+func buildAction(attachment *wecomproto.AttachmentPayload) *botcore.Action {
+	if attachment == nil {
+		return nil
+	}
+	action := &botcore.Action{CallbackID: attachment.CallbackID}
+	if len(attachment.Actions) > 0 {
+		action.Name = attachment.Actions[0].Name
+		action.Value = attachment.Actions[0].Value
+		action.Type = attachment.Actions[0].Type
+	}
+	return action
+}
+
+// buildFeedback 将企业微信反馈事件转换为 botcore.Feedback。
+func buildFeedback(msg *wecomproto.Message) *botcore.Feedback {
+	if msg.Event == nil || msg.Event.FeedbackEvent == nil {
+		return nil
+	}
+	evt := msg.Event.FeedbackEvent
+	feedback := &botcore.Feedback{ID: evt.ID, Comment: evt.Content}
+	switch evt.Type {
+	case 1:
+		feedback.Kind = botcore.FeedbackPositive
+	case 2:
+		feedback.Kind = botcore.FeedbackNegative
+	default:
+		feedback.Kind = botcore.FeedbackCanceled
+	}
+	for _, reason := range evt.InaccurateReasonList {
+		feedback.Reasons = append(feedback.Reasons, strconv.Itoa(reason))
 	}
+	return feedback
 }
 
 // buildReference 将企业微信 quote 转换为 botcore.Reference。
@@ -0,0 +1,64 @@
+package wecom
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	wecomproto "github.com/IMBotPlatform/bot-protocol-wecom/pkg/wecom"
+)
+
+// maxStreamContentBytes 为流式回复 stream.content 的上限（企业微信限制 20480 字节）。
+const maxStreamContentBytes = 20480
+
+// mentionPattern 匹配文本中的 @提及（位于开头或空白之后）。
+var mentionPattern = regexp.MustCompile(`(^|\s)@(\S+)`)
+
+// parseMentions 解析群聊文本中的 @提及：开头的提及视为机器人自身并去除，使 "@机器人 /ping" 也能命中命令路由；
+// 其余提及按出现顺序返回，文本中保留原样。
+// Returns:
+//   - string: 去除开头机器人提及后的文本
+//   - []string: 其余被提及的显示名
+func parseMentions(text string) (string, []string) {
+	if loc := mentionPattern.FindStringIndex(text); loc != nil && loc[0] == 0 {
+		text = strings.TrimLeftFunc(text[loc[1]:], unicode.IsSpace)
+	}
+	var mentions []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		mentions = append(mentions, m[2])
+	}
+	return text, mentions
+}
+
+// BuildFirstSnapshot 将企业微信原始消息标准化为快照（实现 botcore.Bot 的编解码部分）。
+// raw 支持 wecomproto.Context、*wecomproto.Message 与 wecomproto.Message。
+func (a *PipelineAdapter) BuildFirstSnapshot(raw any) (botcore.RequestSnapshot, error) {
+	switch v := raw.(type) {
+	case wecomproto.Context:
+		return buildSnapshot(v), nil
+	case *wecomproto.Message:
+		if v == nil {
+			return botcore.RequestSnapshot{}, fmt.Errorf("wecom: nil message")
+		}
+		return buildSnapshot(wecomproto.Context{Message: v}), nil
+	case wecomproto.Message:
+		return buildSnapshot(wecomproto.Context{Message: &v}), nil
+	default:
+		return botcore.RequestSnapshot{}, fmt.Errorf("wecom: unsupported raw message %T", raw)
+	}
+}
+
+// BuildReply 将单个片段编码为流式回复，文本超过企业微信上限时按 UTF-8 边界截断。
+// NoResponse 片段返回 nil，表示不回复。
+func (a *PipelineAdapter) BuildReply(first botcore.RequestSnapshot, chunk botcore.StreamChunk) (any, error) {
+	if chunk.Payload == botcore.NoResponse {
+		return nil, nil
+	}
+	if chunk.Payload != nil {
+		return encodePayload(chunk.Payload), nil
+	}
+	content := botcore.TruncateUTF8(chunk.Content, maxStreamContentBytes)
+	return wecomproto.BuildStreamReplyWithMsgItems(first.ID, content, chunk.IsFinal, buildStreamMsgItems(chunk.Attachments)), nil
+}
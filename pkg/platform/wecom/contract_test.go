@@ -0,0 +1,50 @@
+package wecom
+
+import (
+	"testing"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore/adaptertest"
+	wecomproto "github.com/IMBotPlatform/bot-protocol-wecom/pkg/wecom"
+)
+
+func TestPipelineAdapterContract(t *testing.T) {
+	adaptertest.Run(t, NewPipelineAdapter(nil), adaptertest.Fixtures{
+		Messages: []adaptertest.Message{
+			{
+				Name: "single text",
+				Raw: &wecomproto.Message{
+					MsgID: "m1", ChatType: "single", MsgType: "text",
+					From: wecomproto.MessageSender{UserID: "u1"},
+					Text: &wecomproto.TextPayload{Content: " hello "},
+				},
+				Want: botcore.RequestSnapshot{SenderID: "u1", ChatType: botcore.ChatTypeSingle, Text: "hello"},
+			},
+			{
+				Name: "group mention",
+				Raw: &wecomproto.Message{
+					MsgID: "m2", ChatID: "c1", ChatType: "group", MsgType: "text",
+					From: wecomproto.MessageSender{UserID: "u1"},
+					Text: &wecomproto.TextPayload{Content: "@RobotA /ping now"},
+				},
+				Want: botcore.RequestSnapshot{ChatID: "c1", ChatType: botcore.ChatTypeChatroom, Text: "/ping now"},
+			},
+			{
+				Name: "template card event",
+				Raw: &wecomproto.Message{
+					MsgID: "m3", ChatType: "single", MsgType: "event",
+					Event: &wecomproto.EventPayload{
+						EventType:         "template_card_event",
+						TemplateCardEvent: &wecomproto.TemplateCardEvent{EventKey: "approve", TaskID: "t1"},
+					},
+				},
+				Want: botcore.RequestSnapshot{Metadata: map[string]string{"event_type": "template_card_event", "event_key": "approve"}},
+			},
+			{Name: "unsupported raw", Raw: "not a message", WantErr: true},
+		},
+		MaxContentBytes: maxStreamContentBytes,
+		ContentOf: func(reply any) string {
+			return reply.(wecomproto.StreamReply).Stream.Content
+		},
+	})
+}
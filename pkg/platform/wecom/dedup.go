@@ -0,0 +1,55 @@
+package wecom
+
+import (
+	"context"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	wecomproto "github.com/IMBotPlatform/bot-protocol-wecom/pkg/wecom"
+)
+
+// dedupKeyPrefix 为适配层去重键的前缀，与路由层 botcore.Dedup 共用存储时互不冲突。
+const dedupKeyPrefix = "wecom:msgid:"
+
+// WithDeduper 为适配器启用 msgid 去重，抵御企业微信超时重试导致的重复执行：窗口内重复的消息不会再次触发 pipeline，
+// 而是回放首次处理的最终回复；首次处理仍在进行中时静默（NoResponse）。存储出错时放行。
+// 单副本使用 botcore.NewMemoryDedupStore()，多副本部署使用共享的 botcore.NewRedisDedupStore(...)
+// （Redis 只缓存回复文本，回放时不含模板卡片等负载）。
+// Parameters:
+//   - store: 去重存储（与 botcore.Dedup 相同）
+//   - window: 去重窗口（应覆盖企业微信的重试窗口）
+func WithDeduper(store botcore.DedupStore, window time.Duration) AdapterOption {
+	return func(a *PipelineAdapter) {
+		a.deduper = store
+		a.dedupWindow = window
+	}
+}
+
+// claimMessage 登记 msgid，返回 false 表示窗口内重复出现。
+func (a *PipelineAdapter) claimMessage(msgID string) bool {
+	if a.deduper == nil || msgID == "" {
+		return true
+	}
+	claimed, err := a.deduper.Claim(context.Background(), dedupKeyPrefix+msgID, a.dedupWindow)
+	return err != nil || claimed
+}
+
+// saveDedupReply 缓存 msgid 的最终回复，供重复回调回放。
+func (a *PipelineAdapter) saveDedupReply(msgID string, reply botcore.DedupReply) {
+	if a.deduper == nil || msgID == "" {
+		return
+	}
+	_ = a.deduper.SaveReply(context.Background(), dedupKeyPrefix+msgID, reply, a.dedupWindow)
+}
+
+// replayDedupReply 回放已缓存的最终回复；尚未完成或读取失败时返回静默信号。
+func (a *PipelineAdapter) replayDedupReply(msgID string) <-chan wecomproto.Chunk {
+	out := make(chan wecomproto.Chunk, 1)
+	if reply, ok, err := a.deduper.LoadReply(context.Background(), dedupKeyPrefix+msgID); err == nil && ok {
+		out <- wecomproto.Chunk{Content: reply.Content, Payload: reply.Payload, IsFinal: true}
+	} else {
+		out <- wecomproto.Chunk{Payload: wecomproto.NoResponse}
+	}
+	close(out)
+	return out
+}
@@ -0,0 +1,26 @@
+package wecom
+
+import "github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+
+// thinkTagReserve 为 <think></think> 标签预留的字节数，标签在格式化链之后才加入。
+const thinkTagReserve = 64
+
+// maxReplyFollowUps 为超长回答可补发的消息条数：每个 response_url 只能调用一次。
+const maxReplyFollowUps = 1
+
+// defaultFormatting 返回默认的输出格式化链：先把通用 Markdown 改写为企业微信 markdown_v2 方言，
+// 再把总长度限制在 stream.content 上限之内，避免超长回答被协议层静默截断。
+func defaultFormatting() []botcore.Middleware {
+	return []botcore.Middleware{
+		botcore.NormalizeMarkdown(botcore.MarkdownDialectWeCom),
+		botcore.LimitBytes(maxStreamContentBytes-thinkTagReserve, botcore.MsgReplyOversize),
+	}
+}
+
+// splittingFormatting 与 defaultFormatting 相同，但超长部分通过 response_url 补发而不是截断。
+func splittingFormatting() []botcore.Middleware {
+	return []botcore.Middleware{
+		botcore.NormalizeMarkdown(botcore.MarkdownDialectWeCom),
+		botcore.SplitLongReply(maxStreamContentBytes-thinkTagReserve, maxReplyFollowUps),
+	}
+}
@@ -0,0 +1,172 @@
+package wecom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// requestGuard 在解密之前拦截来源不在白名单内或请求体过大的回调。
+type requestGuard struct {
+	allowed  []netip.Prefix
+	ipHeader string
+	hops     int
+	maxBody  int64
+	err      error
+}
+
+// WithAllowedCIDRs 仅接受来自指定网段（如企业微信回调出口 IP）的回调，其余返回 403。
+// 非法的 CIDR 或 IP 会使 NewBot 返回错误。单个 IP 视为 /32（IPv6 为 /128）。
+func WithAllowedCIDRs(cidrs ...string) BotOption {
+	return func(b *Bot) {
+		for _, cidr := range cidrs {
+			cidr = strings.TrimSpace(cidr)
+			if cidr == "" {
+				continue
+			}
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				addr, addrErr := netip.ParseAddr(cidr)
+				if addrErr != nil {
+					b.guard.err = errors.Join(b.guard.err, fmt.Errorf("invalid allowed cidr %q: %w", cidr, err))
+					continue
+				}
+				prefix = netip.PrefixFrom(addr, addr.BitLen())
+			}
+			b.guard.allowed = append(b.guard.allowed, prefix.Masked())
+		}
+	}
+}
+
+// WithClientIPHeader 在反向代理之后从指定请求头（如 X-Forwarded-For）读取来源 IP。
+// 默认只信任一层代理，取最右侧（由最近一层代理追加）的地址；左侧地址由客户端控制，不参与校验。
+// 多层代理时用 WithTrustedProxyHops 设置层数。
+func WithClientIPHeader(header string) BotOption {
+	return func(b *Bot) {
+		b.guard.ipHeader = strings.TrimSpace(header)
+	}
+}
+
+// WithTrustedProxyHops 设置 WithClientIPHeader 信任的代理层数（默认 1），来源 IP 取请求头中从右数第 n 个地址。
+// 地址数少于 n 时视为来源不可信并拒绝。
+func WithTrustedProxyHops(n int) BotOption {
+	return func(b *Bot) {
+		if n > 0 {
+			b.guard.hops = n
+		}
+	}
+}
+
+// WithMaxBodyBytes 限制回调请求体大小，超出时返回 413（<=0 表示不限制）。
+func WithMaxBodyBytes(n int64) BotOption {
+	return func(b *Bot) {
+		b.guard.maxBody = n
+	}
+}
+
+// check 校验来源与请求体大小；拒绝时写出响应并返回 false。
+func (g *requestGuard) check(w http.ResponseWriter, r *http.Request) bool {
+	if len(g.allowed) > 0 && !g.allow(g.clientIP(r)) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	if g.maxBody > 0 && r.Body != nil {
+		// 关键步骤：多读一个字节判断是否超限，并把已读内容放回供协议层解析。
+		body, err := io.ReadAll(io.LimitReader(r.Body, g.maxBody+1))
+		_ = r.Body.Close()
+		if err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return false
+		}
+		if int64(len(body)) > g.maxBody {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return false
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return true
+}
+
+// clientIP 解析请求来源 IP。
+func (g *requestGuard) clientIP(r *http.Request) netip.Addr {
+	raw := r.RemoteAddr
+	if g.ipHeader != "" {
+		if values := r.Header.Values(g.ipHeader); len(values) > 0 {
+			// 关键步骤：代理把对端地址追加在末尾，只有右侧 hops 个地址可信。
+			entries := strings.Split(strings.Join(values, ","), ",")
+			hops := max(g.hops, 1)
+			if len(entries) < hops {
+				return netip.Addr{}
+			}
+			raw = entries[len(entries)-hops]
+		}
+	}
+	raw = strings.TrimSpace(raw)
+	if host, _, err := net.SplitHostPort(raw); err == nil {
+		raw = host
+	}
+	addr, err := netip.ParseAddr(raw)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// allow 判断 IP 是否在白名单内。
+func (g *requestGuard) allow(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	for _, prefix := range g.allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP 实现 http.Handler 接口：先执行来源白名单与请求体大小校验，再交给协议层验签解密。
+func (b *Bot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !b.guard.check(w, r) {
+		return
+	}
+	b.Bot.ServeHTTP(w, r)
+}
+
+// Start 启动 HTTP 服务并挂载回调路由（挂载的是本包 Bot，保证白名单与大小限制生效）。
+// Parameters:
+//   - opts: 启动参数（包含监听地址、回调路径、可选的 Mux/Server）
+//
+// Returns:
+//   - error: 启动失败或配置缺失时返回错误
+func (b *Bot) Start(opts StartOptions) error {
+	callbackPath := strings.TrimSpace(opts.CallbackPath)
+	if callbackPath == "" {
+		callbackPath = "/callback/command"
+	}
+	mux := opts.Mux
+	if mux == nil {
+		mux = http.NewServeMux()
+	}
+	mux.Handle(callbackPath, b)
+
+	srv := opts.Server
+	if srv == nil {
+		srv = &http.Server{}
+	}
+	if srv.Handler == nil {
+		srv.Handler = mux
+	}
+	if strings.TrimSpace(srv.Addr) == "" {
+		srv.Addr = strings.TrimSpace(opts.ListenAddr)
+	}
+	if srv.Addr == "" {
+		return errors.New("listen addr is required")
+	}
+	return srv.ListenAndServe()
+}
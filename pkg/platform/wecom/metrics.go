@@ -0,0 +1,156 @@
+package wecom
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/internal/metricsutil"
+)
+
+// defaultLatencyBuckets 为耗时直方图的默认分桶（秒）。
+var defaultLatencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Metrics 收集适配层的运行指标，并以 Prometheus 文本格式暴露（可直接挂载为 /metrics）。
+// 会话 TTL 过期与刷新等待超时发生在协议层 SDK 内部，不在统计范围内。
+type Metrics struct {
+	activePipelines  atomic.Int64
+	pipelinesTotal   atomic.Uint64
+	chunksPublished  atomic.Uint64
+	replyTimeouts    atomic.Uint64
+	dedupReplays     atomic.Uint64
+	shutdownAborts   atomic.Uint64
+	shutdownRejected atomic.Uint64
+
+	firstChunk *metricsutil.Histogram
+	total      *metricsutil.Histogram
+}
+
+// NewMetrics 创建指标收集器。
+// Parameters:
+//   - buckets: 耗时直方图分桶（秒，升序）；为空时使用默认分桶
+//
+// Returns:
+//   - *Metrics: 指标收集器
+func NewMetrics(buckets ...float64) *Metrics {
+	sorted := metricsutil.Buckets(buckets, defaultLatencyBuckets)
+	return &Metrics{
+		firstChunk: metricsutil.NewHistogram(sorted),
+		total:      metricsutil.NewHistogram(sorted),
+	}
+}
+
+// WithMetrics 为适配器启用指标收集。
+func WithMetrics(m *Metrics) AdapterOption {
+	return func(a *PipelineAdapter) {
+		a.metrics = m
+	}
+}
+
+// pipelineStarted 记录一次 pipeline 启动，返回结束时调用的回调。
+func (m *Metrics) pipelineStarted() (firstChunk func(), finished func()) {
+	if m == nil {
+		return func() {}, func() {}
+	}
+	startedAt := time.Now()
+	m.pipelinesTotal.Add(1)
+	m.activePipelines.Add(1)
+
+	var once sync.Once
+	firstChunk = func() {
+		once.Do(func() {
+			m.firstChunk.Observe(time.Since(startedAt).Seconds())
+		})
+	}
+	finished = func() {
+		m.activePipelines.Add(-1)
+		m.total.Observe(time.Since(startedAt).Seconds())
+	}
+	return firstChunk, finished
+}
+
+// chunkPublished 记录一个发往协议层的片段。
+func (m *Metrics) chunkPublished() {
+	if m != nil {
+		m.chunksPublished.Add(1)
+	}
+}
+
+// replyTimedOut 记录一次超时兜底回复。
+func (m *Metrics) replyTimedOut() {
+	if m != nil {
+		m.replyTimeouts.Add(1)
+	}
+}
+
+// dedupReplayed 记录一次重复回调回放。
+func (m *Metrics) dedupReplayed() {
+	if m != nil {
+		m.dedupReplays.Add(1)
+	}
+}
+
+// shutdownAborted 记录一次停机中断。
+func (m *Metrics) shutdownAborted() {
+	if m != nil {
+		m.shutdownAborts.Add(1)
+	}
+}
+
+// rejectedDuringShutdown 记录一次停机期间拒绝的新消息。
+func (m *Metrics) rejectedDuringShutdown() {
+	if m != nil {
+		m.shutdownRejected.Add(1)
+	}
+}
+
+// ServeHTTP 以 Prometheus 文本格式输出指标。
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.Write(w)
+}
+
+// Write 将指标以 Prometheus 文本格式写入 w。
+func (m *Metrics) Write(w io.Writer) error {
+	writeMetric := func(name, kind, help string, value string) error {
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, value)
+		return err
+	}
+	counters := []struct {
+		name, kind, help string
+		value            string
+	}{
+		{"wecom_active_pipelines", "gauge", "Pipelines currently producing output.", strconv.FormatInt(m.activePipelines.Load(), 10)},
+		{"wecom_pipelines_total", "counter", "Pipelines started.", strconv.FormatUint(m.pipelinesTotal.Load(), 10)},
+		{"wecom_chunks_published_total", "counter", "Chunks handed to the protocol layer.", strconv.FormatUint(m.chunksPublished.Load(), 10)},
+		{"wecom_reply_timeouts_total", "counter", "Replies replaced by the retry hint fallback.", strconv.FormatUint(m.replyTimeouts.Load(), 10)},
+		{"wecom_dedup_replays_total", "counter", "Duplicate callbacks answered from the dedup cache.", strconv.FormatUint(m.dedupReplays.Load(), 10)},
+		{"wecom_shutdown_aborts_total", "counter", "In-flight replies aborted by shutdown.", strconv.FormatUint(m.shutdownAborts.Load(), 10)},
+		{"wecom_shutdown_rejected_total", "counter", "Messages rejected while shutting down.", strconv.FormatUint(m.shutdownRejected.Load(), 10)},
+	}
+	for _, c := range counters {
+		if err := writeMetric(c.name, c.kind, c.help, c.value); err != nil {
+			return err
+		}
+	}
+	histograms := []struct {
+		name, help string
+		h          *metricsutil.Histogram
+	}{
+		{"wecom_first_chunk_seconds", "Time from pipeline start to the first chunk.", m.firstChunk},
+		{"wecom_reply_seconds", "Time from pipeline start to the end of output.", m.total},
+	}
+	for _, h := range histograms {
+		if err := metricsutil.WriteHeader(w, h.name, h.help); err != nil {
+			return err
+		}
+		if err := h.h.WriteSamples(w, h.name, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
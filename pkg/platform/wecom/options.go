@@ -0,0 +1,141 @@
+package wecom
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+)
+
+// AdapterOption 自定义 PipelineAdapter 行为。
+type AdapterOption func(*PipelineAdapter)
+
+// WithAccumulator 将每个流的文本片段同步写入共享累积器（按 streamID 索引），
+// 便于其他发送端以全文或增量方式读取同一份输出。累积器条目由调用方负责 Remove。
+func WithAccumulator(acc *botcore.Accumulator) AdapterOption {
+	return func(a *PipelineAdapter) {
+		a.accumulator = acc
+	}
+}
+
+// WithContentMode 声明 pipeline 输出片段的语义（默认 botcore.ContentDelta）。
+// 当 pipeline 每次输出完整文本时设置为 botcore.ContentFull，适配层会转换为协议层需要的增量。
+func WithContentMode(mode botcore.ContentMode) AdapterOption {
+	return func(a *PipelineAdapter) {
+		a.contentMode = mode
+	}
+}
+
+// WithChunkCoalesce 合并 pipeline 输出的细碎增量片段，减少两次刷新之间的队列条目。
+// 缓冲达到 maxBytes 或超过 minInterval 时输出一次，参见 botcore.Coalesce。
+func WithChunkCoalesce(minInterval time.Duration, maxBytes int) AdapterOption {
+	return func(a *PipelineAdapter) {
+		if minInterval <= 0 && maxBytes <= 0 {
+			a.coalesce = nil
+			return
+		}
+		a.coalesce = botcore.Coalesce(minInterval, maxBytes)
+	}
+}
+
+// WithFormatting 替换输出格式化链（默认为 botcore.NormalizeMarkdown 方言改写 + botcore.LimitBytes 长度上限）。
+// 中间件按顺序组成链，第一个位于最外层；输入均为增量语义。不传参数表示关闭格式化。
+func WithFormatting(mws ...botcore.Middleware) AdapterOption {
+	return func(a *PipelineAdapter) {
+		a.formatting = mws
+	}
+}
+
+// WithReplySplitting 将超出流式回复上限的回答拆分为两条消息：被动回复展示前半部分，
+// 其余内容在回答结束后通过 response_url 以 Markdown 补发（企业微信每个 response_url 仅能调用一次，
+// 仍放不下的部分会被截断）。会替换 WithFormatting 设置的格式化链，参见 botcore.SplitLongReply。
+func WithReplySplitting() AdapterOption {
+	return func(a *PipelineAdapter) {
+		a.formatting = splittingFormatting()
+	}
+}
+
+// WithOutputQueue 在 pipeline 与协议层之间加入有界队列，避免刷新轮询过慢时反压卡住 pipeline。
+// OverflowCoalesce 要求增量语义；WithContentMode(botcore.ContentFull) 时应使用 OverflowDropOldest。
+// 参见 botcore.Buffer。
+func WithOutputQueue(capacity int, policy botcore.OverflowPolicy) AdapterOption {
+	return func(a *PipelineAdapter) {
+		if capacity <= 0 {
+			a.buffer = nil
+			return
+		}
+		a.buffer = botcore.Buffer(capacity, policy)
+	}
+}
+
+// WithHooks 注册生命周期回调：入站消息、出站片段，以及回复超时、停机中断、主动发送失败等错误。
+func WithHooks(hooks botcore.Hooks) AdapterOption {
+	return func(a *PipelineAdapter) {
+		a.hooks = hooks
+	}
+}
+
+// WithTracer 为每条消息创建 "wecom.handle" 根 span，并通过 PipelineContext.Context() 传递给下游。
+// 回复超时与停机中断会以对应错误结束 span。解密与验签由协议层 SDK 完成，不在追踪范围内。
+func WithTracer(tracer botcore.Tracer) AdapterOption {
+	return func(a *PipelineAdapter) {
+		a.tracer = tracer
+	}
+}
+
+// WithSessionLifetime 设置单条消息 pipeline 的最长运行时间，超过后视为会话过期：
+// 停止转发输出并取消 PipelineContext.Context()，避免 LLM 在无人接收时继续生成。
+// 协议层 SDK 不暴露会话过期事件，应按企业微信停止刷新轮询的时间配置（<=0 表示不限制）。
+func WithSessionLifetime(d time.Duration) AdapterOption {
+	return func(a *PipelineAdapter) {
+		a.lifetime = d
+	}
+}
+
+// WithErrorRenderer 自定义失败片段（StreamChunk.Err 非空）的展示方式，例如渲染为本地化的模板卡片。
+// 默认使用 botcore.DefaultErrorRenderer。失败同时会触发 Hooks.OnError。
+func WithErrorRenderer(renderer botcore.ErrorRenderer) AdapterOption {
+	return func(a *PipelineAdapter) {
+		a.renderError = renderer
+	}
+}
+
+// WithFeedback 在最终流式回复中携带反馈 ID（源消息 msgid），使企业微信展示点赞/点踩入口。
+// 用户反馈以 RequestSnapshot.Feedback 回调，可配合 botcore.CollectFeedback 记录。
+// 注意：HTTP 回调模式下协议层 SDK 会在进入 handler 前直接应答反馈事件，
+// 需使用长连接模式（wecomproto.NewLongConnBot 并传入 PipelineAdapter）才能收到反馈。
+func WithFeedback() AdapterOption {
+	return func(a *PipelineAdapter) {
+		a.feedback = true
+	}
+}
+
+// WithEventText 将无文本的事件（按 event_type）改写为指定文本，使其进入命令路由，
+// 例如 {"enter_chat": "/welcome"}。未配置的事件保持空文本，可用 botcore.MatchEvent 单独路由。
+func WithEventText(mapping map[string]string) AdapterOption {
+	return func(a *PipelineAdapter) {
+		a.eventText = mapping
+	}
+}
+
+// BotOption 自定义 Bot 行为。
+type BotOption func(*Bot)
+
+// WithAdapterOptions 将适配器选项透传给 NewBot 内部创建的 PipelineAdapter。
+func WithAdapterOptions(opts ...AdapterOption) BotOption {
+	return func(b *Bot) {
+		for _, opt := range opts {
+			opt(b.adapter)
+		}
+	}
+}
+
+// WithLogger 设置结构化日志输出（默认丢弃）。
+// 记录会话开始/结束、重复回调回放、回复超时、停机中断与主动发送失败，均携带 msgid 与 stream_id。
+func WithLogger(logger *slog.Logger) BotOption {
+	return func(b *Bot) {
+		if logger != nil {
+			b.adapter.logger = logger
+		}
+	}
+}
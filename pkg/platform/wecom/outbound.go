@@ -0,0 +1,62 @@
+package wecom
+
+import (
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	wecomproto "github.com/IMBotPlatform/bot-protocol-wecom/pkg/wecom"
+	"github.com/google/uuid"
+)
+
+// translateMessage 将 botcore 平台无关出站消息翻译为企业微信流式回复可承载的形式：
+// 文本与 Markdown 并入流式内容，卡片随最终回答以 stream + template_card 输出（多张时以最后一张为准），
+// 带原始字节的图片作为图文混排附件，其余消息按 botcore.MessageText 降级为文本。
+func translateMessage(chunk botcore.StreamChunk) botcore.StreamChunk {
+	msg, ok := chunk.Payload.(botcore.Message)
+	if !ok {
+		return chunk
+	}
+	chunk.Payload = nil
+	switch m := msg.(type) {
+	case botcore.Card:
+		// 关键步骤：text_notice 卡片必须带跳转地址，既无按钮也无地址的卡片只能降级为文本。
+		if len(m.Buttons) == 0 && m.URL == "" {
+			chunk.Content += botcore.MessageText(m)
+			return chunk
+		}
+		chunk.Payload = botcore.StreamCard{Card: m}
+	case botcore.Image:
+		if len(m.Data) > 0 {
+			chunk.Attachments = append(chunk.Attachments, botcore.Attachment{Type: botcore.AttachmentTypeImage, Data: m.Data})
+			return chunk
+		}
+		chunk.Content += botcore.MessageText(m)
+	default:
+		chunk.Content += botcore.MessageText(msg)
+	}
+	return chunk
+}
+
+// buildTemplateCard 将 botcore.Card 转换为企业微信模板卡片：
+// 有按钮时为 button_interaction（task_id 即按钮回调的 CallbackID），否则为 text_notice。
+func buildTemplateCard(card botcore.Card) *wecomproto.TemplateCard {
+	tc := &wecomproto.TemplateCard{
+		CardType:  "text_notice",
+		MainTitle: &wecomproto.MainTitle{Title: card.Title, Desc: card.Description},
+	}
+	for _, field := range card.Fields {
+		tc.HorizontalContentList = append(tc.HorizontalContentList, wecomproto.HorizontalContent{KeyName: field.Name, Value: field.Value})
+	}
+	if card.URL != "" {
+		tc.CardAction = &wecomproto.CardAction{Type: 1, URL: card.URL}
+	}
+	if len(card.Buttons) > 0 {
+		tc.CardType = "button_interaction"
+		tc.TaskID = card.TaskID
+		if tc.TaskID == "" {
+			tc.TaskID = uuid.NewString()
+		}
+		for _, button := range card.Buttons {
+			tc.ButtonList = append(tc.ButtonList, wecomproto.Button{Text: button.Text, Key: button.Value})
+		}
+	}
+	return tc
+}
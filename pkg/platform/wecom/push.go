@@ -0,0 +1,72 @@
+package wecom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	wecomproto "github.com/IMBotPlatform/bot-protocol-wecom/pkg/wecom"
+)
+
+// ErrChatPushUnsupported 表示未配置 ChatPusher，无法主动向会话推送消息。
+var ErrChatPushUnsupported = errors.New("wecom: chat push requires WithChatPusher")
+
+// ChatPusher 主动向会话推送消息（不依赖 response_url），由长连接模式的 *wecomproto.LongConnBot 实现。
+type ChatPusher interface {
+	SendMarkdown(chatID, content string) error
+	SendTemplateCard(chatID string, card *wecomproto.TemplateCard) error
+}
+
+var _ ChatPusher = (*wecomproto.LongConnBot)(nil)
+
+// WithChatPusher 设置主动推送通道，使 Responser 实现 botcore.ChatSender（如 ExecutionContext.SendToChat）。
+// HTTP 回调协议没有主动推送接口，需要同一机器人的长连接（wecomproto.NewLongConnBot）。
+// 长连接以 PipelineAdapter 作为 handler 创建，可在创建后、Start 之前调用 WithChatPusher(lc)(adapter)。
+func WithChatPusher(pusher ChatPusher) AdapterOption {
+	return func(a *PipelineAdapter) {
+		a.pusher = pusher
+	}
+}
+
+// pushToChat 将消息按类型以 Markdown 或模板卡片推送到 chatID。
+// Parameters:
+//   - pusher: 推送通道，为 nil 时返回 ErrChatPushUnsupported
+//   - chatID: 目标会话 ID
+//   - msg: string（Markdown 文本）、MarkdownMessage、模板卡片（*TemplateCard、botcore.Card）或其他 botcore.Message
+//
+// Returns:
+//   - error: 未配置通道、消息类型不支持或发送失败时返回
+func pushToChat(pusher ChatPusher, chatID string, msg any) error {
+	if pusher == nil {
+		return ErrChatPushUnsupported
+	}
+	switch m := msg.(type) {
+	case string:
+		return pusher.SendMarkdown(chatID, m)
+	case wecomproto.MarkdownMessage:
+		return pusher.SendMarkdown(chatID, m.Markdown.Content)
+	case *wecomproto.MarkdownMessage:
+		return pusher.SendMarkdown(chatID, m.Markdown.Content)
+	}
+	if card := templateCardOf(msg); card != nil {
+		return pusher.SendTemplateCard(chatID, card)
+	}
+	if m, ok := msg.(botcore.Message); ok {
+		return pusher.SendMarkdown(chatID, botcore.MessageText(m))
+	}
+	return fmt.Errorf("wecom: unsupported chat message %T", msg)
+}
+
+// SendToChat 实现 botcore.ChatSender 接口。
+func (r *BotResponser) SendToChat(ctx context.Context, chatID string, msg any) error {
+	if r.pusher == nil {
+		return ErrChatPushUnsupported
+	}
+	return r.report(pushToChat(r.pusher, chatID, msg))
+}
+
+// SendToChat 实现 botcore.ChatSender 接口，需通过 WithAdapterOptions(WithChatPusher(...)) 配置推送通道。
+func (b *Bot) SendToChat(ctx context.Context, chatID string, msg any) error {
+	return pushToChat(b.adapter.pusher, chatID, msg)
+}
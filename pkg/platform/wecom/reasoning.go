@@ -0,0 +1,34 @@
+package wecom
+
+import "github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+
+// thinkRenderer 将推理片段包裹在 <think></think> 标签中，企业微信客户端会将其展示为可折叠的思考过程。
+// 工具调用与结果片段不向用户展示。输入须为增量语义，每个流使用独立实例。
+type thinkRenderer struct {
+	open bool
+}
+
+// Render 渲染单个片段；返回的片段均为正文类型。
+func (r *thinkRenderer) Render(chunk botcore.StreamChunk) botcore.StreamChunk {
+	switch chunk.Type {
+	case botcore.ChunkContent:
+		if r.open && (chunk.Content != "" || chunk.IsFinal) {
+			chunk.Content = "</think>" + chunk.Content
+			r.open = false
+		}
+	case botcore.ChunkReasoning:
+		if !r.open && chunk.Content != "" {
+			chunk.Content = "<think>" + chunk.Content
+			r.open = true
+		}
+	default:
+		chunk.Content = ""
+	}
+	chunk.Type = botcore.ChunkContent
+	if chunk.IsFinal && r.open {
+		// 关键步骤：推理未结束就收尾时补齐闭合标签，避免正文被吞进思考过程。
+		chunk.Content += "</think>"
+		r.open = false
+	}
+	return chunk
+}
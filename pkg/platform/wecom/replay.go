@@ -0,0 +1,170 @@
+package wecom
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultClockSkew 为默认允许的时间戳偏差。
+	defaultClockSkew = 5 * time.Minute
+)
+
+// ReplayOption 自定义 ReplayGuard 行为。
+type ReplayOption func(*ReplayGuard)
+
+// WithClockSkew 设置允许的回调时间戳偏差（<=0 表示不校验时间戳）。
+func WithClockSkew(skew time.Duration) ReplayOption {
+	return func(g *ReplayGuard) {
+		g.skew = skew
+	}
+}
+
+// WithReplayClock 替换时钟（测试用）。
+func WithReplayClock(now func() time.Time) ReplayOption {
+	return func(g *ReplayGuard) {
+		if now != nil {
+			g.now = now
+		}
+	}
+}
+
+// ReplayGuard 在回调进入 Bot 之前校验 timestamp 偏差，并保证同一 timestamp + nonce 只交给下游处理一次，
+// 用于防御截获报文后的重放攻击。
+// 企业微信在超时未收到响应时会以相同参数重试，重复请求因此不会被拒绝：首次处理成功后回放其响应，
+// 仍在处理中时返回空的 200，使重试与重放都不会再次触发下游。
+// 协议层 Crypt 只校验签名，不关心时间与 nonce，因此防护放在 HTTP 入口。
+type ReplayGuard struct {
+	next http.Handler
+	skew time.Duration
+	now  func() time.Time
+
+	mu     sync.Mutex
+	nonces map[string]*replayEntry
+}
+
+// replayEntry 为已预占的 nonce 及其首次处理的响应。
+type replayEntry struct {
+	seen   time.Time
+	done   bool
+	header http.Header
+	body   []byte
+}
+
+// NewReplayGuard 包装回调处理器（通常为 *Bot 或 *TenantMux）。
+// 使用方式：mux.Handle("/callback/command", wecom.NewReplayGuard(bot))。
+// Parameters:
+//   - next: 被保护的回调处理器
+//   - opts: 可选配置（默认允许 5 分钟偏差）
+//
+// Returns:
+//   - *ReplayGuard: http.Handler 实现
+func NewReplayGuard(next http.Handler, opts ...ReplayOption) *ReplayGuard {
+	g := &ReplayGuard{
+		next:   next,
+		skew:   defaultClockSkew,
+		now:    time.Now,
+		nonces: make(map[string]*replayEntry),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// ServeHTTP 实现 http.Handler 接口。
+func (g *ReplayGuard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	now := g.now()
+
+	if g.skew > 0 {
+		ts, err := strconv.ParseInt(strings.TrimSpace(query.Get("timestamp")), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid timestamp", http.StatusBadRequest)
+			return
+		}
+		if diff := now.Sub(time.Unix(ts, 0)); diff > g.skew || diff < -g.skew {
+			http.Error(w, "timestamp out of range", http.StatusForbidden)
+			return
+		}
+	}
+
+	// 关键步骤：nonce 以 timestamp 组合为键，先预占再转发；下游未成功处理时释放，
+	// 避免伪造请求（签名错误）提前占用合法 nonce。
+	key := query.Get("timestamp") + ":" + query.Get("nonce")
+	if entry, ok := g.reserve(key, now); !ok {
+		if entry.done {
+			for k, v := range entry.header {
+				w.Header()[k] = v
+			}
+			_, _ = w.Write(entry.body)
+		}
+		return
+	}
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	g.next.ServeHTTP(rec, r)
+	if rec.status != http.StatusOK {
+		g.release(key)
+		return
+	}
+	g.complete(key, w.Header().Clone(), rec.body.Bytes())
+}
+
+// reserve 预占 nonce，已存在时返回已有条目与 false；同时清理过期条目。
+func (g *ReplayGuard) reserve(key string, now time.Time) (replayEntry, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ttl := 2 * g.skew
+	if ttl <= 0 {
+		ttl = 2 * defaultClockSkew
+	}
+	for k, entry := range g.nonces {
+		if now.Sub(entry.seen) > ttl {
+			delete(g.nonces, k)
+		}
+	}
+	if entry, ok := g.nonces[key]; ok {
+		return *entry, false
+	}
+	g.nonces[key] = &replayEntry{seen: now}
+	return replayEntry{}, true
+}
+
+// complete 记录首次处理的响应，供重复请求回放。
+func (g *ReplayGuard) complete(key string, header http.Header, body []byte) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if entry, ok := g.nonces[key]; ok {
+		entry.done, entry.header, entry.body = true, header, body
+	}
+}
+
+// release 释放预占的 nonce。
+func (g *ReplayGuard) release(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.nonces, key)
+}
+
+// statusRecorder 记录下游写出的 HTTP 状态码与响应体。
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader 实现 http.ResponseWriter 接口。
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write 实现 http.ResponseWriter 接口。
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
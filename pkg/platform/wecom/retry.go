@@ -0,0 +1,161 @@
+package wecom
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	wecomproto "github.com/IMBotPlatform/bot-protocol-wecom/pkg/wecom"
+)
+
+const (
+	// retryEventKeyPrefix 标记重试按钮的 event_key 前缀，其后为原始提问。
+	retryEventKeyPrefix = "retry:"
+	// maxRetryEventKeyBytes 为按钮 key 的长度上限（企业微信限制 1024 字节）。
+	maxRetryEventKeyBytes = 1024
+)
+
+// ErrReplyTimeout 表示 pipeline 超过 RetryHint.Timeout 仍未输出最终片段。
+var ErrReplyTimeout = errors.New("wecom: reply timed out")
+
+// RetryHint 配置 pipeline 超时放弃后的最终提示。
+// Fields:
+//   - Timeout: 等待最终片段的最长时间（<=0 表示不启用）
+//   - Message: 超时提示文案，可按部署语言本地化
+//   - Title: 重试卡片标题
+//   - ButtonText: 重试按钮文案
+type RetryHint struct {
+	Timeout    time.Duration
+	Message    string
+	Title      string
+	ButtonText string
+}
+
+// DefaultRetryHint 返回中文默认文案的重试提示配置。
+func DefaultRetryHint(timeout time.Duration) RetryHint {
+	return RetryHint{
+		Timeout:    timeout,
+		Message:    "⏱ 处理超时，请稍后点击下方按钮重试。",
+		Title:      "处理超时",
+		ButtonText: "重试",
+	}
+}
+
+// ErrRetryPromptTooLong 表示原始提问超出按钮 key 的长度上限（1024 字节），无法附带重试按钮；
+// 此时只发送超时提示，避免截断后的提问在重试时被当作另一条消息执行。
+var ErrRetryPromptTooLong = errors.New("wecom: prompt too long for retry button")
+
+// WithRetryHint 在 pipeline 超过 hint.Timeout 仍未结束时发送最终提示，
+// 并附带携带原始提问的重试按钮；点击后原始提问会作为 Text 重新进入 pipeline。
+// 长连接模式下流式消息由协议层编号，按钮卡片在提示之后经长连接单独推送，
+// 点击重试后的回答同样经长连接推送到会话（卡片事件只能回复卡片更新）。
+func WithRetryHint(hint RetryHint) AdapterOption {
+	return func(a *PipelineAdapter) {
+		a.retryHint = &hint
+	}
+}
+
+// card 构造携带原始提问的重试卡片；提问为空时返回 nil，超出按钮 key 上限时返回 ErrRetryPromptTooLong。
+func (h *RetryHint) card(prompt string) (*wecomproto.TemplateCard, error) {
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return nil, nil
+	}
+	key := retryEventKeyPrefix + prompt
+	if len(key) > maxRetryEventKeyBytes {
+		return nil, ErrRetryPromptTooLong
+	}
+	return &wecomproto.TemplateCard{
+		CardType:  "button_interaction",
+		MainTitle: &wecomproto.MainTitle{Title: h.Title},
+		TaskID:    fmt.Sprintf("retry_%d", time.Now().UnixNano()),
+		ButtonList: []wecomproto.Button{
+			{Text: h.ButtonText, Key: key},
+		},
+	}, nil
+}
+
+// buildChunk 构造超时后的最终片段。
+// 流式会话（webhook 模式）附带重试卡片；无 streamID 或无卡片时退化为纯文本提示。
+func (h *RetryHint) buildChunk(streamID, accumulated string, card *wecomproto.TemplateCard) wecomproto.Chunk {
+	increment := h.increment(accumulated)
+	if streamID == "" || card == nil {
+		// 纯文本片段由协议层负责累积，这里只需给出增量。
+		return wecomproto.Chunk{Content: increment, IsFinal: true}
+	}
+
+	return wecomproto.Chunk{
+		Payload: wecomproto.StreamWithTemplateCardMessage{
+			MsgType: "stream",
+			Stream: wecomproto.StreamReplyBody{
+				ID:      streamID,
+				Finish:  true,
+				Content: accumulated + increment,
+			},
+			TemplateCard: card,
+		},
+		IsFinal: true,
+	}
+}
+
+// increment 返回追加在已输出内容之后的超时提示。
+func (h *RetryHint) increment(accumulated string) string {
+	if accumulated == "" {
+		return h.Message
+	}
+	return "\n\n" + h.Message
+}
+
+// parseRetryEvent 识别重试按钮回调并返回原始提问。
+func parseRetryEvent(msg *wecomproto.Message) (string, bool) {
+	if msg == nil || msg.Event == nil || msg.Event.TemplateCardEvent == nil {
+		return "", false
+	}
+	key := msg.Event.TemplateCardEvent.EventKey
+	if !strings.HasPrefix(key, retryEventKeyPrefix) {
+		return "", false
+	}
+	prompt := strings.TrimPrefix(key, retryEventKeyPrefix)
+	return prompt, prompt != ""
+}
+
+// longConnPusher 返回长连接模式下的推送通道：优先使用 WithChatPusher 配置的通道，否则为接收回调的长连接；
+// 非长连接回调返回 nil。
+func (a *PipelineAdapter) longConnPusher(ctx wecomproto.Context) ChatPusher {
+	if ctx.LongConn == nil {
+		return nil
+	}
+	if a.pusher != nil {
+		return a.pusher
+	}
+	return ctx.LongConn
+}
+
+// pushTarget 返回主动推送的目标会话：群聊为 chatid，单聊为发送者 userid。
+func pushTarget(snapshot botcore.RequestSnapshot) string {
+	if snapshot.ChatID != "" {
+		return snapshot.ChatID
+	}
+	return snapshot.SenderID
+}
+
+// forwardRetryReply 消费长连接模式下重试点击的输出，结束后把完整回答推送到会话，并以 NoResponse 放弃卡片更新。
+func forwardRetryReply(in <-chan wecomproto.Chunk, pusher ChatPusher, target string, onError func(error)) <-chan wecomproto.Chunk {
+	out := make(chan wecomproto.Chunk, 1)
+	go func() {
+		defer close(out)
+		var content strings.Builder
+		for chunk := range in {
+			content.WriteString(chunk.Content)
+		}
+		if text := strings.TrimSpace(content.String()); text != "" {
+			if err := pusher.SendMarkdown(target, text); err != nil {
+				onError(err)
+			}
+		}
+		out <- wecomproto.Chunk{Payload: wecomproto.NoResponse}
+	}()
+	return out
+}
@@ -0,0 +1,136 @@
+package wecom
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	wecomproto "github.com/IMBotPlatform/bot-protocol-wecom/pkg/wecom"
+)
+
+// 优雅停机使用的消息键。
+const (
+	MsgShutdownReject = "wecom.shutdown.reject" // 停机期间新消息的回复
+	MsgShutdownAbort  = "wecom.shutdown.abort"  // 停机超时时追加到未完成回答末尾的提示（含前导空行）
+)
+
+func init() {
+	botcore.Messages.Add("zh", map[string]string{
+		MsgShutdownReject: "服务正在升级，请稍后重新发送。",
+		MsgShutdownAbort:  "\n\n> 服务升级，回答被中断，请稍后重试。",
+	})
+	botcore.Messages.Add("en", map[string]string{
+		MsgShutdownReject: "The service is being upgraded. Please send your message again shortly.",
+		MsgShutdownAbort:  "\n\n> The service is being upgraded and this answer was interrupted. Please try again later.",
+	})
+}
+
+// shutdownAbortGrace 为超时后等待在途流收尾的最长时间；下游不再读取输出时不会无限阻塞停机。
+var shutdownAbortGrace = time.Second
+
+// ErrShutdownAborted 表示停机超时，在途回答被强制中断。
+var ErrShutdownAborted = errors.New("wecom: reply aborted by shutdown")
+
+// lifecycle 跟踪适配器的在途 pipeline，用于优雅停机。
+type lifecycle struct {
+	mu       sync.Mutex
+	closing  bool
+	abort    chan struct{}
+	inflight sync.WaitGroup
+}
+
+// enter 登记一个在途 pipeline；停机开始后返回 false。
+func (l *lifecycle) enter() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closing {
+		return false
+	}
+	l.inflight.Add(1)
+	return true
+}
+
+// leave 注销一个在途 pipeline。
+func (l *lifecycle) leave() {
+	l.inflight.Done()
+}
+
+// aborted 返回停机超时信号；收到后在途 pipeline 应立即输出最终片段。
+func (l *lifecycle) aborted() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.abort == nil {
+		l.abort = make(chan struct{})
+	}
+	return l.abort
+}
+
+// forceFinish 广播停机超时信号（可重复调用）。
+func (l *lifecycle) forceFinish() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.abort == nil {
+		l.abort = make(chan struct{})
+	}
+	select {
+	case <-l.abort:
+	default:
+		close(l.abort)
+	}
+}
+
+// Shutdown 停止接收新消息，并等待在途 pipeline 输出最终片段。
+// ctx 结束时会强制在途流以当前已输出内容加中断提示收尾，最多再等待 1 秒后返回 ctx.Err()。
+// 注意：HTTP 服务需由调用方自行关闭（如 StartOptions.Server.Shutdown），
+// 并且应在本方法返回之后关闭，保证刷新请求能取回最终内容。
+// Parameters:
+//   - ctx: 控制最长等待时间
+//
+// Returns:
+//   - error: 超时返回 ctx.Err()，否则为 nil
+func (a *PipelineAdapter) Shutdown(ctx context.Context) error {
+	a.life.mu.Lock()
+	a.life.closing = true
+	a.life.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		a.life.inflight.Wait()
+		close(done)
+	}()
+
+	a.logger.Info("wecom shutdown started")
+	select {
+	case <-done:
+		a.logger.Info("wecom shutdown completed")
+		return nil
+	case <-ctx.Done():
+		// 关键步骤：超时后通知在途流立即收尾，而不是直接丢弃已生成的回答。
+		a.life.forceFinish()
+		grace := time.NewTimer(shutdownAbortGrace)
+		defer grace.Stop()
+		select {
+		case <-done:
+			a.logger.Warn("wecom shutdown deadline exceeded, in-flight replies aborted", "error", ctx.Err())
+		case <-grace.C:
+			// 关键步骤：输出无人读取时流会阻塞在发送上，不再等待，避免停机超过调用方的期限。
+			a.logger.Warn("wecom shutdown deadline exceeded, in-flight replies still blocked", "error", ctx.Err())
+		}
+		return ctx.Err()
+	}
+}
+
+// rejectChunk 按快照语言返回停机期间对新消息的最终回复。
+func rejectChunk(snapshot botcore.RequestSnapshot) <-chan wecomproto.Chunk {
+	out := make(chan wecomproto.Chunk, 1)
+	out <- wecomproto.Chunk{Content: botcore.Localize(snapshot, MsgShutdownReject), IsFinal: true}
+	close(out)
+	return out
+}
+
+// Shutdown 优雅停机：停止接收新消息并等待在途回答完成，详见 PipelineAdapter.Shutdown。
+func (b *Bot) Shutdown(ctx context.Context) error {
+	return b.adapter.Shutdown(ctx)
+}
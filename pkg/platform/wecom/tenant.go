@@ -0,0 +1,113 @@
+package wecom
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// TenantResolver 从回调请求中解析租户标识（通常对应 receive_id / corpID）。
+type TenantResolver func(r *http.Request) string
+
+// QueryTenant 返回按查询参数解析租户的 TenantResolver，例如 /callback?tenant=corp-a。
+func QueryTenant(param string) TenantResolver {
+	return func(r *http.Request) string {
+		return strings.TrimSpace(r.URL.Query().Get(param))
+	}
+}
+
+// PathTenant 返回按路径前缀之后的首段解析租户的 TenantResolver，例如 /callback/corp-a。
+func PathTenant(prefix string) TenantResolver {
+	return func(r *http.Request) string {
+		rest, ok := strings.CutPrefix(r.URL.Path, prefix)
+		if !ok {
+			return ""
+		}
+		rest = strings.TrimPrefix(rest, "/")
+		if idx := strings.Index(rest, "/"); idx >= 0 {
+			rest = rest[:idx]
+		}
+		return rest
+	}
+}
+
+// TenantMux 在同一个 HTTP 端点上托管多个企业的 Bot。
+// 每个租户使用独立的 Bot 实例，因此加解密凭据与流式会话（StreamManager）天然隔离。
+type TenantMux struct {
+	resolve TenantResolver
+
+	mu   sync.RWMutex
+	bots map[string]*Bot
+}
+
+// NewTenantMux 创建多租户回调复用器。
+// Parameters:
+//   - resolve: 租户解析函数；为 nil 时使用 QueryTenant("tenant")
+//
+// Returns:
+//   - *TenantMux: 复用器实例
+func NewTenantMux(resolve TenantResolver) *TenantMux {
+	if resolve == nil {
+		resolve = QueryTenant("tenant")
+	}
+	return &TenantMux{resolve: resolve, bots: make(map[string]*Bot)}
+}
+
+// Register 注册（或替换）租户对应的 Bot。
+func (m *TenantMux) Register(tenant string, bot *Bot) error {
+	tenant = strings.TrimSpace(tenant)
+	if tenant == "" {
+		return errors.New("tenant is required")
+	}
+	if bot == nil {
+		return errors.New("bot is nil")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bots[tenant] = bot
+	return nil
+}
+
+// Bot 返回租户对应的 Bot。
+func (m *TenantMux) Bot(tenant string) (*Bot, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	bot, ok := m.bots[tenant]
+	return bot, ok
+}
+
+// ServeHTTP 实现 http.Handler 接口，按租户转发至对应 Bot；未知租户返回 404。
+func (m *TenantMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bot, ok := m.Bot(m.resolve(r))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	bot.ServeHTTP(w, r)
+}
+
+// Shutdown 并发关闭全部租户 Bot，返回遇到的第一个错误。
+func (m *TenantMux) Shutdown(ctx context.Context) error {
+	m.mu.RLock()
+	bots := make([]*Bot, 0, len(m.bots))
+	for _, bot := range m.bots {
+		bots = append(bots, bot)
+	}
+	m.mu.RUnlock()
+
+	errs := make(chan error, len(bots))
+	for _, bot := range bots {
+		go func(bot *Bot) {
+			errs <- bot.Shutdown(ctx)
+		}(bot)
+	}
+	var first error
+	for range bots {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
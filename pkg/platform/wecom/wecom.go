@@ -12,6 +12,9 @@ import (
 // Bot 是对 wecomproto.Bot 的包装，支持 botcore.PipelineInvoker。
 type Bot struct {
 	*wecomproto.Bot
+
+	adapter *PipelineAdapter
+	guard   requestGuard
 }
 
 // StartOptions 直接使用 wecomproto 的启动选项。
@@ -25,21 +28,29 @@ type StartOptions = wecomproto.StartOptions
 //   - streamMsgTTL: 流式会话最大存活时间（<=0 时使用默认值）
 //   - streamWaitTimeout: 刷新请求等待流水线片段的最大时长（<=0 时使用默认值）
 //   - pipeline: 首包触发的业务流水线实现，可为 nil
+//   - opts: 可选配置
 //
 // Returns:
 //   - *Bot: 成功初始化的 Bot 实例
 //   - error: 当加解密上下文初始化失败时返回错误
-func NewBot(token, encodingAESKey, corpID string, streamMsgTTL, streamWaitTimeout time.Duration, pipeline botcore.PipelineInvoker) (*Bot, error) {
+func NewBot(token, encodingAESKey, corpID string, streamMsgTTL, streamWaitTimeout time.Duration, pipeline botcore.PipelineInvoker, opts ...BotOption) (*Bot, error) {
 	// 将 pipeline 适配为 wecomproto.Handler
-	adapter := NewPipelineAdapter(pipeline)
+	b := &Bot{adapter: NewPipelineAdapter(pipeline)}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.guard.err != nil {
+		return nil, b.guard.err
+	}
 
 	// 使用 wecomproto SDK 创建底层 Bot
-	bot, err := wecomproto.NewBotWithOptions(token, encodingAESKey, corpID, streamMsgTTL, streamWaitTimeout, adapter)
+	bot, err := wecomproto.NewBotWithOptions(token, encodingAESKey, corpID, streamMsgTTL, streamWaitTimeout, b.adapter)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Bot{Bot: bot}, nil
+	b.Bot = bot
+	return b, nil
 }
 
 // 以下类型别名方便外部使用，避免直接导入 wecomproto
@@ -89,3 +100,14 @@ func (b *Bot) ResponseTemplateCard(responseURL string, card any) error {
 	}
 	return b.Bot.ResponseTemplateCard(responseURL, typedCard)
 }
+
+// React 实现 botcore.Reactor 接口。
+// 企业微信智能机器人不支持表情回应，这里为空操作。
+func (b *Bot) React(snapshot botcore.RequestSnapshot, emoji string) error {
+	return nil
+}
+
+// CanSendVoice 实现 botcore.VoiceReplier 接口，企业微信智能机器人无法发送语音回复。
+func (b *Bot) CanSendVoice() bool {
+	return false
+}
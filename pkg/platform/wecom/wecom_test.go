@@ -3,12 +3,20 @@ package wecom
 
 import (
 	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
 	wecomproto "github.com/IMBotPlatform/bot-protocol-wecom/pkg/wecom"
 )
 
@@ -185,3 +193,907 @@ func pkcs7PadForTest(data []byte, blockSize int) []byte {
 	}
 	return out
 }
+
+func TestPipelineAdapterRetryHintOnTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	pipeline := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk)
+		go func() {
+			defer close(out)
+			out <- botcore.StreamChunk{Content: "partial"}
+			<-release
+		}()
+		return out
+	})
+
+	adapter := NewPipelineAdapter(pipeline, WithRetryHint(DefaultRetryHint(20*time.Millisecond)))
+	ch := adapter.Handle(wecomproto.Context{
+		StreamID: "stream-retry",
+		Message: &wecomproto.Message{
+			MsgType: "text",
+			Text:    &wecomproto.TextPayload{Content: "slow question"},
+		},
+	})
+
+	var chunks []wecomproto.Chunk
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("unexpected chunk count: %d", len(chunks))
+	}
+	final, ok := chunks[1].Payload.(wecomproto.StreamWithTemplateCardMessage)
+	if !ok || !chunks[1].IsFinal {
+		t.Fatalf("unexpected final chunk: %+v", chunks[1])
+	}
+	if !strings.HasPrefix(final.Stream.Content, "partial") || !final.Stream.Finish {
+		t.Fatalf("unexpected stream body: %+v", final.Stream)
+	}
+
+	retryMsg := &wecomproto.Message{
+		MsgType: "event",
+		Event: &wecomproto.EventPayload{
+			EventType:         "template_card_event",
+			TemplateCardEvent: &wecomproto.TemplateCardEvent{EventKey: final.TemplateCard.ButtonList[0].Key},
+		},
+	}
+	snapshot := buildSnapshot(wecomproto.Context{Message: retryMsg, StreamID: "stream-retry-2"})
+	if snapshot.Text != "slow question" || snapshot.Metadata["retry"] != "true" {
+		t.Fatalf("retry event not restored: text=%q meta=%v", snapshot.Text, snapshot.Metadata)
+	}
+}
+
+func TestPipelineAdapterRetryHintLongConn(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	slow := true
+	pipeline := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk)
+		go func() {
+			defer close(out)
+			if !slow {
+				out <- botcore.StreamChunk{Content: "answer", IsFinal: true}
+				return
+			}
+			out <- botcore.StreamChunk{Content: "partial"}
+			<-release
+		}()
+		return out
+	})
+	pusher := &recordingPusher{}
+	adapter := NewPipelineAdapter(pipeline,
+		WithRetryHint(DefaultRetryHint(20*time.Millisecond)),
+		WithChatPusher(pusher),
+	)
+	longConn := &wecomproto.LongConnBot{}
+	from := wecomproto.MessageSender{UserID: "u1"}
+
+	var chunks []wecomproto.Chunk
+	for chunk := range adapter.Handle(wecomproto.Context{
+		LongConn: longConn,
+		Message:  &wecomproto.Message{MsgType: "text", From: from, Text: &wecomproto.TextPayload{Content: "slow question"}},
+	}) {
+		chunks = append(chunks, chunk)
+	}
+	// 长连接模式：提示以纯文本结束流式消息，按钮卡片单独推送。
+	if len(chunks) != 2 || chunks[1].Payload != nil || !chunks[1].IsFinal {
+		t.Fatalf("unexpected chunks: %+v", chunks)
+	}
+	if len(pusher.cards) != 1 {
+		t.Fatalf("expected the retry card to be pushed, got %d", len(pusher.cards))
+	}
+
+	// 点击重试：卡片事件不再更新卡片，回答推送到会话。
+	slow = false
+	retryMsg := &wecomproto.Message{
+		MsgType: "event",
+		From:    from,
+		Event: &wecomproto.EventPayload{
+			EventType:         "template_card_event",
+			TemplateCardEvent: &wecomproto.TemplateCardEvent{EventKey: pusher.cards[0].ButtonList[0].Key},
+		},
+	}
+	chunks = nil
+	for chunk := range adapter.Handle(wecomproto.Context{LongConn: longConn, Message: retryMsg}) {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 1 || chunks[0].Payload != wecomproto.NoResponse {
+		t.Fatalf("unexpected retry chunks: %+v", chunks)
+	}
+	if len(pusher.markdown) != 1 || pusher.markdown[0] != "u1:answer" {
+		t.Fatalf("unexpected pushed reply: %v", pusher.markdown)
+	}
+}
+
+func TestRetryHintRejectsOversizedPrompt(t *testing.T) {
+	hint := DefaultRetryHint(time.Second)
+	if _, err := hint.card(strings.Repeat("问", 400)); !errors.Is(err, ErrRetryPromptTooLong) {
+		t.Fatalf("expected ErrRetryPromptTooLong, got %v", err)
+	}
+	chunk := hint.buildChunk("s1", "partial", nil)
+	if chunk.Payload != nil || chunk.Content != "\n\n"+hint.Message {
+		t.Fatalf("oversized prompt should fall back to a plain hint: %+v", chunk)
+	}
+}
+
+func TestEncodePayloadCardUpdate(t *testing.T) {
+	card := &wecomproto.TemplateCard{CardType: "button_interaction", TaskID: "task-1"}
+	encoded := encodePayload(botcore.CardUpdate{Card: card, UserIDs: []string{"u1"}})
+
+	msg, ok := encoded.(wecomproto.UpdateTemplateCardMessage)
+	if !ok {
+		t.Fatalf("unexpected payload type: %T", encoded)
+	}
+	if msg.ResponseType != "update_template_card" || msg.TemplateCard != card {
+		t.Fatalf("unexpected update message: %+v", msg)
+	}
+	if len(msg.UserIDs) != 1 || msg.UserIDs[0] != "u1" {
+		t.Fatalf("unexpected userids: %v", msg.UserIDs)
+	}
+}
+
+func TestBuildStreamMsgItemsEncodesImages(t *testing.T) {
+	items := buildStreamMsgItems([]botcore.Attachment{
+		{Type: botcore.AttachmentTypeImage, Data: []byte("png-bytes")},
+		{Type: botcore.AttachmentTypeFile, Data: []byte("ignored")},
+		{Type: botcore.AttachmentTypeImage, URL: "https://example.com/no-data.png"},
+	})
+	if len(items) != 1 {
+		t.Fatalf("unexpected item count: %d", len(items))
+	}
+	if items[0].MsgType != "image" || items[0].Image == nil {
+		t.Fatalf("unexpected item: %+v", items[0])
+	}
+	if items[0].Image.Base64 != base64.StdEncoding.EncodeToString([]byte("png-bytes")) || items[0].Image.MD5 == "" {
+		t.Fatalf("unexpected image payload: %+v", items[0].Image)
+	}
+}
+
+func TestPipelineAdapterMergesImageChunksIntoFinal(t *testing.T) {
+	pipeline := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk, 3)
+		out <- botcore.StreamChunk{Content: "here you go"}
+		out <- botcore.ImageChunk([]byte("generated-image"))
+		out <- botcore.StreamChunk{IsFinal: true}
+		close(out)
+		return out
+	})
+
+	var chunks []wecomproto.Chunk
+	for chunk := range NewPipelineAdapter(pipeline).Handle(wecomproto.Context{StreamID: "s"}) {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("unexpected chunk count: %d", len(chunks))
+	}
+	if len(chunks[1].MsgItems) != 0 {
+		t.Fatalf("non-final chunk should not carry msg_item: %+v", chunks[1])
+	}
+	if !chunks[2].IsFinal || len(chunks[2].MsgItems) != 1 {
+		t.Fatalf("final chunk should carry buffered image: %+v", chunks[2])
+	}
+}
+
+func TestBuildSnapshotMapsVoiceContent(t *testing.T) {
+	snapshot := buildSnapshot(wecomproto.Context{
+		StreamID: "stream-voice",
+		Message: &wecomproto.Message{
+			MsgType: "voice",
+			Voice:   &wecomproto.VoicePayload{Content: "语音转写内容"},
+		},
+	})
+	if snapshot.Text != "语音转写内容" || snapshot.Metadata["msgtype"] != "voice" {
+		t.Fatalf("unexpected voice snapshot: text=%q meta=%v", snapshot.Text, snapshot.Metadata)
+	}
+}
+
+func TestBuildSnapshotParsesGroupMentions(t *testing.T) {
+	snapshot := buildSnapshot(wecomproto.Context{
+		StreamID: "stream-mention",
+		Message: &wecomproto.Message{
+			MsgType:  "text",
+			ChatType: "group",
+			Text:     &wecomproto.TextPayload{Content: "@RobotA /assign @张三 @李四 bug#1"},
+		},
+	})
+	if snapshot.Text != "/assign @张三 @李四 bug#1" || !snapshot.MentionedBot {
+		t.Fatalf("unexpected group snapshot: text=%q mentioned=%v", snapshot.Text, snapshot.MentionedBot)
+	}
+	if len(snapshot.Mentions) != 2 || snapshot.Mentions[0] != "张三" || snapshot.Mentions[1] != "李四" {
+		t.Fatalf("unexpected mentions: %v", snapshot.Mentions)
+	}
+}
+
+func TestBuildSnapshotFlattensMixedMessage(t *testing.T) {
+	msg := &wecomproto.Message{
+		MsgType: "mixed",
+		Mixed: &wecomproto.MixedPayload{Items: []wecomproto.MixedItem{
+			{MsgType: "text", Text: &wecomproto.TextPayload{Content: "看看这张图"}},
+			{MsgType: "image", Image: &wecomproto.ImagePayload{URL: "https://example.com/1", Data: []byte("img-1")}},
+			{MsgType: "text", Text: &wecomproto.TextPayload{Content: "还有这张"}},
+			{MsgType: "image", Image: &wecomproto.ImagePayload{URL: "https://example.com/2"}},
+		}},
+	}
+
+	snapshot := buildSnapshot(wecomproto.Context{Message: msg, StreamID: "stream-mixed"})
+	if snapshot.Text != "看看这张图\n还有这张" {
+		t.Fatalf("unexpected mixed text: %q", snapshot.Text)
+	}
+	if len(snapshot.Attachments) != 2 {
+		t.Fatalf("unexpected attachments length: %d", len(snapshot.Attachments))
+	}
+	for _, att := range snapshot.Attachments {
+		if att.Type != botcore.AttachmentTypeImage {
+			t.Fatalf("unexpected attachment type: %s", att.Type)
+		}
+	}
+	if string(snapshot.Attachments[0].Data) != "img-1" {
+		t.Fatalf("decrypted image data should be kept: %q", string(snapshot.Attachments[0].Data))
+	}
+}
+
+func TestPipelineAdapterDeduperReplaysFirstReply(t *testing.T) {
+	runs := 0
+	pipeline := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		runs++
+		out := make(chan botcore.StreamChunk, 2)
+		out <- botcore.StreamChunk{Content: "answer"}
+		out <- botcore.StreamChunk{IsFinal: true}
+		close(out)
+		return out
+	})
+	// 两个适配器共用同一存储，模拟多副本部署时重试回调落到另一副本。
+	store := botcore.NewMemoryDedupStore()
+	adapter := NewPipelineAdapter(pipeline, WithDeduper(store, time.Minute))
+	replica := NewPipelineAdapter(pipeline, WithDeduper(store, time.Minute))
+	msg := &wecomproto.Message{MsgID: "msg-1", MsgType: "text", Text: &wecomproto.TextPayload{Content: "q"}}
+
+	for range adapter.Handle(wecomproto.Context{Message: msg, StreamID: "s1"}) {
+	}
+	var replay []wecomproto.Chunk
+	for chunk := range replica.Handle(wecomproto.Context{Message: msg, StreamID: "s2"}) {
+		replay = append(replay, chunk)
+	}
+
+	if runs != 1 {
+		t.Fatalf("pipeline should run once, got %d", runs)
+	}
+	if len(replay) != 1 || replay[0].Content != "answer" || !replay[0].IsFinal {
+		t.Fatalf("unexpected replay: %+v", replay)
+	}
+}
+
+func TestPipelineAdapterShutdownFinishesInflightStreams(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	pipeline := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk)
+		go func() {
+			defer close(out)
+			out <- botcore.StreamChunk{Content: "partial"}
+			<-release
+		}()
+		return out
+	})
+	adapter := NewPipelineAdapter(pipeline)
+	msg := &wecomproto.Message{MsgType: "text", Text: &wecomproto.TextPayload{Content: "q"}}
+
+	var chunks []wecomproto.Chunk
+	done := make(chan struct{})
+	ch := adapter.Handle(wecomproto.Context{Message: msg, StreamID: "s1"})
+	go func() {
+		defer close(done)
+		for chunk := range ch {
+			chunks = append(chunks, chunk)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := adapter.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	<-done
+	last := chunks[len(chunks)-1]
+	if !last.IsFinal || last.Content != botcore.Localize(botcore.RequestSnapshot{}, MsgShutdownAbort) {
+		t.Fatalf("in-flight stream not finalized: %+v", chunks)
+	}
+
+	var rejected []wecomproto.Chunk
+	for chunk := range adapter.Handle(wecomproto.Context{Message: msg, StreamID: "s2"}) {
+		rejected = append(rejected, chunk)
+	}
+	if len(rejected) != 1 || rejected[0].Content != botcore.Localize(botcore.RequestSnapshot{}, MsgShutdownReject) {
+		t.Fatalf("new message should be rejected after shutdown: %+v", rejected)
+	}
+}
+
+func TestShutdownRejectInEnglish(t *testing.T) {
+	en := botcore.RequestSnapshot{Metadata: map[string]string{botcore.MetaLanguage: "en"}}
+	chunk := <-rejectChunk(en)
+	if !chunk.IsFinal || chunk.Content != "The service is being upgraded. Please send your message again shortly." {
+		t.Fatalf("unexpected reject reply: %+v", chunk)
+	}
+}
+
+func TestPipelineAdapterShutdownDoesNotWaitForBlockedConsumers(t *testing.T) {
+	defer func(grace time.Duration) { shutdownAbortGrace = grace }(shutdownAbortGrace)
+	shutdownAbortGrace = 20 * time.Millisecond
+	release := make(chan struct{})
+	defer close(release)
+	pipeline := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk)
+		go func() {
+			defer close(out)
+			out <- botcore.StreamChunk{Content: "partial"}
+			<-release
+		}()
+		return out
+	})
+	adapter := NewPipelineAdapter(pipeline)
+	msg := &wecomproto.Message{MsgType: "text", Text: &wecomproto.TextPayload{Content: "q"}}
+	// 关键步骤：不读取输出，适配器的 goroutine 阻塞在发送上。
+	adapter.Handle(wecomproto.Context{Message: msg, StreamID: "s1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	returned := make(chan error, 1)
+	go func() { returned <- adapter.Shutdown(ctx) }()
+	select {
+	case err := <-returned:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shutdown blocked on a stalled consumer")
+	}
+}
+
+func TestTenantMuxRoutesByTenant(t *testing.T) {
+	rawKey := bytes.Repeat([]byte{0x22}, 32)
+	key := strings.TrimRight(base64.StdEncoding.EncodeToString(rawKey), "=")
+	bot, err := NewBot("token", key, "corp-a", 0, 0, nil)
+	if err != nil {
+		t.Fatalf("create bot: %v", err)
+	}
+	mux := NewTenantMux(PathTenant("/callback"))
+	if err := mux.Register("corp-a", bot); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	unknown := httptest.NewRecorder()
+	mux.ServeHTTP(unknown, httptest.NewRequest(http.MethodGet, "/callback/corp-b", nil))
+	if unknown.Code != http.StatusNotFound {
+		t.Fatalf("unknown tenant should 404, got %d", unknown.Code)
+	}
+
+	// 已注册租户交给 Bot 处理：缺少签名参数时由 Bot 拒绝，而不是 404。
+	known := httptest.NewRecorder()
+	mux.ServeHTTP(known, httptest.NewRequest(http.MethodGet, "/callback/corp-a", nil))
+	if known.Code == http.StatusNotFound {
+		t.Fatalf("registered tenant should reach its bot")
+	}
+}
+
+func TestReplayGuardRejectsStaleAndReplaysDuplicates(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	calls := 0
+	var guard *ReplayGuard
+	guard = NewReplayGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch nonce := r.URL.Query().Get("nonce"); nonce {
+		case "bad-sig":
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+		case "slow":
+			// 首次处理尚未结束时到达的重试收到空的 200，不会再次进入下游。
+			rec := httptest.NewRecorder()
+			guard.ServeHTTP(rec, r.Clone(r.Context()))
+			if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+				t.Errorf("in-flight retry: %d %q", rec.Code, rec.Body.String())
+			}
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte("reply-" + nonce))
+		}
+	}), WithReplayClock(func() time.Time { return now }))
+
+	serve := func(ts int64, nonce string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		target := "/callback?timestamp=" + strconv.FormatInt(ts, 10) + "&nonce=" + nonce
+		guard.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil))
+		return rec
+	}
+
+	if rec := serve(now.Unix(), "n1"); rec.Code != http.StatusOK || rec.Body.String() != "reply-n1" {
+		t.Fatalf("fresh request: %d %q", rec.Code, rec.Body.String())
+	}
+	// 企业微信超时重试（或重放）回放首次的响应，下游不再执行。
+	rec := serve(now.Unix(), "n1")
+	if rec.Code != http.StatusOK || rec.Body.String() != "reply-n1" || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("duplicate should replay the first response, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := serve(now.Add(-time.Hour).Unix(), "n2"); rec.Code != http.StatusForbidden {
+		t.Fatalf("stale timestamp should be rejected, got %d", rec.Code)
+	}
+	serve(now.Unix(), "slow")
+	// 签名失败的请求不应占用 nonce。
+	serve(now.Unix(), "bad-sig")
+	serve(now.Unix(), "bad-sig")
+	if calls != 4 {
+		t.Fatalf("unexpected downstream calls: %d", calls)
+	}
+}
+
+func TestPipelineAdapterHooks(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	pipeline := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk)
+		go func() {
+			defer close(out)
+			out <- botcore.StreamChunk{Content: "partial"}
+			<-release
+		}()
+		return out
+	})
+
+	var updates int
+	var replies []botcore.StreamChunk
+	var errs []error
+	hint := DefaultRetryHint(20 * time.Millisecond)
+	adapter := NewPipelineAdapter(pipeline,
+		WithRetryHint(hint),
+		WithHooks(botcore.Hooks{
+			OnUpdate: func(botcore.RequestSnapshot) { updates++ },
+			OnReply:  func(_ botcore.RequestSnapshot, chunk botcore.StreamChunk) { replies = append(replies, chunk) },
+			OnError:  func(_ botcore.RequestSnapshot, err error) { errs = append(errs, err) },
+		}),
+	)
+	msg := &wecomproto.Message{MsgType: "text", Text: &wecomproto.TextPayload{Content: "q"}}
+	for range adapter.Handle(wecomproto.Context{Message: msg, StreamID: "s1"}) {
+	}
+
+	// 超时合成的最终提示同样经过 OnReply。
+	if updates != 1 || len(replies) != 2 {
+		t.Fatalf("unexpected hook counts: updates=%d replies=%d", updates, len(replies))
+	}
+	if last := replies[1]; !last.IsFinal || last.Content != "\n\n"+hint.Message {
+		t.Fatalf("synthesized final chunk not reported: %+v", last)
+	}
+	if len(errs) != 1 || errs[0] != ErrReplyTimeout {
+		t.Fatalf("timeout not reported: %v", errs)
+	}
+}
+
+func TestWithLoggerRecordsReplyTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	pipeline := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk)
+		go func() {
+			defer close(out)
+			<-release
+		}()
+		return out
+	})
+
+	var buf bytes.Buffer
+	rawKey := bytes.Repeat([]byte{0x22}, 32)
+	key := strings.TrimRight(base64.StdEncoding.EncodeToString(rawKey), "=")
+	bot, err := NewBot("token", key, "corpID", 0, 0, pipeline,
+		WithLogger(slog.New(slog.NewTextHandler(&buf, nil))),
+		WithAdapterOptions(WithRetryHint(DefaultRetryHint(10*time.Millisecond))),
+	)
+	if err != nil {
+		t.Fatalf("create bot: %v", err)
+	}
+
+	msg := &wecomproto.Message{MsgID: "msg-log", MsgType: "text", Text: &wecomproto.TextPayload{Content: "q"}}
+	for range bot.adapter.Handle(wecomproto.Context{Message: msg, StreamID: "stream-log"}) {
+	}
+	logs := buf.String()
+	if !strings.Contains(logs, "wecom reply timed out") || !strings.Contains(logs, "msgid=msg-log") || !strings.Contains(logs, "stream_id=stream-log") {
+		t.Fatalf("timeout not logged with ids: %s", logs)
+	}
+}
+
+func TestMetricsExposition(t *testing.T) {
+	metrics := NewMetrics()
+	pipeline := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk, 2)
+		out <- botcore.StreamChunk{Content: "a"}
+		out <- botcore.StreamChunk{IsFinal: true}
+		close(out)
+		return out
+	})
+	adapter := NewPipelineAdapter(pipeline, WithMetrics(metrics))
+	for range adapter.Handle(wecomproto.Context{StreamID: "s"}) {
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"wecom_pipelines_total 1",
+		"wecom_active_pipelines 0",
+		"wecom_chunks_published_total 2",
+		"wecom_first_chunk_seconds_count 1",
+		`wecom_reply_seconds_bucket{le="+Inf"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("missing %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestPipelineAdapterCancelsContextAfterTimeout(t *testing.T) {
+	canceled := make(chan struct{})
+	pipeline := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk)
+		go func() {
+			defer close(out)
+			<-ctx.Context().Done()
+			close(canceled)
+		}()
+		return out
+	})
+
+	adapter := NewPipelineAdapter(pipeline, WithRetryHint(DefaultRetryHint(20*time.Millisecond)))
+	for range adapter.Handle(wecomproto.Context{
+		StreamID: "stream-cancel",
+		Message:  &wecomproto.Message{MsgType: "text", Text: &wecomproto.TextPayload{Content: "slow"}},
+	}) {
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatalf("pipeline context not canceled after reply timeout")
+	}
+}
+
+func TestPipelineAdapterSessionLifetimeCancelsPipeline(t *testing.T) {
+	canceled := make(chan struct{})
+	pipeline := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk)
+		go func() {
+			defer close(out)
+			<-ctx.Context().Done()
+			close(canceled)
+		}()
+		return out
+	})
+
+	var errs []error
+	adapter := NewPipelineAdapter(pipeline, WithSessionLifetime(20*time.Millisecond), WithHooks(botcore.Hooks{
+		OnError: func(_ botcore.RequestSnapshot, err error) { errs = append(errs, err) },
+	}))
+	var chunks int
+	for range adapter.Handle(wecomproto.Context{
+		StreamID: "stream-expired",
+		Message:  &wecomproto.Message{MsgType: "text", Text: &wecomproto.TextPayload{Content: "slow"}},
+	}) {
+		chunks++
+	}
+	<-canceled
+	if chunks != 0 || len(errs) != 1 || errs[0] != ErrSessionExpired {
+		t.Fatalf("unexpected expiry handling: chunks=%d errs=%v", chunks, errs)
+	}
+}
+
+func TestPipelineAdapterRendersErrorChunks(t *testing.T) {
+	pipeline := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk, 1)
+		out <- botcore.ErrorChunk(errors.New("quota exceeded"))
+		close(out)
+		return out
+	})
+	adapter := NewPipelineAdapter(pipeline, WithErrorRenderer(func(snapshot botcore.RequestSnapshot, chunk botcore.StreamChunk) botcore.StreamChunk {
+		return botcore.StreamChunk{Content: "服务繁忙: " + chunk.Err.Error()}
+	}))
+	var chunks []wecomproto.Chunk
+	for chunk := range adapter.Handle(wecomproto.Context{
+		StreamID: "stream-error",
+		Message:  &wecomproto.Message{MsgType: "text", Text: &wecomproto.TextPayload{Content: "hi"}},
+	}) {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 1 || !chunks[0].IsFinal || chunks[0].Content != "服务繁忙: quota exceeded" {
+		t.Fatalf("unexpected chunks: %+v", chunks)
+	}
+}
+
+func TestPipelineAdapterAttachesStreamCardToFinal(t *testing.T) {
+	card := &wecomproto.TemplateCard{CardType: "button_interaction", TaskID: "feedback"}
+	adapter := NewPipelineAdapter(botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk, 3)
+		out <- botcore.StreamChunk{Content: "hello ", Payload: botcore.StreamCard{Card: card}}
+		out <- botcore.StreamChunk{Content: "world"}
+		out <- botcore.StreamChunk{IsFinal: true}
+		close(out)
+		return out
+	}))
+
+	var chunks []wecomproto.Chunk
+	for chunk := range adapter.Handle(wecomproto.Context{
+		StreamID: "stream-card",
+		Message:  &wecomproto.Message{MsgType: "text", Text: &wecomproto.TextPayload{Content: "hi"}},
+	}) {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 3 || chunks[0].Payload != nil || chunks[0].Content != "hello " {
+		t.Fatalf("card should not interrupt streaming: %+v", chunks)
+	}
+	final, ok := chunks[2].Payload.(wecomproto.StreamWithTemplateCardMessage)
+	if !ok || !chunks[2].IsFinal {
+		t.Fatalf("unexpected final chunk: %+v", chunks[2])
+	}
+	if final.Stream.Content != "hello world" || !final.Stream.Finish || final.TemplateCard != card {
+		t.Fatalf("unexpected stream+card body: %+v", final)
+	}
+}
+
+func TestPipelineAdapterKeepsStreamCardWithFinalPayload(t *testing.T) {
+	card := &wecomproto.TemplateCard{CardType: "button_interaction", TaskID: "feedback"}
+	run := func(final any, ctx wecomproto.Context, opts ...AdapterOption) wecomproto.Chunk {
+		adapter := NewPipelineAdapter(botcore.PipelineFunc(func(botcore.PipelineContext) <-chan botcore.StreamChunk {
+			out := make(chan botcore.StreamChunk, 2)
+			out <- botcore.StreamChunk{Content: "hello", Payload: botcore.StreamCard{Card: card}}
+			out <- botcore.StreamChunk{Payload: final, IsFinal: true}
+			close(out)
+			return out
+		}), opts...)
+		ctx.Message = &wecomproto.Message{MsgType: "text", From: wecomproto.MessageSender{UserID: "u1"}, Text: &wecomproto.TextPayload{Content: "hi"}}
+		var last wecomproto.Chunk
+		for chunk := range adapter.Handle(ctx) {
+			last = chunk
+		}
+		return last
+	}
+
+	// 流式负载与卡片合并为同一最终帧。
+	stream := wecomproto.StreamReply{MsgType: "stream", Stream: wecomproto.StreamReplyBody{ID: "s1", Finish: true, Content: "custom"}}
+	merged, ok := run(stream, wecomproto.Context{StreamID: "s1"}).Payload.(wecomproto.StreamWithTemplateCardMessage)
+	if !ok || merged.Stream.Content != "custom" || merged.TemplateCard != card {
+		t.Fatalf("stream payload should carry the card: %+v", merged)
+	}
+
+	// 其它负载保持不变，卡片单独推送。
+	pusher := &recordingPusher{}
+	text := wecomproto.TextMessage{MsgType: "text", Text: &wecomproto.TextPayload{Content: "done"}}
+	last := run(text, wecomproto.Context{LongConn: &wecomproto.LongConnBot{}}, WithChatPusher(pusher))
+	if _, ok := last.Payload.(wecomproto.TextMessage); !ok {
+		t.Fatalf("final payload should be kept: %+v", last)
+	}
+	if len(pusher.cards) != 1 || pusher.cards[0] != card {
+		t.Fatalf("card should be sent separately: %v", pusher.cards)
+	}
+}
+
+func TestPipelineAdapterFeedback(t *testing.T) {
+	snapshot := buildSnapshot(wecomproto.Context{Message: &wecomproto.Message{
+		MsgType: "event",
+		Event: &wecomproto.EventPayload{
+			EventType:     "feedback_event",
+			FeedbackEvent: &wecomproto.FeedbackEvent{ID: "m1", Type: 2, InaccurateReasonList: []int{3}},
+		},
+	}})
+	if snapshot.Feedback == nil || snapshot.Feedback.Kind != botcore.FeedbackNegative || snapshot.Feedback.Reasons[0] != "3" {
+		t.Fatalf("unexpected feedback: %+v", snapshot.Feedback)
+	}
+
+	adapter := NewPipelineAdapter(botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk, 1)
+		out <- botcore.StreamChunk{Content: "answer", IsFinal: true}
+		close(out)
+		return out
+	}), WithFeedback())
+	var final wecomproto.Chunk
+	for chunk := range adapter.Handle(wecomproto.Context{
+		StreamID: "stream-feedback",
+		Message:  &wecomproto.Message{MsgID: "m1", MsgType: "text", Text: &wecomproto.TextPayload{Content: "q"}},
+	}) {
+		final = chunk
+	}
+	reply, ok := final.Payload.(wecomproto.StreamReply)
+	if !ok || reply.Stream.Content != "answer" || reply.Stream.Feedback == nil || reply.Stream.Feedback.ID != "m1" {
+		t.Fatalf("unexpected final frame: %+v", final)
+	}
+}
+
+func TestPipelineAdapterEventText(t *testing.T) {
+	var got string
+	adapter := NewPipelineAdapter(botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		got = ctx.Snapshot.Text
+		return nil
+	}), WithEventText(map[string]string{"enter_chat": "/welcome"}))
+	adapter.Handle(wecomproto.Context{
+		StreamID: "stream-event",
+		Message:  &wecomproto.Message{MsgType: "event", Event: &wecomproto.EventPayload{EventType: "enter_chat"}},
+	})
+	if got != "/welcome" {
+		t.Fatalf("unexpected text: %q", got)
+	}
+}
+
+func TestBuildSnapshotMapsAttachmentAction(t *testing.T) {
+	msg := &wecomproto.Message{MsgType: "event", Attachment: &wecomproto.AttachmentPayload{CallbackID: "cb1"}}
+	msg.Attachment.Actions = append(msg.Attachment.Actions, struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+		Type  string `json:"type"`
+	}{Name: "approve", Value: "/approve 42", Type: "button"})
+
+	snapshot := buildSnapshot(wecomproto.Context{Message: msg})
+	if snapshot.Action == nil || snapshot.Action.CallbackID != "cb1" || snapshot.Text != "/approve 42" {
+		t.Fatalf("unexpected snapshot: action=%+v text=%q", snapshot.Action, snapshot.Text)
+	}
+	if snapshot.Metadata["callback_id"] != "cb1" || snapshot.Metadata["action_name"] != "approve" {
+		t.Fatalf("unexpected metadata: %v", snapshot.Metadata)
+	}
+}
+
+func TestBotGuardRejectsBeforeDecryption(t *testing.T) {
+	key := strings.TrimRight(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x22}, 32)), "=")
+	if _, err := NewBot("token", key, "corpID", 0, 0, nil, WithAllowedCIDRs("not-a-cidr")); err == nil {
+		t.Fatalf("expected invalid cidr error")
+	}
+	twoHops, err := NewBot("token", key, "corpID", 0, 0, nil,
+		WithAllowedCIDRs("192.168.1.7"), WithClientIPHeader("X-Forwarded-For"), WithTrustedProxyHops(2))
+	if err != nil {
+		t.Fatalf("create bot: %v", err)
+	}
+	for xff, want := range map[string]bool{"8.8.8.8, 192.168.1.7, 10.0.0.2": true, "192.168.1.7, 8.8.8.8, 10.0.0.2": false, "10.0.0.2": false} {
+		req := httptest.NewRequest(http.MethodPost, "/callback", nil)
+		req.Header.Set("X-Forwarded-For", xff)
+		if got := twoHops.guard.allow(twoHops.guard.clientIP(req)); got != want {
+			t.Fatalf("xff %q: allowed=%v want %v", xff, got, want)
+		}
+	}
+	bot, err := NewBot("token", key, "corpID", 0, 0, nil,
+		WithAllowedCIDRs("10.0.0.0/8", "192.168.1.7"),
+		WithClientIPHeader("X-Forwarded-For"),
+		WithMaxBodyBytes(16),
+	)
+	if err != nil {
+		t.Fatalf("create bot: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		remote string
+		xff    string
+		body   string
+		status int
+	}{
+		{"outside allowlist", "8.8.8.8:443", "", "{}", http.StatusForbidden},
+		{"forwarded outside allowlist", "10.0.0.1:443", "8.8.8.8", "{}", http.StatusForbidden},
+		// 客户端伪造最左侧的白名单地址，代理追加的真实地址在最右侧。
+		{"forged leftmost forwarded ip", "10.0.0.1:443", "192.168.1.7, 8.8.8.8", "{}", http.StatusForbidden},
+		{"forwarded inside allowlist", "10.0.0.1:443", "8.8.8.8, 192.168.1.7", "{}", http.StatusBadRequest},
+		{"body too large", "10.1.2.3:443", "", strings.Repeat("x", 17), http.StatusRequestEntityTooLarge},
+		// 通过校验后由协议层处理：缺少签名参数返回 400。
+		{"allowed single ip", "192.168.1.7:443", "", "{}", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(tc.body))
+		req.RemoteAddr = tc.remote
+		if tc.xff != "" {
+			req.Header.Set("X-Forwarded-For", tc.xff)
+		}
+		rec := httptest.NewRecorder()
+		bot.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Fatalf("%s: status=%d want %d", tc.name, rec.Code, tc.status)
+		}
+	}
+}
+
+func TestPipelineAdapterTranslatesOutboundMessages(t *testing.T) {
+	adapter := NewPipelineAdapter(botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk, 5)
+		out <- botcore.MessageChunk(botcore.TextMessage{Text: "hello "})
+		out <- botcore.MessageChunk(botcore.File{Name: "report.pdf", URL: "https://example.com/r.pdf"})
+		out <- botcore.MessageChunk(botcore.Image{Data: []byte("png")})
+		out <- botcore.MessageChunk(botcore.Card{Title: "审批", Buttons: []botcore.CardButton{{Text: "同意", Value: "approve"}}, TaskID: "task-1"})
+		out <- botcore.StreamChunk{IsFinal: true}
+		close(out)
+		return out
+	}))
+
+	var chunks []wecomproto.Chunk
+	for chunk := range adapter.Handle(wecomproto.Context{
+		StreamID: "stream-outbound",
+		Message:  &wecomproto.Message{MsgType: "text", Text: &wecomproto.TextPayload{Content: "hi"}},
+	}) {
+		chunks = append(chunks, chunk)
+	}
+	final, ok := chunks[len(chunks)-1].Payload.(wecomproto.StreamWithTemplateCardMessage)
+	if !ok {
+		t.Fatalf("unexpected final chunk: %+v", chunks[len(chunks)-1])
+	}
+	if final.Stream.Content != "hello [report.pdf](https://example.com/r.pdf)" || len(final.Stream.MsgItem) != 1 {
+		t.Fatalf("unexpected stream body: %+v", final.Stream)
+	}
+	card := final.TemplateCard
+	if card.CardType != "button_interaction" || card.TaskID != "task-1" || card.MainTitle.Title != "审批" || card.ButtonList[0].Key != "approve" {
+		t.Fatalf("unexpected template card: %+v", card)
+	}
+}
+
+func TestPipelineAdapterRendersReasoningAsThink(t *testing.T) {
+	adapter := NewPipelineAdapter(botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		out := make(chan botcore.StreamChunk, 4)
+		out <- botcore.ReasoningChunk("thinking")
+		out <- botcore.ToolCallChunk("call-1", "search", `{"q":"go"}`)
+		out <- botcore.StreamChunk{Content: "answer"}
+		out <- botcore.StreamChunk{IsFinal: true}
+		close(out)
+		return out
+	}))
+
+	var content strings.Builder
+	for chunk := range adapter.Handle(wecomproto.Context{
+		StreamID: "stream-think",
+		Message:  &wecomproto.Message{MsgType: "text", Text: &wecomproto.TextPayload{Content: "hi"}},
+	}) {
+		content.WriteString(chunk.Content)
+	}
+	if content.String() != "<think>thinking</think>answer" {
+		t.Fatalf("unexpected stream content: %q", content.String())
+	}
+}
+
+// recordingPusher 记录主动推送的消息。
+type recordingPusher struct {
+	markdown []string
+	cards    []*wecomproto.TemplateCard
+}
+
+func (p *recordingPusher) SendMarkdown(chatID, content string) error {
+	p.markdown = append(p.markdown, chatID+":"+content)
+	return nil
+}
+
+func (p *recordingPusher) SendTemplateCard(chatID string, card *wecomproto.TemplateCard) error {
+	p.cards = append(p.cards, card)
+	return nil
+}
+
+func TestPipelineAdapterResponserSendsToChat(t *testing.T) {
+	pusher := &recordingPusher{}
+	var sendErrs []error
+	pipeline := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		sender, ok := ctx.Responser.(botcore.ChatSender)
+		if !ok {
+			t.Fatal("responser does not implement botcore.ChatSender")
+		}
+		sendErrs = append(sendErrs,
+			sender.SendToChat(context.Background(), "room", "**hi**"),
+			sender.SendToChat(context.Background(), "room", botcore.Card{Title: "t", URL: "https://example.com"}),
+			sender.SendToChat(context.Background(), "room", 42),
+		)
+		out := make(chan botcore.StreamChunk, 1)
+		out <- botcore.StreamChunk{Content: "ok", IsFinal: true}
+		close(out)
+		return out
+	})
+	adapter := NewPipelineAdapter(pipeline, WithChatPusher(pusher))
+	msg := &wecomproto.Message{MsgType: "text", Text: &wecomproto.TextPayload{Content: "q"}}
+	for range adapter.Handle(wecomproto.Context{Message: msg, StreamID: "s1"}) {
+	}
+
+	if len(sendErrs) != 3 || sendErrs[0] != nil || sendErrs[1] != nil || sendErrs[2] == nil {
+		t.Fatalf("unexpected send errors: %v", sendErrs)
+	}
+	if len(pusher.markdown) != 1 || pusher.markdown[0] != "room:**hi**" {
+		t.Fatalf("unexpected markdown pushes: %v", pusher.markdown)
+	}
+	if len(pusher.cards) != 1 || pusher.cards[0].MainTitle.Title != "t" {
+		t.Fatalf("unexpected card pushes: %+v", pusher.cards)
+	}
+
+	if err := (&BotResponser{}).SendToChat(context.Background(), "room", "x"); !errors.Is(err, ErrChatPushUnsupported) {
+		t.Fatalf("expected ErrChatPushUnsupported, got %v", err)
+	}
+}
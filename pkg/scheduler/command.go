@@ -0,0 +1,285 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/IMBotPlatform/IMBotCore/pkg/command"
+	"github.com/spf13/cobra"
+)
+
+// 由 /schedule 写入任务 Metadata 的键，触发时还原到合成的 RequestSnapshot。
+const (
+	MetaSenderID = "sender_id" // 创建任务的用户，作为触发时的发送者
+	MetaChatType = "chat_type" // 会话类型
+	MetaTaskID   = "task_id"   // 触发时写入 RequestSnapshot.Metadata，标识来源任务
+)
+
+// /schedule 使用的消息键。
+const (
+	MsgScheduleCreated  = "schedule.created"  // 参数：任务 ID、Cron 表达式、命令、下次执行时间
+	MsgScheduleEmpty    = "schedule.empty"    // 当前会话没有定时任务
+	MsgScheduleList     = "schedule.list"     // 列表标题
+	MsgScheduleRemoved  = "schedule.removed"  // 参数：任务 ID
+	MsgScheduleNotFound = "schedule.notfound" // 参数：任务 ID
+
+	MsgScheduleCreateAction = "schedule.create.action" // 演练模式下描述创建操作，参数：Cron 表达式、命令
+	MsgScheduleRemoveAction = "schedule.remove.action" // 演练模式下描述删除操作，参数：任务 ID
+)
+
+func init() {
+	botcore.Messages.Add("zh", map[string]string{
+		MsgScheduleCreated:  "✅ 已创建定时任务 `%s`：按 `%s` 执行 `%s`，下次执行时间 %s。",
+		MsgScheduleEmpty:    "当前会话没有定时任务。",
+		MsgScheduleList:     "**定时任务**",
+		MsgScheduleRemoved:  "已删除定时任务 `%s`。",
+		MsgScheduleNotFound: "未找到定时任务 `%s`。",
+
+		MsgScheduleCreateAction: "按 `%s` 定时执行 `%s`",
+		MsgScheduleRemoveAction: "删除定时任务 `%s`",
+	})
+	botcore.Messages.Add("en", map[string]string{
+		MsgScheduleCreated:  "✅ Scheduled `%[3]s` as task `%[1]s` (`%[2]s`), next run at %[4]s.",
+		MsgScheduleEmpty:    "No scheduled tasks in this chat.",
+		MsgScheduleList:     "**Scheduled tasks**",
+		MsgScheduleRemoved:  "Removed scheduled task `%s`.",
+		MsgScheduleNotFound: "Scheduled task `%s` not found.",
+
+		MsgScheduleCreateAction: "schedule `%[2]s` on `%[1]s`",
+		MsgScheduleRemoveAction: "remove scheduled task `%s`",
+	})
+}
+
+// shortIDLen 为列表中展示的任务 ID 长度，remove 接受不短于该长度的 ID 前缀。
+const shortIDLen = 8
+
+// ScheduleCommand 返回 /schedule 命令，供会话成员注册周期执行的命令：
+//
+//	/schedule "0 9 * * 1-5" /standup   每个工作日 9 点执行 /standup
+//	/schedule list                      列出当前会话的定时任务
+//	/schedule remove <id>               删除定时任务
+//
+// 任务以当前会话（ChatID）为分组持久化在 s 中，触发执行见 RouteHandler。
+// Parameters:
+//   - s: 任务存储与调度器
+//
+// Returns:
+//   - *cobra.Command: 可挂载到命令树的 /schedule 命令
+func ScheduleCommand(s Scheduler) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     `schedule "<cron>" <command...>`,
+		Short:   "定时执行命令",
+		Example: `/schedule "0 9 * * 1-5" /standup`,
+		Args:    cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			execCtx := command.FromContext(cmd.Context())
+			if execCtx == nil {
+				return errors.New("schedule: missing execution context")
+			}
+			snapshot := execCtx.RequestSnapshot
+			prompt := command.JoinArgs(args[1:])
+			if execCtx.DryRun() {
+				cmd.Print(execCtx.DryRunMessage(botcore.Localize(snapshot, MsgScheduleCreateAction, args[0], prompt)))
+				return nil
+			}
+			metadata := map[string]string{
+				MetaSenderID: snapshot.SenderID,
+				MetaChatType: string(snapshot.ChatType),
+			}
+			if lang := snapshot.Metadata[botcore.MetaLanguage]; lang != "" {
+				metadata[botcore.MetaLanguage] = lang
+			}
+			task, err := s.Create(cmd.Context(), CreateTaskRequest{
+				GroupID:       snapshot.ChatID,
+				ChatID:        snapshot.ChatID,
+				Platform:      snapshot.Metadata["platform"],
+				Prompt:        prompt,
+				ScheduleType:  ScheduleTypeCron,
+				ScheduleValue: args[0],
+				Metadata:      metadata,
+			})
+			if err != nil {
+				return err
+			}
+			cmd.Print(botcore.Localize(snapshot, MsgScheduleCreated, shortID(task.ID), task.ScheduleValue, prompt, formatNextRun(task.NextRun)))
+			return nil
+		},
+	}
+	// 关键步骤：首个位置参数之后的内容都属于被调度的命令，不作为 /schedule 的 flag 解析。
+	cmd.Flags().SetInterspersed(false)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "列出当前会话的定时任务",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			snapshot := snapshotOf(cmd)
+			tasks, err := s.ListByGroup(cmd.Context(), snapshot.ChatID)
+			if err != nil {
+				return err
+			}
+			var b strings.Builder
+			for _, task := range tasks {
+				if task.Status == TaskStatusCompleted {
+					continue
+				}
+				fmt.Fprintf(&b, "\n- `%s` `%s` %s（%s，%s）", shortID(task.ID), task.ScheduleValue, task.Prompt, task.Status, formatNextRun(task.NextRun))
+			}
+			if b.Len() == 0 {
+				cmd.Print(botcore.Localize(snapshot, MsgScheduleEmpty))
+				return nil
+			}
+			cmd.Print(botcore.Localize(snapshot, MsgScheduleList) + b.String())
+			return nil
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:     "remove <id>",
+		Aliases: []string{"rm", "delete"},
+		Short:   "删除定时任务",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			snapshot := snapshotOf(cmd)
+			task, err := findTask(cmd.Context(), s, snapshot.ChatID, args[0])
+			if err != nil {
+				return err
+			}
+			if task == nil {
+				cmd.Print(botcore.Localize(snapshot, MsgScheduleNotFound, args[0]))
+				return nil
+			}
+			if execCtx := command.FromContext(cmd.Context()); execCtx.DryRun() {
+				cmd.Print(execCtx.DryRunMessage(botcore.Localize(snapshot, MsgScheduleRemoveAction, shortID(task.ID))))
+				return nil
+			}
+			if err := s.Delete(cmd.Context(), task.ID); err != nil {
+				return err
+			}
+			cmd.Print(botcore.Localize(snapshot, MsgScheduleRemoved, shortID(task.ID)))
+			return nil
+		},
+	})
+	return cmd
+}
+
+// snapshotOf 返回命令所属请求的快照（缺少 ExecutionContext 时为空快照）。
+func snapshotOf(cmd *cobra.Command) botcore.RequestSnapshot {
+	if execCtx := command.FromContext(cmd.Context()); execCtx != nil {
+		return execCtx.RequestSnapshot
+	}
+	return botcore.RequestSnapshot{}
+}
+
+// findTask 在会话的任务中按完整 ID 或唯一前缀查找任务，未找到或前缀不唯一时返回 nil。
+func findTask(ctx context.Context, s Scheduler, chatID, id string) (*Task, error) {
+	if len(id) < shortIDLen {
+		return nil, nil
+	}
+	tasks, err := s.ListByGroup(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	var found *Task
+	for i := range tasks {
+		if strings.HasPrefix(tasks[i].ID, id) {
+			if found != nil {
+				return nil, nil
+			}
+			found = &tasks[i]
+		}
+	}
+	return found, nil
+}
+
+// shortID 返回用于展示的任务 ID 前缀。
+func shortID(id string) string {
+	if len(id) > shortIDLen {
+		return id[:shortIDLen]
+	}
+	return id
+}
+
+// formatNextRun 格式化下次执行时间。
+func formatNextRun(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// Delivery 将定时任务的输出推送到任务所在会话。
+// 触发时原始请求的 response_url 早已失效，需使用平台的主动推送能力（如群机器人 Webhook、应用消息）。
+type Delivery func(ctx context.Context, task Task, content string) error
+
+// ResponserDelivery 通过 botcore.Responser 以 Markdown 推送任务输出。
+// Parameters:
+//   - r: 主动推送器
+//   - target: 返回任务对应的推送地址（如会话绑定的 Webhook URL），为空时不推送
+//
+// Returns:
+//   - Delivery: 推送函数
+func ResponserDelivery(r botcore.Responser, target func(task Task) string) Delivery {
+	return func(ctx context.Context, task Task, content string) error {
+		url := target(task)
+		if url == "" {
+			return fmt.Errorf("no delivery target for chat %s", task.ChatID)
+		}
+		return r.ResponseMarkdown(url, content)
+	}
+}
+
+// RouteHandler 返回把到期任务当作一条新消息交给 invoker（通常为 botcore.Chain）处理的 TaskHandler，
+// 收集输出后经 deliver 推送，使定时执行与用户手动发送该命令走相同的路由与中间件。
+// 合成的 RequestSnapshot 以任务的 Prompt 为文本、以创建者为发送者，并在 Metadata 中携带 MetaTaskID。
+// 只有推送失败会作为任务错误返回；命令自身的错误提示照常推送。
+// Parameters:
+//   - invoker: 消息处理入口
+//   - deliver: 输出推送函数
+//
+// Returns:
+//   - TaskHandler: 可通过 Scheduler.OnDue 注册的回调
+func RouteHandler(invoker botcore.PipelineInvoker, deliver Delivery) TaskHandler {
+	return func(ctx context.Context, task Task) error {
+		snapshot := TaskSnapshot(task)
+		pipelineCtx := botcore.PipelineContext{Snapshot: snapshot}.WithContext(ctx)
+
+		var out strings.Builder
+		for chunk := range invoker.Trigger(pipelineCtx) {
+			out.WriteString(chunk.Content)
+		}
+		content := strings.TrimSpace(out.String())
+		if content == "" {
+			return nil
+		}
+		return deliver(ctx, task, content)
+	}
+}
+
+// TaskSnapshot 为到期任务合成 RequestSnapshot。
+// Parameters:
+//   - task: 到期任务
+//
+// Returns:
+//   - botcore.RequestSnapshot: 以 Prompt 为文本、以创建者为发送者的请求快照
+func TaskSnapshot(task Task) botcore.RequestSnapshot {
+	metadata := make(map[string]string, len(task.Metadata)+1)
+	for k, v := range task.Metadata {
+		metadata[k] = v
+	}
+	metadata[MetaTaskID] = task.ID
+	if task.Platform != "" {
+		metadata["platform"] = task.Platform
+	}
+	return botcore.RequestSnapshot{
+		ID:       fmt.Sprintf("schedule-%s-%d", task.ID, time.Now().UnixNano()),
+		SenderID: task.Metadata[MetaSenderID],
+		ChatID:   task.ChatID,
+		ChatType: botcore.ChatType(task.Metadata[MetaChatType]),
+		Text:     task.Prompt,
+		Metadata: metadata,
+	}
+}
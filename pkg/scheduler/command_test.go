@@ -0,0 +1,132 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/IMBotPlatform/IMBotCore/pkg/botcore"
+	"github.com/IMBotPlatform/IMBotCore/pkg/command"
+	"github.com/spf13/cobra"
+)
+
+// runCommand 以 chat-1 中 u1 的身份执行命令并返回输出。
+func runCommand(t *testing.T, mgr *command.Manager, text string) string {
+	t.Helper()
+	var out string
+	snapshot := botcore.RequestSnapshot{SenderID: "u1", ChatID: "chat-1", ChatType: botcore.ChatTypeChatroom, Text: text}
+	for chunk := range mgr.Trigger(botcore.PipelineContext{Snapshot: snapshot}) {
+		out += chunk.Content
+	}
+	return out
+}
+
+func TestScheduleCommand(t *testing.T) {
+	sched, err := New(Config{DBPath: t.TempDir() + "/test.db"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer sched.Stop()
+
+	mgr := command.NewManager(func() *cobra.Command {
+		root := &cobra.Command{Use: "bot"}
+		root.AddCommand(ScheduleCommand(sched))
+		return root
+	})
+
+	if got := runCommand(t, mgr, "/schedule list"); got != "当前会话没有定时任务。" {
+		t.Fatalf("unexpected empty list: %q", got)
+	}
+	got := runCommand(t, mgr, `/schedule "0 9 * * 1-5" /standup --team "core infra"`)
+	if !strings.HasPrefix(got, "✅ 已创建定时任务") {
+		t.Fatalf("unexpected create reply: %q", got)
+	}
+
+	tasks, _ := sched.ListByGroup(context.Background(), "chat-1")
+	if len(tasks) != 1 {
+		t.Fatalf("expected 1 task, got %d", len(tasks))
+	}
+	task := tasks[0]
+	if task.Prompt != `/standup --team 'core infra'` || task.ScheduleValue != "0 9 * * 1-5" || task.Metadata[MetaSenderID] != "u1" {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+	if got := runCommand(t, mgr, "/schedule list"); !strings.Contains(got, shortID(task.ID)) || !strings.Contains(got, task.Prompt) {
+		t.Fatalf("unexpected list: %q", got)
+	}
+
+	if got := runCommand(t, mgr, "/schedule remove 1234"); got != "未找到定时任务 `1234`。" {
+		t.Fatalf("expected short prefix to be rejected, got %q", got)
+	}
+	if got := runCommand(t, mgr, "/schedule remove "+shortID(task.ID)); got != "已删除定时任务 `"+shortID(task.ID)+"`。" {
+		t.Fatalf("unexpected remove reply: %q", got)
+	}
+	if tasks, _ := sched.ListByGroup(context.Background(), "chat-1"); len(tasks) != 0 {
+		t.Fatalf("expected task to be removed, got %d", len(tasks))
+	}
+}
+
+func TestScheduleCommandDryRun(t *testing.T) {
+	sched, err := New(Config{DBPath: t.TempDir() + "/test.db"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer sched.Stop()
+
+	mgr := command.NewManager(func() *cobra.Command {
+		root := &cobra.Command{Use: "bot"}
+		root.AddCommand(ScheduleCommand(sched))
+		return root
+	}, command.WithDryRun())
+
+	got := runCommand(t, mgr, `/schedule "0 9 * * 1-5" /standup`)
+	if got != "🧪 [演练] 将会：按 `0 9 * * 1-5` 定时执行 `/standup`" {
+		t.Fatalf("unexpected dry-run reply: %q", got)
+	}
+	if tasks, _ := sched.ListByGroup(context.Background(), "chat-1"); len(tasks) != 0 {
+		t.Fatalf("dry run should not create tasks, got %d", len(tasks))
+	}
+
+	task, err := sched.Create(context.Background(), CreateTaskRequest{
+		GroupID: "chat-1", ChatID: "chat-1", Prompt: "/standup", ScheduleType: ScheduleTypeCron, ScheduleValue: "0 9 * * *",
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if got := runCommand(t, mgr, "/schedule remove "+task.ID); !strings.Contains(got, "删除定时任务 `"+shortID(task.ID)+"`") {
+		t.Fatalf("unexpected dry-run reply: %q", got)
+	}
+	if tasks, _ := sched.ListByGroup(context.Background(), "chat-1"); len(tasks) != 1 {
+		t.Fatalf("dry run should not remove tasks, got %d", len(tasks))
+	}
+}
+
+func TestRouteHandler(t *testing.T) {
+	var received botcore.RequestSnapshot
+	invoker := botcore.PipelineFunc(func(ctx botcore.PipelineContext) <-chan botcore.StreamChunk {
+		received = ctx.Snapshot
+		ch := make(chan botcore.StreamChunk, 2)
+		ch <- botcore.StreamChunk{Content: "今日站会："}
+		ch <- botcore.StreamChunk{Content: "3 人已提交", IsFinal: true}
+		close(ch)
+		return ch
+	})
+	var delivered []string
+	handler := RouteHandler(invoker, func(ctx context.Context, task Task, content string) error {
+		delivered = append(delivered, task.ChatID+": "+content)
+		return nil
+	})
+
+	task := Task{ID: "task-1", ChatID: "chat-1", Platform: "wecom", Prompt: "/standup", Metadata: map[string]string{MetaSenderID: "u1", MetaChatType: "chatroom"}}
+	if err := handler(context.Background(), task); err != nil {
+		t.Fatalf("handler error = %v", err)
+	}
+	if received.Text != "/standup" || received.SenderID != "u1" || received.ChatID != "chat-1" || received.ChatType != botcore.ChatTypeChatroom {
+		t.Fatalf("unexpected snapshot: %+v", received)
+	}
+	if received.Metadata[MetaTaskID] != "task-1" || received.Metadata["platform"] != "wecom" {
+		t.Fatalf("unexpected metadata: %+v", received.Metadata)
+	}
+	if len(delivered) != 1 || delivered[0] != "chat-1: 今日站会：3 人已提交" {
+		t.Fatalf("unexpected delivery: %q", delivered)
+	}
+}
@@ -0,0 +1,125 @@
+// Command newplatform 生成新 IM 平台适配层的骨架代码。
+//
+// 用法：
+//
+//	go run ./tools/newplatform slack
+//
+// 将在 pkg/platform/slack 下生成 Bot（HTTP 回调 + Responser）、PipelineAdapter 与测试。
+package main
+
+import (
+	"bytes"
+	"embed"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// packageNamePattern 限制平台名为合法的 Go 包名。
+var packageNamePattern = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
+// templateData 为模板渲染参数。
+type templateData struct {
+	Package string // Go 包名，如 slack
+	Title   string // 展示名，如 Slack
+}
+
+func main() {
+	root := flag.String("root", "pkg/platform", "output root directory")
+	force := flag.Bool("force", false, "overwrite existing files")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: newplatform [-root dir] [-force] <name>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	files, err := generate(*root, flag.Arg(0), *force)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "newplatform:", err)
+		os.Exit(1)
+	}
+	for _, f := range files {
+		fmt.Println("created", f)
+	}
+}
+
+// generate 渲染全部模板并写入 root/<name>。
+// Parameters:
+//   - root: 输出根目录
+//   - name: 平台名（小写字母与数字）
+//   - force: 是否覆盖已存在的文件
+//
+// Returns:
+//   - []string: 生成的文件路径
+//   - error: 名称非法、文件已存在或渲染失败时返回
+func generate(root, name string, force bool) ([]string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !packageNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid platform name %q: use lowercase letters and digits", name)
+	}
+	data := templateData{Package: name, Title: strings.ToUpper(name[:1]) + name[1:]}
+
+	outputs := map[string]string{
+		"templates/bot.go.tmpl":      name + ".go",
+		"templates/adapter.go.tmpl":  "adapter.go",
+		"templates/bot_test.go.tmpl": name + "_test.go",
+	}
+
+	dir := filepath.Join(root, name)
+	// 关键步骤：先全部渲染并检查冲突，避免生成一半的目录。
+	rendered := make(map[string][]byte, len(outputs))
+	for tmplName, fileName := range outputs {
+		target := filepath.Join(dir, fileName)
+		if _, err := os.Stat(target); err == nil && !force {
+			return nil, fmt.Errorf("%s already exists (use -force to overwrite)", target)
+		}
+		src, err := render(tmplName, data)
+		if err != nil {
+			return nil, err
+		}
+		rendered[target] = src
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create dir: %w", err)
+	}
+	files := make([]string, 0, len(rendered))
+	for target, src := range rendered {
+		if err := os.WriteFile(target, src, 0o644); err != nil {
+			return nil, fmt.Errorf("write %s: %w", target, err)
+		}
+		files = append(files, target)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// render 渲染单个模板并执行 gofmt。
+func render(name string, data templateData) ([]byte, error) {
+	tmpl, err := template.ParseFS(templateFS, name)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("render %s: %w", name, err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format %s: %w", name, err)
+	}
+	return src, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateWritesSkeleton(t *testing.T) {
+	root := t.TempDir()
+	files, err := generate(root, "Slack", false)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("unexpected files: %v", files)
+	}
+	for _, name := range []string{"slack.go", "adapter.go", "slack_test.go"} {
+		if _, err := os.Stat(filepath.Join(root, "slack", name)); err != nil {
+			t.Fatalf("missing %s: %v", name, err)
+		}
+	}
+
+	if _, err := generate(root, "slack", false); err == nil {
+		t.Fatalf("existing package should not be overwritten without -force")
+	}
+	if _, err := generate(root, "bad-name", false); err == nil {
+		t.Fatalf("invalid package name should be rejected")
+	}
+}